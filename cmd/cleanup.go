@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/loopsFreitag/DeviceRegistry/internal/config"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var cleanupChangesOlderThan time.Duration
+
+func init() {
+	cleanupDeviceChangesCmd.Flags().DurationVarP(&cleanupChangesOlderThan, "older-than", "", 7*24*time.Hour, `Remove device changes streams can resume from once older than this (e.g. 168h)`)
+
+	cleanupCmd.AddCommand(cleanupIdempotencyKeysCmd)
+	cleanupCmd.AddCommand(cleanupDeviceChangesCmd)
+	cleanupCmd.AddCommand(cleanupRefreshTokensCmd)
+}
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Remove records the registry no longer needs",
+}
+
+var cleanupIdempotencyKeysCmd = &cobra.Command{
+	Use:   "idempotency-keys",
+	Short: "Remove expired idempotency keys",
+	Run: withCleanupDB(func(dbx *sqlx.DB) {
+		expired, err := repository.NewIdempotencyRepository(dbx).DeleteExpired(time.Now().UTC())
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		log.Printf("Removed %d expired idempotency keys", expired)
+	}),
+}
+
+var cleanupDeviceChangesCmd = &cobra.Command{
+	Use:   "device-changes",
+	Short: "Remove old device changes, which streams can no longer resume from",
+	Run: withCleanupDB(func(dbx *sqlx.DB) {
+		changes, err := repository.NewDeviceChangeRepository(dbx).DeleteChangesBefore(time.Now().UTC().Add(-cleanupChangesOlderThan))
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		log.Printf("Removed %d device changes older than %s", changes, cleanupChangesOlderThan)
+	}),
+}
+
+var cleanupRefreshTokensCmd = &cobra.Command{
+	Use:   "refresh-tokens",
+	Short: "Remove expired refresh tokens",
+	Run: withCleanupDB(func(dbx *sqlx.DB) {
+		expired, err := repository.NewRefreshTokenRepository(dbx).DeleteExpired(time.Now().UTC())
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		log.Printf("Removed %d expired refresh tokens", expired)
+	}),
+}
+
+// withCleanupDB reads the config and hands run a database connection that
+// is closed when the command exits
+func withCleanupDB(run func(dbx *sqlx.DB)) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		config.ReadConfig(model.Environment, "")
+
+		dbx := model.InitDB()
+		defer func(dbx *sqlx.DB) {
+			log.Println("Closing DB connection...")
+			if err := dbx.Close(); err != nil {
+				log.Error("Failed to close DB connection. err: ", err.Error())
+			}
+		}(dbx)
+
+		run(dbx)
+	}
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/loopsFreitag/DeviceRegistry/internal/config"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importDryRun bool
+	importFormat string
+	importAs     string
+)
+
+func init() {
+	devicesImportCmd.Flags().BoolVarP(&importDryRun, "dry-run", "", false, "Only show the column mapping and row errors, do not create devices")
+	devicesImportCmd.Flags().StringVarP(&importFormat, "format", "", "", "File format (csv or xlsx), detected from the file extension by default")
+	devicesImportCmd.Flags().StringVarP(&importAs, "as", "", "", "Email of the user the import is recorded for in the device history")
+
+	devicesCmd.AddCommand(devicesImportCmd)
+}
+
+var devicesCmd = &cobra.Command{
+	Use:   "devices",
+	Short: "Manage the device inventory",
+}
+
+var devicesImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Create devices from a CSV or XLSX file",
+	Long: `Create devices from a CSV or XLSX file with name, brand and optionally state columns.
+The file is validated first and nothing is created unless every row is valid.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config.ReadConfig(model.Environment, "")
+
+		format, err := service.SpreadsheetFormatFromFilename(args[0])
+		if importFormat != "" {
+			format, err = service.ParseSpreadsheetFormat(importFormat)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer file.Close()
+
+		// get the shared instance of dbx just to be able to close it when
+		// the command exits.
+		dbx := model.InitDB()
+		defer func(dbx *sqlx.DB) {
+			log.Println("Closing DB connection...")
+			if err := dbx.Close(); err != nil {
+				log.Error("Failed to close DB connection. err: ", err.Error())
+			}
+		}(dbx)
+
+		actorID := uuid.Nil
+		if importAs != "" {
+			user, err := repository.NewUserRepository(dbx).GetByEmail(importAs)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			actorID = user.ID
+		}
+
+		result, err := newDeviceService(dbx).ImportDevices(file, format, !importDryRun, actorID)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		printImport(result)
+
+		switch {
+		case len(result.Errors) > 0:
+			log.Fatalf("Import aborted: %d of %d rows are invalid", len(result.Errors), result.Rows)
+		case result.Committed:
+			log.Printf("Imported %d devices", result.Created)
+		default:
+			log.Printf("Dry run: %d of %d rows are valid", result.Valid, result.Rows)
+		}
+	},
+}
+
+func printImport(result *service.DeviceImport) {
+	fmt.Println("Columns:")
+	for _, column := range result.Columns {
+		field := column.Field
+		if field == "" {
+			field = "(ignored)"
+		}
+		fmt.Printf("  %-24s -> %s\n", column.Header, field)
+	}
+
+	if len(result.Errors) > 0 {
+		fmt.Println("Errors:")
+		for _, rowError := range result.Errors {
+			if rowError.Column != "" {
+				fmt.Printf("  row %d, %s: %s\n", rowError.Row, rowError.Column, rowError.Message)
+			} else {
+				fmt.Printf("  row %d: %s\n", rowError.Row, rowError.Message)
+			}
+		}
+	}
+}
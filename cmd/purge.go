@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/loopsFreitag/DeviceRegistry/internal/config"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var purgeOlderThan time.Duration
+
+func init() {
+	purgeCmd.Flags().DurationVarP(&purgeOlderThan, "older-than", "", 30*24*time.Hour, `Remove devices deleted longer ago than this (e.g. 720h)`)
+}
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Permanently remove devices that have been in the trash for too long",
+	Run: func(cmd *cobra.Command, args []string) {
+		config.ReadConfig(model.Environment, "")
+
+		// get the shared instance of dbx just to be able to close it when
+		// the command exits.
+		dbx := model.InitDB()
+		defer func(dbx *sqlx.DB) {
+			log.Println("Closing DB connection...")
+			if err := dbx.Close(); err != nil {
+				log.Error("Failed to close DB connection. err: ", err.Error())
+			}
+		}(dbx)
+
+		purged, err := newDeviceService(dbx).PurgeDeletedDevices(purgeOlderThan)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		log.Printf("Purged %d deleted devices older than %s", purged, purgeOlderThan)
+	},
+}
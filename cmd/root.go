@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,11 +11,15 @@ import (
 
 	"github.com/jmoiron/sqlx"
 	"github.com/loopsFreitag/DeviceRegistry/internal/config"
+	"github.com/loopsFreitag/DeviceRegistry/internal/grpcapi"
 	"github.com/loopsFreitag/DeviceRegistry/internal/middleware"
 	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"google.golang.org/grpc"
 )
 
 // @title           Device Registry API
@@ -51,8 +56,36 @@ var rootCmd = &cobra.Command{
 			}
 		}(dbx)
 
+		switch store := viper.GetString("session.store"); store {
+		case "postgres":
+			model.UseSessionStore(repository.NewSessionRepository(model.DBX()))
+		case "memory":
+		default:
+			log.Fatalf("unknown session store %q, expected postgres or memory", store)
+		}
+
+		// Streams and background workers end when the server starts shutting
+		// down, otherwise Shutdown would wait on them forever.
+		streamCtx, stopStreams := context.WithCancel(ctx)
+		defer stopStreams()
+
+		// The device change stream is shared by the REST and gRPC APIs
+		deviceStream := service.NewDeviceStream(repository.NewDeviceChangeRepository(model.DBX()))
+		go func() {
+			listener := repository.NewDeviceChangeListener(model.NewListener())
+			if err := deviceStream.Run(streamCtx, listener); err != nil {
+				log.Error("device change stream stopped. err: ", err.Error())
+			}
+		}()
+
+		// So are the device service and token mode, whose signing key may be
+		// generated at startup
+		deviceService := newDeviceService(model.DBX())
+		userRepo := repository.NewUserRepository(model.DBX())
+		jwtService := newJWTService(model.DBX(), userRepo)
+
 		// Create router
-		router := middleware.NewAppRouter()
+		router := middleware.NewAppRouter(streamCtx, deviceService, deviceStream, jwtService, newOIDCService(userRepo))
 
 		// Create server
 		port := viper.GetInt("port")
@@ -64,6 +97,25 @@ var rootCmd = &cobra.Command{
 			Addr:    fmt.Sprintf(":%d", port),
 			Handler: router,
 		}
+		server.RegisterOnShutdown(stopStreams)
+
+		// Create gRPC server, on its own port. Port 0 disables it.
+		grpcPort := viper.GetInt("grpc.port")
+		var grpcServer *grpc.Server
+		if grpcPort != 0 {
+			listener, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+			if err != nil {
+				log.Fatal("Failed to listen for gRPC. err: ", err.Error())
+			}
+
+			grpcServer = grpcapi.NewServer(streamCtx, deviceService, deviceStream, jwtService)
+			go func() {
+				log.Printf("Starting gRPC server at port %d", grpcPort)
+				if err := grpcServer.Serve(listener); err != nil {
+					log.Error("gRPC server error: ", err)
+				}
+			}()
+		}
 
 		// Handle graceful shutdown
 		go func() {
@@ -72,6 +124,12 @@ var rootCmd = &cobra.Command{
 			<-sigs
 
 			log.Println("Shutting down server...")
+			// stopped first: the process exits as soon as the HTTP server
+			// stops listening
+			if grpcServer != nil {
+				stopStreams()
+				grpcServer.GracefulStop()
+			}
 			if err := server.Shutdown(ctx); err != nil {
 				log.Error("Server shutdown error: ", err)
 			}
@@ -96,6 +154,10 @@ var versionCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(purgeCmd)
+	rootCmd.AddCommand(cleanupCmd)
+	rootCmd.AddCommand(devicesCmd)
+	rootCmd.AddCommand(usersCmd)
 	rootCmd.AddCommand(versionCmd)
 
 	rootCmd.PersistentFlags().StringVarP(&model.Environment, "env", "e", "development", "Environment (development/staging/production)")
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// newDeviceService builds the device service every API and command shares,
+// with the state transitions from the config
+func newDeviceService(dbx *sqlx.DB) *service.DeviceService {
+	transitions, err := service.LoadStateTransitions()
+	if err != nil {
+		log.Fatal("invalid device state transitions config. err: ", err.Error())
+	}
+
+	return service.NewDeviceService(
+		repository.NewDeviceRepository(dbx),
+		repository.NewReservationRepository(dbx),
+		service.WithStateTransitions(transitions),
+	)
+}
+
+// newJWTService builds the service behind token mode from the jwt config,
+// or returns nil when token mode is disabled. The REST and gRPC APIs share
+// it, so tokens signed with a key generated at startup verify on both.
+//
+// The first of jwt.keys signs access tokens; the others are still accepted,
+// so keys are rotated by putting a new one first and dropping the old one
+// once access-ttl passed.
+func newJWTService(dbx *sqlx.DB, userRepo repository.UserRepository) service.JWTServiceInterface {
+	if !viper.GetBool("jwt.enabled") {
+		return nil
+	}
+
+	var keys *service.KeySet
+	var err error
+	if paths := viper.GetStringSlice("jwt.keys"); len(paths) > 0 {
+		keys, err = service.LoadKeySet(paths)
+	} else {
+		log.Warn("no jwt.keys configured, signing access tokens with a key generated at startup. They won't survive a restart nor be accepted by other replicas.")
+		keys, err = service.GenerateKeySet()
+	}
+	if err != nil {
+		log.Fatal("invalid JWT keys. err: ", err.Error())
+	}
+
+	return service.NewJWTService(
+		keys,
+		repository.NewRefreshTokenRepository(dbx),
+		userRepo,
+		service.WithIssuer(viper.GetString("jwt.issuer")),
+		service.WithAccessTokenTTL(viper.GetDuration("jwt.access-ttl")),
+		service.WithRefreshTokenTTL(viper.GetDuration("jwt.refresh-ttl")),
+	)
+}
+
+// newOIDCService builds the single sign-on service from the oidc config, or
+// returns nil when single sign-on is disabled
+func newOIDCService(userRepo repository.UserRepository) service.OIDCServiceInterface {
+	if !viper.GetBool("oidc.enabled") {
+		return nil
+	}
+
+	var groupRoles []struct {
+		Group string
+		Role  model.Role
+	}
+	if err := viper.UnmarshalKey("oidc.group-roles", &groupRoles); err != nil {
+		log.Fatal("invalid oidc.group-roles config. err: ", err.Error())
+	}
+
+	config := service.OIDCConfig{
+		Issuer:       viper.GetString("oidc.issuer"),
+		ClientID:     viper.GetString("oidc.client-id"),
+		ClientSecret: viper.GetString("oidc.client-secret"),
+		RedirectURL:  viper.GetString("oidc.redirect-url"),
+		Scopes:       viper.GetStringSlice("oidc.scopes"),
+		GroupsClaim:  viper.GetString("oidc.groups-claim"),
+		GroupRoles:   make(map[string]model.Role, len(groupRoles)),
+		DefaultRole:  model.Role(viper.GetString("oidc.default-role")),
+	}
+	for _, mapping := range groupRoles {
+		config.GroupRoles[mapping.Group] = mapping.Role
+	}
+
+	oidcService, err := service.NewOIDCService(config, userRepo)
+	if err != nil {
+		log.Fatal("invalid oidc config. err: ", err.Error())
+	}
+	return oidcService
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/loopsFreitag/DeviceRegistry/internal/config"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	usersCmd.AddCommand(usersSetRoleCmd)
+}
+
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Manage users",
+}
+
+var usersSetRoleCmd = &cobra.Command{
+	Use:   "set-role <email> <viewer|operator|admin>",
+	Short: "Change the role of a user",
+	Long: `Change the role of a user. Users register as viewers, so this is how the
+first admin is made; admins can then manage roles through the API.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		config.ReadConfig(model.Environment, "")
+
+		role := model.Role(args[1])
+		if !role.Valid() {
+			log.Fatalf("unknown role %q, expected viewer, operator or admin", args[1])
+		}
+
+		// get the shared instance of dbx just to be able to close it when
+		// the command exits.
+		dbx := model.InitDB()
+		defer func(dbx *sqlx.DB) {
+			log.Println("Closing DB connection...")
+			if err := dbx.Close(); err != nil {
+				log.Error("Failed to close DB connection. err: ", err.Error())
+			}
+		}(dbx)
+
+		users := repository.NewUserRepository(dbx)
+		user, err := users.GetByEmail(args[0])
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if _, err := users.UpdateRole(user.ID, role); err != nil {
+			log.Fatalln(err)
+		}
+
+		log.Printf("%s is now %s", user.Email, role)
+	},
+}
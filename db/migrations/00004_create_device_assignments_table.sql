@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS device_assignments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    checked_out_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    checked_in_at TIMESTAMP
+);
+
+-- A device can only have one open assignment at a time
+CREATE UNIQUE INDEX idx_device_assignments_active ON device_assignments(device_id) WHERE checked_in_at IS NULL;
+CREATE INDEX idx_device_assignments_user_id ON device_assignments(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS device_assignments;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TABLE IF NOT EXISTS device_reservations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT device_reservations_window CHECK (ends_at > starts_at),
+    -- Two reservations of the same device can never overlap
+    CONSTRAINT device_reservations_no_overlap EXCLUDE USING gist (
+        device_id WITH =,
+        tsrange(starts_at, ends_at) WITH &&
+    )
+);
+
+CREATE INDEX idx_device_reservations_user_id ON device_reservations(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS device_reservations;
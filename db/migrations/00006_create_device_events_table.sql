@@ -0,0 +1,18 @@
+-- +goose Up
+-- device_id deliberately has no foreign key so the history of deleted
+-- devices is kept
+CREATE TABLE IF NOT EXISTS device_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    device_id UUID NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    type VARCHAR(32) NOT NULL CHECK (type IN ('created', 'updated', 'state_changed', 'deleted')),
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_device_events_device_id_created_at ON device_events(device_id, created_at DESC);
+CREATE INDEX idx_device_events_user_id ON device_events(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS device_events;
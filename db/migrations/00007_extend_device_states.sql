@@ -0,0 +1,20 @@
+-- +goose Up
+-- 3 = maintenance, 4 = lost, 5 = retired
+ALTER TABLE devices DROP CONSTRAINT IF EXISTS devices_state_check;
+ALTER TABLE devices ADD CONSTRAINT devices_state_check CHECK (state BETWEEN 0 AND 5);
+
+-- +goose Down
+-- Refuse rather than silently turn maintenance, lost and retired devices
+-- into inactive ones. Move them to a state the rollback keeps first.
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM devices WHERE state > 2) THEN
+        RAISE EXCEPTION 'devices in maintenance, lost or retired would lose their state, change it before rolling back';
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+ALTER TABLE devices DROP CONSTRAINT IF EXISTS devices_state_check;
+ALTER TABLE devices ADD CONSTRAINT devices_state_check CHECK (state IN (0, 1, 2));
@@ -0,0 +1,31 @@
+-- +goose Up
+ALTER TABLE devices ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_devices_deleted_at ON devices(deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE device_events DROP CONSTRAINT IF EXISTS device_events_type_check;
+ALTER TABLE device_events ADD CONSTRAINT device_events_type_check
+    CHECK (type IN ('created', 'updated', 'state_changed', 'deleted', 'restored'));
+
+-- +goose Down
+-- Refuse rather than delete the devices in the trash, or bring them back to
+-- life by dropping the column. Empty the trash first with
+-- `deviceregistry purge --older-than 0`, or restore them.
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM devices WHERE deleted_at IS NOT NULL) THEN
+        RAISE EXCEPTION 'devices in the trash would be lost, purge or restore them before rolling back';
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+-- Restores stay in the history, as plain updates
+UPDATE device_events SET type = 'updated' WHERE type = 'restored';
+ALTER TABLE device_events DROP CONSTRAINT IF EXISTS device_events_type_check;
+ALTER TABLE device_events ADD CONSTRAINT device_events_type_check
+    CHECK (type IN ('created', 'updated', 'state_changed', 'deleted'));
+
+DROP INDEX IF EXISTS idx_devices_deleted_at;
+ALTER TABLE devices DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Keyset pagination orders by (column, id), so each sortable column gets a
+-- composite index with the id as tiebreaker
+DROP INDEX IF EXISTS idx_devices_created_at;
+CREATE INDEX idx_devices_name_id ON devices(name, id);
+CREATE INDEX idx_devices_brand_id ON devices(brand, id);
+CREATE INDEX idx_devices_state_id ON devices(state, id);
+CREATE INDEX idx_devices_created_at_id ON devices(created_at, id);
+CREATE INDEX idx_devices_updated_at_id ON devices(updated_at, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_devices_updated_at_id;
+DROP INDEX IF EXISTS idx_devices_created_at_id;
+DROP INDEX IF EXISTS idx_devices_state_id;
+DROP INDEX IF EXISTS idx_devices_brand_id;
+DROP INDEX IF EXISTS idx_devices_name_id;
+CREATE INDEX idx_devices_created_at ON devices(created_at);
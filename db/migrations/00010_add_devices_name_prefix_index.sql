@@ -0,0 +1,6 @@
+-- +goose Up
+-- Supports case-insensitive name prefix filters (lower(name) LIKE 'abc%')
+CREATE INDEX idx_devices_lower_name ON devices(lower(name) text_pattern_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_devices_lower_name;
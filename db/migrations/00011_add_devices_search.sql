@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Full-text document for device search. The 'simple' configuration is used
+-- because names and brands are mostly proper nouns that should not be
+-- stemmed. Extend the expression when new searchable columns are added.
+ALTER TABLE devices ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(brand, '')), 'B')
+) STORED;
+
+CREATE INDEX idx_devices_search_vector ON devices USING GIN (search_vector);
+
+-- Trigram indexes for typo tolerant matching
+CREATE INDEX idx_devices_name_trgm ON devices USING GIN (name gin_trgm_ops);
+CREATE INDEX idx_devices_brand_trgm ON devices USING GIN (brand gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_devices_brand_trgm;
+DROP INDEX IF EXISTS idx_devices_name_trgm;
+DROP INDEX IF EXISTS idx_devices_search_vector;
+ALTER TABLE devices DROP COLUMN IF EXISTS search_vector;
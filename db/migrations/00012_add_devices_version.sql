@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE devices ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- Every write bumps the version, which clients see as the device ETag
+CREATE OR REPLACE FUNCTION increment_version_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER increment_devices_version
+    BEFORE UPDATE ON devices
+    FOR EACH ROW
+    EXECUTE FUNCTION increment_version_column();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS increment_devices_version ON devices;
+DROP FUNCTION IF EXISTS increment_version_column();
+ALTER TABLE devices DROP COLUMN IF EXISTS version;
+-- +goose StatementEnd
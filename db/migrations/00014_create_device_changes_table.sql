@@ -0,0 +1,86 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Every write to devices is appended here and announced on the
+-- device_changes channel, so replicas that did not perform the write can
+-- still stream it. seq is the event id clients resume from.
+CREATE TABLE IF NOT EXISTS device_changes (
+    seq BIGSERIAL PRIMARY KEY,
+    device_id UUID NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    device JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'UTC')
+);
+
+CREATE INDEX idx_device_changes_created_at ON device_changes(created_at);
+
+CREATE OR REPLACE FUNCTION notify_device_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    changed devices%ROWTYPE;
+    change_type VARCHAR(32);
+    change_seq BIGINT;
+    payload JSONB;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        changed := NEW;
+        change_type := 'created';
+    ELSIF TG_OP = 'DELETE' THEN
+        -- purging the trash is not news, the move to the trash was
+        IF OLD.deleted_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        changed := OLD;
+        change_type := 'deleted';
+    ELSE
+        changed := NEW;
+        IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+            change_type := 'deleted';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            change_type := 'restored';
+        ELSIF OLD.state <> NEW.state AND OLD.name = NEW.name AND OLD.brand = NEW.brand THEN
+            change_type := 'state_changed';
+        ELSE
+            change_type := 'updated';
+        END IF;
+    END IF;
+
+    -- timestamps are stored in UTC; tag them so they read as RFC 3339
+    payload := jsonb_build_object(
+        'id', changed.id,
+        'name', changed.name,
+        'brand', changed.brand,
+        'state', changed.state,
+        'version', changed.version,
+        'created_at', changed.created_at AT TIME ZONE 'UTC',
+        'updated_at', changed.updated_at AT TIME ZONE 'UTC',
+        'deleted_at', changed.deleted_at AT TIME ZONE 'UTC'
+    );
+
+    INSERT INTO device_changes (device_id, type, device)
+    VALUES (changed.id, change_type, payload)
+    RETURNING seq INTO change_seq;
+
+    -- the whole change travels with the notification so listeners don't
+    -- have to read it back; a device stays well under the 8000 byte limit
+    PERFORM pg_notify('device_changes', jsonb_build_object(
+        'seq', change_seq,
+        'type', change_type,
+        'device', payload
+    )::text);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notify_devices_change
+    AFTER INSERT OR UPDATE OR DELETE ON devices
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_device_change();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS notify_devices_change ON devices;
+DROP FUNCTION IF EXISTS notify_device_change();
+DROP TABLE IF EXISTS device_changes;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Users registered before roles existed could do everything, so they keep
+-- doing so as admins. New users start as viewers.
+ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'admin';
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'viewer';
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('viewer', 'operator', 'admin'));
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS role;
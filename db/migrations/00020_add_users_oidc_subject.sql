@@ -0,0 +1,8 @@
+-- +goose Up
+-- The subject of the identity provider account a user signs in with. Users
+-- provisioned by single sign-on have no password, and linking an existing
+-- user drops theirs.
+ALTER TABLE users ADD COLUMN oidc_subject VARCHAR(255) UNIQUE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS oidc_subject;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Emails are unique ignoring case, and users are looked up that way. Refuse
+-- rather than guess which of the accounts sharing an address is the real one:
+-- find them with
+-- `SELECT lower(email), array_agg(email) FROM users GROUP BY 1 HAVING count(*) > 1`
+-- and change or delete all but one before migrating.
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users GROUP BY lower(email) HAVING count(*) > 1) THEN
+        RAISE EXCEPTION 'users share an email that only differs in case, resolve them before migrating';
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+CREATE UNIQUE INDEX idx_users_email_lower ON users (lower(email));
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_email_lower;
@@ -52,6 +52,7 @@ func ReadConfig(envFlag string, configDir string) {
 func setDefaults(envFlag string) {
 	// Server defaults
 	viper.SetDefault("port", 8080)
+	viper.SetDefault("grpc.port", 9090)
 
 	// Database defaults
 	viper.SetDefault("db-max-open-connections", 25)
@@ -76,6 +77,37 @@ func setDefaults(envFlag string) {
 	viper.SetDefault("cache.verbose", false)
 	viper.SetDefault("cache.ttl", 10*time.Minute)
 
+	// Idempotency defaults
+	viper.SetDefault("idempotency.ttl", 24*time.Hour)
+
+	// Webhook defaults
+	viper.SetDefault("webhooks.poll-interval", 2*time.Second)
+	viper.SetDefault("webhooks.timeout", 10*time.Second)
+	viper.SetDefault("webhooks.max-attempts", 8)
+	viper.SetDefault("webhooks.retry-backoff", 30*time.Second)
+
+	// Session defaults
+	viper.SetDefault("session.store", "postgres")
+	viper.SetDefault("session.sweep-interval", 10*time.Minute)
+	viper.SetDefault("session.sliding", false)
+	viper.SetDefault("session.max-lifetime", 30*24*time.Hour)
+
+	// JWT defaults
+	viper.SetDefault("jwt.enabled", false)
+	viper.SetDefault("jwt.issuer", AppName)
+	viper.SetDefault("jwt.access-ttl", 15*time.Minute)
+	viper.SetDefault("jwt.refresh-ttl", 30*24*time.Hour)
+
+	// Single sign-on defaults
+	viper.SetDefault("oidc.enabled", false)
+	viper.SetDefault("oidc.scopes", []string{"openid", "email", "profile"})
+	viper.SetDefault("oidc.groups-claim", "groups")
+	viper.SetDefault("oidc.default-role", "viewer")
+	viper.SetDefault("oidc.post-login-url", "/")
+
+	// GraphQL defaults
+	viper.SetDefault("graphql.complexity-limit", 5000)
+
 	// Logging defaults
 	viper.SetDefault("log.structured", false)
 	viper.SetDefault("log.level", uint32(log.InfoLevel))
@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+)
+
+type APITokenController struct {
+	tokenService service.APITokenServiceInterface
+}
+
+func NewAPITokenController() *APITokenController {
+	tokenRepo := repository.NewAPITokenRepository(model.DBX())
+	return &APITokenController{
+		tokenService: service.NewAPITokenService(tokenRepo),
+	}
+}
+
+// NewAPITokenControllerWithService creates a controller with injected service (for testing)
+func NewAPITokenControllerWithService(tokenService service.APITokenServiceInterface) *APITokenController {
+	return &APITokenController{
+		tokenService: tokenService,
+	}
+}
+
+// SetRoutes registers the token routes. r must require authentication and
+// be mounted under /auth. Tokens can only be managed from a login session.
+func (tc *APITokenController) SetRoutes(r *mux.Router) {
+	r.HandleFunc("/tokens", RequireSession(tc.CreateToken)).Methods(http.MethodPost)
+	r.HandleFunc("/tokens", RequireSession(tc.GetTokens)).Methods(http.MethodGet)
+	r.HandleFunc("/tokens/{id}", RequireSession(tc.RevokeToken)).Methods(http.MethodDelete)
+}
+
+// APITokenRequest represents the API token request body. Scopes are the
+// permissions the token is granted; the role of the user must allow them.
+// Without expires_at the token lasts until it is revoked.
+type APITokenRequest struct {
+	Name      string     `json:"name" binding:"required,max=100" example:"ci"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,oneof=devices:read devices:operate devices:manage users:manage" example:"devices:read"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" example:"2026-01-01T00:00:00Z"`
+}
+
+// CreateToken godoc
+// @Summary      Create an API token
+// @Description  Issue a token scripts send as "Authorization: Bearer <token>". It can only do what both its scopes and your role allow. The response carries the token itself; it is not shown again.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      APITokenRequest  true  "Token details"
+// @Success      201      {object}  model.APIToken
+// @Failure      400      {object}  Problem
+// @Failure      401      {object}  Problem
+// @Failure      403      {object}  Problem
+// @Failure      413      {object}  Problem
+// @Failure      422      {object}  Problem
+// @Failure      500      {object}  Problem
+// @Router       /auth/tokens [post]
+func (tc *APITokenController) CreateToken(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req APITokenRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	token, err := tc.tokenService.CreateToken(&model.APIToken{
+		UserID:    user.ID,
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}, user.Role)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusCreated, token)
+}
+
+// GetTokens godoc
+// @Summary      List API tokens
+// @Description  List the API tokens of the authenticated user, newest first, with when each was last used. Expired tokens are listed too.
+// @Tags         auth
+// @Produce      json
+// @Success      200  {array}   model.APIToken
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /auth/tokens [get]
+func (tc *APITokenController) GetTokens(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	tokens, err := tc.tokenService.GetTokens(user.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, tokens)
+}
+
+// RevokeToken godoc
+// @Summary      Revoke an API token
+// @Description  Delete an API token of the authenticated user. Requests made with it are rejected from then on.
+// @Tags         auth
+// @Param        id  path  string  true  "Token ID"
+// @Success      204
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /auth/tokens/{id} [delete]
+func (tc *APITokenController) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid token ID")
+		return
+	}
+
+	if err := tc.tokenService.RevokeToken(id.String(), user.ID); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
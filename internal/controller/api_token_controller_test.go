@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAPITokenService is a mock implementation of APITokenServiceInterface
+type MockAPITokenService struct {
+	mock.Mock
+}
+
+func (m *MockAPITokenService) GetTokens(userID uuid.UUID) ([]model.APIToken, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.APIToken), args.Error(1)
+}
+
+func (m *MockAPITokenService) CreateToken(token *model.APIToken, role model.Role) (*model.APIToken, error) {
+	args := m.Called(token, role)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.APIToken), args.Error(1)
+}
+
+func (m *MockAPITokenService) RevokeToken(id string, userID uuid.UUID) error {
+	args := m.Called(id, userID)
+	return args.Error(0)
+}
+
+func (m *MockAPITokenService) Authenticate(token string) (*model.APIToken, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.APIToken), args.Error(1)
+}
+
+func newAPITokenRouter(tokenService service.APITokenServiceInterface) *mux.Router {
+	router := mux.NewRouter()
+	NewAPITokenControllerWithService(tokenService).SetRoutes(router.PathPrefix("/auth").Subrouter())
+	return router
+}
+
+func TestCreateToken_Success(t *testing.T) {
+	mockService := new(MockAPITokenService)
+	router := newAPITokenRouter(mockService)
+	user := &model.User{ID: uuid.New(), Role: model.RoleOperator}
+
+	var requested *model.APIToken
+	created := &model.APIToken{ID: uuid.New(), UserID: user.ID, Name: "ci", Token: "drt_secret", Prefix: "drt_secre", Scopes: []string{"devices:read"}}
+	mockService.On("CreateToken", mock.AnythingOfType("*model.APIToken"), model.RoleOperator).
+		Run(func(args mock.Arguments) { requested = args.Get(0).(*model.APIToken) }).
+		Return(created, nil).Once()
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/auth/tokens", strings.NewReader(`{"name":"ci","scopes":["devices:read"]}`)), user)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	require.NotNil(t, requested)
+	assert.Equal(t, user.ID, requested.UserID)
+	assert.Equal(t, "ci", requested.Name)
+	assert.Nil(t, requested.ExpiresAt)
+
+	var response model.APIToken
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "drt_secret", response.Token)
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateToken_UnknownScope(t *testing.T) {
+	mockService := new(MockAPITokenService)
+	router := newAPITokenRouter(mockService)
+	user := &model.User{ID: uuid.New(), Role: model.RoleAdmin}
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/auth/tokens", strings.NewReader(`{"name":"ci","scopes":["devices:everything"]}`)), user)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	mockService.AssertNotCalled(t, "CreateToken", mock.Anything, mock.Anything)
+}
+
+func TestCreateToken_ScopeNotAllowed(t *testing.T) {
+	mockService := new(MockAPITokenService)
+	router := newAPITokenRouter(mockService)
+	user := &model.User{ID: uuid.New(), Role: model.RoleViewer}
+
+	mockService.On("CreateToken", mock.AnythingOfType("*model.APIToken"), model.RoleViewer).Return(nil, service.ErrScopeNotAllowed).Once()
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/auth/tokens", strings.NewReader(`{"name":"ci","scopes":["devices:manage"]}`)), user)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "scope_not_allowed", problem.Code)
+}
+
+func TestAPITokenRoutes_RequireSession(t *testing.T) {
+	mockService := new(MockAPITokenService)
+	router := newAPITokenRouter(mockService)
+	user := &model.User{ID: uuid.New(), Role: model.RoleAdmin}
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/auth/tokens", strings.NewReader(`{"name":"ci","scopes":["devices:read"]}`)), user)
+	req = req.WithContext(context.WithValue(req.Context(), model.APITokenContextKey, &model.APIToken{ID: uuid.New(), UserID: user.ID}))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "session_required", problem.Code)
+	mockService.AssertNotCalled(t, "CreateToken", mock.Anything, mock.Anything)
+}
+
+func TestRevokeToken(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Role: model.RoleViewer}
+
+	t.Run("revoked", func(t *testing.T) {
+		mockService := new(MockAPITokenService)
+		id := uuid.New()
+		mockService.On("RevokeToken", id.String(), user.ID).Return(nil).Once()
+
+		w := httptest.NewRecorder()
+		newAPITokenRouter(mockService).ServeHTTP(w, withUser(httptest.NewRequest(http.MethodDelete, "/auth/tokens/"+id.String(), nil), user))
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockService := new(MockAPITokenService)
+		id := uuid.New()
+		mockService.On("RevokeToken", id.String(), user.ID).Return(service.ErrAPITokenNotFound).Once()
+
+		w := httptest.NewRecorder()
+		newAPITokenRouter(mockService).ServeHTTP(w, withUser(httptest.NewRequest(http.MethodDelete, "/auth/tokens/"+id.String(), nil), user))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		mockService := new(MockAPITokenService)
+
+		w := httptest.NewRecorder()
+		newAPITokenRouter(mockService).ServeHTTP(w, withUser(httptest.NewRequest(http.MethodDelete, "/auth/tokens/abc", nil), user))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "RevokeToken", mock.Anything, mock.Anything)
+	})
+}
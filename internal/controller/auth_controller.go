@@ -2,46 +2,92 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/loopsFreitag/DeviceRegistry/internal/model"
 	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
 	SessionCookieName = "session_id"
 	SessionDuration   = 24 * time.Hour
+	defaultUserLimit  = 20
+	maxUserLimit      = 100
 )
 
 type AuthController struct {
 	authService service.AuthServiceInterface
-	sessions    *model.SessionStore
+	sessions    model.SessionStore
+	jwt         service.JWTServiceInterface
 }
 
-func NewAuthController(authService service.AuthServiceInterface) *AuthController {
-	return &AuthController{
+// AuthControllerOption is a functional option to configure the AuthController.
+type AuthControllerOption func(*AuthController)
+
+// WithJWTService enables token mode: logins asking for it get a JWT access
+// token and a refresh token instead of a session
+func WithJWTService(jwt service.JWTServiceInterface) AuthControllerOption {
+	return func(ac *AuthController) {
+		ac.jwt = jwt
+	}
+}
+
+func NewAuthController(authService service.AuthServiceInterface, opts ...AuthControllerOption) *AuthController {
+	return NewAuthControllerWithStore(authService, model.GetSessionStore(), opts...)
+}
+
+// NewAuthControllerWithStore creates a controller keeping sessions in the
+// given store (for testing)
+func NewAuthControllerWithStore(authService service.AuthServiceInterface, sessions model.SessionStore, opts ...AuthControllerOption) *AuthController {
+	ac := &AuthController{
 		authService: authService,
-		sessions:    model.GetSessionStore(),
+		sessions:    sessions,
 	}
+	for _, opt := range opts {
+		opt(ac)
+	}
+	return ac
 }
 
 func (ac *AuthController) SetRoutes(r *mux.Router) {
 	r.HandleFunc("/auth/register", ac.Register).Methods(http.MethodPost)
 	r.HandleFunc("/auth/login", ac.Login).Methods(http.MethodPost)
+	r.HandleFunc("/auth/refresh", ac.Refresh).Methods(http.MethodPost)
+	r.HandleFunc("/.well-known/jwks.json", ac.JWKS).Methods(http.MethodGet)
+}
+
+// SetProtectedRoutes registers the routes for logged in users. r must
+// require authentication and be mounted under /auth.
+func (ac *AuthController) SetProtectedRoutes(r *mux.Router) {
+	r.HandleFunc("/me", ac.Me).Methods(http.MethodGet)
+	r.HandleFunc("/logout", RequireSession(ac.Logout)).Methods(http.MethodPost)
+	r.HandleFunc("/logout-all", RequireSession(ac.LogoutAll)).Methods(http.MethodPost)
+	r.HandleFunc("/sessions", RequireSession(ac.ListSessions)).Methods(http.MethodGet)
+	r.HandleFunc("/sessions/{id}", RequireSession(ac.RevokeSession)).Methods(http.MethodDelete)
+	r.HandleFunc("/users", RequirePermission(model.PermissionManageUsers, ac.ListUsers)).Methods(http.MethodGet)
+	r.HandleFunc("/users/{id}/role", RequirePermission(model.PermissionManageUsers, ac.UpdateUserRole)).Methods(http.MethodPut)
 }
 
-// RegisterRequest represents the registration request body
+// RegisterRequest represents the registration request body. Passwords are
+// capped at the 72 bytes bcrypt takes into account.
 type RegisterRequest struct {
-	Email    string `json:"email" example:"user@example.com"`
-	Password string `json:"password" example:"securepassword123"`
+	Email    string `json:"email" binding:"required,email,max=255" example:"user@example.com"`
+	Password string `json:"password" binding:"required,max=72" example:"securepassword123"`
 }
 
-// LoginRequest represents the login request body
+// LoginRequest represents the login request body. Mode "token" asks for a
+// JWT access token and a refresh token instead of a session cookie.
 type LoginRequest struct {
-	Email    string `json:"email" example:"user@example.com"`
-	Password string `json:"password" example:"securepassword123"`
+	Email    string `json:"email" binding:"required" example:"user@example.com"`
+	Password string `json:"password" binding:"required" example:"securepassword123"`
+	Mode     string `json:"mode,omitempty" binding:"omitempty,oneof=session token" example:"session"`
 }
 
 // AuthResponse represents the authentication response
@@ -50,6 +96,40 @@ type AuthResponse struct {
 	Message string      `json:"message,omitempty"`
 }
 
+// MeResponse is the logged in user along with what they are allowed to do.
+// ExpiresAt is when the session or token the request was made with expires,
+// and is left out for API tokens that don't.
+type MeResponse struct {
+	User        *model.User        `json:"user"`
+	Permissions []model.Permission `json:"permissions" swaggertype:"array,string" example:"devices:read"`
+	ExpiresAt   *time.Time         `json:"expires_at,omitempty"`
+}
+
+// SessionResponse is a login of the user. Current marks the session the
+// request was made with.
+type SessionResponse struct {
+	model.Session
+	Current bool `json:"current" example:"true"`
+}
+
+// SessionsResponse lists the live sessions of the user, newest first
+type SessionsResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
+}
+
+// UserListResponse represents a page of users
+type UserListResponse struct {
+	Users  []model.User `json:"users"`
+	Total  int          `json:"total" example:"42"`
+	Limit  int          `json:"limit" example:"20"`
+	Offset int          `json:"offset" example:"0"`
+}
+
+// UpdateUserRoleRequest represents the role change request body
+type UpdateUserRoleRequest struct {
+	Role model.Role `json:"role" binding:"required,oneof=viewer operator admin" example:"operator"`
+}
+
 // Register godoc
 // @Summary      Register a new user
 // @Description  Create a new user account with email and password
@@ -58,30 +138,21 @@ type AuthResponse struct {
 // @Produce      json
 // @Param        request  body      RegisterRequest  true  "Registration details"
 // @Success      201      {object}  AuthResponse
-// @Failure      400      {object}  ErrorResponse
-// @Failure      409      {object}  ErrorResponse
-// @Failure      500      {object}  ErrorResponse
+// @Failure      400      {object}  Problem
+// @Failure      409      {object}  Problem
+// @Failure      413      {object}  Problem
+// @Failure      422      {object}  Problem
+// @Failure      500      {object}  Problem
 // @Router       /auth/register [post]
 func (ac *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
-		return
-	}
-
-	// Validate input
-	if req.Email == "" || req.Password == "" {
-		RespondWithError(w, http.StatusBadRequest, "Email and password are required")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	user, err := ac.authService.CreateUser(req.Email, req.Password)
 	if err != nil {
-		if err == service.ErrUserAlreadyExists {
-			RespondWithError(w, http.StatusConflict, "User already exists")
-			return
-		}
-		RespondWithError(w, http.StatusInternalServerError, "Failed to create user")
+		WriteError(w, r, err)
 		return
 	}
 
@@ -93,64 +164,375 @@ func (ac *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 
 // Login godoc
 // @Summary      Login
-// @Description  Authenticate user and create session
+// @Description  Authenticate user and create session. With "mode": "token" no session is created; the response is a TokenResponse carrying a JWT access token and a refresh token instead, if the server has token mode enabled.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        request  body      LoginRequest  true  "Login credentials"
 // @Success      200      {object}  AuthResponse
-// @Failure      400      {object}  ErrorResponse
-// @Failure      401      {object}  ErrorResponse
-// @Failure      500      {object}  ErrorResponse
+// @Failure      400      {object}  Problem
+// @Failure      401      {object}  Problem
+// @Failure      413      {object}  Problem
+// @Failure      422      {object}  Problem
+// @Failure      500      {object}  Problem
 // @Router       /auth/login [post]
 func (ac *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Mode == "token" && ac.jwt == nil {
+		WriteError(w, r, service.ErrTokenModeDisabled)
 		return
 	}
 
 	user, err := ac.authService.Login(req.Email, req.Password)
 	if err != nil {
-		if err == service.ErrInvalidCredentials {
-			RespondWithError(w, http.StatusUnauthorized, "Invalid credentials")
+		WriteError(w, r, err)
+		return
+	}
+
+	if req.Mode == "token" {
+		ac.respondWithTokens(w, r, user)
+		return
+	}
+
+	if err := startSession(w, r, ac.sessions, user); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, AuthResponse{
+		User:    user,
+		Message: "Login successful",
+	})
+}
+
+// Me godoc
+// @Summary      Current user
+// @Description  Return the logged in user, the permissions their role allows (narrowed to its scopes for an API token), and when the session or token the request was made with expires. With sliding sessions, every request pushes the session expiry back.
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  MeResponse
+// @Failure      401  {object}  Problem
+// @Router       /auth/me [get]
+func (ac *AuthController) Me(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	response := MeResponse{User: user, Permissions: user.Role.Permissions()}
+	if session := model.SessionFromContext(r.Context()); session != nil {
+		response.ExpiresAt = &session.ExpiresAt
+	} else if token := model.AccessTokenFromContext(r.Context()); token != nil {
+		response.ExpiresAt = &token.ExpiresAt
+	} else if token := model.APITokenFromContext(r.Context()); token != nil {
+		response.ExpiresAt = token.ExpiresAt
+		permissions := []model.Permission{}
+		for _, permission := range response.Permissions {
+			if token.HasScope(permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+		response.Permissions = permissions
+	}
+
+	RespondWithJSON(w, http.StatusOK, response)
+}
+
+// Logout godoc
+// @Summary      Logout
+// @Description  End the session the request was made with and clear its cookie. In token mode, revoke the refresh tokens of the login instead; its access token stays valid until it expires.
+// @Tags         auth
+// @Success      204
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /auth/logout [post]
+func (ac *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	if session := model.SessionFromContext(r.Context()); session != nil {
+		// a session that is already gone is as logged out as it gets
+		if err := ac.sessions.Delete(user.ID, session.ID); err != nil && !errors.Is(err, model.ErrSessionNotFound) {
+			WriteError(w, r, err)
+			return
+		}
+		clearSessionCookie(w)
+	} else if token := model.AccessTokenFromContext(r.Context()); token != nil && ac.jwt != nil {
+		if err := ac.jwt.RevokeLogin(token.FamilyID); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll godoc
+// @Summary      Logout everywhere
+// @Description  End every session of the authenticated user, this one included, and revoke the refresh tokens of all their logins in token mode. API tokens are not revoked.
+// @Tags         auth
+// @Success      204
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /auth/logout-all [post]
+func (ac *AuthController) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	deleted, err := ac.sessions.DeleteByUser(user.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	if ac.jwt != nil {
+		if err := ac.jwt.RevokeUserLogins(user.ID); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+	log.Infof("user %s logged out everywhere, ending %d sessions", user.ID, deleted)
+
+	if model.SessionFromContext(r.Context()) != nil {
+		clearSessionCookie(w)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSessions godoc
+// @Summary      List sessions
+// @Description  List the live sessions of the authenticated user, newest first, with the client each was opened from
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  SessionsResponse
+// @Failure      401  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /auth/sessions [get]
+func (ac *AuthController) ListSessions(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	sessions, err := ac.sessions.ListByUser(user.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	current := model.SessionFromContext(r.Context())
+	response := SessionsResponse{Sessions: make([]SessionResponse, len(sessions))}
+	for i, session := range sessions {
+		response.Sessions[i] = SessionResponse{
+			Session: session,
+			Current: current != nil && current.ID == session.ID,
+		}
+	}
+
+	RespondWithJSON(w, http.StatusOK, response)
+}
+
+// RevokeSession godoc
+// @Summary      Revoke a session
+// @Description  End a session of the authenticated user, logging out the client that holds it. Revoking the current session also clears its cookie.
+// @Tags         auth
+// @Param        id   path      string  true  "Session ID"
+// @Success      204
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /auth/sessions/{id} [delete]
+func (ac *AuthController) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid session ID")
+		return
+	}
+
+	if err := ac.sessions.Delete(user.ID, id); err != nil {
+		if errors.Is(err, model.ErrSessionNotFound) {
+			WriteProblem(w, r, http.StatusNotFound, CodeSessionNotFound, "Session not found")
 			return
 		}
-		RespondWithError(w, http.StatusInternalServerError, "Failed to login")
+		WriteError(w, r, err)
 		return
 	}
 
-	// Create session
-	sessionID := ac.sessions.Create(user.ID, user.Email, SessionDuration)
+	if current := model.SessionFromContext(r.Context()); current != nil && current.ID == id {
+		clearSessionCookie(w)
+	}
 
-	// Set cookie
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListUsers godoc
+// @Summary      List users
+// @Description  Page through the registered users with their roles, oldest first. Admins only.
+// @Tags         auth
+// @Produce      json
+// @Param        limit   query     int  false  "Page size (1-100, default 20)"
+// @Param        offset  query     int  false  "Number of users to skip"
+// @Success      200     {object}  UserListResponse
+// @Failure      400     {object}  Problem
+// @Failure      401     {object}  Problem
+// @Failure      403     {object}  Problem
+// @Failure      500     {object}  Problem
+// @Router       /auth/users [get]
+func (ac *AuthController) ListUsers(w http.ResponseWriter, r *http.Request) {
+	limit := defaultUserLimit
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 || parsed > maxUserLimit {
+			WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid limit parameter. Use a number between 1 and 100")
+			return
+		}
+		limit = parsed
+	}
+
+	offset := 0
+	if offsetParam := r.URL.Query().Get("offset"); offsetParam != "" {
+		parsed, err := strconv.Atoi(offsetParam)
+		if err != nil || parsed < 0 {
+			WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid offset parameter")
+			return
+		}
+		offset = parsed
+	}
+
+	users, total, err := ac.authService.ListUsers(limit, offset)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, UserListResponse{
+		Users:  users,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// UpdateUserRole godoc
+// @Summary      Change the role of a user
+// @Description  Make a user a viewer (read only), an operator (can also check devices in and out, change their state and reserve them) or an admin (can also create and delete devices and manage users). Admins only, and not for their own account.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                 true  "User ID"
+// @Param        request  body      UpdateUserRoleRequest  true  "New role"
+// @Success      200      {object}  model.User
+// @Failure      400      {object}  Problem
+// @Failure      401      {object}  Problem
+// @Failure      403      {object}  Problem
+// @Failure      404      {object}  Problem
+// @Failure      422      {object}  Problem
+// @Failure      500      {object}  Problem
+// @Router       /auth/users/{id}/role [put]
+func (ac *AuthController) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	actor := model.UserFromContext(r.Context())
+	if actor == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid user ID")
+		return
+	}
+
+	var req UpdateUserRoleRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	user, err := ac.authService.UpdateUserRole(id, req.Role, actor.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, user)
+}
+
+// startSession opens a session for user, logged in from the client r comes
+// from, and hands its cookie out
+func startSession(w http.ResponseWriter, r *http.Request, sessions model.SessionStore, user *model.User) error {
+	session := model.NewSession(user.ID, r.UserAgent(), clientIP(r))
+	token, err := sessions.Create(session, SessionDuration)
+	if err != nil {
+		return err
+	}
+
+	SetSessionCookie(w, token, session.ExpiresAt)
+	return nil
+}
+
+// SetSessionCookie hands out the cookie of the session token identifies,
+// kept by the browser until the session expires
+func SetSessionCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     SessionCookieName,
-		Value:    sessionID,
+		Value:    token,
 		Path:     "/",
 		HttpOnly: true,
 		Secure:   false,
 		SameSite: http.SameSiteLaxMode,
-		MaxAge:   int(SessionDuration.Seconds()),
+		MaxAge:   int(time.Until(expiresAt).Round(time.Second).Seconds()),
 	})
+}
 
-	RespondWithJSON(w, http.StatusOK, AuthResponse{
-		User:    user,
-		Message: "Login successful",
+// clearSessionCookie tells the browser to drop the session cookie
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
 	})
 }
 
-// Helper functions (now exported so middleware can use them)
+// clientIP returns the address of the client r comes from, as recorded on
+// the sessions it opens
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// Helper function to send JSON responses
 func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, _ := json.Marshal(payload)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	w.Write(response)
 }
-
-func RespondWithError(w http.ResponseWriter, code int, message string) {
-	RespondWithJSON(w, code, ErrorResponse{
-		Status:  "error",
-		Message: message,
-	})
-}
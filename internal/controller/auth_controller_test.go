@@ -2,6 +2,7 @@ package controller
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -9,10 +10,12 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/loopsFreitag/DeviceRegistry/internal/model"
 	"github.com/loopsFreitag/DeviceRegistry/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockAuthService now implements service.AuthServiceInterface
@@ -44,6 +47,22 @@ func (m *MockAuthService) GetUserByID(userID uuid.UUID) (*model.User, error) {
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
+func (m *MockAuthService) ListUsers(limit, offset int) ([]model.User, int, error) {
+	args := m.Called(limit, offset)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]model.User), args.Int(1), args.Error(2)
+}
+
+func (m *MockAuthService) UpdateUserRole(userID uuid.UUID, role model.Role, actorID uuid.UUID) (*model.User, error) {
+	args := m.Called(userID, role, actorID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
 func (m *MockAuthService) UpdateUser(user *model.User) error {
 	args := m.Called(user)
 	return args.Error(0)
@@ -101,11 +120,11 @@ func TestAuthController_Register(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 
-		var response ErrorResponse
+		var response Problem
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, "error", response.Status)
-		assert.Equal(t, "Invalid request body", response.Message)
+		assert.Equal(t, CodeInvalidRequestBody, response.Code)
+		assert.Equal(t, "Invalid request body", response.Detail)
 	})
 
 	t.Run("missing email", func(t *testing.T) {
@@ -120,12 +139,13 @@ func TestAuthController_Register(t *testing.T) {
 
 		controller.Register(w, req)
 
-		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
 
-		var response ErrorResponse
+		var response Problem
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, "Email and password are required", response.Message)
+		assert.Equal(t, "validation_failed", response.Code)
+		assert.Equal(t, []model.FieldError{{Field: "email", Rule: "required", Message: "email is required"}}, response.Errors)
 	})
 
 	t.Run("missing password", func(t *testing.T) {
@@ -140,12 +160,30 @@ func TestAuthController_Register(t *testing.T) {
 
 		controller.Register(w, req)
 
-		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		var response Problem
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "validation_failed", response.Code)
+		assert.Equal(t, []model.FieldError{{Field: "password", Rule: "required", Message: "password is required"}}, response.Errors)
+	})
+
+	t.Run("invalid email", func(t *testing.T) {
+		body := []byte(`{"email":"not-an-email","password":"password123"}`)
+
+		req := httptest.NewRequest(http.MethodPost, "/auth/register", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
 
-		var response ErrorResponse
+		controller.Register(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		var response Problem
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, "Email and password are required", response.Message)
+		assert.Equal(t, []model.FieldError{{Field: "email", Rule: "email", Message: "email must be a valid email address"}}, response.Errors)
 	})
 
 	t.Run("user already exists", func(t *testing.T) {
@@ -166,10 +204,10 @@ func TestAuthController_Register(t *testing.T) {
 
 		assert.Equal(t, http.StatusConflict, w.Code)
 
-		var response ErrorResponse
+		var response Problem
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, "User already exists", response.Message)
+		assert.Equal(t, "user_already_exists", response.Code)
 
 		mockService.AssertExpectations(t)
 	})
@@ -192,10 +230,10 @@ func TestAuthController_Register(t *testing.T) {
 
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 
-		var response ErrorResponse
+		var response Problem
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, "Failed to create user", response.Message)
+		assert.Equal(t, CodeInternal, response.Code)
 
 		mockService.AssertExpectations(t)
 	})
@@ -262,10 +300,10 @@ func TestAuthController_Login(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 
-		var response ErrorResponse
+		var response Problem
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, "Invalid request body", response.Message)
+		assert.Equal(t, "Invalid request body", response.Detail)
 	})
 
 	t.Run("invalid credentials", func(t *testing.T) {
@@ -286,10 +324,10 @@ func TestAuthController_Login(t *testing.T) {
 
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
 
-		var response ErrorResponse
+		var response Problem
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, "Invalid credentials", response.Message)
+		assert.Equal(t, "invalid_credentials", response.Code)
 
 		mockService.AssertExpectations(t)
 	})
@@ -312,11 +350,378 @@ func TestAuthController_Login(t *testing.T) {
 
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 
-		var response ErrorResponse
+		var response Problem
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, "Failed to login", response.Message)
+		assert.Equal(t, CodeInternal, response.Code)
+
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestAuthController_LoginRecordsClient(t *testing.T) {
+	mockService := new(MockAuthService)
+	sessions := model.NewMemorySessionStore()
+	controller := NewAuthControllerWithStore(mockService, sessions)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	mockService.On("Login", "test@example.com", "password123").Return(user, nil).Once()
+
+	body, _ := json.Marshal(LoginRequest{Email: "test@example.com", Password: "password123"})
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(body))
+	req.Header.Set("User-Agent", "curl/8.5.0")
+	req.RemoteAddr = "203.0.113.7:51234"
+	w := httptest.NewRecorder()
+
+	controller.Login(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	listed, err := sessions.ListByUser(user.ID)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, "curl/8.5.0", listed[0].UserAgent)
+	assert.Equal(t, "203.0.113.7", listed[0].IP)
+
+	mockService.AssertExpectations(t)
+}
+
+// withSession returns req as sent by user over session
+func withSession(req *http.Request, user *model.User, session *model.Session) *http.Request {
+	ctx := context.WithValue(req.Context(), model.UserContextKey, user)
+	ctx = context.WithValue(ctx, model.SessionContextKey, session)
+	return req.WithContext(ctx)
+}
+
+func TestAuthController_ListSessions(t *testing.T) {
+	sessions := model.NewMemorySessionStore()
+	controller := NewAuthControllerWithStore(new(MockAuthService), sessions)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	current := model.NewSession(user.ID, "Firefox", "192.0.2.1")
+	_, err := sessions.Create(current, time.Hour)
+	require.NoError(t, err)
+	other := model.NewSession(user.ID, "curl/8.5.0", "192.0.2.2")
+	_, err = sessions.Create(other, time.Hour)
+	require.NoError(t, err)
+	_, err = sessions.Create(model.NewSession(uuid.New(), "Safari", "192.0.2.3"), time.Hour)
+	require.NoError(t, err)
+
+	t.Run("lists the sessions of the user", func(t *testing.T) {
+		req := withSession(httptest.NewRequest(http.MethodGet, "/auth/sessions", nil), user, current)
+		w := httptest.NewRecorder()
+
+		controller.ListSessions(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response SessionsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Sessions, 2)
+
+		byID := map[uuid.UUID]SessionResponse{}
+		for _, session := range response.Sessions {
+			byID[session.ID] = session
+		}
+		assert.True(t, byID[current.ID].Current)
+		assert.Equal(t, "Firefox", byID[current.ID].UserAgent)
+		assert.False(t, byID[other.ID].Current)
+		assert.Equal(t, "192.0.2.2", byID[other.ID].IP)
+		assert.NotContains(t, w.Body.String(), "token")
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		controller.ListSessions(w, httptest.NewRequest(http.MethodGet, "/auth/sessions", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestAuthController_RevokeSession(t *testing.T) {
+	sessions := model.NewMemorySessionStore()
+	controller := NewAuthControllerWithStore(new(MockAuthService), sessions)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	current := model.NewSession(user.ID, "Firefox", "192.0.2.1")
+	_, err := sessions.Create(current, time.Hour)
+	require.NoError(t, err)
+
+	revoke := func(id string) *httptest.ResponseRecorder {
+		req := withSession(httptest.NewRequest(http.MethodDelete, "/auth/sessions/"+id, nil), user, current)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		controller.RevokeSession(w, req)
+		return w
+	}
+
+	t.Run("revokes another session", func(t *testing.T) {
+		other := model.NewSession(user.ID, "curl/8.5.0", "192.0.2.2")
+		token, err := sessions.Create(other, time.Hour)
+		require.NoError(t, err)
+
+		w := revoke(other.ID.String())
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+		_, err = sessions.Get(token)
+		assert.ErrorIs(t, err, model.ErrSessionNotFound)
+	})
+
+	t.Run("session of another user", func(t *testing.T) {
+		foreign := model.NewSession(uuid.New(), "Safari", "192.0.2.3")
+		token, err := sessions.Create(foreign, time.Hour)
+		require.NoError(t, err)
+
+		w := revoke(foreign.ID.String())
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		var response Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, CodeSessionNotFound, response.Code)
+		_, err = sessions.Get(token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		w := revoke("not-a-uuid")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("current session clears the cookie", func(t *testing.T) {
+		w := revoke(current.ID.String())
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, SessionCookieName, cookies[0].Name)
+		assert.Negative(t, cookies[0].MaxAge)
+	})
+}
+
+func TestAuthController_Me(t *testing.T) {
+	controller := NewAuthControllerWithStore(new(MockAuthService), model.NewMemorySessionStore())
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", Role: model.RoleOperator}
+
+	me := func(req *http.Request) MeResponse {
+		w := httptest.NewRecorder()
+		controller.Me(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response MeResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("session", func(t *testing.T) {
+		session := &model.Session{ID: uuid.New(), UserID: user.ID, ExpiresAt: time.Now().UTC().Add(time.Hour).Truncate(time.Second)}
+
+		response := me(withSession(httptest.NewRequest(http.MethodGet, "/auth/me", nil), user, session))
+
+		assert.Equal(t, user.ID, response.User.ID)
+		assert.Equal(t, model.RoleOperator, response.User.Role)
+		assert.Equal(t, []model.Permission{model.PermissionReadDevices, model.PermissionOperateDevices}, response.Permissions)
+		require.NotNil(t, response.ExpiresAt)
+		assert.True(t, session.ExpiresAt.Equal(*response.ExpiresAt))
+	})
+
+	t.Run("API token narrows permissions to its scopes", func(t *testing.T) {
+		token := &model.APIToken{ID: uuid.New(), UserID: user.ID, Scopes: []string{"devices:read", "users:manage"}}
+		req := withUser(httptest.NewRequest(http.MethodGet, "/auth/me", nil), user)
+		req = req.WithContext(context.WithValue(req.Context(), model.APITokenContextKey, token))
+
+		response := me(req)
+
+		assert.Equal(t, []model.Permission{model.PermissionReadDevices}, response.Permissions)
+		assert.Nil(t, response.ExpiresAt)
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		controller.Me(w, httptest.NewRequest(http.MethodGet, "/auth/me", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestAuthController_Logout(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	t.Run("ends the session and clears its cookie", func(t *testing.T) {
+		sessions := model.NewMemorySessionStore()
+		current := model.NewSession(user.ID, "Firefox", "192.0.2.1")
+		token, err := sessions.Create(current, time.Hour)
+		require.NoError(t, err)
+		other, err := sessions.Create(model.NewSession(user.ID, "curl/8.5.0", "192.0.2.2"), time.Hour)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		NewAuthControllerWithStore(new(MockAuthService), sessions).
+			Logout(w, withSession(httptest.NewRequest(http.MethodPost, "/auth/logout", nil), user, current))
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, SessionCookieName, cookies[0].Name)
+		assert.Negative(t, cookies[0].MaxAge)
+
+		_, err = sessions.Get(token)
+		assert.ErrorIs(t, err, model.ErrSessionNotFound)
+		_, err = sessions.Get(other)
+		assert.NoError(t, err)
+	})
+
+	t.Run("token mode revokes the login", func(t *testing.T) {
+		mockJWT := new(MockJWTService)
+		familyID := uuid.New()
+		mockJWT.On("RevokeLogin", familyID).Return(nil).Once()
+
+		req := withUser(httptest.NewRequest(http.MethodPost, "/auth/logout", nil), user)
+		req = req.WithContext(context.WithValue(req.Context(), model.AccessTokenContextKey, &model.AccessToken{UserID: user.ID, FamilyID: familyID}))
+		w := httptest.NewRecorder()
+		NewAuthControllerWithStore(new(MockAuthService), model.NewMemorySessionStore(), WithJWTService(mockJWT)).Logout(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+		mockJWT.AssertExpectations(t)
+	})
+
+	t.Run("API token", func(t *testing.T) {
+		router := mux.NewRouter()
+		NewAuthControllerWithStore(new(MockAuthService), model.NewMemorySessionStore()).SetProtectedRoutes(router)
+
+		req := withUser(httptest.NewRequest(http.MethodPost, "/logout", nil), user)
+		req = req.WithContext(context.WithValue(req.Context(), model.APITokenContextKey, &model.APIToken{UserID: user.ID}))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestAuthController_LogoutAll(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	sessions := model.NewMemorySessionStore()
+	current := model.NewSession(user.ID, "Firefox", "192.0.2.1")
+	_, err := sessions.Create(current, time.Hour)
+	require.NoError(t, err)
+	_, err = sessions.Create(model.NewSession(user.ID, "curl/8.5.0", "192.0.2.2"), time.Hour)
+	require.NoError(t, err)
+	foreign, err := sessions.Create(model.NewSession(uuid.New(), "Safari", "192.0.2.3"), time.Hour)
+	require.NoError(t, err)
+
+	mockJWT := new(MockJWTService)
+	mockJWT.On("RevokeUserLogins", user.ID).Return(nil).Once()
+
+	w := httptest.NewRecorder()
+	NewAuthControllerWithStore(new(MockAuthService), sessions, WithJWTService(mockJWT)).
+		LogoutAll(w, withSession(httptest.NewRequest(http.MethodPost, "/auth/logout-all", nil), user, current))
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Negative(t, cookies[0].MaxAge)
+
+	remaining, err := sessions.ListByUser(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+	_, err = sessions.Get(foreign)
+	assert.NoError(t, err)
+	mockJWT.AssertExpectations(t)
+}
+
+func TestAuthController_ListUsers(t *testing.T) {
+	mockService := new(MockAuthService)
+	controller := NewAuthController(mockService)
+
+	t.Run("page of users", func(t *testing.T) {
+		users := []model.User{{ID: uuid.New(), Email: "viewer@example.com", Role: model.RoleViewer}}
+		mockService.On("ListUsers", 1, 2).Return(users, 3, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/auth/users?limit=1&offset=2", nil)
+		w := httptest.NewRecorder()
+
+		controller.ListUsers(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response UserListResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 3, response.Total)
+		require.Len(t, response.Users, 1)
+		assert.Equal(t, model.RoleViewer, response.Users[0].Role)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		controller.ListUsers(w, httptest.NewRequest(http.MethodGet, "/auth/users?limit=0", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestAuthController_UpdateUserRole(t *testing.T) {
+	mockService := new(MockAuthService)
+	controller := NewAuthController(mockService)
+
+	admin := &model.User{ID: uuid.New(), Email: "admin@example.com", Role: model.RoleAdmin}
+	userID := uuid.New()
+
+	update := func(id, body string) *httptest.ResponseRecorder {
+		req := withUser(httptest.NewRequest(http.MethodPut, "/auth/users/"+id+"/role", bytes.NewReader([]byte(body))), admin)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		controller.UpdateUserRole(w, req)
+		return w
+	}
 
+	t.Run("role updated", func(t *testing.T) {
+		updated := &model.User{ID: userID, Email: "test@example.com", Role: model.RoleOperator}
+		mockService.On("UpdateUserRole", userID, model.RoleOperator, admin.ID).Return(updated, nil).Once()
+
+		w := update(userID.String(), `{"role":"operator"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response model.User
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, model.RoleOperator, response.Role)
 		mockService.AssertExpectations(t)
 	})
+
+	t.Run("unknown role", func(t *testing.T) {
+		w := update(userID.String(), `{"role":"owner"}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("own role", func(t *testing.T) {
+		mockService.On("UpdateUserRole", admin.ID, model.RoleViewer, admin.ID).Return(nil, service.ErrOwnRole).Once()
+
+		w := update(admin.ID.String(), `{"role":"viewer"}`)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockService.On("UpdateUserRole", userID, model.RoleAdmin, admin.ID).Return(nil, service.ErrUserNotFound).Once()
+
+		w := update(userID.String(), `{"role":"admin"}`)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		w := update("not-a-uuid", `{"role":"admin"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
 }
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+)
+
+// TokenResponse represents the tokens of a login in token mode. User is
+// only set by the login itself.
+type TokenResponse struct {
+	model.TokenPair
+	User *model.User `json:"user,omitempty"`
+}
+
+// RefreshRequest represents the refresh request body
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required" example:"pX8wJ2..."`
+}
+
+// Refresh godoc
+// @Summary      Refresh tokens
+// @Description  Trade a refresh token for a new access token and a new refresh token. Every refresh token works once: presenting one again revokes every refresh token of the login, so both parties holding it have to log in again.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      RefreshRequest  true  "Refresh token"
+// @Success      200      {object}  TokenResponse
+// @Failure      400      {object}  Problem
+// @Failure      401      {object}  Problem
+// @Failure      413      {object}  Problem
+// @Failure      422      {object}  Problem
+// @Failure      500      {object}  Problem
+// @Router       /auth/refresh [post]
+func (ac *AuthController) Refresh(w http.ResponseWriter, r *http.Request) {
+	if ac.jwt == nil {
+		WriteError(w, r, service.ErrTokenModeDisabled)
+		return
+	}
+
+	var req RefreshRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	pair, err := ac.jwt.RefreshTokens(req.RefreshToken)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	RespondWithJSON(w, http.StatusOK, TokenResponse{TokenPair: *pair})
+}
+
+// JWKS godoc
+// @Summary      JSON Web Key Set
+// @Description  The public keys access tokens are signed with, for gateways verifying them on their own. Keys are identified by their RFC 7638 thumbprint, which access tokens carry in their "kid" header.
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  model.JSONWebKeySet
+// @Failure      400  {object}  Problem
+// @Router       /.well-known/jwks.json [get]
+func (ac *AuthController) JWKS(w http.ResponseWriter, r *http.Request) {
+	if ac.jwt == nil {
+		WriteError(w, r, service.ErrTokenModeDisabled)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	RespondWithJSON(w, http.StatusOK, ac.jwt.JWKS())
+}
+
+// respondWithTokens starts a login of user in token mode
+func (ac *AuthController) respondWithTokens(w http.ResponseWriter, r *http.Request, user *model.User) {
+	pair, err := ac.jwt.IssueTokens(user)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	RespondWithJSON(w, http.StatusOK, TokenResponse{TokenPair: *pair, User: user})
+}
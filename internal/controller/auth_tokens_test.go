@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockJWTService is a mock implementation of JWTServiceInterface
+type MockJWTService struct {
+	mock.Mock
+}
+
+func (m *MockJWTService) IssueTokens(user *model.User) (*model.TokenPair, error) {
+	args := m.Called(user)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TokenPair), args.Error(1)
+}
+
+func (m *MockJWTService) RefreshTokens(refreshToken string) (*model.TokenPair, error) {
+	args := m.Called(refreshToken)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TokenPair), args.Error(1)
+}
+
+func (m *MockJWTService) VerifyAccessToken(token string) (*model.AccessToken, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AccessToken), args.Error(1)
+}
+
+func (m *MockJWTService) RevokeLogin(familyID uuid.UUID) error {
+	args := m.Called(familyID)
+	return args.Error(0)
+}
+
+func (m *MockJWTService) RevokeUserLogins(userID uuid.UUID) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockJWTService) JWKS() model.JSONWebKeySet {
+	args := m.Called()
+	return args.Get(0).(model.JSONWebKeySet)
+}
+
+func newTokenModeRouter(authService service.AuthServiceInterface, opts ...AuthControllerOption) *mux.Router {
+	router := mux.NewRouter()
+	NewAuthControllerWithStore(authService, model.NewMemorySessionStore(), opts...).SetRoutes(router)
+	return router
+}
+
+func postJSON(router http.Handler, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthController_LoginTokenMode(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", Role: model.RoleOperator}
+	pair := &model.TokenPair{AccessToken: "eyJ.access.token", TokenType: "Bearer", ExpiresIn: 900, RefreshToken: "refresh", RefreshExpiresIn: 2592000}
+
+	t.Run("issues tokens instead of a session", func(t *testing.T) {
+		mockService := new(MockAuthService)
+		mockJWT := new(MockJWTService)
+		mockService.On("Login", user.Email, "password123").Return(user, nil).Once()
+		mockJWT.On("IssueTokens", user).Return(pair, nil).Once()
+
+		w := postJSON(newTokenModeRouter(mockService, WithJWTService(mockJWT)), "/auth/login",
+			`{"email":"test@example.com","password":"password123","mode":"token"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+		var response TokenResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "eyJ.access.token", response.AccessToken)
+		assert.Equal(t, "refresh", response.RefreshToken)
+		assert.Equal(t, user.ID, response.User.ID)
+		mockService.AssertExpectations(t)
+		mockJWT.AssertExpectations(t)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		mockService := new(MockAuthService)
+
+		w := postJSON(newTokenModeRouter(mockService), "/auth/login",
+			`{"email":"test@example.com","password":"password123","mode":"token"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var problem Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, "token_mode_disabled", problem.Code)
+		mockService.AssertNotCalled(t, "Login", mock.Anything, mock.Anything)
+	})
+
+	t.Run("unknown mode", func(t *testing.T) {
+		w := postJSON(newTokenModeRouter(new(MockAuthService)), "/auth/login",
+			`{"email":"test@example.com","password":"password123","mode":"cookie"}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+}
+
+func TestAuthController_Refresh(t *testing.T) {
+	t.Run("rotates", func(t *testing.T) {
+		mockJWT := new(MockJWTService)
+		mockJWT.On("RefreshTokens", "refresh").Return(&model.TokenPair{AccessToken: "new.access", RefreshToken: "next"}, nil).Once()
+
+		w := postJSON(newTokenModeRouter(new(MockAuthService), WithJWTService(mockJWT)), "/auth/refresh", `{"refresh_token":"refresh"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response TokenResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "next", response.RefreshToken)
+		assert.Nil(t, response.User)
+	})
+
+	t.Run("reused", func(t *testing.T) {
+		mockJWT := new(MockJWTService)
+		mockJWT.On("RefreshTokens", "refresh").Return(nil, service.ErrRefreshTokenReused).Once()
+
+		w := postJSON(newTokenModeRouter(new(MockAuthService), WithJWTService(mockJWT)), "/auth/refresh", `{"refresh_token":"refresh"}`)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		var problem Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, "refresh_token_reused", problem.Code)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		w := postJSON(newTokenModeRouter(new(MockAuthService), WithJWTService(new(MockJWTService))), "/auth/refresh", `{}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+}
+
+func TestAuthController_JWKS(t *testing.T) {
+	t.Run("publishes the keys", func(t *testing.T) {
+		mockJWT := new(MockJWTService)
+		mockJWT.On("JWKS").Return(model.JSONWebKeySet{Keys: []model.JSONWebKey{{KeyType: "OKP", KeyID: "kid", Use: "sig", Algorithm: "EdDSA", Curve: "Ed25519", X: "x"}}}).Once()
+
+		w := httptest.NewRecorder()
+		newTokenModeRouter(new(MockAuthService), WithJWTService(mockJWT)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"keys":[{"kty":"OKP","kid":"kid","use":"sig","alg":"EdDSA","crv":"Ed25519","x":"x"}]}`, w.Body.String())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		newTokenModeRouter(new(MockAuthService)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+)
+
+// RequirePermission wraps a route so it only runs for users whose role
+// allows permission, and, for requests made with an API token, when the
+// token was granted it as a scope. Others get a 403. It relies on the user
+// the auth middleware puts in the request context.
+func RequirePermission(permission model.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := model.UserFromContext(r.Context())
+		if user == nil {
+			WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		if !user.Can(permission) {
+			WriteError(w, r, service.ErrForbidden)
+			return
+		}
+
+		if token := model.APITokenFromContext(r.Context()); token != nil && !token.HasScope(permission) {
+			WriteError(w, r, service.ErrInsufficientScope)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// RequireSession wraps a route so API tokens cannot reach it, only users
+// who logged in. It keeps a token from minting more tokens or ending
+// sessions.
+func RequireSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if model.APITokenFromContext(r.Context()) != nil {
+			WriteError(w, r, service.ErrSessionRequired)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequirePermission(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	handler := RequirePermission(model.PermissionOperateDevices, ok)
+
+	tests := []struct {
+		name   string
+		user   *model.User
+		status int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"viewer", &model.User{ID: uuid.New(), Role: model.RoleViewer}, http.StatusForbidden},
+		{"operator", &model.User{ID: uuid.New(), Role: model.RoleOperator}, http.StatusNoContent},
+		{"admin", &model.User{ID: uuid.New(), Role: model.RoleAdmin}, http.StatusNoContent},
+		{"unknown role", &model.User{ID: uuid.New(), Role: "owner"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/devices/1/checkout", nil)
+			if tt.user != nil {
+				req = withUser(req, tt.user)
+			}
+			w := httptest.NewRecorder()
+
+			handler(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusForbidden {
+				var problem Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+				assert.Equal(t, "forbidden", problem.Code)
+			}
+		})
+	}
+}
+
+func TestRequirePermission_TokenScopes(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	handler := RequirePermission(model.PermissionOperateDevices, ok)
+	admin := &model.User{ID: uuid.New(), Role: model.RoleAdmin}
+
+	serve := func(scopes ...string) *httptest.ResponseRecorder {
+		req := withUser(httptest.NewRequest(http.MethodPost, "/api/devices/1/checkout", nil), admin)
+		token := &model.APIToken{ID: uuid.New(), UserID: admin.ID, Scopes: scopes}
+		req = req.WithContext(context.WithValue(req.Context(), model.APITokenContextKey, token))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	t.Run("scope granted", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve("devices:read", "devices:operate").Code)
+	})
+
+	t.Run("scope missing", func(t *testing.T) {
+		w := serve("devices:read")
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		var problem Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, "insufficient_scope", problem.Code)
+	})
+}
+
+func TestDeviceController_RoutePermissions(t *testing.T) {
+	mockService := new(MockDeviceService)
+	router := mux.NewRouter()
+	NewDeviceControllerWithService(mockService).SetRoutes(router)
+
+	viewer := &model.User{ID: uuid.New(), Role: model.RoleViewer}
+	operator := &model.User{ID: uuid.New(), Role: model.RoleOperator}
+
+	serve := func(method, path, body string, user *model.User) *httptest.ResponseRecorder {
+		req := withUser(httptest.NewRequest(method, path, strings.NewReader(body)), user)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", "*")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("viewers cannot create", func(t *testing.T) {
+		w := serve(http.MethodPost, "/devices", `{"name":"Pixel 8","brand":"Google"}`, viewer)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("operators cannot delete", func(t *testing.T) {
+		w := serve(http.MethodDelete, "/devices/"+uuid.NewString(), "", operator)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("viewers can read", func(t *testing.T) {
+		device := &model.Device{ID: uuid.New(), Name: "Pixel 8", Brand: "Google", State: model.StateAvailable, Version: 1}
+		mockService.On("GetDeviceByID", device.ID.String()).Return(device, nil).Once()
+
+		w := serve(http.MethodGet, "/devices/"+device.ID.String(), "", viewer)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("operators only change the state", func(t *testing.T) {
+		device := &model.Device{ID: uuid.New(), Name: "Pixel 8", Brand: "Google", State: model.StateMaintenance, Version: 2}
+		mockService.On("UpdateDevice", mock.AnythingOfType("*model.Device"), operator.ID, service.StateOnly).Return(device, nil).Once()
+		mockService.On("PatchDevice", device.ID.String(), mock.Anything, service.MergePatch, 0, operator.ID, service.StateOnly).Return(device, nil).Once()
+
+		w := serve(http.MethodPut, "/devices", `{"id":"`+device.ID.String()+`","name":"Pixel 8","brand":"Google","state":"maintenance"}`, operator)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		req := withUser(httptest.NewRequest(http.MethodPatch, "/devices/"+device.ID.String(), strings.NewReader(`{"state":"maintenance"}`)), operator)
+		req.Header.Set("Content-Type", string(service.MergePatch))
+		req.Header.Set("If-Match", "*")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("admins change every field", func(t *testing.T) {
+		admin := &model.User{ID: uuid.New(), Role: model.RoleAdmin}
+		device := &model.Device{ID: uuid.New(), Name: "Pixel 9", Brand: "Google", State: model.StateAvailable, Version: 2}
+		mockService.On("UpdateDevice", mock.AnythingOfType("*model.Device"), admin.ID, service.AllFields).Return(device, nil).Once()
+
+		w := serve(http.MethodPut, "/devices", `{"id":"`+device.ID.String()+`","name":"Pixel 9","brand":"Google","state":"available"}`, admin)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	mockService.AssertNotCalled(t, "CreateDevice", mock.Anything, mock.Anything)
+	mockService.AssertNotCalled(t, "DeleteDevice", mock.Anything, mock.Anything, mock.Anything)
+	mockService.AssertExpectations(t)
+}
+
+func TestWebhookController_RoutePermissions(t *testing.T) {
+	mockService := new(MockWebhookService)
+	router := mux.NewRouter()
+	NewWebhookControllerWithService(mockService).SetRoutes(router)
+
+	viewer := &model.User{ID: uuid.New(), Role: model.RoleViewer}
+	operator := &model.User{ID: uuid.New(), Role: model.RoleOperator}
+	admin := &model.User{ID: uuid.New(), Role: model.RoleAdmin}
+
+	serve := func(method, path, body string, user *model.User, scopes ...string) *httptest.ResponseRecorder {
+		req := withUser(httptest.NewRequest(method, path, strings.NewReader(body)), user)
+		req.Header.Set("Content-Type", "application/json")
+		if scopes != nil {
+			token := &model.APIToken{ID: uuid.New(), UserID: user.ID, Scopes: scopes}
+			req = req.WithContext(context.WithValue(req.Context(), model.APITokenContextKey, token))
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("operators cannot create", func(t *testing.T) {
+		w := serve(http.MethodPost, "/webhooks", `{"url":"https://example.com/hooks"}`, operator)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("viewers cannot delete", func(t *testing.T) {
+		w := serve(http.MethodDelete, "/webhooks/"+uuid.NewString(), "", viewer)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("read-only tokens cannot update", func(t *testing.T) {
+		w := serve(http.MethodPut, "/webhooks/"+uuid.NewString(), `{"url":"https://example.com/hooks"}`, admin, "devices:read")
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		var problem Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, "insufficient_scope", problem.Code)
+	})
+
+	t.Run("tokens without devices:read cannot list deliveries", func(t *testing.T) {
+		w := serve(http.MethodGet, "/webhooks/"+uuid.NewString()+"/deliveries", "", admin, "users:manage")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("viewers can list", func(t *testing.T) {
+		mockService.On("GetWebhooks", viewer.ID).Return([]model.Webhook{}, nil).Once()
+
+		w := serve(http.MethodGet, "/webhooks", "", viewer)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	mockService.AssertNotCalled(t, "CreateWebhook", mock.Anything)
+	mockService.AssertNotCalled(t, "UpdateWebhook", mock.Anything)
+	mockService.AssertNotCalled(t, "DeleteWebhook", mock.Anything, mock.Anything)
+	mockService.AssertNotCalled(t, "GetDeliveries", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockService.AssertExpectations(t)
+}
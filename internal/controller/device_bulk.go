@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	log "github.com/sirupsen/logrus"
+)
+
+// BulkOperationRequest is a single create, update or delete in a bulk
+// request. Updates and deletes carry the version of the device they read.
+type BulkOperationRequest struct {
+	Op      string        `json:"op" example:"update" enums:"create,update,delete"`
+	ID      string        `json:"id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Version int           `json:"version,omitempty" example:"3"`
+	Device  *model.Device `json:"device,omitempty"`
+}
+
+// BulkRequest represents a batch of device writes. Devices are validated per
+// operation so one bad item doesn't reject the whole batch.
+type BulkRequest struct {
+	Operations []BulkOperationRequest `json:"operations" binding:"required,min=1,max=1000"`
+}
+
+// BulkItemResult is the outcome of the operation at Index in the request
+type BulkItemResult struct {
+	Index  int                `json:"index" example:"0"`
+	Op     string             `json:"op" example:"update"`
+	Status int                `json:"status" example:"200"`
+	Device *model.Device      `json:"device,omitempty"`
+	Code   string             `json:"code,omitempty" example:"device_not_found"`
+	Error  string             `json:"error,omitempty" example:"device not found"`
+	Errors []model.FieldError `json:"errors,omitempty"`
+}
+
+// BulkResponse represents the per-item results of a bulk request
+type BulkResponse struct {
+	Atomic    bool             `json:"atomic" example:"false"`
+	Succeeded int              `json:"succeeded" example:"2"`
+	Failed    int              `json:"failed" example:"1"`
+	Results   []BulkItemResult `json:"results"`
+}
+
+// Helper function to get the success status of a bulk operation
+func bulkSuccessStatus(op service.BulkOperationType) int {
+	switch op {
+	case service.BulkCreate:
+		return http.StatusCreated
+	case service.BulkDelete:
+		return http.StatusNoContent
+	default:
+		return http.StatusOK
+	}
+}
+
+// BulkDevices godoc
+// @Summary      Create, update and delete devices in bulk
+// @Description  Apply a list of device operations. Each item reports its own status, aligned with the request index. Updates and deletes must carry the version of the device they were based on: an item without one reports 428, and one whose device changed since reports 412. With atomic=true the operations share one transaction: if any fails, none is applied and the others report 424.
+// @Tags         devices
+// @Accept       json
+// @Produce      json
+// @Param        atomic   query     bool         false  "Apply all operations or none"
+// @Param        request  body      BulkRequest  true   "Operations to apply"
+// @Success      207      {object}  BulkResponse
+// @Failure      400      {object}  Problem
+// @Failure      401      {object}  Problem
+// @Failure      403      {object}  Problem
+// @Failure      413      {object}  Problem
+// @Failure      422      {object}  Problem
+// @Failure      500      {object}  Problem
+// @Router       /api/devices/bulk [post]
+func (dc *DeviceController) BulkDevices(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	atomic := false
+	if str := r.URL.Query().Get("atomic"); str != "" {
+		parsed, err := strconv.ParseBool(str)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid atomic parameter. Use true or false")
+			return
+		}
+		atomic = parsed
+	}
+
+	var request BulkRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	operations := make([]service.BulkOperation, len(request.Operations))
+	for i, item := range request.Operations {
+		operations[i] = service.BulkOperation{
+			Type:    service.BulkOperationType(item.Op),
+			ID:      item.ID,
+			Version: item.Version,
+			Device:  item.Device,
+		}
+	}
+
+	results, err := dc.deviceService.BulkDevices(operations, atomic, user.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	response := BulkResponse{Atomic: atomic, Results: make([]BulkItemResult, len(results))}
+	for i, result := range results {
+		item := BulkItemResult{Index: i, Op: request.Operations[i].Op, Device: result.Device}
+		if result.Err != nil {
+			item.Status = errorStatus(result.Err)
+			item.Code = errorCode(result.Err)
+			item.Error = result.Err.Error()
+			item.Errors = fieldErrors(result.Err)
+			if item.Status == http.StatusInternalServerError {
+				log.Error("bulk operation failed. err: ", result.Err.Error())
+				item.Error = "Internal server error"
+			}
+			item.Device = nil
+			response.Failed++
+		} else {
+			item.Status = bulkSuccessStatus(operations[i].Type)
+			response.Succeeded++
+		}
+		response.Results[i] = item
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusMultiStatus)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBulkDevices_PerItemResults(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	created := &model.Device{ID: uuid.New(), Name: "Pixel 8", Brand: "Google", Version: 1}
+	deviceID := uuid.New()
+
+	mockService.On("BulkDevices", mock.MatchedBy(func(operations []service.BulkOperation) bool {
+		return len(operations) == 3 &&
+			operations[0].Type == service.BulkCreate &&
+			operations[1].Type == service.BulkUpdate && operations[1].Version == 4 &&
+			operations[2].Type == service.BulkDelete && operations[2].ID == deviceID.String()
+	}), false, user.ID).Return([]service.BulkResult{
+		{Device: created},
+		{Err: service.ErrVersionMismatch},
+		{},
+	}, nil)
+
+	body := fmt.Sprintf(`{"operations":[
+		{"op":"create","device":{"name":"Pixel 8","brand":"Google"}},
+		{"op":"update","id":"%s","version":4,"device":{"name":"Pixel 9","brand":"Google"}},
+		{"op":"delete","id":"%s","version":2}
+	]}`, deviceID, deviceID)
+	req := httptest.NewRequest("POST", "/api/devices/bulk", strings.NewReader(body))
+	req = withUser(req, user)
+	w := httptest.NewRecorder()
+
+	controller.BulkDevices(w, req)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var response BulkResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, 2, response.Succeeded)
+	assert.Equal(t, 1, response.Failed)
+	assert.Len(t, response.Results, 3)
+	assert.Equal(t, http.StatusCreated, response.Results[0].Status)
+	assert.Equal(t, created.ID, response.Results[0].Device.ID)
+	assert.Equal(t, 1, response.Results[1].Index)
+	assert.Equal(t, http.StatusPreconditionFailed, response.Results[1].Status)
+	assert.NotEmpty(t, response.Results[1].Error)
+	assert.Equal(t, http.StatusNoContent, response.Results[2].Status)
+	mockService.AssertExpectations(t)
+}
+
+func TestBulkDevices_AtomicAborted(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	mockService.On("BulkDevices", mock.Anything, true, user.ID).Return([]service.BulkResult{
+		{Err: fmt.Errorf("%w: operation 1 failed", service.ErrBulkAborted)},
+		{Err: service.ErrDeviceNameRequired},
+	}, nil)
+
+	body := `{"operations":[{"op":"create","device":{"name":"Pixel 8","brand":"Google"}},{"op":"create","device":{"brand":"Google"}}]}`
+	req := httptest.NewRequest("POST", "/api/devices/bulk?atomic=true", strings.NewReader(body))
+	req = withUser(req, user)
+	w := httptest.NewRecorder()
+
+	controller.BulkDevices(w, req)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var response BulkResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.True(t, response.Atomic)
+	assert.Equal(t, 0, response.Succeeded)
+	assert.Equal(t, http.StatusFailedDependency, response.Results[0].Status)
+	assert.Equal(t, http.StatusUnprocessableEntity, response.Results[1].Status)
+	assert.Equal(t, "device_name_required", response.Results[1].Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestBulkDevices_InvalidRequests(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	tooMany := BulkRequest{Operations: make([]BulkOperationRequest, 1001)}
+	tooManyBody, _ := json.Marshal(tooMany)
+
+	tests := []struct {
+		name   string
+		query  string
+		body   string
+		status int
+	}{
+		{"empty", "", `{"operations":[]}`, http.StatusUnprocessableEntity},
+		{"malformed", "", `{"operations":`, http.StatusBadRequest},
+		{"too many", "", string(tooManyBody), http.StatusUnprocessableEntity},
+		{"unknown field", "", `{"operations":[{"op":"delete","id":"x","force":true}]}`, http.StatusUnprocessableEntity},
+		{"bad atomic", "?atomic=maybe", `{"operations":[{"op":"delete","id":"x"}]}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockDeviceService)
+			controller := NewDeviceControllerWithService(mockService)
+
+			req := httptest.NewRequest("POST", "/api/devices/bulk"+tt.query, bytes.NewBufferString(tt.body))
+			req = withUser(req, user)
+			w := httptest.NewRecorder()
+
+			controller.BulkDevices(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockService.AssertNotCalled(t, "BulkDevices")
+		})
+	}
+}
+
+func TestBulkDevices_Unauthenticated(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	req := httptest.NewRequest("POST", "/api/devices/bulk", strings.NewReader(`{"operations":[]}`))
+	w := httptest.NewRecorder()
+
+	controller.BulkDevices(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -2,28 +2,54 @@ package controller
 
 import (
 	"encoding/json"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/loopsFreitag/DeviceRegistry/internal/model"
-	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
 	"github.com/loopsFreitag/DeviceRegistry/internal/service"
 )
 
-type DeviceController struct {
-	deviceService service.DeviceServiceInterface
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+	defaultDeviceLimit  = 50
+	maxDeviceLimit      = 200
+	defaultSearchLimit  = 20
+	maxSearchLimit      = 100
+)
+
+// DeviceSearchResponse represents ranked search results
+type DeviceSearchResponse struct {
+	Query   string                     `json:"query" example:"thinkpad x1"`
+	Results []model.DeviceSearchResult `json:"results"`
 }
 
-func NewDeviceController() *DeviceController {
-	deviceRepo := repository.NewDeviceRepository(model.DBX())
-	deviceService := service.NewDeviceService(deviceRepo)
-	return &DeviceController{
-		deviceService: deviceService,
-	}
+// DeviceListResponse represents a page of devices
+type DeviceListResponse struct {
+	Devices    []model.Device `json:"devices"`
+	NextCursor string         `json:"next_cursor,omitempty" example:"eyJzIjoibmFtZSIsInYiOiJpUGhvbmUiLCJpZCI6Ii4uLiJ9"`
+	Total      *int           `json:"total,omitempty" example:"42"`
+}
+
+// DeviceHistoryResponse represents a page of a device audit trail
+type DeviceHistoryResponse struct {
+	Events []model.DeviceEvent `json:"events"`
+	Total  int                 `json:"total" example:"42"`
+	Limit  int                 `json:"limit" example:"20"`
+	Offset int                 `json:"offset" example:"0"`
+}
+
+type DeviceController struct {
+	deviceService service.DeviceServiceInterface
 }
 
-// NewDeviceControllerWithService creates a controller with injected service (for testing)
+// NewDeviceControllerWithService creates a controller on top of the device
+// service the other APIs share
 func NewDeviceControllerWithService(deviceService service.DeviceServiceInterface) *DeviceController {
 	return &DeviceController{
 		deviceService: deviceService,
@@ -31,21 +57,25 @@ func NewDeviceControllerWithService(deviceService service.DeviceServiceInterface
 }
 
 func (dc *DeviceController) SetRoutes(r *mux.Router) {
-	r.HandleFunc("/devices", dc.CreateDevice).Methods("POST")
-	r.HandleFunc("/devices", dc.UpdateDevice).Methods("PUT")
-	r.HandleFunc("/devices", dc.GetDevices).Methods("GET")
-	r.HandleFunc("/devices/{id}", dc.GetDevice).Methods("GET")
-	r.HandleFunc("/devices/{id}", dc.DeleteDevice).Methods("DELETE")
-}
+	read := func(h http.HandlerFunc) http.HandlerFunc { return RequirePermission(model.PermissionReadDevices, h) }
+	operate := func(h http.HandlerFunc) http.HandlerFunc { return RequirePermission(model.PermissionOperateDevices, h) }
+	manage := func(h http.HandlerFunc) http.HandlerFunc { return RequirePermission(model.PermissionManageDevices, h) }
 
-// Helper function to send error responses
-func sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(ErrorResponse{
-		Status:  "error",
-		Message: message,
-	})
+	r.HandleFunc("/devices", manage(dc.CreateDevice)).Methods("POST")
+	r.HandleFunc("/devices", operate(dc.UpdateDevice)).Methods("PUT")
+	r.HandleFunc("/devices", read(dc.GetDevices)).Methods("GET")
+	r.HandleFunc("/devices/trash", read(dc.GetDeletedDevices)).Methods("GET")
+	r.HandleFunc("/devices/search", read(dc.SearchDevices)).Methods("GET")
+	r.HandleFunc("/devices/bulk", manage(dc.BulkDevices)).Methods("POST")
+	r.HandleFunc("/devices/export", read(dc.ExportDevices)).Methods("GET")
+	r.HandleFunc("/devices/import", manage(dc.ImportDevices)).Methods("POST")
+	r.HandleFunc("/devices/{id}", read(dc.GetDevice)).Methods("GET")
+	r.HandleFunc("/devices/{id}", operate(dc.PatchDevice)).Methods("PATCH")
+	r.HandleFunc("/devices/{id}", manage(dc.DeleteDevice)).Methods("DELETE")
+	r.HandleFunc("/devices/{id}/restore", manage(dc.RestoreDevice)).Methods("POST")
+	r.HandleFunc("/devices/{id}/history", read(dc.GetDeviceHistory)).Methods("GET")
+	r.HandleFunc("/devices/{id}/checkout", operate(dc.CheckoutDevice)).Methods("POST")
+	r.HandleFunc("/devices/{id}/checkin", operate(dc.CheckinDevice)).Methods("POST")
 }
 
 // CreateDevice godoc
@@ -56,22 +86,32 @@ func sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
 // @Produce      json
 // @Param        device  body      model.Device  true  "Device details"
 // @Success      201     {object}  model.Device
-// @Failure      400     {object}  ErrorResponse
+// @Failure      400     {object}  Problem
+// @Failure      401     {object}  Problem
+// @Failure      403     {object}  Problem
+// @Failure      413     {object}  Problem
+// @Failure      422     {object}  Problem
 // @Router       /api/devices [post]
 func (dc *DeviceController) CreateDevice(w http.ResponseWriter, r *http.Request) {
 	var device model.Device
 
-	if err := json.NewDecoder(r.Body).Decode(&device); err != nil {
-		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &device) {
+		return
+	}
+
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
 		return
 	}
 
-	createdDevice, err := dc.deviceService.CreateDevice(&device)
+	createdDevice, err := dc.deviceService.CreateDevice(&device, user.ID)
 	if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		WriteError(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", deviceETag(createdDevice))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(createdDevice)
@@ -79,101 +119,267 @@ func (dc *DeviceController) CreateDevice(w http.ResponseWriter, r *http.Request)
 
 // UpdateDevice godoc
 // @Summary      Update an existing device
-// @Description  Update the details of an existing device
+// @Description  Update the details of an existing device. Operators may only change the state; renaming takes the devices:manage permission (403 state_only). Devices only go in and out of in-use through checkout and checkin. If-Match must carry the device ETag (or *).
 // @Tags         devices
 // @Accept       json
 // @Produce      json
-// @Param        device  body      model.Device  true  "Updated device details"
-// @Success      200     {object}  model.Device
-// @Failure      400     {object}  ErrorResponse
-// @Failure      404     {object}  ErrorResponse
+// @Param        If-Match  header    string        true  "ETag of the device being replaced"
+// @Param        device    body      model.Device  true  "Updated device details"
+// @Success      200       {object}  model.Device
+// @Failure      400       {object}  Problem
+// @Failure      401       {object}  Problem
+// @Failure      403       {object}  Problem
+// @Failure      404       {object}  Problem
+// @Failure      409       {object}  Problem
+// @Failure      412       {object}  Problem
+// @Failure      413       {object}  Problem
+// @Failure      422       {object}  Problem
+// @Failure      428       {object}  Problem
 // @Router       /api/devices [put]
 func (dc *DeviceController) UpdateDevice(w http.ResponseWriter, r *http.Request) {
 	var device model.Device
 
-	if err := json.NewDecoder(r.Body).Decode(&device); err != nil {
-		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &device) {
+		return
+	}
+
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeIfMatchProblem(w, r, err)
 		return
 	}
+	device.Version = version
 
-	updatedDevice, err := dc.deviceService.UpdateDevice(&device)
+	updatedDevice, err := dc.deviceService.UpdateDevice(&device, user.ID, service.EditableFields(r.Context()))
 	if err != nil {
-		sendErrorResponse(w, http.StatusNotFound, err.Error())
+		WriteError(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", deviceETag(updatedDevice))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(updatedDevice)
 }
 
+// PatchDevice godoc
+// @Summary      Partially update a device
+// @Description  Apply a JSON Merge Patch (RFC 7396, application/merge-patch+json) or a JSON Patch (RFC 6902, application/json-patch+json) to a device. The in-use, state transition and state_only rules of a full update still apply. If-Match must carry the device ETag (or *).
+// @Tags         devices
+// @Accept       json
+// @Produce      json
+// @Param        id        path      string  true  "Device ID"
+// @Param        If-Match  header    string  true  "ETag of the device being patched"
+// @Param        patch     body      object  true  "Merge patch document or JSON Patch operations"
+// @Success      200       {object}  model.Device
+// @Failure      400       {object}  Problem
+// @Failure      401       {object}  Problem
+// @Failure      403       {object}  Problem
+// @Failure      404       {object}  Problem
+// @Failure      409       {object}  Problem
+// @Failure      412       {object}  Problem
+// @Failure      415       {object}  Problem
+// @Failure      422       {object}  Problem
+// @Failure      428       {object}  Problem
+// @Router       /api/devices/{id} [patch]
+func (dc *DeviceController) PatchDevice(w http.ResponseWriter, r *http.Request) {
+	id, ok := deviceID(w, r)
+	if !ok {
+		return
+	}
+
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	patchType, ok := patchTypeOf(r)
+	if !ok {
+		w.Header().Set("Accept-Patch", string(service.MergePatch)+", "+string(service.JSONPatch))
+		WriteProblem(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedMedia, "Unsupported Content-Type. Use application/merge-patch+json or application/json-patch+json")
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeIfMatchProblem(w, r, err)
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequestBody, "Invalid request body")
+		return
+	}
+
+	device, err := dc.deviceService.PatchDevice(id, patch, patchType, version, user.ID, service.EditableFields(r.Context()))
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", deviceETag(device))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(device)
+}
+
+// deviceID returns the device ID path parameter, writing a problem when it
+// is not a UUID
+func deviceID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid device ID")
+		return "", false
+	}
+	return id, true
+}
+
+// patchTypeOf picks the patch format from the request Content-Type. Plain
+// application/json is treated as a merge patch.
+func patchTypeOf(r *http.Request) (service.PatchType, bool) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "", false
+	}
+
+	switch mediaType {
+	case string(service.MergePatch), "application/json":
+		return service.MergePatch, true
+	case string(service.JSONPatch):
+		return service.JSONPatch, true
+	default:
+		return "", false
+	}
+}
+
 // GetDevices godoc
 // @Summary      Get devices
-// @Description  Retrieve devices with optional filters for brand and state
+// @Description  Retrieve a page of devices matching every given filter. brand and state take comma separated values, and brand! / state! exclude them instead (state!=inactive). Pass the returned next_cursor back as cursor to fetch the following page.
 // @Tags         devices
 // @Produce      json
-// @Param        brand  query     string  false  "Filter by brand"
-// @Param        state  query     string  false  "Filter by state (inactive, available, in-use)"
-// @Success      200  {array}   model.Device
-// @Failure      400  {object}  ErrorResponse
-// @Failure      500  {object}  ErrorResponse
+// @Param        brand            query     string  false  "Filter by brands, comma separated"
+// @Param        brand!           query     string  false  "Exclude brands, comma separated"
+// @Param        state            query     string  false  "Filter by states, comma separated (inactive, available, in-use, maintenance, lost, retired)"
+// @Param        state!           query     string  false  "Exclude states, comma separated"
+// @Param        name_prefix      query     string  false  "Case-insensitive name prefix"
+// @Param        created_after    query     string  false  "Created at or after (RFC 3339 or YYYY-MM-DD)"
+// @Param        created_before   query     string  false  "Created before (RFC 3339 or YYYY-MM-DD)"
+// @Param        updated_after    query     string  false  "Updated at or after (RFC 3339 or YYYY-MM-DD)"
+// @Param        updated_before   query     string  false  "Updated before (RFC 3339 or YYYY-MM-DD)"
+// @Param        include_deleted  query     bool    false  "Include devices in the trash"
+// @Param        sort             query     string  false  "Sort by name, brand, state, created_at or updated_at; prefix with - for descending (default -created_at)"
+// @Param        limit            query     int     false  "Page size (default 50, max 200)"
+// @Param        cursor           query     string  false  "Cursor returned by the previous page"
+// @Param        include_total    query     bool    false  "Also count every matching device"
+// @Success      200  {object}  DeviceListResponse
+// @Failure      400  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /api/devices [get]
 func (dc *DeviceController) GetDevices(w http.ResponseWriter, r *http.Request) {
-	filter := repository.DeviceFilter{}
-
-	if brand := r.URL.Query().Get("brand"); brand != "" {
-		filter.Brand = &brand
-	}
-
-	if stateParam := r.URL.Query().Get("state"); stateParam != "" {
-		var state model.DeviceState
-
-		switch stateParam {
-		case "inactive":
-			state = model.StateInactive
-		case "available":
-			state = model.StateAvailable
-		case "in-use":
-			state = model.StateInUse
-		default:
-			stateVal, parseErr := strconv.Atoi(stateParam)
-			if parseErr != nil {
-				sendErrorResponse(w, http.StatusBadRequest, "Invalid state parameter. Use: inactive, available, or in-use")
-				return
-			}
-			state = model.DeviceState(stateVal)
-		}
+	filter, err := parseDeviceFilter(r.URL.Query())
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
 
-		filter.State = &state
+	page, err := dc.deviceService.GetDevices(filter)
+	if err != nil {
+		WriteError(w, r, err)
+		return
 	}
 
-	devices, err := dc.deviceService.GetDevices(filter)
+	devices := page.Devices
+	if devices == nil {
+		devices = []model.Device{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DeviceListResponse{
+		Devices:    devices,
+		NextCursor: page.NextCursor,
+		Total:      page.Total,
+	})
+}
+
+// SearchDevices godoc
+// @Summary      Search devices
+// @Description  Full-text search across device names and brands, tolerant to typos. Results are ordered by relevance.
+// @Tags         devices
+// @Produce      json
+// @Param        q      query     string  true   "Search text"
+// @Param        limit  query     int     false  "Maximum number of results (default 20, max 100)"
+// @Success      200    {object}  DeviceSearchResponse
+// @Failure      400    {object}  Problem
+// @Failure      500    {object}  Problem
+// @Router       /api/devices/search [get]
+func (dc *DeviceController) SearchDevices(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Missing q parameter")
+		return
+	}
+
+	limit := defaultSearchLimit
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 || parsed > maxSearchLimit {
+			WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid limit parameter. Use a number between 1 and 100")
+			return
+		}
+		limit = parsed
+	}
+
+	results, err := dc.deviceService.SearchDevices(query, limit)
 	if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		WriteError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(devices)
+	json.NewEncoder(w).Encode(DeviceSearchResponse{
+		Query:   query,
+		Results: results,
+	})
 }
 
 // GetDevice godoc
 // @Summary      Get a device by ID
-// @Description  Retrieve the details of a device by its ID
+// @Description  Retrieve the details of a device by its ID. The response carries an ETag; send it back in If-None-Match to get a 304 when nothing changed.
 // @Tags         devices
 // @Produce      json
-// @Param        id   path      string  true  "Device ID"
+// @Param        id             path      string  true   "Device ID"
+// @Param        If-None-Match  header    string  false  "ETag from a previous response"
 // @Success      200  {object}  model.Device
-// @Failure      404  {object}  ErrorResponse
+// @Success      304
+// @Failure      400  {object}  Problem
+// @Failure      404  {object}  Problem
 // @Router       /api/devices/{id} [get]
 func (dc *DeviceController) GetDevice(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
+	id, ok := deviceID(w, r)
+	if !ok {
+		return
+	}
 
 	device, err := dc.deviceService.GetDeviceByID(id)
 	if err != nil {
-		sendErrorResponse(w, http.StatusNotFound, err.Error())
+		WriteError(w, r, err)
+		return
+	}
+
+	etag := deviceETag(device)
+	w.Header().Set("ETag", etag)
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -184,22 +390,227 @@ func (dc *DeviceController) GetDevice(w http.ResponseWriter, r *http.Request) {
 
 // DeleteDevice godoc
 // @Summary      Delete a device by ID
-// @Description  Delete a device using its ID
+// @Description  Move a device to the trash using its ID. If-Match must carry the device ETag (or *).
 // @Tags         devices
 // @Produce      json
-// @Param        id   path      string  true  "Device ID"
+// @Param        id        path      string  true  "Device ID"
+// @Param        If-Match  header    string  true  "ETag of the device being deleted"
 // @Success      204
-// @Failure      404  {object}  ErrorResponse
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      412  {object}  Problem
+// @Failure      428  {object}  Problem
 // @Router       /api/devices/{id} [delete]
 func (dc *DeviceController) DeleteDevice(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
+	id, ok := deviceID(w, r)
+	if !ok {
+		return
+	}
+
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeIfMatchProblem(w, r, err)
+		return
+	}
 
-	err := dc.deviceService.DeleteDevice(id)
+	err = dc.deviceService.DeleteDevice(id, version, user.ID)
 	if err != nil {
-		sendErrorResponse(w, http.StatusNotFound, err.Error())
+		WriteError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// GetDeletedDevices godoc
+// @Summary      Get deleted devices
+// @Description  Retrieve the devices currently in the trash, most recently deleted first
+// @Tags         devices
+// @Produce      json
+// @Success      200  {array}   model.Device
+// @Failure      500  {object}  Problem
+// @Router       /api/devices/trash [get]
+func (dc *DeviceController) GetDeletedDevices(w http.ResponseWriter, r *http.Request) {
+	devices, err := dc.deviceService.GetDeletedDevices()
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(devices)
+}
+
+// RestoreDevice godoc
+// @Summary      Restore a deleted device
+// @Description  Bring a device back from the trash
+// @Tags         devices
+// @Produce      json
+// @Param        id   path      string  true  "Device ID"
+// @Success      200  {object}  model.Device
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /api/devices/{id}/restore [post]
+func (dc *DeviceController) RestoreDevice(w http.ResponseWriter, r *http.Request) {
+	id, ok := deviceID(w, r)
+	if !ok {
+		return
+	}
+
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	device, err := dc.deviceService.RestoreDevice(id, user.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", deviceETag(device))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(device)
+}
+
+// GetDeviceHistory godoc
+// @Summary      Get device history
+// @Description  Retrieve the audit trail of a device, newest first
+// @Tags         devices
+// @Produce      json
+// @Param        id      path      string  true   "Device ID"
+// @Param        limit   query     int     false  "Page size (default 20, max 100)"
+// @Param        offset  query     int     false  "Number of events to skip"
+// @Success      200     {object}  DeviceHistoryResponse
+// @Failure      400     {object}  Problem
+// @Failure      500     {object}  Problem
+// @Router       /api/devices/{id}/history [get]
+func (dc *DeviceController) GetDeviceHistory(w http.ResponseWriter, r *http.Request) {
+	id, ok := deviceID(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 || parsed > maxHistoryLimit {
+			WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid limit parameter. Use a number between 1 and 100")
+			return
+		}
+		limit = parsed
+	}
+
+	offset := 0
+	if offsetParam := r.URL.Query().Get("offset"); offsetParam != "" {
+		parsed, err := strconv.Atoi(offsetParam)
+		if err != nil || parsed < 0 {
+			WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid offset parameter")
+			return
+		}
+		offset = parsed
+	}
+
+	events, total, err := dc.deviceService.GetDeviceHistory(id, limit, offset)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DeviceHistoryResponse{
+		Events: events,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// CheckoutDevice godoc
+// @Summary      Check out a device
+// @Description  Assign an available device to the authenticated user and mark it as in use
+// @Tags         devices
+// @Produce      json
+// @Param        id   path      string  true  "Device ID"
+// @Success      200  {object}  model.Assignment
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /api/devices/{id}/checkout [post]
+func (dc *DeviceController) CheckoutDevice(w http.ResponseWriter, r *http.Request) {
+	id, ok := deviceID(w, r)
+	if !ok {
+		return
+	}
+
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	assignment, err := dc.deviceService.CheckoutDevice(id, user.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(assignment)
+}
+
+// CheckinDevice godoc
+// @Summary      Check in a device
+// @Description  Release a device checked out by the authenticated user and mark it as available
+// @Tags         devices
+// @Produce      json
+// @Param        id   path      string  true  "Device ID"
+// @Success      200  {object}  model.Assignment
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /api/devices/{id}/checkin [post]
+func (dc *DeviceController) CheckinDevice(w http.ResponseWriter, r *http.Request) {
+	id, ok := deviceID(w, r)
+	if !ok {
+		return
+	}
+
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	assignment, err := dc.deviceService.CheckinDevice(id, user.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(assignment)
+}
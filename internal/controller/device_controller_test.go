@@ -3,16 +3,21 @@ package controller
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/loopsFreitag/DeviceRegistry/internal/model"
 	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -22,9 +27,12 @@ type MockDeviceService struct {
 	mock.Mock
 }
 
-func (m *MockDeviceService) GetDevices(filter repository.DeviceFilter) ([]model.Device, error) {
+func (m *MockDeviceService) GetDevices(filter repository.DeviceFilter) (*repository.DevicePage, error) {
 	args := m.Called(filter)
-	return args.Get(0).([]model.Device), args.Error(1)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.DevicePage), args.Error(1)
 }
 
 func (m *MockDeviceService) GetDeviceByID(id string) (*model.Device, error) {
@@ -35,27 +43,111 @@ func (m *MockDeviceService) GetDeviceByID(id string) (*model.Device, error) {
 	return args.Get(0).(*model.Device), args.Error(1)
 }
 
-func (m *MockDeviceService) CreateDevice(device *model.Device) (*model.Device, error) {
-	args := m.Called(device)
+func (m *MockDeviceService) SearchDevices(query string, limit int) ([]model.DeviceSearchResult, error) {
+	args := m.Called(query, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.DeviceSearchResult), args.Error(1)
+}
+
+func (m *MockDeviceService) PatchDevice(id string, patch []byte, patchType service.PatchType, version int, userID uuid.UUID, fields service.DeviceFields) (*model.Device, error) {
+	args := m.Called(id, patch, patchType, version, userID, fields)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Device), args.Error(1)
+}
+
+func (m *MockDeviceService) CreateDevice(device *model.Device, userID uuid.UUID) (*model.Device, error) {
+	args := m.Called(device, userID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.Device), args.Error(1)
 }
 
-func (m *MockDeviceService) UpdateDevice(device *model.Device) (*model.Device, error) {
-	args := m.Called(device)
+func (m *MockDeviceService) UpdateDevice(device *model.Device, userID uuid.UUID, fields service.DeviceFields) (*model.Device, error) {
+	args := m.Called(device, userID, fields)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.Device), args.Error(1)
 }
 
-func (m *MockDeviceService) DeleteDevice(id string) error {
-	args := m.Called(id)
+func (m *MockDeviceService) DeleteDevice(id string, version int, userID uuid.UUID) error {
+	args := m.Called(id, version, userID)
+	return args.Error(0)
+}
+
+func (m *MockDeviceService) GetDeletedDevices() ([]model.Device, error) {
+	args := m.Called()
+	return args.Get(0).([]model.Device), args.Error(1)
+}
+
+func (m *MockDeviceService) RestoreDevice(id string, userID uuid.UUID) (*model.Device, error) {
+	args := m.Called(id, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Device), args.Error(1)
+}
+
+func (m *MockDeviceService) PurgeDeletedDevices(olderThan time.Duration) (int64, error) {
+	args := m.Called(olderThan)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockDeviceService) GetDeviceHistory(id string, limit, offset int) ([]model.DeviceEvent, int, error) {
+	args := m.Called(id, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).([]model.DeviceEvent), args.Int(1), args.Error(2)
+}
+
+func (m *MockDeviceService) CheckoutDevice(id string, userID uuid.UUID) (*model.Assignment, error) {
+	args := m.Called(id, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Assignment), args.Error(1)
+}
+
+func (m *MockDeviceService) CheckinDevice(id string, userID uuid.UUID) (*model.Assignment, error) {
+	args := m.Called(id, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Assignment), args.Error(1)
+}
+
+func (m *MockDeviceService) BulkDevices(operations []service.BulkOperation, atomic bool, userID uuid.UUID) ([]service.BulkResult, error) {
+	args := m.Called(operations, atomic, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]service.BulkResult), args.Error(1)
+}
+
+func (m *MockDeviceService) ImportDevices(r io.Reader, format service.SpreadsheetFormat, commit bool, userID uuid.UUID) (*service.DeviceImport, error) {
+	args := m.Called(r, format, commit, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.DeviceImport), args.Error(1)
+}
+
+func (m *MockDeviceService) ExportDevices(w io.Writer, filter repository.DeviceFilter, format service.SpreadsheetFormat) error {
+	args := m.Called(w, filter, format)
 	return args.Error(0)
 }
 
+// withUser attaches an authenticated user to the request context
+func withUser(req *http.Request, user *model.User) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), model.UserContextKey, user))
+}
+
 // Test CreateDevice
 
 func TestCreateDevice_Success(t *testing.T) {
@@ -76,11 +168,12 @@ func TestCreateDevice_Success(t *testing.T) {
 		State: model.StateAvailable,
 	}
 
-	mockService.On("CreateDevice", mock.AnythingOfType("*model.Device")).Return(&responseDevice, nil)
+	mockService.On("CreateDevice", mock.AnythingOfType("*model.Device"), mock.AnythingOfType("uuid.UUID")).Return(&responseDevice, nil)
 
 	body, _ := json.Marshal(requestDevice)
 	req := httptest.NewRequest("POST", "/api/devices", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
+	req = withUser(req, &model.User{ID: uuid.New(), Email: "test@example.com"})
 	w := httptest.NewRecorder()
 
 	controller.CreateDevice(w, req)
@@ -106,10 +199,10 @@ func TestCreateDevice_InvalidJSON(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
-	var errorResp ErrorResponse
+	var errorResp Problem
 	json.Unmarshal(w.Body.Bytes(), &errorResp)
-	assert.Equal(t, "error", errorResp.Status)
-	assert.Equal(t, "Invalid request body", errorResp.Message)
+	assert.Equal(t, CodeInvalidRequestBody, errorResp.Code)
+	assert.Equal(t, "Invalid request body", errorResp.Detail)
 }
 
 func TestCreateDevice_ServiceError(t *testing.T) {
@@ -122,11 +215,12 @@ func TestCreateDevice_ServiceError(t *testing.T) {
 		State: model.StateAvailable,
 	}
 
-	mockService.On("CreateDevice", mock.AnythingOfType("*model.Device")).Return(nil, errors.New("database error"))
+	mockService.On("CreateDevice", mock.AnythingOfType("*model.Device"), mock.AnythingOfType("uuid.UUID")).Return(nil, errors.New("database error"))
 
 	body, _ := json.Marshal(requestDevice)
 	req := httptest.NewRequest("POST", "/api/devices", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
+	req = withUser(req, &model.User{ID: uuid.New(), Email: "test@example.com"})
 	w := httptest.NewRecorder()
 
 	controller.CreateDevice(w, req)
@@ -149,11 +243,13 @@ func TestUpdateDevice_Success(t *testing.T) {
 		State: model.StateInUse,
 	}
 
-	mockService.On("UpdateDevice", mock.AnythingOfType("*model.Device")).Return(&requestDevice, nil)
+	mockService.On("UpdateDevice", mock.AnythingOfType("*model.Device"), mock.AnythingOfType("uuid.UUID"), mock.Anything).Return(&requestDevice, nil)
 
 	body, _ := json.Marshal(requestDevice)
 	req := httptest.NewRequest("PUT", "/api/devices", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	req = withUser(req, &model.User{ID: uuid.New(), Email: "test@example.com"})
 	w := httptest.NewRecorder()
 
 	controller.UpdateDevice(w, req)
@@ -191,11 +287,13 @@ func TestUpdateDevice_NotFound(t *testing.T) {
 		State: model.StateAvailable,
 	}
 
-	mockService.On("UpdateDevice", mock.AnythingOfType("*model.Device")).Return(nil, errors.New("device not found"))
+	mockService.On("UpdateDevice", mock.AnythingOfType("*model.Device"), mock.AnythingOfType("uuid.UUID"), mock.Anything).Return(nil, service.ErrDeviceNotFound)
 
 	body, _ := json.Marshal(requestDevice)
 	req := httptest.NewRequest("PUT", "/api/devices", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	req = withUser(req, &model.User{ID: uuid.New(), Email: "test@example.com"})
 	w := httptest.NewRecorder()
 
 	controller.UpdateDevice(w, req)
@@ -204,6 +302,63 @@ func TestUpdateDevice_NotFound(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestUpdateDevice_Reserved(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	requestDevice := model.Device{
+		ID:    uuid.New(),
+		Name:  "Pixel 8",
+		Brand: "Google",
+		State: model.StateInUse,
+	}
+
+	mockService.On("UpdateDevice", mock.AnythingOfType("*model.Device"), mock.AnythingOfType("uuid.UUID"), mock.Anything).Return(nil, service.ErrDeviceReserved)
+
+	body, _ := json.Marshal(requestDevice)
+	req := httptest.NewRequest("PUT", "/api/devices", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	req = withUser(req, &model.User{ID: uuid.New(), Email: "test@example.com"})
+	w := httptest.NewRecorder()
+
+	controller.UpdateDevice(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateDevice_InvalidTransition(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	requestDevice := model.Device{
+		ID:    uuid.New(),
+		Name:  "ThinkPad T480",
+		Brand: "Lenovo",
+		State: model.StateInUse,
+	}
+
+	mockService.On("UpdateDevice", mock.AnythingOfType("*model.Device"), mock.AnythingOfType("uuid.UUID"), mock.Anything).
+		Return(nil, fmt.Errorf("%w: retired -> in-use", service.ErrInvalidStateTransition))
+
+	body, _ := json.Marshal(requestDevice)
+	req := httptest.NewRequest("PUT", "/api/devices", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	req = withUser(req, &model.User{ID: uuid.New(), Email: "test@example.com"})
+	w := httptest.NewRecorder()
+
+	controller.UpdateDevice(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var errorResp Problem
+	json.Unmarshal(w.Body.Bytes(), &errorResp)
+	assert.Equal(t, "invalid state transition: retired -> in-use", errorResp.Detail)
+	mockService.AssertExpectations(t)
+}
+
 // Test GetDevices
 
 func TestGetDevices_Success(t *testing.T) {
@@ -225,7 +380,7 @@ func TestGetDevices_Success(t *testing.T) {
 		},
 	}
 
-	mockService.On("GetDevices", mock.AnythingOfType("repository.DeviceFilter")).Return(devices, nil)
+	mockService.On("GetDevices", mock.AnythingOfType("repository.DeviceFilter")).Return(&repository.DevicePage{Devices: devices}, nil)
 
 	req := httptest.NewRequest("GET", "/api/devices", nil)
 	w := httptest.NewRecorder()
@@ -234,9 +389,9 @@ func TestGetDevices_Success(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var result []model.Device
+	var result DeviceListResponse
 	json.Unmarshal(w.Body.Bytes(), &result)
-	assert.Len(t, result, 2)
+	assert.Len(t, result.Devices, 2)
 	mockService.AssertExpectations(t)
 }
 
@@ -254,8 +409,8 @@ func TestGetDevices_WithBrandFilter(t *testing.T) {
 	}
 
 	mockService.On("GetDevices", mock.MatchedBy(func(filter repository.DeviceFilter) bool {
-		return filter.Brand != nil && *filter.Brand == "Apple"
-	})).Return(devices, nil)
+		return assert.ObjectsAreEqual([]string{"Apple"}, filter.Brands)
+	})).Return(&repository.DevicePage{Devices: devices}, nil)
 
 	req := httptest.NewRequest("GET", "/api/devices?brand=Apple", nil)
 	w := httptest.NewRecorder()
@@ -264,10 +419,10 @@ func TestGetDevices_WithBrandFilter(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var result []model.Device
+	var result DeviceListResponse
 	json.Unmarshal(w.Body.Bytes(), &result)
-	assert.Len(t, result, 1)
-	assert.Equal(t, "Apple", result[0].Brand)
+	assert.Len(t, result.Devices, 1)
+	assert.Equal(t, "Apple", result.Devices[0].Brand)
 	mockService.AssertExpectations(t)
 }
 
@@ -285,8 +440,8 @@ func TestGetDevices_WithStateFilter(t *testing.T) {
 	}
 
 	mockService.On("GetDevices", mock.MatchedBy(func(filter repository.DeviceFilter) bool {
-		return filter.State != nil && *filter.State == model.StateInUse
-	})).Return(devices, nil)
+		return assert.ObjectsAreEqual([]model.DeviceState{model.StateInUse}, filter.States)
+	})).Return(&repository.DevicePage{Devices: devices}, nil)
 
 	req := httptest.NewRequest("GET", "/api/devices?state=in-use", nil)
 	w := httptest.NewRecorder()
@@ -295,9 +450,26 @@ func TestGetDevices_WithStateFilter(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var result []model.Device
+	var result DeviceListResponse
 	json.Unmarshal(w.Body.Bytes(), &result)
-	assert.Len(t, result, 1)
+	assert.Len(t, result.Devices, 1)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetDevices_WithLifecycleStateFilter(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	mockService.On("GetDevices", mock.MatchedBy(func(filter repository.DeviceFilter) bool {
+		return assert.ObjectsAreEqual([]model.DeviceState{model.StateMaintenance}, filter.States)
+	})).Return(&repository.DevicePage{}, nil)
+
+	req := httptest.NewRequest("GET", "/api/devices?state=maintenance", nil)
+	w := httptest.NewRecorder()
+
+	controller.GetDevices(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
 	mockService.AssertExpectations(t)
 }
 
@@ -312,16 +484,16 @@ func TestGetDevices_InvalidStateParameter(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
-	var errorResp ErrorResponse
+	var errorResp Problem
 	json.Unmarshal(w.Body.Bytes(), &errorResp)
-	assert.Contains(t, errorResp.Message, "Invalid state parameter")
+	assert.Contains(t, errorResp.Detail, "Invalid state parameter")
 }
 
 func TestGetDevices_ServiceError(t *testing.T) {
 	mockService := new(MockDeviceService)
 	controller := NewDeviceControllerWithService(mockService)
 
-	mockService.On("GetDevices", mock.AnythingOfType("repository.DeviceFilter")).Return([]model.Device{}, errors.New("database error"))
+	mockService.On("GetDevices", mock.AnythingOfType("repository.DeviceFilter")).Return(nil, errors.New("database error"))
 
 	req := httptest.NewRequest("GET", "/api/devices", nil)
 	w := httptest.NewRecorder()
@@ -368,7 +540,7 @@ func TestGetDevice_NotFound(t *testing.T) {
 	controller := NewDeviceControllerWithService(mockService)
 
 	deviceID := uuid.New()
-	mockService.On("GetDeviceByID", deviceID.String()).Return(nil, errors.New("device not found"))
+	mockService.On("GetDeviceByID", deviceID.String()).Return(nil, service.ErrDeviceNotFound)
 
 	req := httptest.NewRequest("GET", "/api/devices/"+deviceID.String(), nil)
 	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
@@ -387,10 +559,12 @@ func TestDeleteDevice_Success(t *testing.T) {
 	controller := NewDeviceControllerWithService(mockService)
 
 	deviceID := uuid.New()
-	mockService.On("DeleteDevice", deviceID.String()).Return(nil)
+	mockService.On("DeleteDevice", deviceID.String(), 0, mock.AnythingOfType("uuid.UUID")).Return(nil)
 
 	req := httptest.NewRequest("DELETE", "/api/devices/"+deviceID.String(), nil)
+	req.Header.Set("If-Match", "*")
 	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	req = withUser(req, &model.User{ID: uuid.New(), Email: "test@example.com"})
 	w := httptest.NewRecorder()
 
 	controller.DeleteDevice(w, req)
@@ -404,10 +578,12 @@ func TestDeleteDevice_NotFound(t *testing.T) {
 	controller := NewDeviceControllerWithService(mockService)
 
 	deviceID := uuid.New()
-	mockService.On("DeleteDevice", deviceID.String()).Return(errors.New("device not found"))
+	mockService.On("DeleteDevice", deviceID.String(), 0, mock.AnythingOfType("uuid.UUID")).Return(service.ErrDeviceNotFound)
 
 	req := httptest.NewRequest("DELETE", "/api/devices/"+deviceID.String(), nil)
+	req.Header.Set("If-Match", "*")
 	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	req = withUser(req, &model.User{ID: uuid.New(), Email: "test@example.com"})
 	w := httptest.NewRecorder()
 
 	controller.DeleteDevice(w, req)
@@ -421,18 +597,794 @@ func TestDeleteDevice_InUse(t *testing.T) {
 	controller := NewDeviceControllerWithService(mockService)
 
 	deviceID := uuid.New()
-	mockService.On("DeleteDevice", deviceID.String()).Return(errors.New("cannot delete device: device is currently in use"))
+	mockService.On("DeleteDevice", deviceID.String(), 0, mock.AnythingOfType("uuid.UUID")).Return(fmt.Errorf("cannot delete device: %w", service.ErrDeviceLockedInUse))
 
 	req := httptest.NewRequest("DELETE", "/api/devices/"+deviceID.String(), nil)
+	req.Header.Set("If-Match", "*")
 	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	req = withUser(req, &model.User{ID: uuid.New(), Email: "test@example.com"})
 	w := httptest.NewRecorder()
 
 	controller.DeleteDevice(w, req)
 
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusConflict, w.Code)
 
-	var errorResp ErrorResponse
+	var errorResp Problem
 	json.Unmarshal(w.Body.Bytes(), &errorResp)
-	assert.Contains(t, errorResp.Message, "in use")
+	assert.Contains(t, errorResp.Detail, "in use")
+	mockService.AssertExpectations(t)
+}
+
+// Test GetDeviceHistory
+
+func TestGetDeviceHistory_Success(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	events := []model.DeviceEvent{
+		{ID: uuid.New(), DeviceID: deviceID, Type: model.DeviceEventStateChanged},
+	}
+
+	mockService.On("GetDeviceHistory", deviceID.String(), 10, 5).Return(events, 6, nil)
+
+	req := httptest.NewRequest("GET", "/api/devices/"+deviceID.String()+"/history?limit=10&offset=5", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	w := httptest.NewRecorder()
+
+	controller.GetDeviceHistory(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result DeviceHistoryResponse
+	json.Unmarshal(w.Body.Bytes(), &result)
+	assert.Len(t, result.Events, 1)
+	assert.Equal(t, 6, result.Total)
+	assert.Equal(t, 10, result.Limit)
+	assert.Equal(t, 5, result.Offset)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetDeviceHistory_DefaultPagination(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	mockService.On("GetDeviceHistory", deviceID.String(), defaultHistoryLimit, 0).Return([]model.DeviceEvent{}, 0, nil)
+
+	req := httptest.NewRequest("GET", "/api/devices/"+deviceID.String()+"/history", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	w := httptest.NewRecorder()
+
+	controller.GetDeviceHistory(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetDeviceHistory_InvalidLimit(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+
+	req := httptest.NewRequest("GET", "/api/devices/"+deviceID.String()+"/history?limit=1000", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	w := httptest.NewRecorder()
+
+	controller.GetDeviceHistory(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "GetDeviceHistory")
+}
+
+// Test CheckoutDevice
+
+func TestCheckoutDevice_Success(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	assignment := &model.Assignment{
+		ID:           uuid.New(),
+		DeviceID:     deviceID,
+		UserID:       user.ID,
+		CheckedOutAt: time.Now(),
+	}
+
+	mockService.On("CheckoutDevice", deviceID.String(), user.ID).Return(assignment, nil)
+
+	req := httptest.NewRequest("POST", "/api/devices/"+deviceID.String()+"/checkout", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	req = withUser(req, user)
+	w := httptest.NewRecorder()
+
+	controller.CheckoutDevice(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result model.Assignment
+	json.Unmarshal(w.Body.Bytes(), &result)
+	assert.Equal(t, user.ID, result.UserID)
+	assert.Equal(t, deviceID, result.DeviceID)
+	mockService.AssertExpectations(t)
+}
+
+func TestCheckoutDevice_AlreadyInUse(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	mockService.On("CheckoutDevice", deviceID.String(), user.ID).Return(nil, service.ErrDeviceInUse)
+
+	req := httptest.NewRequest("POST", "/api/devices/"+deviceID.String()+"/checkout", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	req = withUser(req, user)
+	w := httptest.NewRecorder()
+
+	controller.CheckoutDevice(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestCheckoutDevice_Unauthenticated(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+
+	req := httptest.NewRequest("POST", "/api/devices/"+deviceID.String()+"/checkout", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	w := httptest.NewRecorder()
+
+	controller.CheckoutDevice(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockService.AssertNotCalled(t, "CheckoutDevice")
+}
+
+// Test CheckinDevice
+
+func TestCheckinDevice_Success(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	checkedInAt := time.Now()
+	assignment := &model.Assignment{
+		ID:           uuid.New(),
+		DeviceID:     deviceID,
+		UserID:       user.ID,
+		CheckedOutAt: checkedInAt.Add(-time.Hour),
+		CheckedInAt:  &checkedInAt,
+	}
+
+	mockService.On("CheckinDevice", deviceID.String(), user.ID).Return(assignment, nil)
+
+	req := httptest.NewRequest("POST", "/api/devices/"+deviceID.String()+"/checkin", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	req = withUser(req, user)
+	w := httptest.NewRecorder()
+
+	controller.CheckinDevice(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestCheckinDevice_NotAssignee(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	mockService.On("CheckinDevice", deviceID.String(), user.ID).Return(nil, service.ErrNotAssignee)
+
+	req := httptest.NewRequest("POST", "/api/devices/"+deviceID.String()+"/checkin", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	req = withUser(req, user)
+	w := httptest.NewRecorder()
+
+	controller.CheckinDevice(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+// Test trash
+
+func TestGetDevices_IncludeDeleted(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	mockService.On("GetDevices", mock.MatchedBy(func(filter repository.DeviceFilter) bool {
+		return filter.IncludeDeleted
+	})).Return(&repository.DevicePage{}, nil)
+
+	req := httptest.NewRequest("GET", "/api/devices?include_deleted=true", nil)
+	w := httptest.NewRecorder()
+
+	controller.GetDevices(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetDevices_InvalidIncludeDeleted(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	req := httptest.NewRequest("GET", "/api/devices?include_deleted=maybe", nil)
+	w := httptest.NewRecorder()
+
+	controller.GetDevices(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "GetDevices")
+}
+
+func TestGetDeletedDevices_Success(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deletedAt := time.Now()
+	devices := []model.Device{
+		{ID: uuid.New(), Name: "Galaxy S23", Brand: "Samsung", State: model.StateInactive, DeletedAt: &deletedAt},
+	}
+
+	mockService.On("GetDeletedDevices").Return(devices, nil)
+
+	req := httptest.NewRequest("GET", "/api/devices/trash", nil)
+	w := httptest.NewRecorder()
+
+	controller.GetDeletedDevices(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []model.Device
+	json.NewDecoder(w.Body).Decode(&response)
+	assert.Len(t, response, 1)
+	assert.NotNil(t, response[0].DeletedAt)
+	mockService.AssertExpectations(t)
+}
+
+func TestRestoreDevice_Success(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	device := &model.Device{ID: deviceID, Name: "Galaxy S23", Brand: "Samsung", State: model.StateInactive}
+
+	mockService.On("RestoreDevice", deviceID.String(), user.ID).Return(device, nil)
+
+	req := httptest.NewRequest("POST", "/api/devices/"+deviceID.String()+"/restore", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	req = withUser(req, user)
+	w := httptest.NewRecorder()
+
+	controller.RestoreDevice(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestRestoreDevice_NotInTrash(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	mockService.On("RestoreDevice", deviceID.String(), user.ID).Return(nil, service.ErrDeviceNotFound)
+
+	req := httptest.NewRequest("POST", "/api/devices/"+deviceID.String()+"/restore", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	req = withUser(req, user)
+	w := httptest.NewRecorder()
+
+	controller.RestoreDevice(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestRestoreDevice_Unauthenticated(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+
+	req := httptest.NewRequest("POST", "/api/devices/"+deviceID.String()+"/restore", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	w := httptest.NewRecorder()
+
+	controller.RestoreDevice(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockService.AssertNotCalled(t, "RestoreDevice")
+}
+
+// Test pagination
+
+func TestGetDevices_DefaultPagination(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	mockService.On("GetDevices", mock.MatchedBy(func(filter repository.DeviceFilter) bool {
+		return filter.Limit == defaultDeviceLimit && filter.Cursor == "" && filter.Sort == repository.DeviceSort{} && !filter.IncludeTotal
+	})).Return(&repository.DevicePage{}, nil)
+
+	req := httptest.NewRequest("GET", "/api/devices", nil)
+	w := httptest.NewRecorder()
+
+	controller.GetDevices(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"devices": []}`, w.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func TestGetDevices_WithSortAndCursor(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	total := 12
+	page := &repository.DevicePage{
+		Devices:    []model.Device{{ID: uuid.New(), Name: "iPhone 15", Brand: "Apple"}},
+		NextCursor: "next-page",
+		Total:      &total,
+	}
+
+	mockService.On("GetDevices", mock.MatchedBy(func(filter repository.DeviceFilter) bool {
+		return filter.Sort == repository.DeviceSort{Field: "name", Descending: true} &&
+			filter.Limit == 1 && filter.Cursor == "this-page" && filter.IncludeTotal
+	})).Return(page, nil)
+
+	req := httptest.NewRequest("GET", "/api/devices?sort=-name&limit=1&cursor=this-page&include_total=true", nil)
+	w := httptest.NewRecorder()
+
+	controller.GetDevices(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result DeviceListResponse
+	json.Unmarshal(w.Body.Bytes(), &result)
+	assert.Len(t, result.Devices, 1)
+	assert.Equal(t, "next-page", result.NextCursor)
+	assert.Equal(t, 12, *result.Total)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetDevices_InvalidPaginationParameters(t *testing.T) {
+	for _, query := range []string{"sort=serial", "limit=0", "limit=201", "limit=abc", "include_total=maybe"} {
+		t.Run(query, func(t *testing.T) {
+			mockService := new(MockDeviceService)
+			controller := NewDeviceControllerWithService(mockService)
+
+			req := httptest.NewRequest("GET", "/api/devices?"+query, nil)
+			w := httptest.NewRecorder()
+
+			controller.GetDevices(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			mockService.AssertNotCalled(t, "GetDevices")
+		})
+	}
+}
+
+func TestGetDevices_InvalidCursor(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	mockService.On("GetDevices", mock.AnythingOfType("repository.DeviceFilter")).Return(nil, service.ErrInvalidCursor)
+
+	req := httptest.NewRequest("GET", "/api/devices?cursor=garbage", nil)
+	w := httptest.NewRecorder()
+
+	controller.GetDevices(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+// Test SearchDevices
+
+func TestSearchDevices_Success(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	results := []model.DeviceSearchResult{
+		{Device: model.Device{ID: uuid.New(), Name: "ThinkPad X1 Carbon", Brand: "Lenovo"}, Rank: 0.9},
+	}
+
+	mockService.On("SearchDevices", "thinkpad x1", defaultSearchLimit).Return(results, nil)
+
+	req := httptest.NewRequest("GET", "/api/devices/search?q=thinkpad+x1", nil)
+	w := httptest.NewRecorder()
+
+	controller.SearchDevices(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response DeviceSearchResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "thinkpad x1", response.Query)
+	assert.Len(t, response.Results, 1)
+	assert.Equal(t, "Lenovo", response.Results[0].Brand)
+	assert.Equal(t, 0.9, response.Results[0].Rank)
+	mockService.AssertExpectations(t)
+}
+
+func TestSearchDevices_MissingQuery(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	req := httptest.NewRequest("GET", "/api/devices/search?q=+", nil)
+	w := httptest.NewRecorder()
+
+	controller.SearchDevices(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "SearchDevices")
+}
+
+func TestSearchDevices_InvalidLimit(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	req := httptest.NewRequest("GET", "/api/devices/search?q=Lenvo&limit=500", nil)
+	w := httptest.NewRecorder()
+
+	controller.SearchDevices(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "SearchDevices")
+}
+
+// Test PatchDevice
+
+func TestPatchDevice_MergePatch(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	body := []byte(`{"state":"maintenance"}`)
+	device := &model.Device{ID: deviceID, Name: "ThinkPad X1", Brand: "Lenovo", State: model.StateMaintenance}
+
+	mockService.On("PatchDevice", deviceID.String(), body, service.MergePatch, 2, user.ID, mock.Anything).Return(device, nil)
+
+	req := httptest.NewRequest("PATCH", "/api/devices/"+deviceID.String(), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"2"`)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	req = withUser(req, user)
+	w := httptest.NewRecorder()
+
+	controller.PatchDevice(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response model.Device
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, model.StateMaintenance, response.State)
+	mockService.AssertExpectations(t)
+}
+
+func TestPatchDevice_JSONPatch(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	body := []byte(`[{"op":"replace","path":"/name","value":"ThinkPad X1 Carbon"}]`)
+
+	mockService.On("PatchDevice", deviceID.String(), body, service.JSONPatch, 2, user.ID, mock.Anything).Return(&model.Device{ID: deviceID}, nil)
+
+	req := httptest.NewRequest("PATCH", "/api/devices/"+deviceID.String(), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json-patch+json; charset=utf-8")
+	req.Header.Set("If-Match", `"2"`)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	req = withUser(req, user)
+	w := httptest.NewRecorder()
+
+	controller.PatchDevice(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestPatchDevice_UnsupportedContentType(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	req := httptest.NewRequest("PATCH", "/api/devices/"+deviceID.String(), bytes.NewReader([]byte(`state=lost`)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	req = withUser(req, user)
+	w := httptest.NewRecorder()
+
+	controller.PatchDevice(w, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Contains(t, w.Header().Get("Accept-Patch"), "application/merge-patch+json")
+	mockService.AssertNotCalled(t, "PatchDevice")
+}
+
+func TestPatchDevice_ErrorStatuses(t *testing.T) {
+	tests := map[string]struct {
+		err    error
+		status int
+	}{
+		"not found":           {service.ErrDeviceNotFound, http.StatusNotFound},
+		"invalid patch":       {fmt.Errorf("%w: id cannot be changed", service.ErrInvalidPatch), http.StatusUnprocessableEntity},
+		"rename while in use": {fmt.Errorf("cannot update name: %w", service.ErrDeviceLockedInUse), http.StatusConflict},
+		"bad transition":      {service.ErrInvalidStateTransition, http.StatusConflict},
+		"database error":      {errors.New("database error"), http.StatusInternalServerError},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockService := new(MockDeviceService)
+			controller := NewDeviceControllerWithService(mockService)
+
+			deviceID := uuid.New()
+			user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+			mockService.On("PatchDevice", deviceID.String(), mock.Anything, service.MergePatch, 2, user.ID, mock.Anything).Return(nil, tt.err)
+
+			req := httptest.NewRequest("PATCH", "/api/devices/"+deviceID.String(), bytes.NewReader([]byte(`{"name":"x"}`)))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			req.Header.Set("If-Match", `"2"`)
+			req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+			req = withUser(req, user)
+			w := httptest.NewRecorder()
+
+			controller.PatchDevice(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestPatchDevice_Unauthenticated(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+
+	req := httptest.NewRequest("PATCH", "/api/devices/"+deviceID.String(), bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"2"`)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	w := httptest.NewRecorder()
+
+	controller.PatchDevice(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// Test ETags
+
+func TestGetDevice_ETag(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	device := &model.Device{ID: deviceID, Name: "iPhone 15", Brand: "Apple", Version: 3}
+	mockService.On("GetDeviceByID", deviceID.String()).Return(device, nil)
+
+	t.Run("returns the version as ETag", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/devices/"+deviceID.String(), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+		w := httptest.NewRecorder()
+
+		controller.GetDevice(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/devices/"+deviceID.String(), nil)
+		req.Header.Set("If-None-Match", `"3"`)
+		req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+		w := httptest.NewRecorder()
+
+		controller.GetDevice(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("modified since", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/devices/"+deviceID.String(), nil)
+		req.Header.Set("If-None-Match", `"2"`)
+		req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+		w := httptest.NewRecorder()
+
+		controller.GetDevice(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestUpdateDevice_IfMatch(t *testing.T) {
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	body, _ := json.Marshal(model.Device{ID: deviceID, Name: "iPhone 15", Brand: "Apple", State: model.StateAvailable})
+
+	t.Run("missing If-Match", func(t *testing.T) {
+		mockService := new(MockDeviceService)
+		controller := NewDeviceControllerWithService(mockService)
+
+		req := httptest.NewRequest("PUT", "/api/devices", bytes.NewBuffer(body))
+		req = withUser(req, user)
+		w := httptest.NewRecorder()
+
+		controller.UpdateDevice(w, req)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+		mockService.AssertNotCalled(t, "UpdateDevice")
+	})
+
+	t.Run("passes the expected version and returns the new ETag", func(t *testing.T) {
+		mockService := new(MockDeviceService)
+		controller := NewDeviceControllerWithService(mockService)
+
+		mockService.On("UpdateDevice", mock.MatchedBy(func(device *model.Device) bool {
+			return device.Version == 4
+		}), user.ID, mock.Anything).Return(&model.Device{ID: deviceID, Version: 5}, nil)
+
+		req := httptest.NewRequest("PUT", "/api/devices", bytes.NewBuffer(body))
+		req.Header.Set("If-Match", `"4"`)
+		req = withUser(req, user)
+		w := httptest.NewRecorder()
+
+		controller.UpdateDevice(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		mockService := new(MockDeviceService)
+		controller := NewDeviceControllerWithService(mockService)
+
+		mockService.On("UpdateDevice", mock.AnythingOfType("*model.Device"), user.ID, mock.Anything).Return(nil, service.ErrVersionMismatch)
+
+		req := httptest.NewRequest("PUT", "/api/devices", bytes.NewBuffer(body))
+		req.Header.Set("If-Match", `"3"`)
+		req = withUser(req, user)
+		w := httptest.NewRecorder()
+
+		controller.UpdateDevice(w, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+}
+
+func TestPatchDevice_IfMatch(t *testing.T) {
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	t.Run("missing If-Match", func(t *testing.T) {
+		mockService := new(MockDeviceService)
+		controller := NewDeviceControllerWithService(mockService)
+
+		req := httptest.NewRequest("PATCH", "/api/devices/"+deviceID.String(), bytes.NewReader([]byte(`{"state":"lost"}`)))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+		req = withUser(req, user)
+		w := httptest.NewRecorder()
+
+		controller.PatchDevice(w, req)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+		mockService.AssertNotCalled(t, "PatchDevice")
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		mockService := new(MockDeviceService)
+		controller := NewDeviceControllerWithService(mockService)
+
+		mockService.On("PatchDevice", deviceID.String(), mock.Anything, service.MergePatch, 1, user.ID, mock.Anything).Return(nil, service.ErrVersionMismatch)
+
+		req := httptest.NewRequest("PATCH", "/api/devices/"+deviceID.String(), bytes.NewReader([]byte(`{"state":"lost"}`)))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		req.Header.Set("If-Match", `"1"`)
+		req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+		req = withUser(req, user)
+		w := httptest.NewRecorder()
+
+		controller.PatchDevice(w, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestDeleteDevice_IfMatch(t *testing.T) {
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	t.Run("missing If-Match", func(t *testing.T) {
+		mockService := new(MockDeviceService)
+		controller := NewDeviceControllerWithService(mockService)
+
+		req := httptest.NewRequest("DELETE", "/api/devices/"+deviceID.String(), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+		req = withUser(req, user)
+		w := httptest.NewRecorder()
+
+		controller.DeleteDevice(w, req)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+		mockService.AssertNotCalled(t, "DeleteDevice")
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		mockService := new(MockDeviceService)
+		controller := NewDeviceControllerWithService(mockService)
+
+		mockService.On("DeleteDevice", deviceID.String(), 2, user.ID).Return(service.ErrVersionMismatch)
+
+		req := httptest.NewRequest("DELETE", "/api/devices/"+deviceID.String(), nil)
+		req.Header.Set("If-Match", `"2"`)
+		req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+		req = withUser(req, user)
+		w := httptest.NewRecorder()
+
+		controller.DeleteDevice(w, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestDeviceRoutes_InvalidID(t *testing.T) {
+	mockService := new(MockDeviceService)
+	router := mux.NewRouter()
+	NewDeviceControllerWithService(mockService).SetRoutes(router)
+	admin := &model.User{ID: uuid.New(), Email: "admin@example.com", Role: model.RoleAdmin}
+
+	routes := []struct{ method, path string }{
+		{http.MethodGet, "/devices/not-a-uuid"},
+		{http.MethodPatch, "/devices/not-a-uuid"},
+		{http.MethodDelete, "/devices/not-a-uuid"},
+		{http.MethodPost, "/devices/not-a-uuid/restore"},
+		{http.MethodGet, "/devices/not-a-uuid/history"},
+		{http.MethodPost, "/devices/not-a-uuid/checkout"},
+		{http.MethodPost, "/devices/not-a-uuid/checkin"},
+	}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			req := withUser(httptest.NewRequest(route.method, route.path, bytes.NewBufferString(`{}`)), admin)
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			req.Header.Set("If-Match", `"1"`)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var problem Problem
+			json.Unmarshal(w.Body.Bytes(), &problem)
+			assert.Equal(t, CodeInvalidParameter, problem.Code)
+		})
+	}
+
 	mockService.AssertExpectations(t)
 }
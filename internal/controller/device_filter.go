@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+)
+
+// parseDeviceFilter builds a device filter out of the listing query string.
+// List filters accept comma separated or repeated values, and a trailing !
+// on the key negates them (state!=inactive).
+func parseDeviceFilter(query url.Values) (repository.DeviceFilter, error) {
+	filter := repository.DeviceFilter{
+		Brands:        splitValues(query["brand"]),
+		ExcludeBrands: splitValues(query["brand!"]),
+		NamePrefix:    strings.TrimSpace(query.Get("name_prefix")),
+		Limit:         defaultDeviceLimit,
+		Cursor:        query.Get("cursor"),
+	}
+
+	var err error
+	if filter.States, err = parseStates(query["state"]); err != nil {
+		return filter, err
+	}
+	if filter.ExcludeStates, err = parseStates(query["state!"]); err != nil {
+		return filter, err
+	}
+
+	if filter.CreatedAfter, err = parseTimeParam(query, "created_after"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = parseTimeParam(query, "created_before"); err != nil {
+		return filter, err
+	}
+	if filter.UpdatedAfter, err = parseTimeParam(query, "updated_after"); err != nil {
+		return filter, err
+	}
+	if filter.UpdatedBefore, err = parseTimeParam(query, "updated_before"); err != nil {
+		return filter, err
+	}
+
+	if filter.IncludeDeleted, err = parseBoolParam(query, "include_deleted"); err != nil {
+		return filter, err
+	}
+	if filter.IncludeTotal, err = parseBoolParam(query, "include_total"); err != nil {
+		return filter, err
+	}
+
+	if sortParam := query.Get("sort"); sortParam != "" {
+		filter.Sort, err = repository.ParseDeviceSort(sortParam)
+		if err != nil {
+			return filter, errors.New("Invalid sort parameter. Use: name, brand, state, created_at or updated_at, optionally prefixed with -")
+		}
+	}
+
+	if limitParam := query.Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 || parsed > maxDeviceLimit {
+			return filter, errors.New("Invalid limit parameter. Use a number between 1 and 200")
+		}
+		filter.Limit = parsed
+	}
+
+	return filter, nil
+}
+
+// splitValues flattens repeated and comma separated values, dropping blanks
+func splitValues(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+func parseStates(values []string) ([]model.DeviceState, error) {
+	var states []model.DeviceState
+	for _, value := range splitValues(values) {
+		state, err := model.ParseDeviceState(value)
+		if err != nil {
+			return nil, errors.New("Invalid state parameter. Use: inactive, available, in-use, maintenance, lost or retired")
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// parseTimeParam accepts either an RFC 3339 timestamp or a plain date
+func parseTimeParam(query url.Values, param string) (*time.Time, error) {
+	value := query.Get(param)
+	if value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			parsed = parsed.UTC()
+			return &parsed, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Invalid %s parameter. Use an RFC 3339 timestamp or a YYYY-MM-DD date", param)
+}
+
+func parseBoolParam(query url.Values, param string) (bool, error) {
+	value := query.Get(param)
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("Invalid %s parameter. Use true or false", param)
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test parseDeviceFilter
+
+func TestParseDeviceFilter_Defaults(t *testing.T) {
+	filter, err := parseDeviceFilter(url.Values{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, repository.DeviceFilter{Limit: defaultDeviceLimit}, filter)
+}
+
+func TestParseDeviceFilter_ListsAndNegation(t *testing.T) {
+	query, _ := url.ParseQuery("brand=Apple,Dell&brand=Lenovo&state!=inactive,retired&name_prefix=mac")
+
+	filter, err := parseDeviceFilter(query)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Apple", "Dell", "Lenovo"}, filter.Brands)
+	assert.Empty(t, filter.States)
+	assert.Equal(t, []model.DeviceState{model.StateInactive, model.StateRetired}, filter.ExcludeStates)
+	assert.Equal(t, "mac", filter.NamePrefix)
+}
+
+func TestParseDeviceFilter_DateRanges(t *testing.T) {
+	query, _ := url.ParseQuery("created_after=2024-01-01&updated_before=2024-03-01T12:00:00%2B02:00")
+
+	filter, err := parseDeviceFilter(query)
+
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), *filter.CreatedAfter)
+	assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), *filter.UpdatedBefore)
+	assert.Nil(t, filter.CreatedBefore)
+	assert.Nil(t, filter.UpdatedAfter)
+}
+
+func TestParseDeviceFilter_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"state=broken":            "Invalid state parameter",
+		"state!=broken":           "Invalid state parameter",
+		"created_after=yesterday": "Invalid created_after parameter",
+		"updated_before=2024-13":  "Invalid updated_before parameter",
+		"include_deleted=maybe":   "Invalid include_deleted parameter",
+	}
+
+	for raw, message := range tests {
+		t.Run(raw, func(t *testing.T) {
+			query, _ := url.ParseQuery(raw)
+
+			_, err := parseDeviceFilter(query)
+
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), message)
+		})
+	}
+}
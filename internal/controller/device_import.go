@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxImportFileSize caps the multipart bodies accepted by ImportDevices
+const MaxImportFileSize = 10 << 20
+
+// ExportDevices godoc
+// @Summary      Export devices
+// @Description  Download every device matching the listing filters as a CSV or XLSX file. limit and cursor are ignored.
+// @Tags         devices
+// @Produce      text/csv
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param        format           query     string  false  "File format"  Enums(csv, xlsx)  default(csv)
+// @Param        brand            query     string  false  "Only these brands (comma separated)"
+// @Param        brand!           query     string  false  "Exclude these brands (comma separated)"
+// @Param        state            query     string  false  "Only these states (comma separated)"
+// @Param        state!           query     string  false  "Exclude these states (comma separated)"
+// @Param        name_prefix      query     string  false  "Name starts with (case insensitive)"
+// @Param        created_after    query     string  false  "Created at or after (RFC3339 or YYYY-MM-DD)"
+// @Param        created_before   query     string  false  "Created before (RFC3339 or YYYY-MM-DD)"
+// @Param        updated_after    query     string  false  "Updated at or after (RFC3339 or YYYY-MM-DD)"
+// @Param        updated_before   query     string  false  "Updated before (RFC3339 or YYYY-MM-DD)"
+// @Param        include_deleted  query     bool    false  "Include devices in the trash"
+// @Param        sort             query     string  false  "Sort field, prefix with - for descending"  default(-created_at)
+// @Success      200              {file}    file
+// @Failure      400              {object}  Problem
+// @Failure      500              {object}  Problem
+// @Router       /api/devices/export [get]
+func (dc *DeviceController) ExportDevices(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	format := service.FormatCSV
+	if str := query.Get("format"); str != "" {
+		parsed, err := service.ParseSpreadsheetFormat(str)
+		if err != nil {
+			WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid format parameter. Use csv or xlsx")
+			return
+		}
+		format = parsed
+	}
+
+	filter, err := parseDeviceFilter(query)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("devices-%s.%s", time.Now().UTC().Format("20060102"), format)
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	// Rows are streamed as they are read, so once the export has started
+	// a failure can only be logged
+	if err := dc.deviceService.ExportDevices(w, filter, format); err != nil {
+		log.Error("device export failed. err: ", err.Error())
+	}
+}
+
+// ImportDevices godoc
+// @Summary      Import devices
+// @Description  Upload a CSV or XLSX file with name, brand and optionally state columns. By default only the column mapping, a preview and the row errors are returned. With commit=true the devices are created, all at once and only if every row is valid.
+// @Tags         devices
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        file    formData  file    true   "CSV or XLSX file"
+// @Param        format  query     string  false  "File format, detected from the file name by default"  Enums(csv, xlsx)
+// @Param        commit  query     bool    false  "Create the devices instead of only previewing them"
+// @Success      200     {object}  service.DeviceImport
+// @Success      201     {object}  service.DeviceImport
+// @Failure      400     {object}  Problem
+// @Failure      401     {object}  Problem
+// @Failure      403     {object}  Problem
+// @Failure      422     {object}  service.DeviceImport
+// @Failure      500     {object}  Problem
+// @Router       /api/devices/import [post]
+func (dc *DeviceController) ImportDevices(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	commit, err := parseBoolParam(r.URL.Query(), "commit")
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImportFileSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "A file upload in the file field of at most 10 MiB is required")
+		return
+	}
+	defer file.Close()
+
+	var format service.SpreadsheetFormat
+	if str := r.URL.Query().Get("format"); str != "" {
+		format, err = service.ParseSpreadsheetFormat(str)
+	} else {
+		format, err = service.SpreadsheetFormatFromFilename(header.Filename)
+	}
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Unsupported file format. Use csv or xlsx")
+		return
+	}
+
+	result, err := dc.deviceService.ImportDevices(file, format, commit, user.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	switch {
+	case len(result.Errors) > 0:
+		status = http.StatusUnprocessableEntity
+	case result.Committed:
+		status = http.StatusCreated
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newImportRequest builds a multipart upload of content named filename
+func newImportRequest(t *testing.T, target, filename, content string) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filename)
+	assert.NoError(t, err)
+	part.Write([]byte(content))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", target, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestExportDevices_CSV(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	mockService.On("ExportDevices", mock.Anything, mock.MatchedBy(func(filter repository.DeviceFilter) bool {
+		return len(filter.Brands) == 1 && filter.Brands[0] == "Apple"
+	}), service.FormatCSV).Run(func(args mock.Arguments) {
+		io.WriteString(args.Get(0).(io.Writer), "id,name\n")
+	}).Return(nil)
+
+	req := httptest.NewRequest("GET", "/api/devices/export?brand=Apple", nil)
+	w := httptest.NewRecorder()
+
+	controller.ExportDevices(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), ".csv")
+	assert.Equal(t, "id,name\n", w.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func TestExportDevices_InvalidParams(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	for _, query := range []string{"format=ods", "state=broken"} {
+		req := httptest.NewRequest("GET", "/api/devices/export?"+query, nil)
+		w := httptest.NewRecorder()
+
+		controller.ExportDevices(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	mockService.AssertNotCalled(t, "ExportDevices")
+}
+
+func TestImportDevices_Preview(t *testing.T) {
+	mockService := new(MockDeviceService)
+	controller := NewDeviceControllerWithService(mockService)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	preview := &service.DeviceImport{
+		Columns: []service.ImportColumn{{Index: 0, Header: "name", Field: "name"}},
+		Rows:    1,
+		Valid:   1,
+		Errors:  []service.ImportRowError{},
+	}
+
+	mockService.On("ImportDevices", mock.Anything, service.FormatCSV, false, user.ID).Return(preview, nil)
+
+	req := newImportRequest(t, "/api/devices/import", "inventory.csv", "name,brand\nPixel 8,Google\n")
+	req = withUser(req, user)
+	w := httptest.NewRecorder()
+
+	controller.ImportDevices(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result service.DeviceImport
+	json.Unmarshal(w.Body.Bytes(), &result)
+	assert.Equal(t, 1, result.Valid)
+	assert.Equal(t, "name", result.Columns[0].Field)
+	mockService.AssertExpectations(t)
+}
+
+func TestImportDevices_CommitStatuses(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	tests := []struct {
+		name     string
+		result   *service.DeviceImport
+		expected int
+	}{
+		{"created", &service.DeviceImport{Committed: true, Created: 2}, http.StatusCreated},
+		{"row errors", &service.DeviceImport{Errors: []service.ImportRowError{{Row: 2, Message: "device name cannot be empty"}}}, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockDeviceService)
+			controller := NewDeviceControllerWithService(mockService)
+
+			mockService.On("ImportDevices", mock.Anything, service.FormatXLSX, true, user.ID).Return(tt.result, nil)
+
+			req := newImportRequest(t, "/api/devices/import?commit=true", "inventory.xlsx", "...")
+			req = withUser(req, user)
+			w := httptest.NewRecorder()
+
+			controller.ImportDevices(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestImportDevices_FileErrors(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	t.Run("missing file", func(t *testing.T) {
+		controller := NewDeviceControllerWithService(new(MockDeviceService))
+
+		req := httptest.NewRequest("POST", "/api/devices/import", strings.NewReader("name,brand\n"))
+		req = withUser(req, user)
+		w := httptest.NewRecorder()
+
+		controller.ImportDevices(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("unsupported extension", func(t *testing.T) {
+		controller := NewDeviceControllerWithService(new(MockDeviceService))
+
+		req := newImportRequest(t, "/api/devices/import", "inventory.ods", "...")
+		req = withUser(req, user)
+		w := httptest.NewRecorder()
+
+		controller.ImportDevices(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("missing column", func(t *testing.T) {
+		mockService := new(MockDeviceService)
+		controller := NewDeviceControllerWithService(mockService)
+
+		mockService.On("ImportDevices", mock.Anything, service.FormatCSV, false, user.ID).
+			Return(nil, service.ErrMissingImportColumn)
+
+		req := newImportRequest(t, "/api/devices/import", "inventory.csv", "serial\nSN1\n")
+		req = withUser(req, user)
+		w := httptest.NewRecorder()
+
+		controller.ImportDevices(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+}
+
+func TestImportDevices_Unauthenticated(t *testing.T) {
+	controller := NewDeviceControllerWithService(new(MockDeviceService))
+
+	req := newImportRequest(t, "/api/devices/import", "inventory.csv", "name,brand\n")
+	w := httptest.NewRecorder()
+
+	controller.ImportDevices(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	streamReplayPageSize = 200
+	streamHeartbeat      = 15 * time.Second
+	streamRetry          = 3 * time.Second
+)
+
+type DeviceStreamController struct {
+	stream service.DeviceStreamInterface
+}
+
+func NewDeviceStreamController(stream service.DeviceStreamInterface) *DeviceStreamController {
+	return &DeviceStreamController{
+		stream: stream,
+	}
+}
+
+func (sc *DeviceStreamController) SetRoutes(r *mux.Router) {
+	r.HandleFunc("/devices/events", RequirePermission(model.PermissionReadDevices, sc.StreamDeviceEvents)).Methods("GET")
+}
+
+// StreamDeviceEvents godoc
+// @Summary      Stream device changes
+// @Description  Server-Sent Events stream of device changes made on any replica. Each event is named after the change type (created, updated, state_changed, deleted or restored), its id is the change sequence number and its data a model.DeviceChange. Reconnecting with Last-Event-ID (or last_event_id) replays the changes missed since that id. Changes are sent in sequence order, each once every change before it has committed or rolled back.
+// @Tags         devices
+// @Produce      text/event-stream
+// @Param        brand          query     string  false  "Only changes to devices of these brands (comma separated)"
+// @Param        state          query     string  false  "Only changes leaving devices in these states (comma separated)"
+// @Param        Last-Event-ID  header    int     false  "Resume after this change"
+// @Param        last_event_id  query     int     false  "Resume after this change, for clients that cannot set headers"
+// @Success      200            {object}  model.DeviceChange
+// @Failure      400            {object}  Problem
+// @Failure      401            {object}  Problem
+// @Router       /api/devices/events [get]
+func (sc *DeviceStreamController) StreamDeviceEvents(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := repository.DeviceChangeFilter{Brands: splitValues(query["brand"])}
+
+	var err error
+	if filter.States, err = parseStates(query["state"]); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
+
+	lastID, err := lastEventID(r)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
+
+	select {
+	case <-sc.stream.Ready():
+	case <-r.Context().Done():
+		return
+	}
+
+	// subscribe before catching up so nothing written meanwhile is missed.
+	// Changes arrive in sequence order, so the overlap is skipped by it.
+	sub := sc.stream.Subscribe(filter)
+	defer sc.stream.Unsubscribe(sub)
+
+	flusher := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	if err := flusher.Flush(); err != nil {
+		log.Error("device stream cannot be flushed. err: ", err.Error())
+		return
+	}
+
+	if lastID > 0 {
+		for {
+			changes, err := sc.stream.GetChangesSince(lastID, filter, streamReplayPageSize)
+			if err != nil {
+				log.Error("failed to replay device changes. err: ", err.Error())
+				return
+			}
+
+			for _, change := range changes {
+				writeDeviceChange(w, change)
+				lastID = change.Seq
+			}
+			if err := flusher.Flush(); err != nil {
+				return
+			}
+
+			if len(changes) < streamReplayPageSize {
+				break
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case change, ok := <-sub.Changes():
+			if !ok {
+				// dropped, the client reconnects and resumes from lastID
+				return
+			}
+			if change.Seq <= lastID {
+				continue
+			}
+			writeDeviceChange(w, change)
+			lastID = change.Seq
+		case <-heartbeat.C:
+			io.WriteString(w, ": heartbeat\n\n")
+		}
+
+		if err := flusher.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeDeviceChange writes change as a server-sent event
+func writeDeviceChange(w io.Writer, change model.DeviceChange) {
+	data, _ := json.Marshal(change)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.Seq, change.Type, data)
+}
+
+// lastEventID returns the change a stream resumes after, taken from the
+// Last-Event-ID header browsers send on reconnect or the last_event_id
+// parameter. Zero means no replay.
+func lastEventID(r *http.Request) (int64, error) {
+	value := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, errors.New("Invalid Last-Event-ID. Use the id of the last event received")
+	}
+
+	return id, nil
+}
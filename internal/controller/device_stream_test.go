@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeChangeLog is a change log whose latest changes may still be in flight
+type fakeChangeLog struct {
+	mu         sync.Mutex
+	changes    []model.DeviceChange
+	horizon    int64
+	inFlight   bool
+	replayed   chan struct{}
+	replayOnce sync.Once
+	since      int64
+	filter     repository.DeviceChangeFilter
+}
+
+// write hands out seq, and commits change unless it is nil
+func (f *fakeChangeLog) write(seq int64, change *model.DeviceChange) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if seq > f.horizon {
+		f.horizon = seq
+	}
+	f.inFlight = change == nil
+	if change != nil {
+		f.changes = append(f.changes, *change)
+		slices.SortFunc(f.changes, func(a, b model.DeviceChange) int { return cmp.Compare(a.Seq, b.Seq) })
+	}
+}
+
+func (f *fakeChangeLog) GetChangesSince(seq, until int64, filter repository.DeviceChangeFilter, limit int) ([]model.DeviceChange, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(filter.Brands) > 0 {
+		f.replayOnce.Do(func() {
+			f.since, f.filter = seq, filter
+			close(f.replayed)
+		})
+	}
+
+	changes := []model.DeviceChange{}
+	for _, change := range f.changes {
+		if change.Seq > seq && change.Seq <= until && filter.Matches(change) {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func (f *fakeChangeLog) GetChangeHorizon() (repository.ChangeHorizon, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return repository.ChangeHorizon{Seq: f.horizon}, nil
+}
+
+func (f *fakeChangeLog) HorizonSettled(horizon repository.ChangeHorizon) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.inFlight, nil
+}
+
+func (f *fakeChangeLog) DeleteChangesBefore(cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// fakeChangeSource announces the changes it is given until it is stopped,
+// which ends every subscription
+type fakeChangeSource struct {
+	changes chan model.DeviceChange
+	stop    chan struct{}
+}
+
+func (f *fakeChangeSource) Listen(ctx context.Context, publish func(model.DeviceChange), reset func()) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-f.stop:
+			return nil
+		case change := <-f.changes:
+			publish(change)
+		}
+	}
+}
+
+func streamChange(seq int64, eventType model.DeviceEventType) model.DeviceChange {
+	return model.DeviceChange{
+		Seq:    seq,
+		Type:   eventType,
+		Device: model.Device{ID: uuid.New(), Name: "iPhone 15", Brand: "Apple", State: model.StateAvailable},
+	}
+}
+
+// Test StreamDeviceEvents
+
+func TestStreamDeviceEvents_ResumesThenStreams(t *testing.T) {
+	created := streamChange(5, model.DeviceEventCreated)
+	changeLog := &fakeChangeLog{changes: []model.DeviceChange{created}, horizon: 5, replayed: make(chan struct{})}
+	stream := service.NewDeviceStream(changeLog)
+	controller := NewDeviceStreamController(stream)
+
+	source := &fakeChangeSource{changes: make(chan model.DeviceChange), stop: make(chan struct{})}
+	go stream.Run(context.Background(), source)
+
+	req := httptest.NewRequest("GET", "/api/devices/events?brand=Apple&state=available", nil)
+	req.Header.Set("Last-Event-ID", "4")
+	req = withUser(req, &model.User{ID: uuid.New(), Email: "test@example.com"})
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		controller.StreamDeviceEvents(w, req)
+	}()
+	<-changeLog.replayed
+
+	watcher := stream.Subscribe(repository.DeviceChangeFilter{})
+
+	// 6 is taken first but commits after 7, which must wait for it
+	updated, deleted := streamChange(6, model.DeviceEventUpdated), streamChange(7, model.DeviceEventDeleted)
+	changeLog.write(6, nil)
+	changeLog.write(7, &deleted)
+	source.changes <- deleted
+	changeLog.write(6, &updated)
+	source.changes <- updated
+
+	assert.Equal(t, int64(6), (<-watcher.Changes()).Seq)
+	assert.Equal(t, int64(7), (<-watcher.Changes()).Seq)
+	close(source.stop)
+	<-done
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, int64(4), changeLog.since)
+	assert.Equal(t, []string{"Apple"}, changeLog.filter.Brands)
+	assert.Equal(t, []model.DeviceState{model.StateAvailable}, changeLog.filter.States)
+
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, "retry: 3000\n\n"))
+	assert.Equal(t, 1, strings.Count(body, "id: 5\nevent: created\n"))
+	assert.Equal(t, 1, strings.Count(body, "id: 6\nevent: updated\n"))
+	assert.Equal(t, 1, strings.Count(body, "id: 7\nevent: deleted\n"))
+	assert.Less(t, strings.Index(body, "id: 5"), strings.Index(body, "id: 6"))
+	assert.Less(t, strings.Index(body, "id: 6"), strings.Index(body, "id: 7"))
+	assert.Contains(t, body, `data: {"seq":7,"type":"deleted","device":{`)
+}
+
+func TestStreamDeviceEvents_InvalidRequests(t *testing.T) {
+	tests := map[string]struct {
+		url         string
+		lastEventID string
+	}{
+		"bad state":         {"/api/devices/events?state=broken", ""},
+		"bad last event id": {"/api/devices/events", "abc"},
+		"negative query id": {"/api/devices/events?last_event_id=-1", ""},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			controller := NewDeviceStreamController(service.NewDeviceStream(&fakeChangeLog{}))
+
+			req := httptest.NewRequest("GET", tt.url, nil)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			req = withUser(req, &model.User{ID: uuid.New(), Email: "test@example.com"})
+			w := httptest.NewRecorder()
+
+			controller.StreamDeviceEvents(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestStreamDeviceEvents_Unauthenticated(t *testing.T) {
+	controller := NewDeviceStreamController(service.NewDeviceStream(&fakeChangeLog{}))
+
+	req := httptest.NewRequest("GET", "/api/devices/events", nil)
+	w := httptest.NewRecorder()
+
+	controller.StreamDeviceEvents(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+var (
+	errMissingIfMatch = errors.New("If-Match header is required")
+	errInvalidIfMatch = errors.New("Invalid If-Match header. Use the ETag returned by the device or *")
+)
+
+// deviceETag is the strong entity tag of a device, derived from its version
+func deviceETag(device *model.Device) string {
+	return fmt.Sprintf(`"%d"`, device.Version)
+}
+
+// ifMatchVersion returns the device version a write is conditioned on. An
+// If-Match of * accepts any version and yields 0. Weak tags never match.
+func ifMatchVersion(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return 0, errMissingIfMatch
+	}
+
+	if header == "*" {
+		return 0, nil
+	}
+
+	if len(header) < 3 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, errInvalidIfMatch
+	}
+
+	version, err := strconv.Atoi(header[1 : len(header)-1])
+	if err != nil || version < 1 {
+		return 0, errInvalidIfMatch
+	}
+
+	return version, nil
+}
+
+// writeIfMatchProblem reports an If-Match parsing error
+func writeIfMatchProblem(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errMissingIfMatch) {
+		WriteProblem(w, r, http.StatusPreconditionRequired, CodePreconditionMissing, err.Error())
+		return
+	}
+	WriteProblem(w, r, http.StatusBadRequest, CodeInvalidPrecondition, err.Error())
+}
+
+// notModified reports whether the If-None-Match header lists etag, using
+// the weak comparison the header calls for
+func notModified(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test ETag helpers
+
+func TestDeviceETag(t *testing.T) {
+	assert.Equal(t, `"7"`, deviceETag(&model.Device{Version: 7}))
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := map[string]struct {
+		header  string
+		version int
+		status  int
+	}{
+		"strong tag":    {`"4"`, 4, 0},
+		"any version":   {"*", 0, 0},
+		"missing":       {"", 0, http.StatusPreconditionRequired},
+		"weak tag":      {`W/"4"`, 0, http.StatusBadRequest},
+		"unquoted":      {"4", 0, http.StatusBadRequest},
+		"not a version": {`"abc"`, 0, http.StatusBadRequest},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/api/devices", nil)
+			if tt.header != "" {
+				req.Header.Set("If-Match", tt.header)
+			}
+
+			version, err := ifMatchVersion(req)
+
+			if tt.status == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.version, version)
+			} else {
+				assert.Error(t, err)
+				w := httptest.NewRecorder()
+				writeIfMatchProblem(w, req, err)
+				assert.Equal(t, tt.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := map[string]struct {
+		header string
+		want   bool
+	}{
+		"no header": {"", false},
+		"same tag":  {`"3"`, true},
+		"weak tag":  {`W/"3"`, true},
+		"in a list": {`"1", "3"`, true},
+		"wildcard":  {"*", true},
+		"other tag": {`"2"`, false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/devices/x", nil)
+			if tt.header != "" {
+				req.Header.Set("If-None-Match", tt.header)
+			}
+
+			assert.Equal(t, tt.want, notModified(req, `"3"`))
+		})
+	}
+}
@@ -23,12 +23,6 @@ type HealthResponse struct {
 	Message string `json:"message,omitempty" example:"Service is healthy"`
 }
 
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Status  string `json:"status" example:"error"`
-	Message string `json:"message" example:"Service unavailable"`
-}
-
 // Checker is an interface that defines a dependency service health check.
 type Checker interface {
 	// Check performs the health check and returns an error if the service is unhealthy.
@@ -104,7 +98,7 @@ func (h HealthCheck) SetRoutes(r *mux.Router) {
 // @Accept       json
 // @Produce      json
 // @Success      200  {object}  HealthResponse
-// @Failure      500  {object}  ErrorResponse
+// @Failure      500  {object}  Problem
 // @Router       /healthz [get]
 func (h HealthCheck) checkHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -121,8 +115,8 @@ func (h HealthCheck) checkHealth(w http.ResponseWriter, r *http.Request) {
 // @Accept       json
 // @Produce      json
 // @Success      200  {object}  HealthResponse
-// @Failure      500  {object}  ErrorResponse
-// @Failure      503  {object}  ErrorResponse
+// @Failure      500  {object}  Problem
+// @Failure      503  {object}  Problem
 // @Router       /readyz [get]
 func (h HealthCheck) checkReady(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -130,10 +124,7 @@ func (h HealthCheck) checkReady(w http.ResponseWriter, r *http.Request) {
 	// if the number of checkers increases, this can be further optimized to run in parallel.
 	for _, c := range h.checkers {
 		if err := c.Check(); errors.Is(err, ErrNotReady) {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			if _, err := w.Write([]byte(`{"status":"error","message":"` + err.Error() + `"}`)); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-			}
+			WriteProblem(w, r, http.StatusServiceUnavailable, CodeNotReady, err.Error())
 			return
 		}
 	}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// OIDCLoginCookieName holds the login in progress between the redirect
+	// to the identity provider and the callback
+	OIDCLoginCookieName = "oidc_login"
+	// oidcLoginDuration is how long users have to sign in at the identity
+	// provider
+	oidcLoginDuration = 10 * time.Minute
+	oidcCookiePath    = "/auth/oidc"
+)
+
+type OIDCController struct {
+	oidcService service.OIDCServiceInterface
+	sessions    model.SessionStore
+	redirectURL string
+}
+
+// NewOIDCController creates a controller sending users to redirectURL once
+// they are logged in
+func NewOIDCController(oidcService service.OIDCServiceInterface, redirectURL string) *OIDCController {
+	return NewOIDCControllerWithStore(oidcService, model.GetSessionStore(), redirectURL)
+}
+
+// NewOIDCControllerWithStore creates a controller keeping sessions in the
+// given store (for testing)
+func NewOIDCControllerWithStore(oidcService service.OIDCServiceInterface, sessions model.SessionStore, redirectURL string) *OIDCController {
+	return &OIDCController{
+		oidcService: oidcService,
+		sessions:    sessions,
+		redirectURL: redirectURL,
+	}
+}
+
+func (oc *OIDCController) SetRoutes(r *mux.Router) {
+	r.HandleFunc("/auth/oidc/login", oc.Login).Methods(http.MethodGet)
+	r.HandleFunc("/auth/oidc/callback", oc.Callback).Methods(http.MethodGet)
+}
+
+// Login godoc
+// @Summary      Single sign-on
+// @Description  Start a login at the OpenID Connect identity provider. Browsers are redirected there, and come back to /auth/oidc/callback.
+// @Tags         auth
+// @Success      302
+// @Failure      500  {object}  Problem
+// @Router       /auth/oidc/login [get]
+func (oc *OIDCController) Login(w http.ResponseWriter, r *http.Request) {
+	login, authURL, err := oc.oidcService.StartLogin(r.Context())
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	value, err := json.Marshal(login)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDCLoginCookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		Path:     oidcCookiePath,
+		HttpOnly: true,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(oidcLoginDuration.Seconds()),
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback godoc
+// @Summary      Single sign-on callback
+// @Description  Where the identity provider sends users back to. Users are created on their first login, or linked by their verified email to the account registered with it, whose password stops working. Their role follows the groups they are in. A session is opened as with /auth/login and the browser is redirected to the app.
+// @Tags         auth
+// @Param        state  query  string  true  "State of the login"
+// @Param        code   query  string  true  "Authorization code"
+// @Success      302
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /auth/oidc/callback [get]
+func (oc *OIDCController) Callback(w http.ResponseWriter, r *http.Request) {
+	login := oidcLoginFromCookie(r)
+	// the login is good for a single callback, whatever its outcome
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDCLoginCookieName,
+		Value:    "",
+		Path:     oidcCookiePath,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
+	})
+
+	query := r.URL.Query()
+	if idpError := query.Get("error"); idpError != "" {
+		log.Warnf("identity provider refused the login: %s %s", idpError, query.Get("error_description"))
+		WriteError(w, r, service.ErrOIDCLoginFailed)
+		return
+	}
+
+	user, err := oc.oidcService.FinishLogin(r.Context(), login, query.Get("state"), query.Get("code"))
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	if err := startSession(w, r, oc.sessions, user); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, oc.redirectURL, http.StatusFound)
+}
+
+// oidcLoginFromCookie reads the login in progress, if any
+func oidcLoginFromCookie(r *http.Request) *service.OIDCLogin {
+	cookie, err := r.Cookie(OIDCLoginCookieName)
+	if err != nil {
+		return nil
+	}
+
+	value, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return nil
+	}
+
+	var login service.OIDCLogin
+	if err := json.Unmarshal(value, &login); err != nil {
+		return nil
+	}
+	return &login
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockOIDCService is a mock implementation of OIDCServiceInterface
+type MockOIDCService struct {
+	mock.Mock
+}
+
+func (m *MockOIDCService) StartLogin(ctx context.Context) (*service.OIDCLogin, string, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).(*service.OIDCLogin), args.String(1), args.Error(2)
+}
+
+func (m *MockOIDCService) FinishLogin(ctx context.Context, login *service.OIDCLogin, state, code string) (*model.User, error) {
+	args := m.Called(ctx, login, state, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func findCookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestOIDCController_Flow(t *testing.T) {
+	mockService := new(MockOIDCService)
+	sessions := model.NewMemorySessionStore()
+	router := mux.NewRouter()
+	NewOIDCControllerWithStore(mockService, sessions, "/app").SetRoutes(router)
+
+	login := &service.OIDCLogin{State: "state", Nonce: "nonce", Verifier: "verifier"}
+	user := &model.User{ID: uuid.New(), Email: "alice@example.com", Role: model.RoleOperator}
+
+	mockService.On("StartLogin", mock.Anything).Return(login, "https://idp.example.com/authorize?state=state", nil).Once()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://idp.example.com/authorize?state=state", w.Header().Get("Location"))
+	loginCookie := findCookie(w, OIDCLoginCookieName)
+	require.NotNil(t, loginCookie)
+	assert.True(t, loginCookie.HttpOnly)
+	assert.Equal(t, "/auth/oidc", loginCookie.Path)
+
+	mockService.On("FinishLogin", mock.Anything, login, "state", "code").Return(user, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?state=state&code=code", nil)
+	req.AddCookie(loginCookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/app", w.Header().Get("Location"))
+
+	cleared := findCookie(w, OIDCLoginCookieName)
+	require.NotNil(t, cleared)
+	assert.Negative(t, cleared.MaxAge)
+
+	sessionCookie := findCookie(w, SessionCookieName)
+	require.NotNil(t, sessionCookie)
+	session, err := sessions.Get(sessionCookie.Value)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, session.UserID)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestOIDCController_Callback_Errors(t *testing.T) {
+	t.Run("login without cookie", func(t *testing.T) {
+		mockService := new(MockOIDCService)
+		router := mux.NewRouter()
+		NewOIDCControllerWithStore(mockService, model.NewMemorySessionStore(), "/").SetRoutes(router)
+
+		mockService.On("FinishLogin", mock.Anything, (*service.OIDCLogin)(nil), "state", "code").Return(nil, service.ErrOIDCStateMismatch).Once()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?state=state&code=code", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Nil(t, findCookie(w, SessionCookieName))
+
+		var problem Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, "invalid_oidc_state", problem.Code)
+	})
+
+	t.Run("refused by the identity provider", func(t *testing.T) {
+		mockService := new(MockOIDCService)
+		router := mux.NewRouter()
+		NewOIDCControllerWithStore(mockService, model.NewMemorySessionStore(), "/").SetRoutes(router)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?error=access_denied&state=state", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		var problem Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, "oidc_login_failed", problem.Code)
+		mockService.AssertNotCalled(t, "FinishLogin", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("no mapped group", func(t *testing.T) {
+		mockService := new(MockOIDCService)
+		router := mux.NewRouter()
+		NewOIDCControllerWithStore(mockService, model.NewMemorySessionStore(), "/").SetRoutes(router)
+
+		mockService.On("FinishLogin", mock.Anything, mock.Anything, "state", "code").Return(nil, service.ErrOIDCNoRole).Once()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?state=state&code=code", nil))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
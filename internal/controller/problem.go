@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	log "github.com/sirupsen/logrus"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Codes of the problems raised by the HTTP layer itself. Domain errors
+// carry their own code.
+const (
+	CodeInvalidRequestBody  = "invalid_request_body"
+	CodeRequestTooLarge     = "request_too_large"
+	CodeInvalidParameter    = "invalid_parameter"
+	CodeUnauthorized        = "unauthorized"
+	CodeInvalidSession      = "invalid_session"
+	CodeSessionNotFound     = "session_not_found"
+	CodeUnsupportedMedia    = "unsupported_media_type"
+	CodePreconditionMissing = "precondition_required"
+	CodeInvalidPrecondition = "invalid_precondition"
+	CodeNotReady            = "not_ready"
+	CodeInternal            = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details response. Code is a stable, machine
+// readable identifier of the problem. Errors lists the offending fields when
+// a payload fails validation.
+type Problem struct {
+	Type     string             `json:"type" example:"about:blank"`
+	Title    string             `json:"title" example:"Not Found"`
+	Status   int                `json:"status" example:"404"`
+	Detail   string             `json:"detail,omitempty" example:"device not found"`
+	Instance string             `json:"instance,omitempty" example:"/api/devices/123e4567-e89b-12d3-a456-426614174000"`
+	Code     string             `json:"code" example:"device_not_found"`
+	Errors   []model.FieldError `json:"errors,omitempty"`
+}
+
+// WriteProblem sends an application/problem+json response
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	writeProblem(w, r, status, code, detail, nil)
+}
+
+// writeProblem sends a problem along with the fields that failed validation
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string, fields []model.FieldError) {
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+		Errors: fields,
+	}
+	if r != nil {
+		problem.Instance = r.URL.Path
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// WriteError sends the problem matching a service error. Unexpected errors
+// are logged and reported as a generic 500 so internals don't leak.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var domain *service.Error
+	if !errors.As(err, &domain) {
+		log.Error("unexpected error. err: ", err.Error())
+		WriteProblem(w, r, http.StatusInternalServerError, CodeInternal, "Internal server error")
+		return
+	}
+
+	writeProblem(w, r, errorStatus(err), domain.Code, err.Error(), fieldErrors(err))
+}
+
+// errorStatus maps the kind of a service error to a status code
+func errorStatus(err error) int {
+	switch service.ErrorKindOf(err) {
+	case service.KindInvalid:
+		return http.StatusBadRequest
+	case service.KindValidation:
+		return http.StatusUnprocessableEntity
+	case service.KindUnauthorized:
+		return http.StatusUnauthorized
+	case service.KindForbidden:
+		return http.StatusForbidden
+	case service.KindNotFound:
+		return http.StatusNotFound
+	case service.KindConflict:
+		return http.StatusConflict
+	case service.KindPrecondition:
+		return http.StatusPreconditionFailed
+	case service.KindPreconditionRequired:
+		return http.StatusPreconditionRequired
+	case service.KindUnsupported:
+		return http.StatusUnsupportedMediaType
+	case service.KindAborted:
+		return http.StatusFailedDependency
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorCode is the code reported for err, as WriteError would
+func errorCode(err error) string {
+	var domain *service.Error
+	if errors.As(err, &domain) {
+		return domain.Code
+	}
+	return CodeInternal
+}
+
+// fieldErrors returns the fields listed by a validation error in err's chain
+func fieldErrors(err error) []model.FieldError {
+	var invalid *model.ValidationError
+	if errors.As(err, &invalid) {
+		return invalid.Fields
+	}
+	return nil
+}
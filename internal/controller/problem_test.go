@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteProblem(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/devices/42", nil)
+	w := httptest.NewRecorder()
+
+	WriteProblem(w, req, http.StatusBadRequest, CodeInvalidParameter, "Invalid limit parameter")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	var problem Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, Problem{
+		Type:     "about:blank",
+		Title:    "Bad Request",
+		Status:   http.StatusBadRequest,
+		Detail:   "Invalid limit parameter",
+		Instance: "/api/devices/42",
+		Code:     CodeInvalidParameter,
+	}, problem)
+}
+
+func TestWriteError(t *testing.T) {
+	tests := map[string]struct {
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		"not found":  {service.ErrDeviceNotFound, http.StatusNotFound, "device_not_found", "device not found"},
+		"validation": {service.ErrDeviceNameRequired, http.StatusUnprocessableEntity, "device_name_required", "device name cannot be empty"},
+		"conflict":   {fmt.Errorf("cannot update name: %w", service.ErrDeviceLockedInUse), http.StatusConflict, "device_locked", "cannot update name: device is currently in use"},
+		"forbidden":  {service.ErrNotAssignee, http.StatusForbidden, "not_assignee", service.ErrNotAssignee.Error()},
+		"version":    {service.ErrVersionMismatch, http.StatusPreconditionFailed, "version_mismatch", service.ErrVersionMismatch.Error()},
+		"no version": {service.ErrVersionRequired, http.StatusPreconditionRequired, "precondition_required", service.ErrVersionRequired.Error()},
+		"unexpected": {errors.New("pq: connection refused"), http.StatusInternalServerError, CodeInternal, "Internal server error"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/api/devices", nil)
+			w := httptest.NewRecorder()
+
+			WriteError(w, req, tt.err)
+
+			assert.Equal(t, tt.status, w.Code)
+
+			var problem Problem
+			json.Unmarshal(w.Body.Bytes(), &problem)
+			assert.Equal(t, tt.code, problem.Code)
+			assert.Equal(t, tt.detail, problem.Detail)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+)
+
+type ReservationController struct {
+	reservationService service.ReservationServiceInterface
+}
+
+func NewReservationController() *ReservationController {
+	reservationRepo := repository.NewReservationRepository(model.DBX())
+	reservationService := service.NewReservationService(reservationRepo)
+	return &ReservationController{
+		reservationService: reservationService,
+	}
+}
+
+// NewReservationControllerWithService creates a controller with injected service (for testing)
+func NewReservationControllerWithService(reservationService service.ReservationServiceInterface) *ReservationController {
+	return &ReservationController{
+		reservationService: reservationService,
+	}
+}
+
+func (rc *ReservationController) SetRoutes(r *mux.Router) {
+	r.HandleFunc("/devices/{id}/reservations", RequirePermission(model.PermissionOperateDevices, rc.CreateReservation)).Methods("POST")
+	r.HandleFunc("/devices/{id}/reservations", RequirePermission(model.PermissionReadDevices, rc.GetReservations)).Methods("GET")
+	r.HandleFunc("/devices/{id}/reservations/{reservationId}", RequirePermission(model.PermissionOperateDevices, rc.CancelReservation)).Methods("DELETE")
+}
+
+// ReservationRequest represents the reservation request body
+type ReservationRequest struct {
+	StartsAt time.Time `json:"starts_at" binding:"required" example:"2025-01-01T09:00:00Z"`
+	EndsAt   time.Time `json:"ends_at" binding:"required" example:"2025-01-01T17:00:00Z"`
+}
+
+// CreateReservation godoc
+// @Summary      Reserve a device
+// @Description  Book a device for the authenticated user over a time window
+// @Tags         reservations
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string              true  "Device ID"
+// @Param        request  body      ReservationRequest  true  "Reservation window"
+// @Success      201      {object}  model.Reservation
+// @Failure      400      {object}  Problem
+// @Failure      401      {object}  Problem
+// @Failure      403      {object}  Problem
+// @Failure      404      {object}  Problem
+// @Failure      409      {object}  Problem
+// @Failure      413      {object}  Problem
+// @Failure      422      {object}  Problem
+// @Failure      500      {object}  Problem
+// @Router       /api/devices/{id}/reservations [post]
+func (rc *ReservationController) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	deviceID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid device ID")
+		return
+	}
+
+	var req ReservationRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	reservation, err := rc.reservationService.CreateReservation(&model.Reservation{
+		DeviceID: deviceID,
+		UserID:   user.ID,
+		StartsAt: req.StartsAt,
+		EndsAt:   req.EndsAt,
+	})
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reservation)
+}
+
+// GetReservations godoc
+// @Summary      List device reservations
+// @Description  Retrieve the active and upcoming reservations of a device
+// @Tags         reservations
+// @Produce      json
+// @Param        id   path      string  true  "Device ID"
+// @Success      200  {array}   model.Reservation
+// @Failure      400  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /api/devices/{id}/reservations [get]
+func (rc *ReservationController) GetReservations(w http.ResponseWriter, r *http.Request) {
+	id, ok := deviceID(w, r)
+	if !ok {
+		return
+	}
+
+	reservations, err := rc.reservationService.GetReservations(id)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(reservations)
+}
+
+// CancelReservation godoc
+// @Summary      Cancel a reservation
+// @Description  Cancel a reservation owned by the authenticated user
+// @Tags         reservations
+// @Produce      json
+// @Param        id             path  string  true  "Device ID"
+// @Param        reservationId  path  string  true  "Reservation ID"
+// @Success      204
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /api/devices/{id}/reservations/{reservationId} [delete]
+func (rc *ReservationController) CancelReservation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, ok := deviceID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := uuid.Parse(vars["reservationId"]); err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid reservation ID")
+		return
+	}
+
+	err := rc.reservationService.CancelReservation(id, vars["reservationId"], user.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockReservationService is a mock implementation of the reservation service
+type MockReservationService struct {
+	mock.Mock
+}
+
+func (m *MockReservationService) GetReservations(deviceID string) ([]model.Reservation, error) {
+	args := m.Called(deviceID)
+	return args.Get(0).([]model.Reservation), args.Error(1)
+}
+
+func (m *MockReservationService) CreateReservation(reservation *model.Reservation) (*model.Reservation, error) {
+	args := m.Called(reservation)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Reservation), args.Error(1)
+}
+
+func (m *MockReservationService) CancelReservation(deviceID, id string, userID uuid.UUID) error {
+	args := m.Called(deviceID, id, userID)
+	return args.Error(0)
+}
+
+func newReservationRequest(deviceID uuid.UUID, startsAt, endsAt time.Time) *http.Request {
+	body, _ := json.Marshal(ReservationRequest{StartsAt: startsAt, EndsAt: endsAt})
+	req := httptest.NewRequest("POST", "/api/devices/"+deviceID.String()+"/reservations", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	return mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+}
+
+// Test CreateReservation
+
+func TestCreateReservation_Success(t *testing.T) {
+	mockService := new(MockReservationService)
+	controller := NewReservationControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	startsAt := time.Now().Add(time.Hour).UTC()
+	endsAt := startsAt.Add(2 * time.Hour)
+
+	mockService.On("CreateReservation", mock.MatchedBy(func(r *model.Reservation) bool {
+		return r.DeviceID == deviceID && r.UserID == user.ID
+	})).Return(&model.Reservation{
+		ID:       uuid.New(),
+		DeviceID: deviceID,
+		UserID:   user.ID,
+		StartsAt: startsAt,
+		EndsAt:   endsAt,
+	}, nil)
+
+	req := withUser(newReservationRequest(deviceID, startsAt, endsAt), user)
+	w := httptest.NewRecorder()
+
+	controller.CreateReservation(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var result model.Reservation
+	json.Unmarshal(w.Body.Bytes(), &result)
+	assert.Equal(t, deviceID, result.DeviceID)
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateReservation_Conflict(t *testing.T) {
+	mockService := new(MockReservationService)
+	controller := NewReservationControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	startsAt := time.Now().Add(time.Hour)
+
+	mockService.On("CreateReservation", mock.AnythingOfType("*model.Reservation")).
+		Return(nil, service.ErrReservationConflict)
+
+	req := withUser(newReservationRequest(deviceID, startsAt, startsAt.Add(time.Hour)), user)
+	w := httptest.NewRecorder()
+
+	controller.CreateReservation(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateReservation_InvalidWindow(t *testing.T) {
+	mockService := new(MockReservationService)
+	controller := NewReservationControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	startsAt := time.Now().Add(time.Hour)
+
+	mockService.On("CreateReservation", mock.AnythingOfType("*model.Reservation")).
+		Return(nil, service.ErrInvalidReservationWindow)
+
+	req := withUser(newReservationRequest(deviceID, startsAt, startsAt.Add(-time.Hour)), user)
+	w := httptest.NewRecorder()
+
+	controller.CreateReservation(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateReservation_InvalidDeviceID(t *testing.T) {
+	mockService := new(MockReservationService)
+	controller := NewReservationControllerWithService(mockService)
+
+	req := httptest.NewRequest("POST", "/api/devices/not-a-uuid/reservations", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "not-a-uuid"})
+	w := httptest.NewRecorder()
+
+	controller.CreateReservation(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "CreateReservation")
+}
+
+// Test GetReservations
+
+func TestGetReservations_Success(t *testing.T) {
+	mockService := new(MockReservationService)
+	controller := NewReservationControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	reservations := []model.Reservation{
+		{ID: uuid.New(), DeviceID: deviceID, UserID: uuid.New()},
+		{ID: uuid.New(), DeviceID: deviceID, UserID: uuid.New()},
+	}
+
+	mockService.On("GetReservations", deviceID.String()).Return(reservations, nil)
+
+	req := httptest.NewRequest("GET", "/api/devices/"+deviceID.String()+"/reservations", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String()})
+	w := httptest.NewRecorder()
+
+	controller.GetReservations(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result []model.Reservation
+	json.Unmarshal(w.Body.Bytes(), &result)
+	assert.Len(t, result, 2)
+	mockService.AssertExpectations(t)
+}
+
+// Test CancelReservation
+
+func TestCancelReservation_Success(t *testing.T) {
+	mockService := new(MockReservationService)
+	controller := NewReservationControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	reservationID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	mockService.On("CancelReservation", deviceID.String(), reservationID.String(), user.ID).Return(nil)
+
+	req := httptest.NewRequest("DELETE", "/api/devices/"+deviceID.String()+"/reservations/"+reservationID.String(), nil)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String(), "reservationId": reservationID.String()})
+	req = withUser(req, user)
+	w := httptest.NewRecorder()
+
+	controller.CancelReservation(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestCancelReservation_NotOwner(t *testing.T) {
+	mockService := new(MockReservationService)
+	controller := NewReservationControllerWithService(mockService)
+
+	deviceID := uuid.New()
+	reservationID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	mockService.On("CancelReservation", deviceID.String(), reservationID.String(), user.ID).
+		Return(service.ErrNotReservationOwner)
+
+	req := httptest.NewRequest("DELETE", "/api/devices/"+deviceID.String()+"/reservations/"+reservationID.String(), nil)
+	req = mux.SetURLVars(req, map[string]string{"id": deviceID.String(), "reservationId": reservationID.String()})
+	req = withUser(req, user)
+	w := httptest.NewRecorder()
+
+	controller.CancelReservation(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+)
+
+// MaxRequestBodySize caps the JSON bodies decoded by decodeJSON
+const MaxRequestBodySize = 1 << 20
+
+// decodeJSON decodes the JSON body of r into dst and validates it against its
+// binding tags. Unknown fields, trailing data and bodies over
+// MaxRequestBodySize are rejected. When it returns false the problem has
+// already been written.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestBodySize))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err == nil && decoder.Decode(&json.RawMessage{}) != io.EOF {
+		err = errors.New("unexpected data after the JSON body")
+	}
+	if err == nil {
+		err = model.Validate(dst)
+	}
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		WriteProblem(w, r, http.StatusRequestEntityTooLarge, CodeRequestTooLarge,
+			fmt.Sprintf("Request body must not exceed %d bytes", MaxRequestBodySize))
+		return false
+	}
+
+	invalid := decodeFieldError(err)
+	if invalid == nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequestBody, "Invalid request body")
+		return false
+	}
+
+	writeProblem(w, r, http.StatusUnprocessableEntity, service.ErrValidationFailed.Code, invalid.Error(), invalid.Fields)
+	return false
+}
+
+// decodeFieldError returns the field level form of a decoding or validation
+// error, or nil when the body is not usable JSON at all
+func decodeFieldError(err error) *model.ValidationError {
+	var invalid *model.ValidationError
+	if errors.As(err, &invalid) {
+		return invalid
+	}
+
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field = strings.Trim(field, `"`)
+		return &model.ValidationError{Fields: []model.FieldError{
+			{Field: field, Rule: "unknown", Message: field + " is not allowed"},
+		}}
+	}
+
+	if errors.Is(err, model.ErrInvalidDeviceState) {
+		return &model.ValidationError{Fields: []model.FieldError{
+			{Field: "state", Rule: "device_state", Message: err.Error()},
+		}}
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &model.ValidationError{Fields: []model.FieldError{
+			{Field: typeErr.Field, Rule: "type", Message: fmt.Sprintf("%s must be a %s", typeErr.Field, typeErr.Type)},
+		}}
+	}
+
+	return nil
+}
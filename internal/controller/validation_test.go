@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test request decoding and validation
+
+func TestDecodeJSON_Valid(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/devices", strings.NewReader(`{"name":"iPhone 15","brand":"Apple","state":"available"}`))
+	w := httptest.NewRecorder()
+
+	var device model.Device
+	assert.True(t, decodeJSON(w, req, &device))
+	assert.Equal(t, "iPhone 15", device.Name)
+	assert.Equal(t, model.StateAvailable, device.State)
+}
+
+func TestDecodeJSON_FieldErrors(t *testing.T) {
+	long := strings.Repeat("a", 256)
+
+	tests := map[string]struct {
+		body   string
+		fields []model.FieldError
+	}{
+		"missing fields": {`{"state":1}`, []model.FieldError{
+			{Field: "name", Rule: "required", Message: "name is required"},
+			{Field: "brand", Rule: "required", Message: "brand is required"},
+		}},
+		"too long": {`{"name":"` + long + `","brand":"Apple"}`, []model.FieldError{
+			{Field: "name", Rule: "max", Message: "name must be at most 255 characters long"},
+		}},
+		"state out of range": {`{"name":"iPhone 15","brand":"Apple","state":9}`, []model.FieldError{
+			{Field: "state", Rule: "device_state", Message: "state must be one of inactive, available, in-use, maintenance, lost, retired"},
+		}},
+		"unknown state": {`{"name":"iPhone 15","brand":"Apple","state":"broken"}`, []model.FieldError{
+			{Field: "state", Rule: "device_state", Message: "invalid device state: broken"},
+		}},
+		"unknown field": {`{"name":"iPhone 15","brand":"Apple","colour":"black"}`, []model.FieldError{
+			{Field: "colour", Rule: "unknown", Message: "colour is not allowed"},
+		}},
+		"wrong type": {`{"name":15,"brand":"Apple"}`, []model.FieldError{
+			{Field: "name", Rule: "type", Message: "name must be a string"},
+		}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/devices", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			var device model.Device
+			assert.False(t, decodeJSON(w, req, &device))
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+			assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+
+			var problem Problem
+			json.Unmarshal(w.Body.Bytes(), &problem)
+			assert.Equal(t, "validation_failed", problem.Code)
+			assert.Equal(t, tt.fields, problem.Errors)
+		})
+	}
+}
+
+func TestDecodeJSON_Malformed(t *testing.T) {
+	tests := map[string]string{
+		"not json":      `name=iPhone`,
+		"trailing data": `{"name":"iPhone 15","brand":"Apple"} {}`,
+		"empty":         ``,
+	}
+
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/devices", strings.NewReader(body))
+			w := httptest.NewRecorder()
+
+			var device model.Device
+			assert.False(t, decodeJSON(w, req, &device))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestDecodeJSON_TooLarge(t *testing.T) {
+	body := `{"name":"` + strings.Repeat("a", MaxRequestBodySize) + `","brand":"Apple"}`
+	req := httptest.NewRequest("POST", "/api/devices", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	var device model.Device
+	assert.False(t, decodeJSON(w, req, &device))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	var problem Problem
+	json.Unmarshal(w.Body.Bytes(), &problem)
+	assert.Equal(t, CodeRequestTooLarge, problem.Code)
+}
@@ -0,0 +1,330 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+)
+
+type WebhookController struct {
+	webhookService service.WebhookServiceInterface
+}
+
+func NewWebhookController() *WebhookController {
+	webhookRepo := repository.NewWebhookRepository(model.DBX())
+	return &WebhookController{
+		webhookService: service.NewWebhookService(webhookRepo),
+	}
+}
+
+// NewWebhookControllerWithService creates a controller with injected service (for testing)
+func NewWebhookControllerWithService(webhookService service.WebhookServiceInterface) *WebhookController {
+	return &WebhookController{
+		webhookService: webhookService,
+	}
+}
+
+// SetRoutes registers the webhook routes. Webhooks receive device events, so
+// reading them needs the devices:read permission and managing them
+// devices:manage.
+func (wc *WebhookController) SetRoutes(r *mux.Router) {
+	read := func(h http.HandlerFunc) http.HandlerFunc { return RequirePermission(model.PermissionReadDevices, h) }
+	manage := func(h http.HandlerFunc) http.HandlerFunc { return RequirePermission(model.PermissionManageDevices, h) }
+
+	r.HandleFunc("/webhooks", manage(wc.CreateWebhook)).Methods("POST")
+	r.HandleFunc("/webhooks", read(wc.GetWebhooks)).Methods("GET")
+	r.HandleFunc("/webhooks/{id}", read(wc.GetWebhook)).Methods("GET")
+	r.HandleFunc("/webhooks/{id}", manage(wc.UpdateWebhook)).Methods("PUT")
+	r.HandleFunc("/webhooks/{id}", manage(wc.DeleteWebhook)).Methods("DELETE")
+	r.HandleFunc("/webhooks/{id}/deliveries", read(wc.GetDeliveries)).Methods("GET")
+}
+
+// WebhookRequest represents the webhook request body. An empty event_types
+// list subscribes to every event type; active defaults to true.
+type WebhookRequest struct {
+	URL        string   `json:"url" binding:"required,max=2048" example:"https://tickets.example.com/hooks/devices"`
+	EventTypes []string `json:"event_types" binding:"dive,oneof=created updated state_changed deleted restored" example:"state_changed"`
+	Active     *bool    `json:"active,omitempty" example:"true"`
+}
+
+// WebhookDeliveriesResponse represents a page of a webhook delivery log
+type WebhookDeliveriesResponse struct {
+	Deliveries []model.WebhookDelivery `json:"deliveries"`
+	Total      int                     `json:"total" example:"42"`
+	Limit      int                     `json:"limit" example:"20"`
+	Offset     int                     `json:"offset" example:"0"`
+}
+
+// webhook builds the webhook described by the request
+func (req WebhookRequest) webhook(userID uuid.UUID) *model.Webhook {
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	eventTypes := req.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+
+	return &model.Webhook{
+		UserID:     userID,
+		URL:        req.URL,
+		EventTypes: eventTypes,
+		Active:     active,
+	}
+}
+
+// CreateWebhook godoc
+// @Summary      Register a webhook
+// @Description  Register an endpoint device events are posted to. The response carries the secret payloads are signed with; it is not shown again.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        request  body      WebhookRequest  true  "Webhook details"
+// @Success      201      {object}  model.Webhook
+// @Failure      400      {object}  Problem
+// @Failure      401      {object}  Problem
+// @Failure      403      {object}  Problem
+// @Failure      413      {object}  Problem
+// @Failure      422      {object}  Problem
+// @Failure      500      {object}  Problem
+// @Router       /api/webhooks [post]
+func (wc *WebhookController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req WebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	webhook, err := wc.webhookService.CreateWebhook(req.webhook(user.ID))
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusCreated, webhook)
+}
+
+// GetWebhooks godoc
+// @Summary      List webhooks
+// @Description  List the webhooks of the authenticated user
+// @Tags         webhooks
+// @Produce      json
+// @Success      200  {array}   model.Webhook
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /api/webhooks [get]
+func (wc *WebhookController) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	webhooks, err := wc.webhookService.GetWebhooks(user.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, webhooks)
+}
+
+// GetWebhook godoc
+// @Summary      Get a webhook
+// @Description  Retrieve a webhook of the authenticated user
+// @Tags         webhooks
+// @Produce      json
+// @Param        id   path      string  true  "Webhook ID"
+// @Success      200  {object}  model.Webhook
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /api/webhooks/{id} [get]
+func (wc *WebhookController) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	webhook, err := wc.webhookService.GetWebhook(id.String(), user.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, webhook)
+}
+
+// UpdateWebhook godoc
+// @Summary      Update a webhook
+// @Description  Replace the url, event types and active flag of a webhook. The secret is kept.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string          true  "Webhook ID"
+// @Param        request  body      WebhookRequest  true  "Webhook details"
+// @Success      200      {object}  model.Webhook
+// @Failure      400      {object}  Problem
+// @Failure      401      {object}  Problem
+// @Failure      403      {object}  Problem
+// @Failure      404      {object}  Problem
+// @Failure      413      {object}  Problem
+// @Failure      422      {object}  Problem
+// @Failure      500      {object}  Problem
+// @Router       /api/webhooks/{id} [put]
+func (wc *WebhookController) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	var req WebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	webhook := req.webhook(user.ID)
+	webhook.ID = id
+
+	updated, err := wc.webhookService.UpdateWebhook(webhook)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, updated)
+}
+
+// DeleteWebhook godoc
+// @Summary      Delete a webhook
+// @Description  Remove a webhook along with its delivery log
+// @Tags         webhooks
+// @Param        id  path  string  true  "Webhook ID"
+// @Success      204
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /api/webhooks/{id} [delete]
+func (wc *WebhookController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := wc.webhookService.DeleteWebhook(id.String(), user.ID); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries godoc
+// @Summary      Get webhook deliveries
+// @Description  Retrieve the delivery log of a webhook, newest first. Failed deliveries are retried with exponential backoff and end up dead after the last attempt.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id      path      string  true   "Webhook ID"
+// @Param        limit   query     int     false  "Page size (default 20, max 100)"
+// @Param        offset  query     int     false  "Number of deliveries to skip"
+// @Success      200     {object}  WebhookDeliveriesResponse
+// @Failure      400     {object}  Problem
+// @Failure      401     {object}  Problem
+// @Failure      403     {object}  Problem
+// @Failure      404     {object}  Problem
+// @Failure      500     {object}  Problem
+// @Router       /api/webhooks/{id}/deliveries [get]
+func (wc *WebhookController) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	user := model.UserFromContext(r.Context())
+	if user == nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 || parsed > maxHistoryLimit {
+			WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid limit parameter. Use a number between 1 and 100")
+			return
+		}
+		limit = parsed
+	}
+
+	offset := 0
+	if offsetParam := r.URL.Query().Get("offset"); offsetParam != "" {
+		parsed, err := strconv.Atoi(offsetParam)
+		if err != nil || parsed < 0 {
+			WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid offset parameter")
+			return
+		}
+		offset = parsed
+	}
+
+	deliveries, total, err := wc.webhookService.GetDeliveries(id.String(), user.ID, limit, offset)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(WebhookDeliveriesResponse{
+		Deliveries: deliveries,
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+	})
+}
+
+// webhookID parses the webhook ID path parameter, writing a problem when it
+// is not a UUID
+func webhookID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid webhook ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,250 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockWebhookService is a mock implementation of the webhook service
+type MockWebhookService struct {
+	mock.Mock
+}
+
+func (m *MockWebhookService) GetWebhooks(userID uuid.UUID) ([]model.Webhook, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) GetWebhook(id string, userID uuid.UUID) (*model.Webhook, error) {
+	args := m.Called(id, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) CreateWebhook(webhook *model.Webhook) (*model.Webhook, error) {
+	args := m.Called(webhook)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) UpdateWebhook(webhook *model.Webhook) (*model.Webhook, error) {
+	args := m.Called(webhook)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) DeleteWebhook(id string, userID uuid.UUID) error {
+	args := m.Called(id, userID)
+	return args.Error(0)
+}
+
+func (m *MockWebhookService) GetDeliveries(webhookID string, userID uuid.UUID, limit, offset int) ([]model.WebhookDelivery, int, error) {
+	args := m.Called(webhookID, userID, limit, offset)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]model.WebhookDelivery), args.Int(1), args.Error(2)
+}
+
+func TestCreateWebhook_Success(t *testing.T) {
+	mockService := new(MockWebhookService)
+	controller := NewWebhookControllerWithService(mockService)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	mockService.On("CreateWebhook", mock.MatchedBy(func(w *model.Webhook) bool {
+		return w.UserID == user.ID && w.URL == "https://example.com/hooks" &&
+			w.Active && len(w.EventTypes) == 1 && w.EventTypes[0] == "state_changed"
+	})).Return(&model.Webhook{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		URL:        "https://example.com/hooks",
+		Secret:     "s3cret",
+		EventTypes: []string{"state_changed"},
+		Active:     true,
+	}, nil)
+
+	body := `{"url":"https://example.com/hooks","event_types":["state_changed"]}`
+	req := withUser(httptest.NewRequest("POST", "/api/webhooks", bytes.NewBufferString(body)), user)
+	w := httptest.NewRecorder()
+
+	controller.CreateWebhook(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var result model.Webhook
+	json.Unmarshal(w.Body.Bytes(), &result)
+	assert.Equal(t, "s3cret", result.Secret)
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateWebhook_UnknownEventType(t *testing.T) {
+	mockService := new(MockWebhookService)
+	controller := NewWebhookControllerWithService(mockService)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	body := `{"url":"https://example.com/hooks","event_types":["exploded"]}`
+	req := withUser(httptest.NewRequest("POST", "/api/webhooks", bytes.NewBufferString(body)), user)
+	w := httptest.NewRecorder()
+
+	controller.CreateWebhook(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var problem Problem
+	json.Unmarshal(w.Body.Bytes(), &problem)
+	if assert.Len(t, problem.Errors, 1) {
+		assert.Equal(t, "event_types[0]", problem.Errors[0].Field)
+		assert.Equal(t, "oneof", problem.Errors[0].Rule)
+	}
+	mockService.AssertNotCalled(t, "CreateWebhook", mock.Anything)
+}
+
+func TestCreateWebhook_InvalidURL(t *testing.T) {
+	mockService := new(MockWebhookService)
+	controller := NewWebhookControllerWithService(mockService)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	mockService.On("CreateWebhook", mock.AnythingOfType("*model.Webhook")).Return(nil, service.ErrInvalidWebhookURL)
+
+	body := `{"url":"example.com"}`
+	req := withUser(httptest.NewRequest("POST", "/api/webhooks", bytes.NewBufferString(body)), user)
+	w := httptest.NewRecorder()
+
+	controller.CreateWebhook(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateWebhook_Unauthorized(t *testing.T) {
+	mockService := new(MockWebhookService)
+	controller := NewWebhookControllerWithService(mockService)
+
+	req := httptest.NewRequest("POST", "/api/webhooks", bytes.NewBufferString(`{"url":"https://example.com"}`))
+	w := httptest.NewRecorder()
+
+	controller.CreateWebhook(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestUpdateWebhook_NotFound(t *testing.T) {
+	mockService := new(MockWebhookService)
+	controller := NewWebhookControllerWithService(mockService)
+
+	webhookID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	mockService.On("UpdateWebhook", mock.MatchedBy(func(w *model.Webhook) bool {
+		return w.ID == webhookID && w.UserID == user.ID && !w.Active
+	})).Return(nil, service.ErrWebhookNotFound)
+
+	body := `{"url":"https://example.com/hooks","active":false}`
+	req := httptest.NewRequest("PUT", "/api/webhooks/"+webhookID.String(), bytes.NewBufferString(body))
+	req = withUser(mux.SetURLVars(req, map[string]string{"id": webhookID.String()}), user)
+	w := httptest.NewRecorder()
+
+	controller.UpdateWebhook(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteWebhook_Success(t *testing.T) {
+	mockService := new(MockWebhookService)
+	controller := NewWebhookControllerWithService(mockService)
+
+	webhookID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	mockService.On("DeleteWebhook", webhookID.String(), user.ID).Return(nil)
+
+	req := httptest.NewRequest("DELETE", "/api/webhooks/"+webhookID.String(), nil)
+	req = withUser(mux.SetURLVars(req, map[string]string{"id": webhookID.String()}), user)
+	w := httptest.NewRecorder()
+
+	controller.DeleteWebhook(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetWebhook_InvalidID(t *testing.T) {
+	mockService := new(MockWebhookService)
+	controller := NewWebhookControllerWithService(mockService)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	req := httptest.NewRequest("GET", "/api/webhooks/not-a-uuid", nil)
+	req = withUser(mux.SetURLVars(req, map[string]string{"id": "not-a-uuid"}), user)
+	w := httptest.NewRecorder()
+
+	controller.GetWebhook(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "GetWebhook", mock.Anything, mock.Anything)
+}
+
+func TestGetDeliveries_Pagination(t *testing.T) {
+	mockService := new(MockWebhookService)
+	controller := NewWebhookControllerWithService(mockService)
+
+	webhookID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	deliveries := []model.WebhookDelivery{{ID: uuid.New(), WebhookID: webhookID, Status: model.WebhookDeliveryDead}}
+
+	mockService.On("GetDeliveries", webhookID.String(), user.ID, 5, 10).Return(deliveries, 11, nil)
+
+	req := httptest.NewRequest("GET", "/api/webhooks/"+webhookID.String()+"/deliveries?limit=5&offset=10", nil)
+	req = withUser(mux.SetURLVars(req, map[string]string{"id": webhookID.String()}), user)
+	w := httptest.NewRecorder()
+
+	controller.GetDeliveries(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result WebhookDeliveriesResponse
+	json.Unmarshal(w.Body.Bytes(), &result)
+	assert.Equal(t, 11, result.Total)
+	assert.Equal(t, 5, result.Limit)
+	assert.Equal(t, 10, result.Offset)
+	assert.Len(t, result.Deliveries, 1)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetDeliveries_InvalidLimit(t *testing.T) {
+	mockService := new(MockWebhookService)
+	controller := NewWebhookControllerWithService(mockService)
+
+	webhookID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	req := httptest.NewRequest("GET", "/api/webhooks/"+webhookID.String()+"/deliveries?limit=500", nil)
+	req = withUser(mux.SetURLVars(req, map[string]string{"id": webhookID.String()}), user)
+	w := httptest.NewRecorder()
+
+	controller.GetDeliveries(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "GetDeliveries", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	log "github.com/sirupsen/logrus"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// presentError reports service errors with the same code REST problems
+// carry, under the "code" extension, along with the offending fields of
+// validation errors. Other errors are GraphQL errors, such as malformed
+// arguments, and go out as they are.
+func presentError(ctx context.Context, err error) *gqlerror.Error {
+	var domain *service.Error
+	if !errors.As(err, &domain) {
+		return graphql.DefaultErrorPresenter(ctx, err)
+	}
+
+	presented := gqlerror.ErrorPathf(graphql.GetPath(ctx), "%s", domain.Error())
+	presented.Extensions = map[string]interface{}{
+		"code": domain.Code,
+		"kind": domain.Kind,
+	}
+
+	var invalid *model.ValidationError
+	if errors.As(err, &invalid) {
+		presented.Extensions["fields"] = invalid.Fields
+	}
+	return presented
+}
+
+// maskUnexpectedErrors logs the errors resolvers return that are neither
+// service nor GraphQL errors, and reports a generic internal error instead
+// so internals don't leak
+func maskUnexpectedErrors(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	res, err := next(ctx)
+	if err == nil {
+		return res, nil
+	}
+
+	var domain *service.Error
+	var gqlErr *gqlerror.Error
+	if errors.As(err, &domain) || errors.As(err, &gqlErr) {
+		return res, err
+	}
+
+	log.Error("unexpected error. err: ", err.Error())
+	return res, gqlerror.ErrorPathf(graphql.GetPath(ctx), "internal server error")
+}
+
+// invalidArgument reports a malformed argument the way the service layer
+// reports its KindInvalid errors
+func invalidArgument(ctx context.Context, message string) *gqlerror.Error {
+	err := gqlerror.ErrorPathf(graphql.GetPath(ctx), "%s", message)
+	err.Extensions = map[string]interface{}{
+		"code": "invalid_argument",
+		"kind": service.KindInvalid,
+	}
+	return err
+}
+
+// unauthorized reports a call made without a user
+func unauthorized(ctx context.Context) *gqlerror.Error {
+	err := gqlerror.ErrorPathf(graphql.GetPath(ctx), "Unauthorized")
+	err.Extensions = map[string]interface{}{
+		"code": "unauthorized",
+		"kind": service.KindUnauthorized,
+	}
+	return err
+}
+
+// authorize returns the user the call was made by, as long as their role
+// and, for calls made with an API token, its scopes allow permission
+func authorize(ctx context.Context, permission model.Permission) (*model.User, error) {
+	user := model.UserFromContext(ctx)
+	if user == nil {
+		return nil, unauthorized(ctx)
+	}
+	if !user.Can(permission) {
+		return nil, service.ErrForbidden
+	}
+	if token := model.APITokenFromContext(ctx); token != nil && !token.HasScope(permission) {
+		return nil, service.ErrInsufficientScope
+	}
+	return user, nil
+}
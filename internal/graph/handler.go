@@ -0,0 +1,83 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	log "github.com/sirupsen/logrus"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	// DefaultComplexityLimit lets a full page of devices be fetched along
+	// with their assignments, but not a page of audit trails for each
+	DefaultComplexityLimit = 5000
+
+	defaultDeviceLimit  = 50
+	maxDeviceLimit      = 200
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+
+	// assignmentsComplexity is the assumed number of assignments of a device
+	assignmentsComplexity = 10
+)
+
+// NewHandler serves the schema over HTTP GET and POST. Every request gets
+// its own loaders, and operations costing more than complexityLimit are
+// rejected before anything is resolved.
+func NewHandler(deviceService service.DeviceServiceInterface, devices repository.DeviceBatchRepositoryInterface, users repository.UserRepository, complexityLimit int) http.Handler {
+	if complexityLimit <= 0 {
+		complexityLimit = DefaultComplexityLimit
+	}
+
+	srv := handler.New(NewExecutableSchema(Config{
+		Resolvers:  &Resolver{deviceService: deviceService},
+		Complexity: complexityRoot(),
+	}))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.FixedComplexityLimit(complexityLimit))
+	srv.AroundFields(maskUnexpectedErrors)
+	srv.SetErrorPresenter(presentError)
+	srv.SetRecoverFunc(func(ctx context.Context, err interface{}) error {
+		log.Error("graphql resolver panic. err: ", err)
+		return gqlerror.ErrorPathf(graphql.GetPath(ctx), "internal server error")
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithLoaders(r.Context(), NewLoaders(devices, users))
+		srv.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// complexityRoot weighs list fields by the number of items they may return
+func complexityRoot() ComplexityRoot {
+	var c ComplexityRoot
+
+	c.Query.Devices = func(childComplexity int, filter *DeviceFilter, sort *string, first *int, after *string, includeTotal *bool) int {
+		return childComplexity * intOr(first, defaultDeviceLimit)
+	}
+	c.Device.History = func(childComplexity int, limit *int, offset *int) int {
+		return childComplexity * intOr(limit, defaultHistoryLimit)
+	}
+	c.Device.Assignments = func(childComplexity int) int {
+		return childComplexity * assignmentsComplexity
+	}
+
+	return c
+}
+
+// intOr dereferences an optional argument
+func intOr(v *int, fallback int) int {
+	if v == nil {
+		return fallback
+	}
+	return *v
+}
@@ -0,0 +1,434 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockDeviceService mocks the device service calls the GraphQL API makes
+type MockDeviceService struct {
+	service.DeviceServiceInterface
+	mock.Mock
+}
+
+func (m *MockDeviceService) GetDevices(filter repository.DeviceFilter) (*repository.DevicePage, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.DevicePage), args.Error(1)
+}
+
+func (m *MockDeviceService) GetDeviceByID(id string) (*model.Device, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Device), args.Error(1)
+}
+
+func (m *MockDeviceService) CreateDevice(device *model.Device, userID uuid.UUID) (*model.Device, error) {
+	args := m.Called(device, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Device), args.Error(1)
+}
+
+func (m *MockDeviceService) DeleteDevice(id string, version int, userID uuid.UUID) error {
+	args := m.Called(id, version, userID)
+	return args.Error(0)
+}
+
+// fakeBatchRepository serves batch reads from memory and counts the
+// queries made
+type fakeBatchRepository struct {
+	mu          sync.Mutex
+	devices     []model.Device
+	assignments []model.Assignment
+	events      []model.DeviceEvent
+	calls       map[string]int
+}
+
+func (f *fakeBatchRepository) called(method string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.calls == nil {
+		f.calls = map[string]int{}
+	}
+	f.calls[method]++
+}
+
+func (f *fakeBatchRepository) GetDevicesByIDs(ids []uuid.UUID) ([]model.Device, error) {
+	f.called("GetDevicesByIDs")
+	devices := []model.Device{}
+	for _, device := range f.devices {
+		if containsID(ids, device.ID) {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+func (f *fakeBatchRepository) GetAssignmentsByDeviceIDs(deviceIDs []uuid.UUID) ([]model.Assignment, error) {
+	f.called("GetAssignmentsByDeviceIDs")
+	assignments := []model.Assignment{}
+	for _, assignment := range f.assignments {
+		if containsID(deviceIDs, assignment.DeviceID) {
+			assignments = append(assignments, assignment)
+		}
+	}
+	return assignments, nil
+}
+
+func (f *fakeBatchRepository) GetDeviceHistories(deviceIDs []uuid.UUID, limit, offset int) ([]model.DeviceEvent, map[uuid.UUID]int, error) {
+	f.called("GetDeviceHistories")
+	events := []model.DeviceEvent{}
+	totals := map[uuid.UUID]int{}
+	positions := map[uuid.UUID]int{}
+	for _, event := range f.events {
+		if !containsID(deviceIDs, event.DeviceID) {
+			continue
+		}
+		totals[event.DeviceID]++
+		position := positions[event.DeviceID]
+		positions[event.DeviceID]++
+		if position >= offset && position < offset+limit {
+			events = append(events, event)
+		}
+	}
+	return events, totals, nil
+}
+
+// fakeUserRepository serves users from memory and counts the batch reads
+type fakeUserRepository struct {
+	repository.UserRepository
+	mu      sync.Mutex
+	users   []model.User
+	batches int
+}
+
+func (f *fakeUserRepository) GetByIDs(ids []uuid.UUID) ([]model.User, error) {
+	f.mu.Lock()
+	f.batches++
+	f.mu.Unlock()
+
+	users := []model.User{}
+	for _, user := range f.users {
+		if containsID(ids, user.ID) {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func containsID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// execute posts query as user, or anonymously when user is nil
+func execute(t *testing.T, h http.Handler, user *model.User, query string, variables map[string]interface{}) graphQLResponse {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if user != nil {
+		req = req.WithContext(context.WithValue(req.Context(), model.UserContextKey, user))
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var resp graphQLResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestHandler_DevicesBatchesNestedReads(t *testing.T) {
+	alice := model.User{ID: uuid.New(), Email: "alice@example.com", Role: model.RoleViewer}
+	bob := model.User{ID: uuid.New(), Email: "bob@example.com"}
+	pixel := model.Device{ID: uuid.New(), Name: "Pixel 8", Brand: "Google", State: model.StateInUse, Version: 2}
+	iphone := model.Device{ID: uuid.New(), Name: "iPhone 15", Brand: "Apple", State: model.StateAvailable, Version: 1}
+	now := time.Now()
+
+	devices := &fakeBatchRepository{
+		devices: []model.Device{pixel, iphone},
+		assignments: []model.Assignment{
+			{ID: uuid.New(), DeviceID: pixel.ID, UserID: alice.ID, CheckedOutAt: now},
+			{ID: uuid.New(), DeviceID: iphone.ID, UserID: bob.ID, CheckedOutAt: now.Add(-time.Hour), CheckedInAt: &now},
+		},
+		events: []model.DeviceEvent{
+			{ID: uuid.New(), DeviceID: pixel.ID, UserID: &alice.ID, Type: model.DeviceEventStateChanged, CreatedAt: now},
+			{ID: uuid.New(), DeviceID: pixel.ID, Type: model.DeviceEventCreated, CreatedAt: now.Add(-time.Hour)},
+			{ID: uuid.New(), DeviceID: iphone.ID, UserID: &bob.ID, Type: model.DeviceEventCreated, CreatedAt: now},
+		},
+	}
+	users := &fakeUserRepository{users: []model.User{alice, bob}}
+
+	deviceService := new(MockDeviceService)
+	deviceService.On("GetDevices", mock.MatchedBy(func(filter repository.DeviceFilter) bool {
+		return filter.Limit == 2 && filter.NamePrefix == "i" &&
+			assert.ObjectsAreEqual([]model.DeviceState{model.StateInUse, model.StateAvailable}, filter.States) &&
+			filter.Sort == repository.DeviceSort{Field: "name"} && filter.IncludeTotal
+	})).Return(&repository.DevicePage{Devices: []model.Device{pixel, iphone}, NextCursor: "next", Total: intPtr(7)}, nil)
+
+	h := NewHandler(deviceService, devices, users, 0)
+
+	resp := execute(t, h, &alice, `
+        query($filter: DeviceFilter) {
+            devices(filter: $filter, sort: "name", first: 2, includeTotal: true) {
+                nodes {
+                    name
+                    state
+                    currentAssignment { user { email } }
+                    assignments { user { email } device { name } }
+                    history(limit: 1) { total events { type user { email } } }
+                }
+                nextCursor
+                totalCount
+            }
+        }`, map[string]interface{}{"filter": map[string]interface{}{"namePrefix": " i ", "states": []string{"IN_USE", "AVAILABLE"}}})
+
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"devices": {
+        "nodes": [
+            {
+                "name": "Pixel 8",
+                "state": "IN_USE",
+                "currentAssignment": {"user": {"email": "alice@example.com"}},
+                "assignments": [{"user": {"email": "alice@example.com"}, "device": {"name": "Pixel 8"}}],
+                "history": {"total": 2, "events": [{"type": "STATE_CHANGED", "user": {"email": "alice@example.com"}}]}
+            },
+            {
+                "name": "iPhone 15",
+                "state": "AVAILABLE",
+                "currentAssignment": null,
+                "assignments": [{"user": {"email": "bob@example.com"}, "device": {"name": "iPhone 15"}}],
+                "history": {"total": 1, "events": [{"type": "CREATED", "user": {"email": "bob@example.com"}}]}
+            }
+        ],
+        "nextCursor": "next",
+        "totalCount": 7
+    }}`, string(resp.Data))
+
+	// one query per level, however many devices the page holds
+	assert.Equal(t, 1, devices.calls["GetAssignmentsByDeviceIDs"])
+	assert.Equal(t, 1, devices.calls["GetDeviceHistories"])
+	assert.Equal(t, 1, devices.calls["GetDevicesByIDs"])
+	// assignment and audit trail users are fetched in the same batch unless
+	// their parents come back more than a batch window apart
+	assert.LessOrEqual(t, users.batches, 2)
+	deviceService.AssertExpectations(t)
+}
+
+func TestHandler_Device(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "alice@example.com", Role: model.RoleViewer}
+
+	t.Run("not found resolves to null", func(t *testing.T) {
+		id := uuid.New()
+		deviceService := new(MockDeviceService)
+		deviceService.On("GetDeviceByID", id.String()).Return(nil, service.ErrDeviceNotFound)
+
+		resp := execute(t, NewHandler(deviceService, &fakeBatchRepository{}, &fakeUserRepository{}, 0), user,
+			`query($id: ID!) { device(id: $id) { name } }`, map[string]interface{}{"id": id.String()})
+
+		assert.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"device": null}`, string(resp.Data))
+	})
+
+	t.Run("unexpected errors don't leak", func(t *testing.T) {
+		id := uuid.New()
+		deviceService := new(MockDeviceService)
+		deviceService.On("GetDeviceByID", id.String()).Return(nil, fmt.Errorf("pq: connection refused"))
+
+		resp := execute(t, NewHandler(deviceService, &fakeBatchRepository{}, &fakeUserRepository{}, 0), user,
+			`query($id: ID!) { device(id: $id) { name } }`, map[string]interface{}{"id": id.String()})
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "internal server error", resp.Errors[0].Message)
+	})
+}
+
+func TestHandler_Mutations(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "alice@example.com", Role: model.RoleAdmin}
+
+	t.Run("create defaults to inactive", func(t *testing.T) {
+		created := &model.Device{ID: uuid.New(), Name: "Pixel 8", Brand: "Google", State: model.StateInactive, Version: 1}
+		deviceService := new(MockDeviceService)
+		deviceService.On("CreateDevice", &model.Device{Name: "Pixel 8", Brand: "Google", State: model.StateInactive}, user.ID).Return(created, nil)
+
+		resp := execute(t, NewHandler(deviceService, &fakeBatchRepository{}, &fakeUserRepository{}, 0), user,
+			`mutation { createDevice(input: {name: "Pixel 8", brand: "Google"}) { id state version } }`, nil)
+
+		require.Empty(t, resp.Errors)
+		assert.JSONEq(t, fmt.Sprintf(`{"createDevice": {"id": %q, "state": "INACTIVE", "version": 1}}`, created.ID), string(resp.Data))
+	})
+
+	t.Run("validation errors carry the offending fields", func(t *testing.T) {
+		invalid := &model.ValidationError{Fields: []model.FieldError{{Field: "name", Rule: "max", Message: "must be at most 255 characters"}}}
+		deviceService := new(MockDeviceService)
+		deviceService.On("CreateDevice", mock.Anything, user.ID).Return(nil, &service.Error{
+			Kind:    service.KindValidation,
+			Code:    service.ErrValidationFailed.Code,
+			Message: invalid.Error(),
+			Err:     invalid,
+		})
+
+		resp := execute(t, NewHandler(deviceService, &fakeBatchRepository{}, &fakeUserRepository{}, 0), user,
+			`mutation { createDevice(input: {name: "Pixel 8", brand: "Google", state: AVAILABLE}) { id } }`, nil)
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "validation_failed", resp.Errors[0].Extensions["code"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"field": "name", "rule": "max", "message": "must be at most 255 characters"}}, resp.Errors[0].Extensions["fields"])
+	})
+
+	t.Run("delete reports version mismatches", func(t *testing.T) {
+		id := uuid.New()
+		deviceService := new(MockDeviceService)
+		deviceService.On("DeleteDevice", id.String(), 3, user.ID).Return(service.ErrVersionMismatch)
+
+		resp := execute(t, NewHandler(deviceService, &fakeBatchRepository{}, &fakeUserRepository{}, 0), user,
+			`mutation($id: ID!) { deleteDevice(id: $id, version: 3) }`, map[string]interface{}{"id": id.String()})
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "version_mismatch", resp.Errors[0].Extensions["code"])
+		assert.Equal(t, "precondition", resp.Errors[0].Extensions["kind"])
+	})
+
+	t.Run("updates need a version", func(t *testing.T) {
+		deviceService := new(MockDeviceService)
+		handler := NewHandler(deviceService, &fakeBatchRepository{}, &fakeUserRepository{}, 0)
+		variables := map[string]interface{}{"id": uuid.NewString()}
+
+		resp := execute(t, handler, user,
+			`mutation($id: ID!) { updateDevice(input: {id: $id, name: "Pixel 8", brand: "Google", state: AVAILABLE, version: 0}) { id } }`, variables)
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "precondition_required", resp.Errors[0].Extensions["code"])
+
+		resp = execute(t, handler, user, `mutation($id: ID!) { deleteDevice(id: $id, version: 0) }`, variables)
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "precondition_required", resp.Errors[0].Extensions["code"])
+		deviceService.AssertNotCalled(t, "UpdateDevice", mock.Anything, mock.Anything)
+		deviceService.AssertNotCalled(t, "DeleteDevice", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("anonymous calls are rejected", func(t *testing.T) {
+		resp := execute(t, NewHandler(new(MockDeviceService), &fakeBatchRepository{}, &fakeUserRepository{}, 0), nil,
+			`mutation { createDevice(input: {name: "Pixel 8", brand: "Google"}) { id } }`, nil)
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "unauthorized", resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("operators cannot delete", func(t *testing.T) {
+		operator := &model.User{ID: uuid.New(), Email: "bob@example.com", Role: model.RoleOperator}
+		deviceService := new(MockDeviceService)
+
+		resp := execute(t, NewHandler(deviceService, &fakeBatchRepository{}, &fakeUserRepository{}, 0), operator,
+			`mutation($id: ID!) { deleteDevice(id: $id, version: 1) }`, map[string]interface{}{"id": uuid.NewString()})
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "forbidden", resp.Errors[0].Extensions["code"])
+		deviceService.AssertNotCalled(t, "DeleteDevice", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestHandler_APITokenScopes(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "alice@example.com", Role: model.RoleAdmin}
+	token := &model.APIToken{ID: uuid.New(), UserID: user.ID, Scopes: []string{string(model.PermissionReadDevices)}}
+	deviceService := new(MockDeviceService)
+
+	body, err := json.Marshal(map[string]interface{}{"query": `mutation { createDevice(input: {name: "Pixel 8", brand: "Google"}) { id } }`})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/api/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	ctx := context.WithValue(req.Context(), model.UserContextKey, user)
+	req = req.WithContext(context.WithValue(ctx, model.APITokenContextKey, token))
+
+	rr := httptest.NewRecorder()
+	NewHandler(deviceService, &fakeBatchRepository{}, &fakeUserRepository{}, 0).ServeHTTP(rr, req)
+
+	var resp graphQLResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "insufficient_scope", resp.Errors[0].Extensions["code"])
+	deviceService.AssertNotCalled(t, "CreateDevice", mock.Anything, mock.Anything)
+}
+
+func TestHandler_Limits(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "alice@example.com", Role: model.RoleViewer}
+
+	t.Run("page size out of range", func(t *testing.T) {
+		resp := execute(t, NewHandler(new(MockDeviceService), &fakeBatchRepository{}, &fakeUserRepository{}, 0), user,
+			`{ devices(first: 500) { nodes { id } } }`, nil)
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "invalid_argument", resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("complexity limit", func(t *testing.T) {
+		deviceService := new(MockDeviceService)
+
+		resp := execute(t, NewHandler(deviceService, &fakeBatchRepository{}, &fakeUserRepository{}, 0), user,
+			`{ devices(first: 200) { nodes { history(limit: 100) { events { id type occurredAt } } } } }`, nil)
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "COMPLEXITY_LIMIT_EXCEEDED", resp.Errors[0].Extensions["code"])
+		deviceService.AssertNotCalled(t, "GetDevices", mock.Anything)
+	})
+}
+
+func TestDeviceStateEnum(t *testing.T) {
+	for _, state := range model.DeviceStates {
+		var buf bytes.Buffer
+		MarshalDeviceState(state).MarshalGQL(&buf)
+
+		var value string
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &value))
+
+		parsed, err := UnmarshalDeviceState(value)
+		assert.NoError(t, err)
+		assert.Equal(t, state, parsed)
+	}
+
+	_, err := UnmarshalDeviceState("BROKEN")
+	assert.Error(t, err)
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,167 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/graph-gophers/dataloader/v7"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+)
+
+type loadersContextKey struct{}
+
+// Loaders batch and cache the nested reads of a single request, so resolving
+// a field for every device of a page costs one query instead of one per
+// device. They are created per request and must not be shared.
+type Loaders struct {
+	devices     *dataloader.Loader[uuid.UUID, *model.Device]
+	users       *dataloader.Loader[uuid.UUID, *model.User]
+	assignments *dataloader.Loader[uuid.UUID, []model.Assignment]
+	histories   *dataloader.Loader[historyKey, *DeviceHistory]
+}
+
+// historyKey identifies a page of the audit trail of a device
+type historyKey struct {
+	DeviceID uuid.UUID
+	Limit    int
+	Offset   int
+}
+
+func NewLoaders(devices repository.DeviceBatchRepositoryInterface, users repository.UserRepository) *Loaders {
+	return &Loaders{
+		devices:     dataloader.NewBatchedLoader(loadDevices(devices)),
+		users:       dataloader.NewBatchedLoader(loadUsers(users)),
+		assignments: dataloader.NewBatchedLoader(loadAssignments(devices)),
+		histories:   dataloader.NewBatchedLoader(loadHistories(devices)),
+	}
+}
+
+// WithLoaders returns ctx carrying loaders
+func WithLoaders(ctx context.Context, loaders *Loaders) context.Context {
+	return context.WithValue(ctx, loadersContextKey{}, loaders)
+}
+
+// loadersFrom returns the loaders of the request ctx belongs to
+func loadersFrom(ctx context.Context) *Loaders {
+	return ctx.Value(loadersContextKey{}).(*Loaders)
+}
+
+// loadDevices fetches devices by id, trashed ones included. Unknown ids
+// resolve to nil.
+func loadDevices(repo repository.DeviceBatchRepositoryInterface) dataloader.BatchFunc[uuid.UUID, *model.Device] {
+	return func(ctx context.Context, ids []uuid.UUID) []*dataloader.Result[*model.Device] {
+		devices, err := repo.GetDevicesByIDs(ids)
+		if err != nil {
+			return failAll[*model.Device](len(ids), err)
+		}
+
+		byID := make(map[uuid.UUID]*model.Device, len(devices))
+		for i := range devices {
+			byID[devices[i].ID] = &devices[i]
+		}
+
+		results := make([]*dataloader.Result[*model.Device], len(ids))
+		for i, id := range ids {
+			results[i] = &dataloader.Result[*model.Device]{Data: byID[id]}
+		}
+		return results
+	}
+}
+
+// loadUsers fetches users by id. Unknown ids resolve to nil.
+func loadUsers(repo repository.UserRepository) dataloader.BatchFunc[uuid.UUID, *model.User] {
+	return func(ctx context.Context, ids []uuid.UUID) []*dataloader.Result[*model.User] {
+		users, err := repo.GetByIDs(ids)
+		if err != nil {
+			return failAll[*model.User](len(ids), err)
+		}
+
+		byID := make(map[uuid.UUID]*model.User, len(users))
+		for i := range users {
+			byID[users[i].ID] = &users[i]
+		}
+
+		results := make([]*dataloader.Result[*model.User], len(ids))
+		for i, id := range ids {
+			results[i] = &dataloader.Result[*model.User]{Data: byID[id]}
+		}
+		return results
+	}
+}
+
+// loadAssignments fetches the assignments of devices by device id, latest
+// checkout first
+func loadAssignments(repo repository.DeviceBatchRepositoryInterface) dataloader.BatchFunc[uuid.UUID, []model.Assignment] {
+	return func(ctx context.Context, deviceIDs []uuid.UUID) []*dataloader.Result[[]model.Assignment] {
+		assignments, err := repo.GetAssignmentsByDeviceIDs(deviceIDs)
+		if err != nil {
+			return failAll[[]model.Assignment](len(deviceIDs), err)
+		}
+
+		byDevice := make(map[uuid.UUID][]model.Assignment, len(deviceIDs))
+		for _, assignment := range assignments {
+			byDevice[assignment.DeviceID] = append(byDevice[assignment.DeviceID], assignment)
+		}
+
+		results := make([]*dataloader.Result[[]model.Assignment], len(deviceIDs))
+		for i, id := range deviceIDs {
+			data := byDevice[id]
+			if data == nil {
+				data = []model.Assignment{}
+			}
+			results[i] = &dataloader.Result[[]model.Assignment]{Data: data}
+		}
+		return results
+	}
+}
+
+// loadHistories fetches pages of audit trails, with one query for every
+// distinct page requested
+func loadHistories(repo repository.DeviceBatchRepositoryInterface) dataloader.BatchFunc[historyKey, *DeviceHistory] {
+	return func(ctx context.Context, keys []historyKey) []*dataloader.Result[*DeviceHistory] {
+		type page struct{ limit, offset int }
+
+		pages := map[page][]uuid.UUID{}
+		for _, key := range keys {
+			p := page{key.Limit, key.Offset}
+			pages[p] = append(pages[p], key.DeviceID)
+		}
+
+		histories := make(map[historyKey]*DeviceHistory, len(keys))
+		errs := map[page]error{}
+		for p, deviceIDs := range pages {
+			events, totals, err := repo.GetDeviceHistories(deviceIDs, p.limit, p.offset)
+			if err != nil {
+				errs[p] = err
+				continue
+			}
+
+			for _, id := range deviceIDs {
+				histories[historyKey{id, p.limit, p.offset}] = &DeviceHistory{Events: []model.DeviceEvent{}, Total: totals[id]}
+			}
+			for _, event := range events {
+				history := histories[historyKey{event.DeviceID, p.limit, p.offset}]
+				history.Events = append(history.Events, event)
+			}
+		}
+
+		results := make([]*dataloader.Result[*DeviceHistory], len(keys))
+		for i, key := range keys {
+			results[i] = &dataloader.Result[*DeviceHistory]{
+				Data:  histories[key],
+				Error: errs[page{key.Limit, key.Offset}],
+			}
+		}
+		return results
+	}
+}
+
+// failAll reports err for every key of a batch
+func failAll[V any](n int, err error) []*dataloader.Result[V] {
+	results := make([]*dataloader.Result[V], n)
+	for i := range results {
+		results[i] = &dataloader.Result[V]{Error: err}
+	}
+	return results
+}
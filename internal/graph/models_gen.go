@@ -0,0 +1,59 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graph
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+type CreateDeviceInput struct {
+	Name  string `json:"name"`
+	Brand string `json:"brand"`
+	// Defaults to INACTIVE
+	State *model.DeviceState `json:"state,omitempty"`
+}
+
+type DeviceConnection struct {
+	Nodes []model.Device `json:"nodes"`
+	// Pass as after to fetch the next page, null on the last page
+	NextCursor *string `json:"nextCursor,omitempty"`
+	// Only computed when includeTotal is set
+	TotalCount *int `json:"totalCount,omitempty"`
+}
+
+type DeviceFilter struct {
+	Brands        []string            `json:"brands,omitempty"`
+	ExcludeBrands []string            `json:"excludeBrands,omitempty"`
+	States        []model.DeviceState `json:"states,omitempty"`
+	ExcludeStates []model.DeviceState `json:"excludeStates,omitempty"`
+	// Case-insensitive name prefix
+	NamePrefix     *string    `json:"namePrefix,omitempty"`
+	CreatedAfter   *time.Time `json:"createdAfter,omitempty"`
+	CreatedBefore  *time.Time `json:"createdBefore,omitempty"`
+	UpdatedAfter   *time.Time `json:"updatedAfter,omitempty"`
+	UpdatedBefore  *time.Time `json:"updatedBefore,omitempty"`
+	IncludeDeleted *bool      `json:"includeDeleted,omitempty"`
+}
+
+type DeviceHistory struct {
+	Events []model.DeviceEvent `json:"events"`
+	Total  int                 `json:"total"`
+}
+
+type Mutation struct {
+}
+
+type Query struct {
+}
+
+type UpdateDeviceInput struct {
+	ID    uuid.UUID         `json:"id"`
+	Name  string            `json:"name"`
+	Brand string            `json:"brand"`
+	State model.DeviceState `json:"state"`
+	// The version expected to be overwritten, as the REST API takes it in If-Match
+	Version int `json:"version"`
+}
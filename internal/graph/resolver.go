@@ -0,0 +1,67 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+)
+
+// Resolver is the root resolver. Writes go through deviceService, like they
+// do for the REST and gRPC APIs, while nested reads go through the loaders
+// of the request.
+type Resolver struct {
+	deviceService service.DeviceServiceInterface
+}
+
+// deviceFilter turns the arguments of the devices query into the filter
+// DeviceService takes
+func deviceFilter(ctx context.Context, filter *DeviceFilter, sort *string, first *int, after *string, includeTotal *bool) (repository.DeviceFilter, error) {
+	devicesFilter := repository.DeviceFilter{Limit: intOr(first, defaultDeviceLimit)}
+	if devicesFilter.Limit < 1 || devicesFilter.Limit > maxDeviceLimit {
+		return devicesFilter, invalidArgument(ctx, "Invalid first. Use a number between 1 and 200")
+	}
+
+	if sort != nil && *sort != "" {
+		parsed, err := repository.ParseDeviceSort(*sort)
+		if err != nil {
+			return devicesFilter, invalidArgument(ctx, "Invalid sort. Use: name, brand, state, created_at or updated_at, optionally prefixed with -")
+		}
+		devicesFilter.Sort = parsed
+	}
+	if after != nil {
+		devicesFilter.Cursor = *after
+	}
+	if includeTotal != nil {
+		devicesFilter.IncludeTotal = *includeTotal
+	}
+
+	if filter != nil {
+		devicesFilter.Brands = filter.Brands
+		devicesFilter.ExcludeBrands = filter.ExcludeBrands
+		devicesFilter.States = filter.States
+		devicesFilter.ExcludeStates = filter.ExcludeStates
+		devicesFilter.CreatedAfter = filter.CreatedAfter
+		devicesFilter.CreatedBefore = filter.CreatedBefore
+		devicesFilter.UpdatedAfter = filter.UpdatedAfter
+		devicesFilter.UpdatedBefore = filter.UpdatedBefore
+		if filter.NamePrefix != nil {
+			devicesFilter.NamePrefix = strings.TrimSpace(*filter.NamePrefix)
+		}
+		if filter.IncludeDeleted != nil {
+			devicesFilter.IncludeDeleted = *filter.IncludeDeleted
+		}
+	}
+
+	return devicesFilter, nil
+}
+
+// rawJSON passes an optional JSON document through, nil as null
+func rawJSON(raw *json.RawMessage) JSON {
+	if raw == nil {
+		return nil
+	}
+	return JSON(*raw)
+}
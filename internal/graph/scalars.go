@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+// The DeviceState and DeviceEventType enums are bound to the model types
+// through the Marshal and Unmarshal functions below. Enum
+// values are the model names upper-cased, with dashes turned to underscores.
+
+func MarshalDeviceState(state model.DeviceState) graphql.Marshaler {
+	return graphql.MarshalString(enumName(state.String()))
+}
+
+func UnmarshalDeviceState(v interface{}) (model.DeviceState, error) {
+	str, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("%T is not a device state", v)
+	}
+	return model.ParseDeviceState(modelName(str))
+}
+
+func MarshalDeviceEventType(eventType model.DeviceEventType) graphql.Marshaler {
+	return graphql.MarshalString(enumName(string(eventType)))
+}
+
+func UnmarshalDeviceEventType(v interface{}) (model.DeviceEventType, error) {
+	str, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%T is not a device event type", v)
+	}
+	return model.DeviceEventType(strings.ToLower(str)), nil
+}
+
+// JSON is raw JSON passed through as is
+type JSON json.RawMessage
+
+func (j JSON) MarshalGQL(w io.Writer) {
+	if len(j) == 0 {
+		io.WriteString(w, "null")
+		return
+	}
+	w.Write(j)
+}
+
+func (j *JSON) UnmarshalGQL(v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	*j = raw
+	return nil
+}
+
+// enumName turns a model name such as "in-use" into the enum value IN_USE
+func enumName(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// modelName turns an enum value such as IN_USE into the model name "in-use"
+func modelName(value string) string {
+	return strings.ToLower(strings.ReplaceAll(value, "_", "-"))
+}
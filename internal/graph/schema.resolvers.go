@@ -0,0 +1,225 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+)
+
+// Device is the resolver for the device field.
+func (r *assignmentResolver) Device(ctx context.Context, obj *model.Assignment) (*model.Device, error) {
+	return loadersFrom(ctx).devices.Load(ctx, obj.DeviceID)()
+}
+
+// User is the resolver for the user field.
+func (r *assignmentResolver) User(ctx context.Context, obj *model.Assignment) (*model.User, error) {
+	return loadersFrom(ctx).users.Load(ctx, obj.UserID)()
+}
+
+// CurrentAssignment is the resolver for the currentAssignment field.
+func (r *deviceResolver) CurrentAssignment(ctx context.Context, obj *model.Device) (*model.Assignment, error) {
+	assignments, err := loadersFrom(ctx).assignments.Load(ctx, obj.ID)()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range assignments {
+		if assignments[i].CheckedInAt == nil {
+			return &assignments[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Assignments is the resolver for the assignments field.
+func (r *deviceResolver) Assignments(ctx context.Context, obj *model.Device) ([]model.Assignment, error) {
+	return loadersFrom(ctx).assignments.Load(ctx, obj.ID)()
+}
+
+// History is the resolver for the history field.
+func (r *deviceResolver) History(ctx context.Context, obj *model.Device, limit *int, offset *int) (*DeviceHistory, error) {
+	key := historyKey{DeviceID: obj.ID, Limit: intOr(limit, defaultHistoryLimit), Offset: intOr(offset, 0)}
+	if key.Limit < 1 || key.Limit > maxHistoryLimit {
+		return nil, invalidArgument(ctx, "Invalid limit. Use a number between 1 and 100")
+	}
+	if key.Offset < 0 {
+		return nil, invalidArgument(ctx, "Invalid offset. Use a non-negative number")
+	}
+
+	return loadersFrom(ctx).histories.Load(ctx, key)()
+}
+
+// User is the resolver for the user field.
+func (r *deviceEventResolver) User(ctx context.Context, obj *model.DeviceEvent) (*model.User, error) {
+	if obj.UserID == nil {
+		return nil, nil
+	}
+	return loadersFrom(ctx).users.Load(ctx, *obj.UserID)()
+}
+
+// Before is the resolver for the before field.
+func (r *deviceEventResolver) Before(ctx context.Context, obj *model.DeviceEvent) (JSON, error) {
+	return rawJSON(obj.Before), nil
+}
+
+// After is the resolver for the after field.
+func (r *deviceEventResolver) After(ctx context.Context, obj *model.DeviceEvent) (JSON, error) {
+	return rawJSON(obj.After), nil
+}
+
+// CreateDevice is the resolver for the createDevice field.
+func (r *mutationResolver) CreateDevice(ctx context.Context, input CreateDeviceInput) (*model.Device, error) {
+	user, err := authorize(ctx, model.PermissionManageDevices)
+	if err != nil {
+		return nil, err
+	}
+
+	device := &model.Device{Name: input.Name, Brand: input.Brand, State: model.StateInactive}
+	if input.State != nil {
+		device.State = *input.State
+	}
+
+	return r.deviceService.CreateDevice(device, user.ID)
+}
+
+// UpdateDevice is the resolver for the updateDevice field.
+func (r *mutationResolver) UpdateDevice(ctx context.Context, input UpdateDeviceInput) (*model.Device, error) {
+	user, err := authorize(ctx, model.PermissionOperateDevices)
+	if err != nil {
+		return nil, err
+	}
+
+	// the service takes version 0 as If-Match: *, which the schema has no
+	// way to say
+	if input.Version < 1 {
+		return nil, service.ErrVersionRequired
+	}
+
+	return r.deviceService.UpdateDevice(&model.Device{
+		ID:      input.ID,
+		Name:    input.Name,
+		Brand:   input.Brand,
+		State:   input.State,
+		Version: input.Version,
+	}, user.ID, service.EditableFields(ctx))
+}
+
+// DeleteDevice is the resolver for the deleteDevice field.
+func (r *mutationResolver) DeleteDevice(ctx context.Context, id uuid.UUID, version int) (bool, error) {
+	user, err := authorize(ctx, model.PermissionManageDevices)
+	if err != nil {
+		return false, err
+	}
+
+	if version < 1 {
+		return false, service.ErrVersionRequired
+	}
+
+	if err := r.deviceService.DeleteDevice(id.String(), version, user.ID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RestoreDevice is the resolver for the restoreDevice field.
+func (r *mutationResolver) RestoreDevice(ctx context.Context, id uuid.UUID) (*model.Device, error) {
+	user, err := authorize(ctx, model.PermissionManageDevices)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.deviceService.RestoreDevice(id.String(), user.ID)
+}
+
+// CheckoutDevice is the resolver for the checkoutDevice field.
+func (r *mutationResolver) CheckoutDevice(ctx context.Context, id uuid.UUID) (*model.Assignment, error) {
+	user, err := authorize(ctx, model.PermissionOperateDevices)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.deviceService.CheckoutDevice(id.String(), user.ID)
+}
+
+// CheckinDevice is the resolver for the checkinDevice field.
+func (r *mutationResolver) CheckinDevice(ctx context.Context, id uuid.UUID) (*model.Assignment, error) {
+	user, err := authorize(ctx, model.PermissionOperateDevices)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.deviceService.CheckinDevice(id.String(), user.ID)
+}
+
+// Devices is the resolver for the devices field.
+func (r *queryResolver) Devices(ctx context.Context, filter *DeviceFilter, sort *string, first *int, after *string, includeTotal *bool) (*DeviceConnection, error) {
+	if _, err := authorize(ctx, model.PermissionReadDevices); err != nil {
+		return nil, err
+	}
+
+	devicesFilter, err := deviceFilter(ctx, filter, sort, first, after, includeTotal)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := r.deviceService.GetDevices(devicesFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	connection := &DeviceConnection{Nodes: page.Devices, TotalCount: page.Total}
+	if page.NextCursor != "" {
+		connection.NextCursor = &page.NextCursor
+	}
+	return connection, nil
+}
+
+// Device is the resolver for the device field.
+func (r *queryResolver) Device(ctx context.Context, id uuid.UUID) (*model.Device, error) {
+	if _, err := authorize(ctx, model.PermissionReadDevices); err != nil {
+		return nil, err
+	}
+
+	device, err := r.deviceService.GetDeviceByID(id.String())
+	if errors.Is(err, service.ErrDeviceNotFound) {
+		return nil, nil
+	}
+	return device, err
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
+	user := model.UserFromContext(ctx)
+	if user == nil {
+		return nil, unauthorized(ctx)
+	}
+	return user, nil
+}
+
+// Assignment returns AssignmentResolver implementation.
+func (r *Resolver) Assignment() AssignmentResolver { return &assignmentResolver{r} }
+
+// Device returns DeviceResolver implementation.
+func (r *Resolver) Device() DeviceResolver { return &deviceResolver{r} }
+
+// DeviceEvent returns DeviceEventResolver implementation.
+func (r *Resolver) DeviceEvent() DeviceEventResolver { return &deviceEventResolver{r} }
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+type assignmentResolver struct{ *Resolver }
+type deviceResolver struct{ *Resolver }
+type deviceEventResolver struct{ *Resolver }
+type mutationResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
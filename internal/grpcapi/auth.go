@@ -0,0 +1,239 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/controller"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	pb "github.com/loopsFreitag/DeviceRegistry/internal/pb/deviceregistry/v1"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// publicServices need no credentials
+var publicServices = map[string]bool{
+	pb.AuthService_ServiceDesc.ServiceName:     true,
+	healthpb.Health_ServiceDesc.ServiceName:    true,
+	"grpc.reflection.v1.ServerReflection":      true,
+	"grpc.reflection.v1alpha.ServerReflection": true,
+}
+
+// methodPermissions is what the role of the caller must allow for each
+// method of the non-public services. Methods missing here are denied.
+var methodPermissions = map[string]model.Permission{
+	pb.DeviceService_ListDevices_FullMethodName:  model.PermissionReadDevices,
+	pb.DeviceService_GetDevice_FullMethodName:    model.PermissionReadDevices,
+	pb.DeviceService_WatchDevices_FullMethodName: model.PermissionReadDevices,
+	pb.DeviceService_UpdateDevice_FullMethodName: model.PermissionOperateDevices,
+	pb.DeviceService_CreateDevice_FullMethodName: model.PermissionManageDevices,
+	pb.DeviceService_DeleteDevice_FullMethodName: model.PermissionManageDevices,
+}
+
+// Authenticator resolves the user behind a call, the gRPC counterpart of
+// middleware.AuthMiddleware. Clients send an API token, a JWT access token or
+// the session id they got from Login as "authorization: Bearer <token>"
+// metadata, or the session cookie of the REST API as "cookie" metadata.
+type Authenticator struct {
+	authService  service.AuthServiceInterface
+	tokenService service.APITokenServiceInterface
+	jwt          service.JWTServiceInterface
+	sessions     model.SessionStore
+
+	slidingSessions    bool
+	maxSessionLifetime time.Duration
+}
+
+// AuthenticatorOption is a functional option to configure the Authenticator
+type AuthenticatorOption func(*Authenticator)
+
+// WithJWTService makes the Authenticator accept JWT access tokens as bearer
+// tokens, alongside API tokens and sessions
+func WithJWTService(jwt service.JWTServiceInterface) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.jwt = jwt
+	}
+}
+
+// WithSlidingSessions extends sessions on every call, the way
+// middleware.WithSlidingSessions does for REST requests, so gRPC clients
+// keep their sessions alive the same way browsers do. A positive
+// maxLifetime still ends them that long after login.
+func WithSlidingSessions(maxLifetime time.Duration) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.slidingSessions = true
+		a.maxSessionLifetime = maxLifetime
+	}
+}
+
+func NewAuthenticator(authService service.AuthServiceInterface, tokenService service.APITokenServiceInterface, opts ...AuthenticatorOption) *Authenticator {
+	a := &Authenticator{
+		authService:  authService,
+		tokenService: tokenService,
+		sessions:     model.GetSessionStore(),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// UnaryInterceptor authenticates and authorizes unary calls to non-public
+// services
+func (a *Authenticator) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if isPublic(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor authenticates and authorizes streaming calls to
+// non-public services
+func (a *Authenticator) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isPublic(info.FullMethod) {
+		return handler(srv, ss)
+	}
+
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticate returns ctx carrying the user the call was made by, as long
+// as their role, and the scopes of the API token it was made with, allow
+// fullMethod
+func (a *Authenticator) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	token, bearer := credential(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	ctx, userID, err := a.resolve(ctx, token, bearer)
+	if err != nil {
+		return nil, err
+	}
+
+	// The role comes from the database rather than the access token, so
+	// role changes apply right away
+	user, err := a.authService.GetUserByID(userID)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "User not found")
+	}
+
+	permission, ok := methodPermissions[fullMethod]
+	if !ok || !user.Can(permission) {
+		return nil, statusError(service.ErrForbidden)
+	}
+	if apiToken := model.APITokenFromContext(ctx); apiToken != nil && !apiToken.HasScope(permission) {
+		return nil, statusError(service.ErrInsufficientScope)
+	}
+
+	return context.WithValue(ctx, model.UserContextKey, user), nil
+}
+
+// resolve checks token and returns ctx carrying what it stands for, along
+// with the user it belongs to. Bearer tokens are API tokens when they carry
+// the API token prefix, JWT access tokens when they are dot separated and
+// token mode is on, and session ids otherwise.
+func (a *Authenticator) resolve(ctx context.Context, token string, bearer bool) (context.Context, uuid.UUID, error) {
+	switch {
+	case bearer && strings.HasPrefix(token, model.APITokenPrefix):
+		apiToken, err := a.tokenService.Authenticate(token)
+		if err != nil {
+			return nil, uuid.Nil, statusError(err)
+		}
+		return context.WithValue(ctx, model.APITokenContextKey, apiToken), apiToken.UserID, nil
+	case bearer && a.jwt != nil && strings.Contains(token, "."):
+		accessToken, err := a.jwt.VerifyAccessToken(token)
+		if err != nil {
+			return nil, uuid.Nil, statusError(err)
+		}
+		return context.WithValue(ctx, model.AccessTokenContextKey, accessToken), accessToken.UserID, nil
+	}
+
+	session, err := a.sessions.Get(token)
+	if errors.Is(err, model.ErrSessionNotFound) {
+		return nil, uuid.Nil, status.Error(codes.Unauthenticated, "Invalid or expired session")
+	}
+	if err != nil {
+		return nil, uuid.Nil, statusError(err)
+	}
+	if a.slidingSessions {
+		a.extendSession(session)
+	}
+	return context.WithValue(ctx, model.SessionContextKey, session), session.UserID, nil
+}
+
+// extendSession pushes the expiry of session back to a SessionDuration from
+// now, capped at the max lifetime. Failing to is not worth failing the call
+// over.
+func (a *Authenticator) extendSession(session *model.Session) {
+	expiresAt, ok := session.SlidingExpiry(controller.SessionDuration, a.maxSessionLifetime)
+	if !ok {
+		return
+	}
+
+	if err := a.sessions.Extend(session.ID, expiresAt); err != nil {
+		log.Warnf("failed to extend session %s. err: %s", session.ID, err.Error())
+		return
+	}
+	session.ExpiresAt = expiresAt
+}
+
+// credential extracts the bearer token or, failing that, the session cookie
+// from the call metadata, and tells which it found
+func credential(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	for _, value := range md.Get("authorization") {
+		if scheme, token, found := strings.Cut(value, " "); found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token), true
+		}
+	}
+
+	if cookies := md.Get("cookie"); len(cookies) > 0 {
+		req := http.Request{Header: http.Header{"Cookie": cookies}}
+		if cookie, err := req.Cookie(controller.SessionCookieName); err == nil {
+			return cookie.Value, false
+		}
+	}
+
+	return "", false
+}
+
+// isPublic reports whether fullMethod ("/package.Service/Method") belongs to
+// a public service
+func isPublic(fullMethod string) bool {
+	service, _, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return publicServices[service]
+}
+
+// authenticatedStream overrides the context of a stream with the one
+// carrying the user
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
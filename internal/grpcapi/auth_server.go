@@ -0,0 +1,92 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/controller"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	pb "github.com/loopsFreitag/DeviceRegistry/internal/pb/deviceregistry/v1"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AuthServer implements the AuthService. Sessions it opens are shared with
+// the REST API.
+type AuthServer struct {
+	pb.UnimplementedAuthServiceServer
+
+	authService service.AuthServiceInterface
+	sessions    model.SessionStore
+}
+
+func NewAuthServer(authService service.AuthServiceInterface) *AuthServer {
+	return &AuthServer{
+		authService: authService,
+		sessions:    model.GetSessionStore(),
+	}
+}
+
+func (s *AuthServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+	// same rules as POST /auth/register
+	if err := model.Validate(controller.RegisterRequest{Email: req.GetEmail(), Password: req.GetPassword()}); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	user, err := s.authService.CreateUser(req.GetEmail(), req.GetPassword())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &pb.RegisterResponse{User: userToProto(user)}, nil
+}
+
+func (s *AuthServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	if err := model.Validate(controller.LoginRequest{Email: req.GetEmail(), Password: req.GetPassword()}); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	user, err := s.authService.Login(req.GetEmail(), req.GetPassword())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	session := model.NewSession(user.ID, userAgent(ctx), peerIP(ctx))
+	token, err := s.sessions.Create(session, controller.SessionDuration)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &pb.LoginResponse{
+		User:      userToProto(user),
+		SessionId: token,
+		ExpiresAt: timestamppb.New(session.ExpiresAt),
+	}, nil
+}
+
+// userAgent returns the user agent the client sent with the call
+func userAgent(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get("user-agent"); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// peerIP returns the address the call comes from, or "" when it didn't come
+// over TCP
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if addr, ok := p.Addr.(*net.TCPAddr); ok {
+		return addr.IP.String()
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+package grpcapi
+
+import (
+	"errors"
+	"time"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	pb "github.com/loopsFreitag/DeviceRegistry/internal/pb/deviceregistry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var errUnspecifiedState = errors.New("state is required")
+
+// The proto enum reserves 0 for an unset state, so every model state is
+// shifted up by one
+func stateToProto(state model.DeviceState) pb.DeviceState {
+	return pb.DeviceState(state + 1)
+}
+
+func stateFromProto(state pb.DeviceState) (model.DeviceState, error) {
+	if state == pb.DeviceState_DEVICE_STATE_UNSPECIFIED {
+		return 0, errUnspecifiedState
+	}
+
+	converted := model.DeviceState(state - 1)
+	if !converted.IsValid() {
+		return 0, model.ErrInvalidDeviceState
+	}
+	return converted, nil
+}
+
+func statesFromProto(states []pb.DeviceState) ([]model.DeviceState, error) {
+	var converted []model.DeviceState
+	for _, state := range states {
+		s, err := stateFromProto(state)
+		if err != nil {
+			return nil, err
+		}
+		converted = append(converted, s)
+	}
+	return converted, nil
+}
+
+func deviceToProto(device *model.Device) *pb.Device {
+	return &pb.Device{
+		Id:        device.ID.String(),
+		Name:      device.Name,
+		Brand:     device.Brand,
+		State:     stateToProto(device.State),
+		Version:   int32(device.Version),
+		CreatedAt: timestamppb.New(device.CreatedAt),
+		UpdatedAt: timestamppb.New(device.UpdatedAt),
+		DeletedAt: optionalTimestamp(device.DeletedAt),
+	}
+}
+
+var changeTypes = map[model.DeviceEventType]pb.DeviceChangeType{
+	model.DeviceEventCreated:      pb.DeviceChangeType_DEVICE_CHANGE_TYPE_CREATED,
+	model.DeviceEventUpdated:      pb.DeviceChangeType_DEVICE_CHANGE_TYPE_UPDATED,
+	model.DeviceEventStateChanged: pb.DeviceChangeType_DEVICE_CHANGE_TYPE_STATE_CHANGED,
+	model.DeviceEventDeleted:      pb.DeviceChangeType_DEVICE_CHANGE_TYPE_DELETED,
+	model.DeviceEventRestored:     pb.DeviceChangeType_DEVICE_CHANGE_TYPE_RESTORED,
+}
+
+func changeToProto(change model.DeviceChange) *pb.DeviceChange {
+	return &pb.DeviceChange{
+		Seq:    change.Seq,
+		Type:   changeTypes[change.Type],
+		Device: deviceToProto(&change.Device),
+	}
+}
+
+func userToProto(user *model.User) *pb.User {
+	return &pb.User{
+		Id:        user.ID.String(),
+		Email:     user.Email,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+		UpdatedAt: timestamppb.New(user.UpdatedAt),
+	}
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,253 @@
+package grpcapi
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	pb "github.com/loopsFreitag/DeviceRegistry/internal/pb/deviceregistry/v1"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPageSize     = 50
+	maxPageSize         = 200
+	watchReplayPageSize = 200
+)
+
+// DeviceServer implements the DeviceService on top of the same services the
+// REST controllers use
+type DeviceServer struct {
+	pb.UnimplementedDeviceServiceServer
+
+	deviceService service.DeviceServiceInterface
+	stream        service.DeviceStreamInterface
+}
+
+func NewDeviceServer(deviceService service.DeviceServiceInterface, stream service.DeviceStreamInterface) *DeviceServer {
+	return &DeviceServer{
+		deviceService: deviceService,
+		stream:        stream,
+	}
+}
+
+func (s *DeviceServer) ListDevices(ctx context.Context, req *pb.ListDevicesRequest) (*pb.ListDevicesResponse, error) {
+	filter := repository.DeviceFilter{
+		Brands:         req.GetBrands(),
+		NamePrefix:     strings.TrimSpace(req.GetNamePrefix()),
+		IncludeDeleted: req.GetIncludeDeleted(),
+		IncludeTotal:   req.GetIncludeTotal(),
+		Limit:          defaultPageSize,
+		Cursor:         req.GetPageToken(),
+	}
+
+	var err error
+	if filter.States, err = statesFromProto(req.GetStates()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid states. Use inactive, available, in-use, maintenance, lost or retired")
+	}
+
+	if req.GetSort() != "" {
+		if filter.Sort, err = repository.ParseDeviceSort(req.GetSort()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "Invalid sort. Use: name, brand, state, created_at or updated_at, optionally prefixed with -")
+		}
+	}
+
+	if size := req.GetPageSize(); size != 0 {
+		if size < 1 || size > maxPageSize {
+			return nil, status.Error(codes.InvalidArgument, "Invalid page_size. Use a number between 1 and 200")
+		}
+		filter.Limit = int(size)
+	}
+
+	page, err := s.deviceService.GetDevices(filter)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &pb.ListDevicesResponse{
+		Devices:       make([]*pb.Device, len(page.Devices)),
+		NextPageToken: page.NextCursor,
+	}
+	for i := range page.Devices {
+		resp.Devices[i] = deviceToProto(&page.Devices[i])
+	}
+	if page.Total != nil {
+		total := int32(*page.Total)
+		resp.Total = &total
+	}
+
+	return resp, nil
+}
+
+func (s *DeviceServer) GetDevice(ctx context.Context, req *pb.GetDeviceRequest) (*pb.GetDeviceResponse, error) {
+	if _, err := uuid.Parse(req.GetId()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid device ID")
+	}
+
+	device, err := s.deviceService.GetDeviceByID(req.GetId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &pb.GetDeviceResponse{Device: deviceToProto(device)}, nil
+}
+
+func (s *DeviceServer) CreateDevice(ctx context.Context, req *pb.CreateDeviceRequest) (*pb.CreateDeviceResponse, error) {
+	user := model.UserFromContext(ctx)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	device := &model.Device{
+		Name:  req.GetName(),
+		Brand: req.GetBrand(),
+		State: model.StateInactive,
+	}
+	if req.GetState() != pb.DeviceState_DEVICE_STATE_UNSPECIFIED {
+		state, err := stateFromProto(req.GetState())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		device.State = state
+	}
+
+	created, err := s.deviceService.CreateDevice(device, user.ID)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &pb.CreateDeviceResponse{Device: deviceToProto(created)}, nil
+}
+
+func (s *DeviceServer) UpdateDevice(ctx context.Context, req *pb.UpdateDeviceRequest) (*pb.UpdateDeviceResponse, error) {
+	user := model.UserFromContext(ctx)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid device ID")
+	}
+
+	if req.GetVersion() < 1 {
+		return nil, versionRequired()
+	}
+
+	state, err := stateFromProto(req.GetState())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	updated, err := s.deviceService.UpdateDevice(&model.Device{
+		ID:      id,
+		Name:    req.GetName(),
+		Brand:   req.GetBrand(),
+		State:   state,
+		Version: int(req.GetVersion()),
+	}, user.ID, service.EditableFields(ctx))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &pb.UpdateDeviceResponse{Device: deviceToProto(updated)}, nil
+}
+
+func (s *DeviceServer) DeleteDevice(ctx context.Context, req *pb.DeleteDeviceRequest) (*pb.DeleteDeviceResponse, error) {
+	user := model.UserFromContext(ctx)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	if _, err := uuid.Parse(req.GetId()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid device ID")
+	}
+	if req.GetVersion() < 1 {
+		return nil, versionRequired()
+	}
+
+	if err := s.deviceService.DeleteDevice(req.GetId(), int(req.GetVersion()), user.ID); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &pb.DeleteDeviceResponse{}, nil
+}
+
+// versionRequired reports a write without the version it applies to. The
+// version is a plain field here rather than a precondition header, so a
+// missing one is an invalid argument.
+func versionRequired() error {
+	return withDetails(status.New(codes.InvalidArgument, service.ErrVersionRequired.Error()), service.ErrVersionRequired.Code, nil)
+}
+
+// WatchDevices streams device changes the way GET /api/devices/events does:
+// it subscribes, replays the changes after after_seq, then goes live,
+// skipping what the replay already sent. Changes arrive in seq order, each
+// once every change before it has committed or rolled back. The stream ends
+// when this replica drops the subscriber; clients resume from the last seq
+// they got.
+func (s *DeviceServer) WatchDevices(req *pb.WatchDevicesRequest, stream pb.DeviceService_WatchDevicesServer) error {
+	states, err := statesFromProto(req.GetStates())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "Invalid states. Use inactive, available, in-use, maintenance, lost or retired")
+	}
+	if req.GetAfterSeq() < 0 {
+		return status.Error(codes.InvalidArgument, "Invalid after_seq. Use the seq of the last change received")
+	}
+
+	filter := repository.DeviceChangeFilter{Brands: req.GetBrands(), States: states}
+	lastSeq := req.GetAfterSeq()
+
+	select {
+	case <-s.stream.Ready():
+	case <-stream.Context().Done():
+		return nil
+	}
+
+	// subscribe before catching up so nothing written meanwhile is missed.
+	// Changes arrive in seq order, so the overlap is skipped by it.
+	sub := s.stream.Subscribe(filter)
+	defer s.stream.Unsubscribe(sub)
+
+	if lastSeq > 0 {
+		for {
+			changes, err := s.stream.GetChangesSince(lastSeq, filter, watchReplayPageSize)
+			if err != nil {
+				return statusError(err)
+			}
+
+			for _, change := range changes {
+				if err := stream.Send(&pb.WatchDevicesResponse{Change: changeToProto(change)}); err != nil {
+					return err
+				}
+				lastSeq = change.Seq
+			}
+
+			if len(changes) < watchReplayPageSize {
+				break
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case change, ok := <-sub.Changes():
+			if !ok {
+				return status.Error(codes.Unavailable, "change stream interrupted, resume from the last seq received")
+			}
+			if change.Seq <= lastSeq {
+				continue
+			}
+			if err := stream.Send(&pb.WatchDevicesResponse{Change: changeToProto(change)}); err != nil {
+				return err
+			}
+			lastSeq = change.Seq
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package grpcapi
+
+import (
+	"errors"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain is the domain of the ErrorInfo detail attached to every error,
+// whose reason carries the same code REST problems report
+const ErrorDomain = "deviceregistry"
+
+// statusError turns a service error into a gRPC status. Unexpected errors
+// are logged and reported as a generic Internal error so internals don't
+// leak.
+func statusError(err error) error {
+	var domain *service.Error
+	if !errors.As(err, &domain) {
+		log.Error("unexpected error. err: ", err.Error())
+		return status.Error(codes.Internal, "internal server error")
+	}
+
+	return withDetails(status.New(errorCode(err), err.Error()), domain.Code, fieldErrors(err))
+}
+
+// validationStatus reports the field errors of model.Validate like the
+// service layer's validation errors
+func validationStatus(err error) error {
+	var invalid *model.ValidationError
+	if !errors.As(err, &invalid) {
+		return statusError(err)
+	}
+	return withDetails(status.New(codes.InvalidArgument, invalid.Error()), service.ErrValidationFailed.Code, invalid.Fields)
+}
+
+// withDetails attaches the error code and any offending fields to st
+func withDetails(st *status.Status, code string, fields []model.FieldError) error {
+	if withInfo, err := st.WithDetails(&errdetails.ErrorInfo{Reason: code, Domain: ErrorDomain}); err == nil {
+		st = withInfo
+	}
+
+	if len(fields) > 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(fields))
+		for i, field := range fields {
+			violations[i] = &errdetails.BadRequest_FieldViolation{Field: field.Field, Description: field.Message}
+		}
+		if withFields, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+			st = withFields
+		}
+	}
+
+	return st.Err()
+}
+
+// errorCode maps the kind of a service error to a status code
+func errorCode(err error) codes.Code {
+	switch service.ErrorKindOf(err) {
+	case service.KindInvalid, service.KindValidation, service.KindUnsupported:
+		return codes.InvalidArgument
+	case service.KindUnauthorized:
+		return codes.Unauthenticated
+	case service.KindForbidden:
+		return codes.PermissionDenied
+	case service.KindNotFound:
+		return codes.NotFound
+	case service.KindConflict, service.KindPreconditionRequired:
+		return codes.FailedPrecondition
+	case service.KindPrecondition, service.KindAborted:
+		return codes.Aborted
+	default:
+		return codes.Internal
+	}
+}
+
+// fieldErrors returns the fields listed by a validation error in err's chain
+func fieldErrors(err error) []model.FieldError {
+	var invalid *model.ValidationError
+	if errors.As(err, &invalid) {
+		return invalid.Fields
+	}
+	return nil
+}
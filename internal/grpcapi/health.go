@@ -0,0 +1,71 @@
+package grpcapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/controller"
+	pb "github.com/loopsFreitag/DeviceRegistry/internal/pb/deviceregistry/v1"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthCheckInterval is how often the checkers are probed
+const healthCheckInterval = 5 * time.Second
+
+// servedServices are reported by the health service, along with the server
+// as a whole ("")
+var servedServices = []string{
+	"",
+	pb.DeviceService_ServiceDesc.ServiceName,
+	pb.AuthService_ServiceDesc.ServiceName,
+}
+
+// HealthChecker reports through the standard gRPC health service whether
+// the server is ready, probing the same checkers as /readyz
+type HealthChecker struct {
+	server   *health.Server
+	checkers []controller.Checker
+}
+
+func NewHealthChecker(checkers ...controller.Checker) *HealthChecker {
+	return &HealthChecker{
+		server:   health.NewServer(),
+		checkers: checkers,
+	}
+}
+
+// Run probes the checkers every interval until ctx is done, after which
+// every service reports NOT_SERVING so clients move elsewhere
+func (h *HealthChecker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		h.Check()
+
+		select {
+		case <-ctx.Done():
+			h.server.Shutdown()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check probes the checkers once and updates the served status
+func (h *HealthChecker) Check() {
+	status := healthpb.HealthCheckResponse_SERVING
+	for _, c := range h.checkers {
+		if err := c.Check(); err != nil {
+			log.Warn("gRPC health check failed. err: ", err.Error())
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+			break
+		}
+	}
+
+	for _, service := range servedServices {
+		h.server.SetServingStatus(service, status)
+	}
+}
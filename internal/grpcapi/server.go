@@ -0,0 +1,53 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/controller"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	pb "github.com/loopsFreitag/DeviceRegistry/internal/pb/deviceregistry/v1"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// NewServer builds the gRPC server: the device and auth services behind the
+// auth interceptors, health checking and server reflection. Devices are
+// served by deviceService and their changes watched on deviceStream, JWT
+// access tokens are verified by jwtService when token mode is on, and health
+// is probed until ctx is done.
+func NewServer(ctx context.Context, deviceService service.DeviceServiceInterface, deviceStream service.DeviceStreamInterface, jwtService service.JWTServiceInterface) *grpc.Server {
+	userRepo := repository.NewUserRepository(model.DBX())
+	authService := service.NewAuthService(userRepo)
+
+	healthChecker := NewHealthChecker(controller.NewDBChecker(model.DBX()))
+	go healthChecker.Run(ctx, healthCheckInterval)
+
+	tokenService := service.NewAPITokenService(repository.NewAPITokenRepository(model.DBX()))
+
+	authOptions := []AuthenticatorOption{WithJWTService(jwtService)}
+	if viper.GetBool("session.sliding") {
+		authOptions = append(authOptions, WithSlidingSessions(viper.GetDuration("session.max-lifetime")))
+	}
+
+	return newServer(authService, tokenService, deviceService, deviceStream, healthChecker, authOptions...)
+}
+
+func newServer(authService service.AuthServiceInterface, tokenService service.APITokenServiceInterface, deviceService service.DeviceServiceInterface, deviceStream service.DeviceStreamInterface, healthChecker *HealthChecker, authOptions ...AuthenticatorOption) *grpc.Server {
+	authenticator := NewAuthenticator(authService, tokenService, authOptions...)
+
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(authenticator.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(authenticator.StreamInterceptor),
+	)
+
+	pb.RegisterDeviceServiceServer(server, NewDeviceServer(deviceService, deviceStream))
+	pb.RegisterAuthServiceServer(server, NewAuthServer(authService))
+	healthpb.RegisterHealthServer(server, healthChecker.server)
+	reflection.Register(server)
+
+	return server
+}
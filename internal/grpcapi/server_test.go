@@ -0,0 +1,716 @@
+package grpcapi
+
+import (
+	"cmp"
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/controller"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	pb "github.com/loopsFreitag/DeviceRegistry/internal/pb/deviceregistry/v1"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// MockDeviceService mocks the device service calls the gRPC API makes
+type MockDeviceService struct {
+	service.DeviceServiceInterface
+	mock.Mock
+}
+
+func (m *MockDeviceService) GetDevices(filter repository.DeviceFilter) (*repository.DevicePage, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.DevicePage), args.Error(1)
+}
+
+func (m *MockDeviceService) GetDeviceByID(id string) (*model.Device, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Device), args.Error(1)
+}
+
+func (m *MockDeviceService) CreateDevice(device *model.Device, userID uuid.UUID) (*model.Device, error) {
+	args := m.Called(device, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Device), args.Error(1)
+}
+
+func (m *MockDeviceService) UpdateDevice(device *model.Device, userID uuid.UUID, fields service.DeviceFields) (*model.Device, error) {
+	args := m.Called(device, userID, fields)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Device), args.Error(1)
+}
+
+func (m *MockDeviceService) DeleteDevice(id string, version int, userID uuid.UUID) error {
+	args := m.Called(id, version, userID)
+	return args.Error(0)
+}
+
+// MockAuthService is a mock implementation of the auth service
+type MockAuthService struct {
+	mock.Mock
+}
+
+func (m *MockAuthService) CreateUser(email, password string) (*model.User, error) {
+	args := m.Called(email, password)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockAuthService) Login(email, password string) (*model.User, error) {
+	args := m.Called(email, password)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockAuthService) GetUserByID(userID uuid.UUID) (*model.User, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockAuthService) ListUsers(limit, offset int) ([]model.User, int, error) {
+	args := m.Called(limit, offset)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]model.User), args.Int(1), args.Error(2)
+}
+
+func (m *MockAuthService) UpdateUserRole(userID uuid.UUID, role model.Role, actorID uuid.UUID) (*model.User, error) {
+	args := m.Called(userID, role, actorID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+// MockAPITokenService mocks the API token checks the gRPC API makes
+type MockAPITokenService struct {
+	service.APITokenServiceInterface
+	mock.Mock
+}
+
+func (m *MockAPITokenService) Authenticate(token string) (*model.APIToken, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.APIToken), args.Error(1)
+}
+
+// MockJWTService mocks the access token checks the gRPC API makes
+type MockJWTService struct {
+	service.JWTServiceInterface
+	mock.Mock
+}
+
+func (m *MockJWTService) VerifyAccessToken(token string) (*model.AccessToken, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AccessToken), args.Error(1)
+}
+
+// fakeChangeLog is a change log whose latest change may still be in flight
+type fakeChangeLog struct {
+	mu       sync.Mutex
+	changes  []model.DeviceChange
+	horizon  int64
+	inFlight bool
+}
+
+// write hands out seq, and commits change unless it is nil
+func (f *fakeChangeLog) write(seq int64, change *model.DeviceChange) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if seq > f.horizon {
+		f.horizon = seq
+	}
+	f.inFlight = change == nil
+	if change != nil {
+		f.changes = append(f.changes, *change)
+		slices.SortFunc(f.changes, func(a, b model.DeviceChange) int { return cmp.Compare(a.Seq, b.Seq) })
+	}
+}
+
+func (f *fakeChangeLog) GetChangesSince(seq, until int64, filter repository.DeviceChangeFilter, limit int) ([]model.DeviceChange, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	changes := []model.DeviceChange{}
+	for _, change := range f.changes {
+		if change.Seq > seq && change.Seq <= until && filter.Matches(change) {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func (f *fakeChangeLog) GetChangeHorizon() (repository.ChangeHorizon, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return repository.ChangeHorizon{Seq: f.horizon}, nil
+}
+
+func (f *fakeChangeLog) HorizonSettled(horizon repository.ChangeHorizon) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.inFlight, nil
+}
+
+func (f *fakeChangeLog) DeleteChangesBefore(cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// fakeChangeSource announces the changes it is given
+type fakeChangeSource struct {
+	changes chan model.DeviceChange
+}
+
+func (f *fakeChangeSource) Listen(ctx context.Context, publish func(model.DeviceChange), reset func()) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case change := <-f.changes:
+			publish(change)
+		}
+	}
+}
+
+type fakeChecker struct {
+	err error
+}
+
+func (f fakeChecker) Check() error {
+	return f.err
+}
+
+type testServer struct {
+	t             *testing.T
+	conn          *grpc.ClientConn
+	authService   *MockAuthService
+	tokenService  *MockAPITokenService
+	jwtService    *MockJWTService
+	deviceService *MockDeviceService
+	stream        *service.DeviceStream
+	changeLog     *fakeChangeLog
+	health        *HealthChecker
+}
+
+// newTestServer serves the API over an in-memory connection
+func newTestServer(t *testing.T, checkers ...controller.Checker) *testServer {
+	ts := &testServer{
+		t:             t,
+		authService:   new(MockAuthService),
+		tokenService:  new(MockAPITokenService),
+		jwtService:    new(MockJWTService),
+		deviceService: new(MockDeviceService),
+		changeLog:     &fakeChangeLog{},
+	}
+	ts.stream = service.NewDeviceStream(ts.changeLog)
+	ts.health = NewHealthChecker(checkers...)
+
+	listener := bufconn.Listen(1 << 20)
+	server := newServer(ts.authService, ts.tokenService, ts.deviceService, ts.stream, ts.health, WithJWTService(ts.jwtService))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	ts.conn = conn
+
+	return ts
+}
+
+// login opens a session for user and returns a context sending it
+func (ts *testServer) login(user *model.User) context.Context {
+	sessionID, err := model.GetSessionStore().Create(model.NewSession(user.ID, "", ""), time.Hour)
+	require.NoError(ts.t, err)
+	ts.authService.On("GetUserByID", user.ID).Return(user, nil)
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+sessionID)
+}
+
+func TestDeviceService_RequiresCredentials(t *testing.T) {
+	ts := newTestServer(t)
+	client := pb.NewDeviceServiceClient(ts.conn)
+
+	t.Run("no credentials", func(t *testing.T) {
+		_, err := client.GetDevice(context.Background(), &pb.GetDeviceRequest{Id: uuid.NewString()})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("unknown session", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+uuid.NewString())
+		_, err := client.GetDevice(ctx, &pb.GetDeviceRequest{Id: uuid.NewString()})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("streams too", func(t *testing.T) {
+		stream, err := client.WatchDevices(context.Background(), &pb.WatchDevicesRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+// errorReason returns the code in the ErrorInfo detail of err
+func errorReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+// bearer returns a context sending token as a bearer token
+func bearer(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestDeviceService_APITokens(t *testing.T) {
+	ts := newTestServer(t)
+	client := pb.NewDeviceServiceClient(ts.conn)
+
+	user := &model.User{ID: uuid.New(), Email: "admin@example.com", Role: model.RoleAdmin}
+	ts.authService.On("GetUserByID", user.ID).Return(user, nil)
+	ts.tokenService.On("Authenticate", "drt_read").
+		Return(&model.APIToken{UserID: user.ID, Scopes: []string{string(model.PermissionReadDevices)}}, nil)
+	ts.tokenService.On("Authenticate", "drt_revoked").Return(nil, service.ErrInvalidAPIToken)
+
+	t.Run("granted scope", func(t *testing.T) {
+		device := &model.Device{ID: uuid.New(), Name: "iPhone", Brand: "Apple", State: model.StateAvailable}
+		ts.deviceService.On("GetDeviceByID", device.ID.String()).Return(device, nil).Once()
+
+		_, err := client.GetDevice(bearer("drt_read"), &pb.GetDeviceRequest{Id: device.ID.String()})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("missing scope", func(t *testing.T) {
+		_, err := client.DeleteDevice(bearer("drt_read"), &pb.DeleteDeviceRequest{Id: uuid.NewString()})
+
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		assert.Equal(t, "insufficient_scope", errorReason(err))
+		ts.deviceService.AssertNotCalled(t, "DeleteDevice", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, err := client.GetDevice(bearer("drt_revoked"), &pb.GetDeviceRequest{Id: uuid.NewString()})
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+func TestDeviceService_AccessTokens(t *testing.T) {
+	ts := newTestServer(t)
+	client := pb.NewDeviceServiceClient(ts.conn)
+
+	user := &model.User{ID: uuid.New(), Email: "viewer@example.com", Role: model.RoleViewer}
+	ts.authService.On("GetUserByID", user.ID).Return(user, nil)
+	ts.jwtService.On("VerifyAccessToken", "header.claims.signature").Return(&model.AccessToken{UserID: user.ID}, nil)
+	ts.jwtService.On("VerifyAccessToken", "header.claims.forged").Return(nil, service.ErrInvalidAccessToken)
+
+	t.Run("valid token", func(t *testing.T) {
+		device := &model.Device{ID: uuid.New(), Name: "iPhone", Brand: "Apple", State: model.StateAvailable}
+		ts.deviceService.On("GetDeviceByID", device.ID.String()).Return(device, nil).Once()
+
+		_, err := client.GetDevice(bearer("header.claims.signature"), &pb.GetDeviceRequest{Id: device.ID.String()})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("role still applies", func(t *testing.T) {
+		_, err := client.DeleteDevice(bearer("header.claims.signature"), &pb.DeleteDeviceRequest{Id: uuid.NewString()})
+
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, err := client.GetDevice(bearer("header.claims.forged"), &pb.GetDeviceRequest{Id: uuid.NewString()})
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		assert.Equal(t, "invalid_access_token", errorReason(err))
+	})
+}
+
+func TestDeviceService_RequiresPermission(t *testing.T) {
+	ts := newTestServer(t)
+	client := pb.NewDeviceServiceClient(ts.conn)
+	ctx := ts.login(&model.User{ID: uuid.New(), Email: "viewer@example.com", Role: model.RoleViewer})
+
+	t.Run("viewers cannot delete", func(t *testing.T) {
+		_, err := client.DeleteDevice(ctx, &pb.DeleteDeviceRequest{Id: uuid.NewString()})
+
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		ts.deviceService.AssertNotCalled(t, "DeleteDevice", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("viewers can read", func(t *testing.T) {
+		device := &model.Device{ID: uuid.New(), Name: "iPhone", Brand: "Apple", State: model.StateAvailable}
+		ts.deviceService.On("GetDeviceByID", device.ID.String()).Return(device, nil).Once()
+
+		_, err := client.GetDevice(ctx, &pb.GetDeviceRequest{Id: device.ID.String()})
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestDeviceService_SessionCookie(t *testing.T) {
+	ts := newTestServer(t)
+	client := pb.NewDeviceServiceClient(ts.conn)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", Role: model.RoleAdmin}
+	sessionID, err := model.GetSessionStore().Create(model.NewSession(user.ID, "", ""), time.Hour)
+	require.NoError(t, err)
+	ts.authService.On("GetUserByID", user.ID).Return(user, nil)
+
+	device := &model.Device{ID: uuid.New(), Name: "iPhone", Brand: "Apple", State: model.StateAvailable, Version: 3}
+	ts.deviceService.On("GetDeviceByID", device.ID.String()).Return(device, nil)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "cookie", "theme=dark; session_id="+sessionID)
+	resp, err := client.GetDevice(ctx, &pb.GetDeviceRequest{Id: device.ID.String()})
+
+	require.NoError(t, err)
+	assert.Equal(t, "iPhone", resp.GetDevice().GetName())
+	assert.Equal(t, pb.DeviceState_DEVICE_STATE_AVAILABLE, resp.GetDevice().GetState())
+	assert.Equal(t, int32(3), resp.GetDevice().GetVersion())
+}
+
+func TestListDevices(t *testing.T) {
+	ts := newTestServer(t)
+	client := pb.NewDeviceServiceClient(ts.conn)
+	ctx := ts.login(&model.User{ID: uuid.New(), Email: "test@example.com", Role: model.RoleAdmin})
+
+	t.Run("filters and pages", func(t *testing.T) {
+		total := 7
+		ts.deviceService.On("GetDevices", mock.MatchedBy(func(f repository.DeviceFilter) bool {
+			return f.Limit == 2 && f.Cursor == "abc" && f.IncludeTotal &&
+				assert.ObjectsAreEqual([]string{"Apple"}, f.Brands) &&
+				assert.ObjectsAreEqual([]model.DeviceState{model.StateInUse}, f.States) &&
+				f.Sort.Descending
+		})).Return(&repository.DevicePage{
+			Devices:    []model.Device{{ID: uuid.New(), Name: "iPhone"}, {ID: uuid.New(), Name: "iPad"}},
+			NextCursor: "def",
+			Total:      &total,
+		}, nil).Once()
+
+		resp, err := client.ListDevices(ctx, &pb.ListDevicesRequest{
+			Brands:       []string{"Apple"},
+			States:       []pb.DeviceState{pb.DeviceState_DEVICE_STATE_IN_USE},
+			Sort:         "-name",
+			PageSize:     2,
+			PageToken:    "abc",
+			IncludeTotal: true,
+		})
+
+		require.NoError(t, err)
+		assert.Len(t, resp.GetDevices(), 2)
+		assert.Equal(t, "def", resp.GetNextPageToken())
+		assert.Equal(t, int32(7), resp.GetTotal())
+	})
+
+	t.Run("invalid page size", func(t *testing.T) {
+		_, err := client.ListDevices(ctx, &pb.ListDevicesRequest{PageSize: 500})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("invalid sort", func(t *testing.T) {
+		_, err := client.ListDevices(ctx, &pb.ListDevicesRequest{Sort: "color"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestCreateDevice_ValidationDetails(t *testing.T) {
+	ts := newTestServer(t)
+	client := pb.NewDeviceServiceClient(ts.conn)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", Role: model.RoleAdmin}
+	ctx := ts.login(user)
+
+	invalid := &model.ValidationError{Fields: []model.FieldError{{Field: "name", Rule: "max", Message: "name must be at most 255 characters long"}}}
+	ts.deviceService.On("CreateDevice", mock.MatchedBy(func(d *model.Device) bool {
+		return d.State == model.StateInactive
+	}), user.ID).Return(nil, &service.Error{Kind: service.KindValidation, Code: "validation_failed", Message: invalid.Error(), Err: invalid})
+
+	_, err := client.CreateDevice(ctx, &pb.CreateDeviceRequest{Name: "x", Brand: "Apple"})
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+
+	var reason string
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			reason = d.GetReason()
+		case *errdetails.BadRequest:
+			violations = d.GetFieldViolations()
+		}
+	}
+	assert.Equal(t, "validation_failed", reason)
+	if assert.Len(t, violations, 1) {
+		assert.Equal(t, "name", violations[0].GetField())
+	}
+}
+
+func TestUpdateDevice(t *testing.T) {
+	ts := newTestServer(t)
+	client := pb.NewDeviceServiceClient(ts.conn)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", Role: model.RoleAdmin}
+	ctx := ts.login(user)
+	deviceID := uuid.New()
+
+	t.Run("version mismatch", func(t *testing.T) {
+		ts.deviceService.On("UpdateDevice", mock.MatchedBy(func(d *model.Device) bool {
+			return d.ID == deviceID && d.Version == 2 && d.State == model.StateMaintenance
+		}), user.ID, mock.Anything).Return(nil, service.ErrVersionMismatch).Once()
+
+		_, err := client.UpdateDevice(ctx, &pb.UpdateDeviceRequest{
+			Id:      deviceID.String(),
+			Name:    "iPhone",
+			Brand:   "Apple",
+			State:   pb.DeviceState_DEVICE_STATE_MAINTENANCE,
+			Version: 2,
+		})
+
+		assert.Equal(t, codes.Aborted, status.Code(err))
+	})
+
+	t.Run("state is required", func(t *testing.T) {
+		_, err := client.UpdateDevice(ctx, &pb.UpdateDeviceRequest{Id: deviceID.String(), Name: "iPhone", Brand: "Apple", Version: 2})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("version is required", func(t *testing.T) {
+		_, err := client.UpdateDevice(ctx, &pb.UpdateDeviceRequest{
+			Id:    deviceID.String(),
+			Name:  "iPhone",
+			Brand: "Apple",
+			State: pb.DeviceState_DEVICE_STATE_MAINTENANCE,
+		})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, "precondition_required", errorReason(err))
+	})
+}
+
+func TestDeleteDevice_NotFound(t *testing.T) {
+	ts := newTestServer(t)
+	client := pb.NewDeviceServiceClient(ts.conn)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", Role: model.RoleAdmin}
+	ctx := ts.login(user)
+	deviceID := uuid.NewString()
+
+	ts.deviceService.On("DeleteDevice", deviceID, 1, user.ID).Return(service.ErrDeviceNotFound)
+
+	_, err := client.DeleteDevice(ctx, &pb.DeleteDeviceRequest{Id: deviceID, Version: 1})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestDeleteDevice_VersionRequired(t *testing.T) {
+	ts := newTestServer(t)
+	client := pb.NewDeviceServiceClient(ts.conn)
+	ctx := ts.login(&model.User{ID: uuid.New(), Email: "test@example.com", Role: model.RoleAdmin})
+
+	_, err := client.DeleteDevice(ctx, &pb.DeleteDeviceRequest{Id: uuid.NewString()})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "precondition_required", errorReason(err))
+	ts.deviceService.AssertNotCalled(t, "DeleteDevice", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWatchDevices_ReplaysThenStreams(t *testing.T) {
+	ts := newTestServer(t)
+	client := pb.NewDeviceServiceClient(ts.conn)
+	ctx, cancel := context.WithCancel(ts.login(&model.User{ID: uuid.New(), Email: "test@example.com", Role: model.RoleAdmin}))
+	defer cancel()
+
+	device := model.Device{ID: uuid.New(), Name: "iPhone", Brand: "Apple"}
+	ts.changeLog.write(5, &model.DeviceChange{Seq: 5, Type: model.DeviceEventCreated, Device: device})
+
+	source := &fakeChangeSource{changes: make(chan model.DeviceChange)}
+	go ts.stream.Run(ctx, source)
+	<-ts.stream.Ready()
+
+	stream, err := client.WatchDevices(ctx, &pb.WatchDevicesRequest{AfterSeq: 4})
+	require.NoError(t, err)
+
+	first, err := stream.Recv()
+	require.NoError(t, err)
+
+	// the replay is done, so the subscription is in place
+	changed := model.DeviceChange{Seq: 6, Type: model.DeviceEventStateChanged, Device: device}
+	ts.changeLog.write(6, &changed)
+	source.changes <- changed
+
+	second, err := stream.Recv()
+	require.NoError(t, err)
+
+	// 7 is taken first but commits after 8, which must wait for it
+	updated := model.DeviceChange{Seq: 7, Type: model.DeviceEventUpdated, Device: device}
+	deleted := model.DeviceChange{Seq: 8, Type: model.DeviceEventDeleted, Device: device}
+	ts.changeLog.write(7, nil)
+	ts.changeLog.write(8, &deleted)
+	source.changes <- deleted
+	ts.changeLog.write(7, &updated)
+	source.changes <- updated
+
+	third, err := stream.Recv()
+	require.NoError(t, err)
+	fourth, err := stream.Recv()
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(5), first.GetChange().GetSeq())
+	assert.Equal(t, pb.DeviceChangeType_DEVICE_CHANGE_TYPE_CREATED, first.GetChange().GetType())
+	assert.Equal(t, int64(6), second.GetChange().GetSeq())
+	assert.Equal(t, pb.DeviceChangeType_DEVICE_CHANGE_TYPE_STATE_CHANGED, second.GetChange().GetType())
+	assert.Equal(t, int64(7), third.GetChange().GetSeq())
+	assert.Equal(t, int64(8), fourth.GetChange().GetSeq())
+}
+
+func TestAuthService_Login(t *testing.T) {
+	ts := newTestServer(t)
+	client := pb.NewAuthServiceClient(ts.conn)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	t.Run("opens a session", func(t *testing.T) {
+		ts.authService.On("Login", "test@example.com", "password").Return(user, nil).Once()
+
+		resp, err := client.Login(context.Background(), &pb.LoginRequest{Email: "test@example.com", Password: "password"})
+
+		require.NoError(t, err)
+		assert.Equal(t, user.ID.String(), resp.GetUser().GetId())
+		session, err := model.GetSessionStore().Get(resp.GetSessionId())
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, session.UserID)
+		assert.Contains(t, session.UserAgent, "grpc-go/")
+		assert.True(t, resp.GetExpiresAt().AsTime().Equal(session.ExpiresAt))
+	})
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		ts.authService.On("Login", "test@example.com", "wrong").Return(nil, service.ErrInvalidCredentials).Once()
+
+		_, err := client.Login(context.Background(), &pb.LoginRequest{Email: "test@example.com", Password: "wrong"})
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("missing fields", func(t *testing.T) {
+		_, err := client.Login(context.Background(), &pb.LoginRequest{Email: "test@example.com"})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestHealth_FollowsCheckers(t *testing.T) {
+	t.Run("ready", func(t *testing.T) {
+		ts := newTestServer(t, fakeChecker{})
+		ts.health.Check()
+
+		resp, err := healthpb.NewHealthClient(ts.conn).Check(context.Background(), &healthpb.HealthCheckRequest{
+			Service: pb.DeviceService_ServiceDesc.ServiceName,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+	})
+
+	t.Run("database down", func(t *testing.T) {
+		ts := newTestServer(t, fakeChecker{err: assert.AnError})
+		ts.health.Check()
+
+		resp, err := healthpb.NewHealthClient(ts.conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.GetStatus())
+	})
+}
+
+func TestAuthenticator_SlidingSessions(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", Role: model.RoleViewer}
+
+	authenticate := func(opts ...AuthenticatorOption) (*model.Session, *model.Session) {
+		authService := new(MockAuthService)
+		authService.On("GetUserByID", user.ID).Return(user, nil)
+		authenticator := NewAuthenticator(authService, new(MockAPITokenService), opts...)
+		sessions := model.NewMemorySessionStore()
+		authenticator.sessions = sessions
+
+		sessionID, err := sessions.Create(model.NewSession(user.ID, "", ""), time.Hour)
+		require.NoError(t, err)
+
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+sessionID))
+		ctx, err = authenticator.authenticate(ctx, pb.DeviceService_GetDevice_FullMethodName)
+		require.NoError(t, err)
+
+		stored, err := sessions.Get(sessionID)
+		require.NoError(t, err)
+		return model.SessionFromContext(ctx), stored
+	}
+
+	t.Run("calls extend the session", func(t *testing.T) {
+		seen, stored := authenticate(WithSlidingSessions(0))
+
+		assert.WithinDuration(t, time.Now().Add(controller.SessionDuration), seen.ExpiresAt, time.Minute)
+		assert.Equal(t, seen.ExpiresAt, stored.ExpiresAt)
+	})
+
+	t.Run("no further than the max lifetime", func(t *testing.T) {
+		seen, stored := authenticate(WithSlidingSessions(2 * time.Hour))
+
+		assert.Equal(t, seen.CreatedAt.Add(2*time.Hour), stored.ExpiresAt)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		seen, stored := authenticate()
+
+		assert.WithinDuration(t, time.Now().Add(time.Hour), stored.ExpiresAt, time.Minute)
+		assert.Equal(t, stored.ExpiresAt, seen.ExpiresAt)
+	})
+}
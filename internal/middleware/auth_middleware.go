@@ -2,67 +2,167 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/controller"
 	"github.com/loopsFreitag/DeviceRegistry/internal/model"
 	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	log "github.com/sirupsen/logrus"
 )
 
-type contextKey string
-
-const UserContextKey contextKey = "user"
+const UserContextKey = model.UserContextKey
 
 type AuthMiddleware struct {
-	authService service.AuthServiceInterface
-	sessions    *model.SessionStore
+	authService  service.AuthServiceInterface
+	tokenService service.APITokenServiceInterface
+	jwt          service.JWTServiceInterface
+	sessions     model.SessionStore
+
+	slidingSessions    bool
+	maxSessionLifetime time.Duration
+}
+
+// AuthMiddlewareOption is a functional option to configure the AuthMiddleware.
+type AuthMiddlewareOption func(*AuthMiddleware)
+
+// WithJWTService makes RequireAuth accept JWT access tokens as bearer
+// tokens, alongside API tokens
+func WithJWTService(jwt service.JWTServiceInterface) AuthMiddlewareOption {
+	return func(am *AuthMiddleware) {
+		am.jwt = jwt
+	}
 }
 
-func NewAuthMiddleware(authService service.AuthServiceInterface) *AuthMiddleware {
-	return &AuthMiddleware{
-		authService: authService,
-		sessions:    model.GetSessionStore(),
+// WithSlidingSessions makes sessions expire after a SessionDuration without
+// requests instead of a SessionDuration after login. A positive maxLifetime
+// still ends them that long after login, however active.
+func WithSlidingSessions(maxLifetime time.Duration) AuthMiddlewareOption {
+	return func(am *AuthMiddleware) {
+		am.slidingSessions = true
+		am.maxSessionLifetime = maxLifetime
+	}
+}
+
+func NewAuthMiddleware(authService service.AuthServiceInterface, tokenService service.APITokenServiceInterface, opts ...AuthMiddlewareOption) *AuthMiddleware {
+	am := &AuthMiddleware{
+		authService:  authService,
+		tokenService: tokenService,
+		sessions:     model.GetSessionStore(),
+	}
+	for _, opt := range opts {
+		opt(am)
 	}
+	return am
 }
 
+// RequireAuth lets through requests made with an API token or a JWT access
+// token, sent as "Authorization: Bearer <token>", or with a session cookie,
+// and puts the user along with the token or session in the request context
 func (am *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if header := r.Header.Get("Authorization"); header != "" {
+			am.requireToken(w, r, header, next)
+			return
+		}
+
 		cookie, err := r.Cookie("session_id")
 		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			controller.WriteProblem(w, r, http.StatusUnauthorized, controller.CodeUnauthorized, "Unauthorized")
 			return
 		}
 
-		session, exists := am.sessions.Get(cookie.Value)
-		if !exists {
-			respondWithError(w, http.StatusUnauthorized, "Invalid or expired session")
+		session, err := am.sessions.Get(cookie.Value)
+		if errors.Is(err, model.ErrSessionNotFound) {
+			controller.WriteProblem(w, r, http.StatusUnauthorized, controller.CodeInvalidSession, "Invalid or expired session")
+			return
+		}
+		if err != nil {
+			controller.WriteError(w, r, err)
 			return
 		}
 
 		// Get user from database
 		user, err := am.authService.GetUserByID(session.UserID)
 		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "User not found")
+			controller.WriteProblem(w, r, http.StatusUnauthorized, controller.CodeUnauthorized, "User not found")
 			return
 		}
 
-		// Add user to context
+		if am.slidingSessions {
+			am.extendSession(w, cookie.Value, session)
+		}
+
+		// Add user and session to context
 		ctx := context.WithValue(r.Context(), UserContextKey, user)
+		ctx = context.WithValue(ctx, model.SessionContextKey, session)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// Helper function to get user from context
-func GetUserFromContext(ctx context.Context) *model.User {
-	user, ok := ctx.Value(UserContextKey).(*model.User)
+// extendSession pushes the expiry of session back to a SessionDuration from
+// now, capped at the max lifetime, and renews its cookie. Failing to is not
+// worth failing the request over.
+func (am *AuthMiddleware) extendSession(w http.ResponseWriter, token string, session *model.Session) {
+	expiresAt, ok := session.SlidingExpiry(controller.SessionDuration, am.maxSessionLifetime)
 	if !ok {
-		return nil
+		return
+	}
+
+	if err := am.sessions.Extend(session.ID, expiresAt); err != nil {
+		log.Warnf("failed to extend session %s. err: %s", session.ID, err.Error())
+		return
 	}
-	return user
+	session.ExpiresAt = expiresAt
+	controller.SetSessionCookie(w, token, expiresAt)
 }
 
-// Local helper for errors
-func respondWithError(w http.ResponseWriter, code int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	w.Write([]byte(`{"status":"error","message":"` + message + `"}`))
+// requireToken authenticates a request by the bearer token in its
+// Authorization header. API tokens are told apart from JWTs by their prefix.
+func (am *AuthMiddleware) requireToken(w http.ResponseWriter, r *http.Request, header string, next http.Handler) {
+	scheme, credential, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		controller.WriteProblem(w, r, http.StatusUnauthorized, controller.CodeUnauthorized, "Unsupported authorization scheme, use Bearer")
+		return
+	}
+	credential = strings.TrimSpace(credential)
+
+	var userID uuid.UUID
+	ctx := r.Context()
+	if am.jwt != nil && !strings.HasPrefix(credential, model.APITokenPrefix) {
+		token, err := am.jwt.VerifyAccessToken(credential)
+		if err != nil {
+			controller.WriteError(w, r, err)
+			return
+		}
+		userID = token.UserID
+		ctx = context.WithValue(ctx, model.AccessTokenContextKey, token)
+	} else {
+		token, err := am.tokenService.Authenticate(credential)
+		if err != nil {
+			controller.WriteError(w, r, err)
+			return
+		}
+		userID = token.UserID
+		ctx = context.WithValue(ctx, model.APITokenContextKey, token)
+	}
+
+	// The role comes from the database rather than the access token, so
+	// role changes apply right away
+	user, err := am.authService.GetUserByID(userID)
+	if err != nil {
+		controller.WriteProblem(w, r, http.StatusUnauthorized, controller.CodeUnauthorized, "User not found")
+		return
+	}
+
+	ctx = context.WithValue(ctx, UserContextKey, user)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// Helper function to get user from context
+func GetUserFromContext(ctx context.Context) *model.User {
+	return model.UserFromContext(ctx)
 }
@@ -12,8 +12,10 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockAuthService is a mock implementation of AuthServiceInterface
@@ -29,6 +31,22 @@ func (m *MockAuthService) GetUserByID(id uuid.UUID) (*model.User, error) {
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
+func (m *MockAuthService) ListUsers(limit, offset int) ([]model.User, int, error) {
+	args := m.Called(limit, offset)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]model.User), args.Int(1), args.Error(2)
+}
+
+func (m *MockAuthService) UpdateUserRole(userID uuid.UUID, role model.Role, actorID uuid.UUID) (*model.User, error) {
+	args := m.Called(userID, role, actorID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
 func (m *MockAuthService) CreateUser(email, password string) (*model.User, error) {
 	args := m.Called(email, password)
 	if args.Get(0) == nil {
@@ -69,10 +87,88 @@ func testHandler() http.Handler {
 	})
 }
 
+// MockAPITokenService is a mock implementation of APITokenServiceInterface
+type MockAPITokenService struct {
+	mock.Mock
+}
+
+func (m *MockAPITokenService) GetTokens(userID uuid.UUID) ([]model.APIToken, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.APIToken), args.Error(1)
+}
+
+func (m *MockAPITokenService) CreateToken(token *model.APIToken, role model.Role) (*model.APIToken, error) {
+	args := m.Called(token, role)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.APIToken), args.Error(1)
+}
+
+func (m *MockAPITokenService) RevokeToken(id string, userID uuid.UUID) error {
+	args := m.Called(id, userID)
+	return args.Error(0)
+}
+
+func (m *MockAPITokenService) Authenticate(token string) (*model.APIToken, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.APIToken), args.Error(1)
+}
+
+// MockJWTService is a mock implementation of JWTServiceInterface
+type MockJWTService struct {
+	mock.Mock
+}
+
+func (m *MockJWTService) IssueTokens(user *model.User) (*model.TokenPair, error) {
+	args := m.Called(user)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TokenPair), args.Error(1)
+}
+
+func (m *MockJWTService) RefreshTokens(refreshToken string) (*model.TokenPair, error) {
+	args := m.Called(refreshToken)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TokenPair), args.Error(1)
+}
+
+func (m *MockJWTService) VerifyAccessToken(token string) (*model.AccessToken, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AccessToken), args.Error(1)
+}
+
+func (m *MockJWTService) RevokeLogin(familyID uuid.UUID) error {
+	args := m.Called(familyID)
+	return args.Error(0)
+}
+
+func (m *MockJWTService) RevokeUserLogins(userID uuid.UUID) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockJWTService) JWKS() model.JSONWebKeySet {
+	args := m.Called()
+	return args.Get(0).(model.JSONWebKeySet)
+}
+
 func TestAuthMiddleware_RequireAuth(t *testing.T) {
 	t.Run("successful authentication", func(t *testing.T) {
 		mockAuthService := new(MockAuthService)
-		middleware := NewAuthMiddleware(mockAuthService)
+		middleware := NewAuthMiddleware(mockAuthService, new(MockAPITokenService))
 
 		// Create a test user
 		userID := uuid.New()
@@ -86,7 +182,9 @@ func TestAuthMiddleware_RequireAuth(t *testing.T) {
 
 		// Create a session using the SessionStore
 		sessionStore := model.GetSessionStore()
-		sessionID := sessionStore.Create(userID, user.Email, 24*time.Hour)
+		session := model.NewSession(userID, "", "")
+		sessionID, err := sessionStore.Create(session, 24*time.Hour)
+		require.NoError(t, err)
 
 		// Mock the GetUserByID call
 		mockAuthService.On("GetUserByID", userID).Return(user, nil)
@@ -116,12 +214,12 @@ func TestAuthMiddleware_RequireAuth(t *testing.T) {
 		mockAuthService.AssertExpectations(t)
 
 		// Clean up
-		sessionStore.Delete(sessionID)
+		sessionStore.Delete(userID, session.ID)
 	})
 
 	t.Run("no session cookie", func(t *testing.T) {
 		mockAuthService := new(MockAuthService)
-		middleware := NewAuthMiddleware(mockAuthService)
+		middleware := NewAuthMiddleware(mockAuthService, new(MockAPITokenService))
 
 		// Create request without session cookie
 		req := httptest.NewRequest("GET", "/protected", nil)
@@ -136,13 +234,13 @@ func TestAuthMiddleware_RequireAuth(t *testing.T) {
 
 		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "error", response["status"])
-		assert.Equal(t, "Unauthorized", response["message"])
+		assert.Equal(t, "unauthorized", response["code"])
+		assert.Equal(t, "Unauthorized", response["detail"])
 	})
 
 	t.Run("invalid session cookie", func(t *testing.T) {
 		mockAuthService := new(MockAuthService)
-		middleware := NewAuthMiddleware(mockAuthService)
+		middleware := NewAuthMiddleware(mockAuthService, new(MockAPITokenService))
 
 		// Create request with invalid session cookie
 		req := httptest.NewRequest("GET", "/protected", nil)
@@ -161,19 +259,21 @@ func TestAuthMiddleware_RequireAuth(t *testing.T) {
 
 		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "error", response["status"])
-		assert.Equal(t, "Invalid or expired session", response["message"])
+		assert.Equal(t, "invalid_session", response["code"])
+		assert.Equal(t, "Invalid or expired session", response["detail"])
 	})
 
 	t.Run("expired session", func(t *testing.T) {
 		mockAuthService := new(MockAuthService)
-		middleware := NewAuthMiddleware(mockAuthService)
+		middleware := NewAuthMiddleware(mockAuthService, new(MockAPITokenService))
 
 		userID := uuid.New()
 
 		// Create an expired session (negative duration)
 		sessionStore := model.GetSessionStore()
-		sessionID := sessionStore.Create(userID, "test@example.com", -1*time.Hour)
+		session := model.NewSession(userID, "", "")
+		sessionID, err := sessionStore.Create(session, -1*time.Hour)
+		require.NoError(t, err)
 
 		// Create request with expired session cookie
 		req := httptest.NewRequest("GET", "/protected", nil)
@@ -192,21 +292,23 @@ func TestAuthMiddleware_RequireAuth(t *testing.T) {
 
 		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "error", response["status"])
+		assert.Equal(t, "invalid_session", response["code"])
 
 		// Clean up
-		sessionStore.Delete(sessionID)
+		sessionStore.Delete(userID, session.ID)
 	})
 
 	t.Run("user not found in database", func(t *testing.T) {
 		mockAuthService := new(MockAuthService)
-		middleware := NewAuthMiddleware(mockAuthService)
+		middleware := NewAuthMiddleware(mockAuthService, new(MockAPITokenService))
 
 		userID := uuid.New()
 
 		// Create a valid session
 		sessionStore := model.GetSessionStore()
-		sessionID := sessionStore.Create(userID, "test@example.com", 24*time.Hour)
+		session := model.NewSession(userID, "", "")
+		sessionID, err := sessionStore.Create(session, 24*time.Hour)
+		require.NoError(t, err)
 
 		// Mock GetUserByID to return error (user not found)
 		mockAuthService.On("GetUserByID", userID).Return(nil, errors.New("user not found"))
@@ -228,18 +330,18 @@ func TestAuthMiddleware_RequireAuth(t *testing.T) {
 
 		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "error", response["status"])
-		assert.Equal(t, "User not found", response["message"])
+		assert.Equal(t, "unauthorized", response["code"])
+		assert.Equal(t, "User not found", response["detail"])
 
 		mockAuthService.AssertExpectations(t)
 
 		// Clean up
-		sessionStore.Delete(sessionID)
+		sessionStore.Delete(userID, session.ID)
 	})
 
 	t.Run("user context is properly set", func(t *testing.T) {
 		mockAuthService := new(MockAuthService)
-		middleware := NewAuthMiddleware(mockAuthService)
+		middleware := NewAuthMiddleware(mockAuthService, new(MockAPITokenService))
 
 		userID := uuid.New()
 		user := &model.User{
@@ -252,7 +354,9 @@ func TestAuthMiddleware_RequireAuth(t *testing.T) {
 
 		// Create a session
 		sessionStore := model.GetSessionStore()
-		sessionID := sessionStore.Create(userID, user.Email, 24*time.Hour)
+		session := model.NewSession(userID, "", "")
+		sessionID, err := sessionStore.Create(session, 24*time.Hour)
+		require.NoError(t, err)
 
 		mockAuthService.On("GetUserByID", userID).Return(user, nil)
 
@@ -270,6 +374,9 @@ func TestAuthMiddleware_RequireAuth(t *testing.T) {
 			assert.NotNil(t, contextUser)
 			assert.Equal(t, userID, contextUser.ID)
 			assert.Equal(t, "context@example.com", contextUser.Email)
+			contextSession := model.SessionFromContext(r.Context())
+			assert.NotNil(t, contextSession)
+			assert.Equal(t, session.ID, contextSession.ID)
 			w.WriteHeader(http.StatusOK)
 		})
 
@@ -280,7 +387,205 @@ func TestAuthMiddleware_RequireAuth(t *testing.T) {
 		mockAuthService.AssertExpectations(t)
 
 		// Clean up
-		sessionStore.Delete(sessionID)
+		sessionStore.Delete(userID, session.ID)
+	})
+}
+
+func TestAuthMiddleware_RequireAuthToken(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "ci@example.com", Role: model.RoleOperator}
+	token := &model.APIToken{ID: uuid.New(), UserID: user.ID, Scopes: []string{"devices:read"}}
+
+	serve := func(middleware *AuthMiddleware, authorization string, handler http.Handler) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/devices", nil)
+		req.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		middleware.RequireAuth(handler).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		mockAuthService := new(MockAuthService)
+		mockTokenService := new(MockAPITokenService)
+		mockTokenService.On("Authenticate", "drt_secret").Return(token, nil).Once()
+		mockAuthService.On("GetUserByID", user.ID).Return(user, nil).Once()
+
+		w := serve(NewAuthMiddleware(mockAuthService, mockTokenService), "Bearer drt_secret",
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, user.ID, GetUserFromContext(r.Context()).ID)
+				assert.Equal(t, token.ID, model.APITokenFromContext(r.Context()).ID)
+				assert.Nil(t, model.SessionFromContext(r.Context()))
+				w.WriteHeader(http.StatusOK)
+			}))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockAuthService.AssertExpectations(t)
+		mockTokenService.AssertExpectations(t)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		mockTokenService := new(MockAPITokenService)
+		mockTokenService.On("Authenticate", "drt_revoked").Return(nil, service.ErrInvalidAPIToken).Once()
+
+		w := serve(NewAuthMiddleware(new(MockAuthService), mockTokenService), "Bearer drt_revoked", testHandler())
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, "invalid_api_token", response["code"])
+	})
+
+	t.Run("other schemes", func(t *testing.T) {
+		w := serve(NewAuthMiddleware(new(MockAuthService), new(MockAPITokenService)), "Basic dXNlcjpwYXNz", testHandler())
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockAuthService := new(MockAuthService)
+		mockTokenService := new(MockAPITokenService)
+		mockTokenService.On("Authenticate", "drt_secret").Return(token, nil).Once()
+		mockAuthService.On("GetUserByID", user.ID).Return(nil, service.ErrUserNotFound).Once()
+
+		w := serve(NewAuthMiddleware(mockAuthService, mockTokenService), "Bearer drt_secret", testHandler())
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestAuthMiddleware_RequireAuthJWT(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "gateway@example.com", Role: model.RoleViewer}
+	access := &model.AccessToken{ID: uuid.NewString(), UserID: user.ID, FamilyID: uuid.New(), Role: model.RoleAdmin}
+
+	serve := func(middleware *AuthMiddleware, token string, handler http.Handler) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/devices", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		middleware.RequireAuth(handler).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("valid access token", func(t *testing.T) {
+		mockAuthService := new(MockAuthService)
+		mockJWT := new(MockJWTService)
+		mockJWT.On("VerifyAccessToken", "eyJ.access.token").Return(access, nil).Once()
+		mockAuthService.On("GetUserByID", user.ID).Return(user, nil).Once()
+
+		middleware := NewAuthMiddleware(mockAuthService, new(MockAPITokenService), WithJWTService(mockJWT))
+		w := serve(middleware, "eyJ.access.token", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the role is the current one, not the one in the token
+			assert.Equal(t, model.RoleViewer, GetUserFromContext(r.Context()).Role)
+			assert.Equal(t, access.FamilyID, model.AccessTokenFromContext(r.Context()).FamilyID)
+			assert.Nil(t, model.APITokenFromContext(r.Context()))
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockJWT.AssertExpectations(t)
+	})
+
+	t.Run("invalid access token", func(t *testing.T) {
+		mockJWT := new(MockJWTService)
+		mockJWT.On("VerifyAccessToken", "eyJ.expired").Return(nil, service.ErrInvalidAccessToken).Once()
+
+		w := serve(NewAuthMiddleware(new(MockAuthService), new(MockAPITokenService), WithJWTService(mockJWT)), "eyJ.expired", testHandler())
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, "invalid_access_token", response["code"])
+	})
+
+	t.Run("API tokens still work", func(t *testing.T) {
+		mockAuthService := new(MockAuthService)
+		mockTokenService := new(MockAPITokenService)
+		mockJWT := new(MockJWTService)
+		mockTokenService.On("Authenticate", "drt_secret").Return(&model.APIToken{ID: uuid.New(), UserID: user.ID}, nil).Once()
+		mockAuthService.On("GetUserByID", user.ID).Return(user, nil).Once()
+
+		w := serve(NewAuthMiddleware(mockAuthService, mockTokenService, WithJWTService(mockJWT)), "drt_secret", testHandler())
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockJWT.AssertNotCalled(t, "VerifyAccessToken", mock.Anything)
+	})
+}
+
+func TestAuthMiddleware_SlidingSessions(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	request := func(middleware *AuthMiddleware, token string) (*httptest.ResponseRecorder, *model.Session) {
+		var seen *model.Session
+		handler := middleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = model.SessionFromContext(r.Context())
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: token})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w, seen
+	}
+
+	newMiddleware := func(opts ...AuthMiddlewareOption) (*AuthMiddleware, *model.MemorySessionStore) {
+		mockAuthService := new(MockAuthService)
+		mockAuthService.On("GetUserByID", user.ID).Return(user, nil)
+		middleware := NewAuthMiddleware(mockAuthService, new(MockAPITokenService), opts...)
+		sessions := model.NewMemorySessionStore()
+		middleware.sessions = sessions
+		return middleware, sessions
+	}
+
+	t.Run("activity extends the session", func(t *testing.T) {
+		middleware, sessions := newMiddleware(WithSlidingSessions(0))
+		token, err := sessions.Create(model.NewSession(user.ID, "", ""), time.Hour)
+		require.NoError(t, err)
+
+		w, seen := request(middleware, token)
+
+		require.NotNil(t, seen)
+		assert.WithinDuration(t, time.Now().Add(24*time.Hour), seen.ExpiresAt, time.Minute)
+		stored, err := sessions.Get(token)
+		require.NoError(t, err)
+		assert.Equal(t, seen.ExpiresAt, stored.ExpiresAt)
+
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, token, cookies[0].Value)
+		assert.InDelta(t, (24 * time.Hour).Seconds(), cookies[0].MaxAge, 60)
+	})
+
+	t.Run("no further than the max lifetime", func(t *testing.T) {
+		middleware, sessions := newMiddleware(WithSlidingSessions(2 * time.Hour))
+		session := model.NewSession(user.ID, "", "")
+		token, err := sessions.Create(session, time.Hour)
+		require.NoError(t, err)
+
+		_, seen := request(middleware, token)
+
+		assert.Equal(t, session.CreatedAt.Add(2*time.Hour), seen.ExpiresAt)
+	})
+
+	t.Run("not written again right away", func(t *testing.T) {
+		middleware, sessions := newMiddleware(WithSlidingSessions(0))
+		token, err := sessions.Create(model.NewSession(user.ID, "", ""), 24*time.Hour)
+		require.NoError(t, err)
+
+		w, _ := request(middleware, token)
+
+		assert.Empty(t, w.Result().Cookies())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		middleware, sessions := newMiddleware()
+		session := model.NewSession(user.ID, "", "")
+		token, err := sessions.Create(session, time.Hour)
+		require.NoError(t, err)
+
+		w, seen := request(middleware, token)
+
+		assert.Equal(t, session.ExpiresAt, seen.ExpiresAt)
+		assert.Empty(t, w.Result().Cookies())
 	})
 }
 
@@ -326,41 +631,20 @@ func TestGetUserFromContext(t *testing.T) {
 	})
 }
 
-func TestRespondWithError(t *testing.T) {
-	t.Run("formats error response correctly", func(t *testing.T) {
-		w := httptest.NewRecorder()
-
-		respondWithError(w, http.StatusBadRequest, "Test error message")
+func TestRequireAuth_ProblemResponse(t *testing.T) {
+	middleware := NewAuthMiddleware(new(MockAuthService), new(MockAPITokenService))
 
-		assert.Equal(t, http.StatusBadRequest, w.Code)
-		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	req := httptest.NewRequest("GET", "/api/devices", nil)
+	w := httptest.NewRecorder()
 
-		var response map[string]interface{}
-		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "error", response["status"])
-		assert.Equal(t, "Test error message", response["message"])
-	})
+	middleware.RequireAuth(testHandler()).ServeHTTP(w, req)
 
-	t.Run("handles different status codes", func(t *testing.T) {
-		testCases := []struct {
-			statusCode int
-			message    string
-		}{
-			{http.StatusUnauthorized, "Unauthorized"},
-			{http.StatusForbidden, "Forbidden"},
-			{http.StatusInternalServerError, "Internal Server Error"},
-		}
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
 
-		for _, tc := range testCases {
-			w := httptest.NewRecorder()
-			respondWithError(w, tc.statusCode, tc.message)
-
-			assert.Equal(t, tc.statusCode, w.Code)
-
-			var response map[string]interface{}
-			json.Unmarshal(w.Body.Bytes(), &response)
-			assert.Equal(t, "error", response["status"])
-			assert.Equal(t, tc.message, response["message"])
-		}
-	})
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "Unauthorized", response["title"])
+	assert.Equal(t, float64(http.StatusUnauthorized), response["status"])
+	assert.Equal(t, "/api/devices", response["instance"])
 }
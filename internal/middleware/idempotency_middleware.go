@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/controller"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	DefaultIdempotencyKeysTTL = 24 * time.Hour
+)
+
+// Codes of the problems reported for Idempotency-Key misuse
+const (
+	CodeInvalidIdempotencyKey    = "invalid_idempotency_key"
+	CodeIdempotencyKeyReused     = "idempotency_key_reused"
+	CodeIdempotencyKeyInProgress = "idempotency_key_in_progress"
+)
+
+type IdempotencyMiddleware struct {
+	repo repository.IdempotencyRepositoryInterface
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+func NewIdempotencyMiddleware(repo repository.IdempotencyRepositoryInterface, ttl time.Duration) *IdempotencyMiddleware {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyKeysTTL
+	}
+
+	return &IdempotencyMiddleware{
+		repo: repo,
+		ttl:  ttl,
+		now:  time.Now,
+	}
+}
+
+// bodyLimit returns how much of the body of r may be buffered: the JSON limit,
+// or the import limit for multipart uploads.
+func bodyLimit(r *http.Request) int64 {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		return controller.MaxImportFileSize
+	}
+	return controller.MaxRequestBodySize
+}
+
+// Handle makes mutating requests carrying an Idempotency-Key header safe to
+// retry. The first response for a key is stored and replayed to repeats of
+// the same request; reusing the key for a different request is refused.
+// Keys are scoped to the authenticated user, so it must run after
+// RequireAuth.
+func (im *IdempotencyMiddleware) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		user := model.UserFromContext(r.Context())
+		if key == "" || user == nil || !mutatingMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			controller.WriteProblem(w, r, http.StatusBadRequest, CodeInvalidIdempotencyKey, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		limit := bodyLimit(r)
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				controller.WriteProblem(w, r, http.StatusRequestEntityTooLarge, controller.CodeRequestTooLarge,
+					fmt.Sprintf("Request body must not exceed %d bytes", limit))
+				return
+			}
+			controller.WriteProblem(w, r, http.StatusBadRequest, controller.CodeInvalidRequestBody, "Invalid request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := im.now().UTC()
+		claimed, existing, err := im.repo.Claim(&model.IdempotencyKey{
+			UserID:      user.ID,
+			Key:         key,
+			Fingerprint: requestFingerprint(r, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(im.ttl),
+		})
+		if err != nil {
+			controller.WriteError(w, r, fmt.Errorf("failed to claim idempotency key: %w", err))
+			return
+		}
+
+		if !claimed {
+			im.replay(w, r, existing, body)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		// Server errors are not remembered so the request can be retried
+		if recorder.status >= http.StatusInternalServerError {
+			if err := im.repo.Release(user.ID, key); err != nil {
+				log.Error("failed to release idempotency key. err: ", err.Error())
+			}
+			return
+		}
+
+		header, _ := json.Marshal(storedHeader(recorder.Header()))
+		if err := im.repo.Complete(user.ID, key, recorder.status, header, recorder.body.Bytes()); err != nil {
+			log.Error("failed to store idempotent response. err: ", err.Error())
+		}
+	})
+}
+
+// replay answers a repeated request with the stored response
+func (im *IdempotencyMiddleware) replay(w http.ResponseWriter, r *http.Request, existing *model.IdempotencyKey, body []byte) {
+	if existing.Fingerprint != requestFingerprint(r, body) {
+		controller.WriteProblem(w, r, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request")
+		return
+	}
+
+	if !existing.Completed() {
+		controller.WriteProblem(w, r, http.StatusConflict, CodeIdempotencyKeyInProgress, "A request with this Idempotency-Key is still being processed")
+		return
+	}
+
+	var header http.Header
+	if err := json.Unmarshal(existing.ResponseHeader, &header); err == nil {
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(*existing.StatusCode)
+	w.Write(existing.ResponseBody)
+}
+
+// storedHeader returns the response header to keep for replays. Cookies are
+// left out: they may carry the session token, which is never stored, and a
+// replay must not hand it to whoever repeats the key.
+func storedHeader(header http.Header) http.Header {
+	stored := header.Clone()
+	stored.Del("Set-Cookie")
+	return stored
+}
+
+// requestFingerprint identifies a request by its method, target and body
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+"\n"+r.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func mutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if !rr.wroteHeader {
+		rr.status = status
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(data []byte) (int, error) {
+	rr.wroteHeader = true
+	rr.body.Write(data)
+	return rr.ResponseWriter.Write(data)
+}
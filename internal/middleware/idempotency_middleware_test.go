@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/controller"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockIdempotencyRepository is a mock implementation of IdempotencyRepositoryInterface
+type MockIdempotencyRepository struct {
+	mock.Mock
+}
+
+func (m *MockIdempotencyRepository) Claim(key *model.IdempotencyKey) (bool, *model.IdempotencyKey, error) {
+	args := m.Called(key)
+	if args.Get(1) == nil {
+		return args.Bool(0), nil, args.Error(2)
+	}
+	return args.Bool(0), args.Get(1).(*model.IdempotencyKey), args.Error(2)
+}
+
+func (m *MockIdempotencyRepository) Complete(userID uuid.UUID, key string, status int, header json.RawMessage, body []byte) error {
+	args := m.Called(userID, key, status, header, body)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepository) Release(userID uuid.UUID, key string) error {
+	args := m.Called(userID, key)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepository) DeleteExpired(now time.Time) (int64, error) {
+	args := m.Called(now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// createdHandler answers like a device creation and counts its calls
+func createdHandler(calls *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"1"}`))
+	})
+}
+
+func newIdempotentRequest(user *model.User, method, body, key string) *http.Request {
+	req := httptest.NewRequest(method, "/api/devices", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	if user != nil {
+		req = req.WithContext(context.WithValue(req.Context(), UserContextKey, user))
+	}
+	return req
+}
+
+func TestIdempotencyMiddleware_Handle(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	body := `{"name":"Pixel 8","brand":"Google"}`
+
+	t.Run("first request is stored", func(t *testing.T) {
+		repo := new(MockIdempotencyRepository)
+		calls := 0
+
+		repo.On("Claim", mock.MatchedBy(func(key *model.IdempotencyKey) bool {
+			return key.UserID == user.ID && key.Key == "abc" && key.ExpiresAt.Sub(key.CreatedAt) == time.Hour
+		})).Return(true, nil, nil)
+		repo.On("Complete", user.ID, "abc", http.StatusCreated, mock.Anything, []byte(`{"id":"1"}`)).Return(nil)
+
+		w := httptest.NewRecorder()
+		NewIdempotencyMiddleware(repo, time.Hour).Handle(createdHandler(&calls)).
+			ServeHTTP(w, newIdempotentRequest(user, "POST", body, "abc"))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, 1, calls)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("cookies are not stored", func(t *testing.T) {
+		repo := new(MockIdempotencyRepository)
+		repo.On("Claim", mock.Anything).Return(true, nil, nil)
+		repo.On("Complete", user.ID, "abc", http.StatusCreated, mock.Anything, mock.Anything).Return(nil)
+
+		handler := NewIdempotencyMiddleware(repo, time.Hour).Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.SetCookie(w, &http.Cookie{Name: controller.SessionCookieName, Value: "session-token"})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+		}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newIdempotentRequest(user, http.MethodPost, body, "abc"))
+
+		assert.NotEmpty(t, w.Result().Cookies())
+		stored := repo.Calls[len(repo.Calls)-1].Arguments.Get(3).(json.RawMessage)
+		var header http.Header
+		assert.NoError(t, json.Unmarshal(stored, &header))
+		assert.Equal(t, "application/json", header.Get("Content-Type"))
+		assert.Empty(t, header.Values("Set-Cookie"))
+		assert.NotContains(t, string(stored), "session-token")
+	})
+
+	t.Run("repeat is replayed", func(t *testing.T) {
+		repo := new(MockIdempotencyRepository)
+		calls := 0
+		status := http.StatusCreated
+		fingerprint := requestFingerprint(newIdempotentRequest(user, "POST", body, "abc"), []byte(body))
+
+		repo.On("Claim", mock.Anything).Return(false, &model.IdempotencyKey{
+			Fingerprint:    fingerprint,
+			StatusCode:     &status,
+			ResponseHeader: json.RawMessage(`{"Content-Type":["application/json"]}`),
+			ResponseBody:   []byte(`{"id":"1"}`),
+		}, nil)
+
+		w := httptest.NewRecorder()
+		NewIdempotencyMiddleware(repo, time.Hour).Handle(createdHandler(&calls)).
+			ServeHTTP(w, newIdempotentRequest(user, "POST", body, "abc"))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, 0, calls)
+		assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Equal(t, `{"id":"1"}`, w.Body.String())
+	})
+
+	t.Run("different body is refused", func(t *testing.T) {
+		repo := new(MockIdempotencyRepository)
+		calls := 0
+		status := http.StatusCreated
+
+		repo.On("Claim", mock.Anything).Return(false, &model.IdempotencyKey{Fingerprint: "other", StatusCode: &status}, nil)
+
+		w := httptest.NewRecorder()
+		NewIdempotencyMiddleware(repo, time.Hour).Handle(createdHandler(&calls)).
+			ServeHTTP(w, newIdempotentRequest(user, "POST", body, "abc"))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("request still in flight", func(t *testing.T) {
+		repo := new(MockIdempotencyRepository)
+		calls := 0
+		fingerprint := requestFingerprint(newIdempotentRequest(user, "POST", body, "abc"), []byte(body))
+
+		repo.On("Claim", mock.Anything).Return(false, &model.IdempotencyKey{Fingerprint: fingerprint}, nil)
+
+		w := httptest.NewRecorder()
+		NewIdempotencyMiddleware(repo, time.Hour).Handle(createdHandler(&calls)).
+			ServeHTTP(w, newIdempotentRequest(user, "POST", body, "abc"))
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("server errors release the key", func(t *testing.T) {
+		repo := new(MockIdempotencyRepository)
+
+		repo.On("Claim", mock.Anything).Return(true, nil, nil)
+		repo.On("Release", user.ID, "abc").Return(nil)
+
+		failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+
+		w := httptest.NewRecorder()
+		NewIdempotencyMiddleware(repo, time.Hour).Handle(failing).
+			ServeHTTP(w, newIdempotentRequest(user, "POST", body, "abc"))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		repo.AssertExpectations(t)
+		repo.AssertNotCalled(t, "Complete")
+	})
+
+	t.Run("requests without a key pass through", func(t *testing.T) {
+		repo := new(MockIdempotencyRepository)
+		calls := 0
+
+		handler := NewIdempotencyMiddleware(repo, time.Hour).Handle(createdHandler(&calls))
+		handler.ServeHTTP(httptest.NewRecorder(), newIdempotentRequest(user, "POST", body, ""))
+		handler.ServeHTTP(httptest.NewRecorder(), newIdempotentRequest(user, "GET", "", "abc"))
+		handler.ServeHTTP(httptest.NewRecorder(), newIdempotentRequest(nil, "POST", body, "abc"))
+
+		assert.Equal(t, 3, calls)
+		repo.AssertNotCalled(t, "Claim")
+	})
+
+	t.Run("key too long", func(t *testing.T) {
+		repo := new(MockIdempotencyRepository)
+		calls := 0
+
+		w := httptest.NewRecorder()
+		NewIdempotencyMiddleware(repo, time.Hour).Handle(createdHandler(&calls)).
+			ServeHTTP(w, newIdempotentRequest(user, "POST", body, strings.Repeat("k", 256)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, 0, calls)
+	})
+	t.Run("body too large", func(t *testing.T) {
+		repo := new(MockIdempotencyRepository)
+		calls := 0
+		large := `{"name":"` + strings.Repeat("a", controller.MaxRequestBodySize) + `"}`
+
+		w := httptest.NewRecorder()
+		NewIdempotencyMiddleware(repo, time.Hour).Handle(createdHandler(&calls)).
+			ServeHTTP(w, newIdempotentRequest(user, "POST", large, "abc"))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Equal(t, 0, calls)
+		repo.AssertNotCalled(t, "Claim")
+	})
+}
@@ -1,35 +1,88 @@
 package middleware
 
 import (
+	"context"
+	"net/http"
+
 	"github.com/gorilla/mux"
 	"github.com/loopsFreitag/DeviceRegistry/internal/controller"
+	"github.com/loopsFreitag/DeviceRegistry/internal/graph"
 	"github.com/loopsFreitag/DeviceRegistry/internal/model"
 	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
 	"github.com/loopsFreitag/DeviceRegistry/internal/service"
+	"github.com/spf13/viper"
 
 	_ "github.com/loopsFreitag/DeviceRegistry/docs"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-func NewAppRouter() *mux.Router {
+// NewAppRouter builds the application routes. REST and GraphQL share
+// deviceService, device changes are streamed from deviceStream, token mode
+// runs on jwtService and single sign-on on oidcService (either nil when it is
+// off), and the webhook dispatcher and session sweeper run until ctx is done.
+func NewAppRouter(ctx context.Context, deviceService service.DeviceServiceInterface, deviceStream service.DeviceStreamInterface, jwtService service.JWTServiceInterface, oidcService service.OIDCServiceInterface) *mux.Router {
 	router := mux.NewRouter()
 
 	userRepo := repository.NewUserRepository(model.DBX())
 	authService := service.NewAuthService(userRepo)
 
-	authMiddleware := NewAuthMiddleware(authService)
+	authMiddlewareOptions := []AuthMiddlewareOption{WithJWTService(jwtService)}
+	if viper.GetBool("session.sliding") {
+		authMiddlewareOptions = append(authMiddlewareOptions, WithSlidingSessions(viper.GetDuration("session.max-lifetime")))
+	}
+	authMiddleware := NewAuthMiddleware(
+		authService,
+		service.NewAPITokenService(repository.NewAPITokenRepository(model.DBX())),
+		authMiddlewareOptions...,
+	)
+	idempotencyMiddleware := NewIdempotencyMiddleware(
+		repository.NewIdempotencyRepository(model.DBX()),
+		viper.GetDuration("idempotency.ttl"),
+	)
+
+	webhookDispatcher := service.NewWebhookDispatcher(
+		repository.NewWebhookRepository(model.DBX()),
+		service.WithTimeout(viper.GetDuration("webhooks.timeout")),
+		service.WithMaxAttempts(viper.GetInt("webhooks.max-attempts")),
+		service.WithRetryBackoff(viper.GetDuration("webhooks.retry-backoff")),
+	)
+	go webhookDispatcher.Run(ctx, viper.GetDuration("webhooks.poll-interval"))
+	go service.NewSessionSweeper(model.GetSessionStore()).Run(ctx, viper.GetDuration("session.sweep-interval"))
 
 	// Public routes
+	authController := controller.NewAuthController(authService, controller.WithJWTService(jwtService))
 	controller.NewHealthCheck(controller.WithDBChecker()).SetRoutes(router)
-	controller.NewAuthController(authService).SetRoutes(router)
+	authController.SetRoutes(router)
+	if oidcService != nil {
+		controller.NewOIDCController(oidcService, viper.GetString("oidc.post-login-url")).SetRoutes(router)
+	}
+
+	// Account routes, for the logged in user
+	accountRouter := router.PathPrefix("/auth").Subrouter()
+	accountRouter.Use(authMiddleware.RequireAuth)
+	authController.SetProtectedRoutes(accountRouter)
+	controller.NewAPITokenController().SetRoutes(accountRouter)
 
 	// Protected routes
 	protectedRouter := router.PathPrefix("/api").Subrouter()
 	protectedRouter.Use(authMiddleware.RequireAuth)
-	controller.NewDeviceController().SetRoutes(protectedRouter)
+	protectedRouter.Use(idempotencyMiddleware.Handle)
+	// registered ahead of the device routes so /devices/{id} doesn't shadow it
+	controller.NewDeviceStreamController(deviceStream).SetRoutes(protectedRouter)
+	controller.NewDeviceControllerWithService(deviceService).SetRoutes(protectedRouter)
+	controller.NewReservationController().SetRoutes(protectedRouter)
+	controller.NewWebhookController().SetRoutes(protectedRouter)
+	protectedRouter.Handle("/graphql", newGraphQLHandler(deviceService, userRepo)).Methods(http.MethodGet, http.MethodPost)
 
 	// Swagger
 	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
 	return router
 }
+
+// newGraphQLHandler serves the GraphQL API on top of the same device service
+// the REST controllers use
+func newGraphQLHandler(deviceService service.DeviceServiceInterface, userRepo repository.UserRepository) http.Handler {
+	deviceRepo := repository.NewDeviceRepository(model.DBX())
+	return graph.NewHandler(deviceService, deviceRepo, userRepo, viper.GetInt("graphql.complexity-limit"))
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// APITokenPrefix starts every API token, which tells them apart from
+	// session ids and makes leaked ones easy to scan for
+	APITokenPrefix = "drt_"
+	// apiTokenBytes is the length of the random part of an API token
+	apiTokenBytes = 32
+	// apiTokenShownLength is how much of a token is kept to recognise it by
+	apiTokenShownLength = len(APITokenPrefix) + 6
+)
+
+// APIToken lets scripts authenticate as a user. It only allows what both
+// its scopes and the role of the user allow. Token is the credential: it is
+// only shown when the token is created, and only its hash is stored.
+type APIToken struct {
+	ID         uuid.UUID      `json:"id" db:"id"`
+	UserID     uuid.UUID      `json:"user_id" db:"user_id"`
+	Name       string         `json:"name" db:"name" example:"ci"`
+	Token      string         `json:"token,omitempty" db:"-" example:"drt_Qk3vX9..."`
+	Prefix     string         `json:"prefix" db:"prefix" example:"drt_Qk3vX9"`
+	Scopes     pq.StringArray `json:"scopes" db:"scopes" swaggertype:"array,string" example:"devices:read"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time     `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+}
+
+// HasScope reports whether the token was granted permission
+func (t *APIToken) HasScope(permission Permission) bool {
+	for _, scope := range t.Scopes {
+		if Permission(scope) == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAPIToken returns a random API token along with its hash and the
+// prefix it is shown by
+func NewAPIToken() (token, hash, prefix string, err error) {
+	b := make([]byte, apiTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+
+	token = APITokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return token, HashAPIToken(token), token[:apiTokenShownLength], nil
+}
+
+// HashAPIToken returns the hash API tokens are looked up by
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
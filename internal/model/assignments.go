@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Assignment records a user checking a device out and, eventually, back in
+type Assignment struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	DeviceID     uuid.UUID  `json:"device_id" db:"device_id"`
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	CheckedOutAt time.Time  `json:"checked_out_at" db:"checked_out_at"`
+	CheckedInAt  *time.Time `json:"checked_in_at,omitempty" db:"checked_in_at"`
+}
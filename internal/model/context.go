@@ -0,0 +1,70 @@
+package model
+
+import "context"
+
+type contextKey string
+
+// UserContextKey is the request context key holding the authenticated *User.
+// It lives here so both middleware and controllers can reach it without an
+// import cycle.
+const UserContextKey contextKey = "user"
+
+// UserFromContext returns the authenticated user stored in ctx, if any
+func UserFromContext(ctx context.Context) *User {
+	user, ok := ctx.Value(UserContextKey).(*User)
+	if !ok {
+		return nil
+	}
+	return user
+}
+
+// AllowedFromContext reports whether the caller in ctx may use permission:
+// their role allows it and, when they sent an API token, so do its scopes
+func AllowedFromContext(ctx context.Context, permission Permission) bool {
+	if !UserFromContext(ctx).Can(permission) {
+		return false
+	}
+	if token := APITokenFromContext(ctx); token != nil && !token.HasScope(permission) {
+		return false
+	}
+	return true
+}
+
+// SessionContextKey is the request context key holding the *Session the
+// request was authenticated with
+const SessionContextKey contextKey = "session"
+
+// SessionFromContext returns the session stored in ctx, if any
+func SessionFromContext(ctx context.Context) *Session {
+	session, ok := ctx.Value(SessionContextKey).(*Session)
+	if !ok {
+		return nil
+	}
+	return session
+}
+
+// APITokenContextKey is the request context key holding the *APIToken the
+// request was authenticated with, when it didn't use a session
+const APITokenContextKey contextKey = "api_token"
+
+// APITokenFromContext returns the API token stored in ctx, if any
+func APITokenFromContext(ctx context.Context) *APIToken {
+	token, ok := ctx.Value(APITokenContextKey).(*APIToken)
+	if !ok {
+		return nil
+	}
+	return token
+}
+
+// AccessTokenContextKey is the request context key holding the
+// *AccessToken the request was authenticated with, when it sent a JWT
+const AccessTokenContextKey contextKey = "access_token"
+
+// AccessTokenFromContext returns the access token stored in ctx, if any
+func AccessTokenFromContext(ctx context.Context) *AccessToken {
+	token, ok := ctx.Value(AccessTokenContextKey).(*AccessToken)
+	if !ok {
+		return nil
+	}
+	return token
+}
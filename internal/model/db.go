@@ -6,7 +6,7 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
@@ -26,16 +26,8 @@ func DBX() *sqlx.DB {
 		log.Printf("Init DB (%s), max open connections: %d, max idle time (min): %d",
 			Environment, maxOpenConnections, maxIdleTime)
 
-		connString := fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s sslmode=%s",
-			viper.GetString("db.user"),
-			viper.GetString("db.password"),
-			viper.GetString("db.host"),
-			viper.GetInt("db.port"),
-			viper.GetString("db.name"),
-			viper.GetString("db.ssl-mode"))
-
 		var err error
-		dbx, err = sqlx.Connect("postgres", connString)
+		dbx, err = sqlx.Connect("postgres", connString())
 		if err != nil {
 			log.Fatal("couldn't establish a DB connection. Bailing out. err: ", err.Error())
 		}
@@ -46,3 +38,24 @@ func DBX() *sqlx.DB {
 
 	return dbx
 }
+
+// NewListener opens a dedicated connection for LISTEN. It reconnects on its
+// own; a nil notification on its Notify channel signals that it did, and that
+// notifications may have been missed meanwhile.
+func NewListener() *pq.Listener {
+	return pq.NewListener(connString(), 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Error("DB listener error. err: ", err.Error())
+		}
+	})
+}
+
+func connString() string {
+	return fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s sslmode=%s",
+		viper.GetString("db.user"),
+		viper.GetString("db.password"),
+		viper.GetString("db.host"),
+		viper.GetInt("db.port"),
+		viper.GetString("db.name"),
+		viper.GetString("db.ssl-mode"))
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DeviceEventType string
+
+const (
+	DeviceEventCreated      DeviceEventType = "created"
+	DeviceEventUpdated      DeviceEventType = "updated"
+	DeviceEventStateChanged DeviceEventType = "state_changed"
+	DeviceEventDeleted      DeviceEventType = "deleted"
+	DeviceEventRestored     DeviceEventType = "restored"
+)
+
+// DeviceEvent is an entry of a device audit trail. Before and After only
+// hold the fields that changed.
+type DeviceEvent struct {
+	ID        uuid.UUID        `json:"id" db:"id"`
+	DeviceID  uuid.UUID        `json:"device_id" db:"device_id"`
+	UserID    *uuid.UUID       `json:"user_id,omitempty" db:"user_id"`
+	Type      DeviceEventType  `json:"type" db:"type"`
+	Before    *json.RawMessage `json:"before,omitempty" db:"before" swaggertype:"object"`
+	After     *json.RawMessage `json:"after,omitempty" db:"after" swaggertype:"object"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+}
+
+// DeviceChange is a write to the devices table as announced to every
+// replica. Seq orders the changes and is the id a stream resumes from.
+type DeviceChange struct {
+	Seq    int64           `json:"seq" example:"42"`
+	Type   DeviceEventType `json:"type" example:"updated"`
+	Device Device          `json:"device"`
+}
+
+// DiffDevices returns the audited fields that differ between two versions of
+// a device. A nil side yields every field of the other one.
+func DiffDevices(before, after *Device) (map[string]interface{}, map[string]interface{}) {
+	b := auditedFields(before)
+	a := auditedFields(after)
+
+	if before != nil && after != nil {
+		for field := range b {
+			if b[field] == a[field] {
+				delete(b, field)
+				delete(a, field)
+			}
+		}
+	}
+
+	return b, a
+}
+
+func auditedFields(device *Device) map[string]interface{} {
+	if device == nil {
+		return map[string]interface{}{}
+	}
+
+	return map[string]interface{}{
+		"name":  device.Name,
+		"brand": device.Brand,
+		"state": device.State,
+	}
+}
@@ -4,6 +4,7 @@ package model
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,11 +13,24 @@ import (
 type DeviceState int
 
 const (
-	StateInactive  DeviceState = 0
-	StateAvailable DeviceState = 1
-	StateInUse     DeviceState = 2
+	StateInactive    DeviceState = 0
+	StateAvailable   DeviceState = 1
+	StateInUse       DeviceState = 2
+	StateMaintenance DeviceState = 3
+	StateLost        DeviceState = 4
+	StateRetired     DeviceState = 5
 )
 
+// DeviceStates lists every known state in ascending order
+var DeviceStates = []DeviceState{
+	StateInactive,
+	StateAvailable,
+	StateInUse,
+	StateMaintenance,
+	StateLost,
+	StateRetired,
+}
+
 func (s DeviceState) String() string {
 	switch s {
 	case StateInactive:
@@ -25,11 +39,38 @@ func (s DeviceState) String() string {
 		return "available"
 	case StateInUse:
 		return "in-use"
+	case StateMaintenance:
+		return "maintenance"
+	case StateLost:
+		return "lost"
+	case StateRetired:
+		return "retired"
 	default:
 		return "unknown"
 	}
 }
 
+// IsValid reports whether s is one of the known states
+func (s DeviceState) IsValid() bool {
+	return s >= StateInactive && s <= StateRetired
+}
+
+// ParseDeviceState parses a state from its name or numeric value
+func ParseDeviceState(str string) (DeviceState, error) {
+	for _, state := range DeviceStates {
+		if state.String() == str {
+			return state, nil
+		}
+	}
+
+	num, err := strconv.Atoi(str)
+	if err != nil || !DeviceState(num).IsValid() {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidDeviceState, str)
+	}
+
+	return DeviceState(num), nil
+}
+
 func (s DeviceState) MarshalJSON() ([]byte, error) {
 	return json.Marshal(s.String())
 }
@@ -45,26 +86,29 @@ func (s *DeviceState) UnmarshalJSON(data []byte) error {
 		return nil
 	}
 
-	switch str {
-	case "inactive":
-		*s = StateInactive
-	case "available":
-		*s = StateAvailable
-	case "in-use":
-		*s = StateInUse
-	default:
-		return fmt.Errorf("invalid device state: %s", str)
+	state, err := ParseDeviceState(str)
+	if err != nil {
+		return err
 	}
 
+	*s = state
 	return nil
 }
 
 // Device represents a device in the system
 type Device struct {
 	ID        uuid.UUID   `json:"id" db:"id"`
-	Name      string      `json:"name" db:"name" binding:"required"`
-	Brand     string      `json:"brand" db:"brand" binding:"required"`
-	State     DeviceState `json:"state" db:"state" binding:"required"`
+	Name      string      `json:"name" db:"name" binding:"required,max=255"`
+	Brand     string      `json:"brand" db:"brand" binding:"required,max=255"`
+	State     DeviceState `json:"state" db:"state" binding:"device_state"`
+	Version   int         `json:"version" db:"version" example:"1"`
 	CreatedAt time.Time   `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time  `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// DeviceSearchResult is a device matched by a search along with its relevance
+type DeviceSearchResult struct {
+	Device
+	Rank float64 `json:"rank" db:"rank" example:"0.82"`
 }
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKey remembers the first response to a request sent with an
+// Idempotency-Key header. StatusCode is nil until that request completes.
+type IdempotencyKey struct {
+	UserID         uuid.UUID       `db:"user_id"`
+	Key            string          `db:"key"`
+	Fingerprint    string          `db:"fingerprint"`
+	StatusCode     *int            `db:"status_code"`
+	ResponseHeader json.RawMessage `db:"response_header"`
+	ResponseBody   []byte          `db:"response_body"`
+	CreatedAt      time.Time       `db:"created_at"`
+	ExpiresAt      time.Time       `db:"expires_at"`
+}
+
+// Completed reports whether the response of the first request was stored
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != nil
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken trades for a new access token, once: every refresh hands out
+// a successor in the same family. A token presented again after it was used
+// gives away that it leaked, and the whole family is revoked. Only the hash
+// of the token is stored.
+type RefreshToken struct {
+	ID        uuid.UUID  `db:"id"`
+	FamilyID  uuid.UUID  `db:"family_id"`
+	UserID    uuid.UUID  `db:"user_id"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// AccessToken is what a verified JWT access token asserts. FamilyID is the
+// refresh token family it was issued along with.
+type AccessToken struct {
+	ID        string
+	UserID    uuid.UUID
+	FamilyID  uuid.UUID
+	Role      Role
+	ExpiresAt time.Time
+}
+
+// TokenPair is handed out by logins in token mode and by refreshes. The
+// access token is a JWT sent as "Authorization: Bearer <token>"; the refresh
+// token is opaque.
+type TokenPair struct {
+	AccessToken      string `json:"access_token" example:"eyJhbGciOiJFZERTQSIsImtpZCI6Ii4uLiJ9..."`
+	TokenType        string `json:"token_type" example:"Bearer"`
+	ExpiresIn        int    `json:"expires_in" example:"900"`
+	RefreshToken     string `json:"refresh_token" example:"pX8wJ2..."`
+	RefreshExpiresIn int    `json:"refresh_expires_in" example:"2592000"`
+}
+
+// JSONWebKey is the public half of a signing key, as published in the JWKS
+type JSONWebKey struct {
+	KeyType   string `json:"kty" example:"OKP"`
+	KeyID     string `json:"kid" example:"0fX2cY..."`
+	Use       string `json:"use" example:"sig"`
+	Algorithm string `json:"alg" example:"EdDSA"`
+	Curve     string `json:"crv,omitempty" example:"Ed25519"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// JSONWebKeySet lists the keys access tokens may be signed with
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
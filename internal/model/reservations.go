@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reservation books a device for a user over the [StartsAt, EndsAt) window
+type Reservation struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	DeviceID  uuid.UUID `json:"device_id" db:"device_id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	StartsAt  time.Time `json:"starts_at" db:"starts_at"`
+	EndsAt    time.Time `json:"ends_at" db:"ends_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
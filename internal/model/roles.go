@@ -0,0 +1,58 @@
+package model
+
+// Role decides what a user is allowed to do
+type Role string
+
+const (
+	// RoleViewer can only read devices
+	RoleViewer Role = "viewer"
+	// RoleOperator can also check devices in and out, change their state and
+	// reserve them
+	RoleOperator Role = "operator"
+	// RoleAdmin can also create and delete devices and manage users
+	RoleAdmin Role = "admin"
+)
+
+// Permission is an action routes require the user to be allowed
+type Permission string
+
+const (
+	PermissionReadDevices    Permission = "devices:read"
+	PermissionOperateDevices Permission = "devices:operate"
+	PermissionManageDevices  Permission = "devices:manage"
+	PermissionManageUsers    Permission = "users:manage"
+)
+
+// rolePermissions lists what each role is allowed. Every role is allowed
+// what the roles below it are.
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:   {PermissionReadDevices},
+	RoleOperator: {PermissionReadDevices, PermissionOperateDevices},
+	RoleAdmin:    {PermissionReadDevices, PermissionOperateDevices, PermissionManageDevices, PermissionManageUsers},
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether r is allowed permission
+func (r Role) Can(permission Permission) bool {
+	for _, allowed := range rolePermissions[r] {
+		if allowed == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions lists what r is allowed
+func (r Role) Permissions() []Permission {
+	return append([]Permission{}, rolePermissions[r]...)
+}
+
+// Outranks reports whether r is allowed more than other
+func (r Role) Outranks(other Role) bool {
+	return len(rolePermissions[r]) > len(rolePermissions[other])
+}
@@ -1,59 +1,238 @@
 package model
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const (
+	// sessionTokenBytes is the length of the random token identifying a session
+	sessionTokenBytes = 32
+	// maxUserAgentLength caps the user agent recorded on a session
+	maxUserAgentLength = 512
+	// sessionExtendInterval is how far a sliding session expiry must move
+	// before it is written, so busy clients don't write on every request
+	sessionExtendInterval = time.Minute
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is a login. The token it was handed out with is the credential and
+// is never stored, only its hash; ID is safe to show.
 type Session struct {
-	UserID    uuid.UUID
-	Email     string
-	ExpiresAt time.Time
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	UserAgent string    `json:"user_agent" db:"user_agent" example:"Mozilla/5.0 (X11; Linux x86_64)"`
+	IP        string    `json:"ip" db:"ip" example:"203.0.113.7"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
 }
 
-type SessionStore struct {
-	sessions map[string]*Session
-	mu       sync.RWMutex
+// NewSession describes a login of userID from the client with the given
+// user agent and IP
+func NewSession(userID uuid.UUID, userAgent, ip string) *Session {
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+	return &Session{UserID: userID, UserAgent: userAgent, IP: ip}
+}
+
+// SlidingExpiry is when the session should expire after activity now: a
+// duration from now, capped at maxLifetime after login when that is
+// positive. It reports false when the expiry would barely move, so it isn't
+// worth writing.
+func (s *Session) SlidingExpiry(duration, maxLifetime time.Duration) (time.Time, bool) {
+	expiresAt := time.Now().UTC().Add(duration)
+	if maxLifetime > 0 {
+		if limit := s.CreatedAt.Add(maxLifetime); expiresAt.After(limit) {
+			expiresAt = limit
+		}
+	}
+	return expiresAt, expiresAt.Sub(s.ExpiresAt) >= sessionExtendInterval
 }
 
-var sessionStore = &SessionStore{
-	sessions: make(map[string]*Session),
+// SessionStore keeps the sessions opened by logins
+type SessionStore interface {
+	// Create opens session, filling in its ID and timestamps, and returns the
+	// token that identifies it
+	Create(session *Session, duration time.Duration) (string, error)
+	// Get returns the live session token identifies, or ErrSessionNotFound
+	Get(token string) (*Session, error)
+	// ListByUser returns the live sessions of a user, newest first
+	ListByUser(userID uuid.UUID) ([]Session, error)
+	// Delete ends a session of a user, or returns ErrSessionNotFound
+	Delete(userID, id uuid.UUID) error
+	// DeleteByUser ends every session of a user and returns how many there
+	// were
+	DeleteByUser(userID uuid.UUID) (int64, error)
+	// Extend moves the expiry of a live session to expiresAt, or returns
+	// ErrSessionNotFound
+	Extend(id uuid.UUID, expiresAt time.Time) error
+	// DeleteExpired removes the sessions that expired before now
+	DeleteExpired(now time.Time) (int64, error)
 }
 
-func GetSessionStore() *SessionStore {
+var (
+	sessionStore   SessionStore = NewMemorySessionStore()
+	sessionStoreMu sync.RWMutex
+)
+
+// GetSessionStore returns the store sessions are kept in, in memory unless
+// UseSessionStore picked another one
+func GetSessionStore() SessionStore {
+	sessionStoreMu.RLock()
+	defer sessionStoreMu.RUnlock()
 	return sessionStore
 }
 
-// Maybe create a session on db so dont have to rely on in-memory store
-// later
-func (s *SessionStore) Create(userID uuid.UUID, email string, duration time.Duration) string {
+// UseSessionStore replaces the session store. It is meant to be called at
+// startup, before anything got hold of the previous store.
+func UseSessionStore(store SessionStore) {
+	sessionStoreMu.Lock()
+	defer sessionStoreMu.Unlock()
+	sessionStore = store
+}
+
+// NewSessionToken returns a random session token along with its hash
+func NewSessionToken() (string, string, error) {
+	b := make([]byte, sessionTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashSessionToken(token), nil
+}
+
+// HashSessionToken returns the hash sessions are looked up by
+func HashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemorySessionStore keeps sessions in process. They are lost on restart and
+// not shared between replicas.
+type MemorySessionStore struct {
+	sessions map[string]*Session
+	mu       sync.RWMutex
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]*Session),
+	}
+}
+
+func (s *MemorySessionStore) Create(session *Session, duration time.Duration) (string, error) {
+	token, hash, err := NewSessionToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	session.ID = uuid.New()
+	session.CreatedAt = now
+	session.ExpiresAt = now.Add(duration)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	sessionID := uuid.New().String()
-	s.sessions[sessionID] = &Session{
-		UserID:    userID,
-		Email:     email,
-		ExpiresAt: time.Now().Add(duration),
+	stored := *session
+	s.sessions[hash] = &stored
+	return token, nil
+}
+
+func (s *MemorySessionStore) Get(token string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, exists := s.sessions[HashSessionToken(token)]
+	if !exists || !time.Now().UTC().Before(session.ExpiresAt) {
+		return nil, ErrSessionNotFound
 	}
-	return sessionID
+
+	found := *session
+	return &found, nil
 }
 
-func (s *SessionStore) Get(sessionID string) (*Session, bool) {
+func (s *MemorySessionStore) ListByUser(userID uuid.UUID) ([]Session, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	session, exists := s.sessions[sessionID]
-	if !exists || time.Now().After(session.ExpiresAt) {
-		return nil, false
+	now := time.Now().UTC()
+	sessions := []Session{}
+	for _, session := range s.sessions {
+		if session.UserID == userID && now.Before(session.ExpiresAt) {
+			sessions = append(sessions, *session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+func (s *MemorySessionStore) Delete(userID, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, session := range s.sessions {
+		if session.ID == id && session.UserID == userID {
+			delete(s.sessions, hash)
+			return nil
+		}
 	}
-	return session, true
+	return ErrSessionNotFound
 }
 
-func (s *SessionStore) Delete(sessionID string) {
+func (s *MemorySessionStore) DeleteByUser(userID uuid.UUID) (int64, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	delete(s.sessions, sessionID)
+
+	var deleted int64
+	for hash, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, hash)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *MemorySessionStore) Extend(id uuid.UUID, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, session := range s.sessions {
+		if session.ID == id && now.Before(session.ExpiresAt) {
+			session.ExpiresAt = expiresAt
+			return nil
+		}
+	}
+	return ErrSessionNotFound
+}
+
+func (s *MemorySessionStore) DeleteExpired(now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for hash, session := range s.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(s.sessions, hash)
+			deleted++
+		}
+	}
+	return deleted, nil
 }
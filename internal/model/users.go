@@ -10,6 +10,13 @@ type User struct {
 	ID           uuid.UUID `db:"id" json:"id"`
 	Email        string    `db:"email" json:"email"`
 	PasswordHash string    `db:"password_hash" json:"-"`
+	Role         Role      `db:"role" json:"role" example:"operator"`
+	OIDCSubject  *string   `db:"oidc_subject" json:"-"`
 	CreatedAt    time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
 }
+
+// Can reports whether the user is allowed permission
+func (u *User) Can(permission Permission) bool {
+	return u != nil && u.Role.Can(permission)
+}
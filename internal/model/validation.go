@@ -0,0 +1,134 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ErrInvalidDeviceState is returned when a state name or number is unknown
+var ErrInvalidDeviceState = errors.New("invalid device state")
+
+// FieldError describes why a single request field was rejected. Field is the
+// JSON path of the field, such as "name" or "operations[2].op".
+type FieldError struct {
+	Field   string `json:"field" example:"name"`
+	Rule    string `json:"rule" example:"required"`
+	Message string `json:"message" example:"name is required"`
+}
+
+// ValidationError lists every field of a payload that failed validation
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// validate checks the binding tags of request payloads and models
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.SetTagName("binding")
+
+	// report fields by the name clients send them under
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	v.RegisterValidation("device_state", func(fl validator.FieldLevel) bool {
+		state, ok := fl.Field().Interface().(DeviceState)
+		return ok && state.IsValid()
+	})
+
+	return v
+}
+
+// Validate checks v against its binding tags and returns a *ValidationError
+// listing every offending field
+func Validate(v interface{}) error {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return err
+	}
+
+	fields := make([]FieldError, len(invalid))
+	for i, fe := range invalid {
+		fields[i] = fieldError(fe)
+	}
+
+	return &ValidationError{Fields: fields}
+}
+
+// fieldError turns a validator failure into its client facing form
+func fieldError(fe validator.FieldError) FieldError {
+	// the namespace starts with the name of the validated struct
+	field := fe.Namespace()
+	if i := strings.Index(field, "."); i >= 0 {
+		field = field[i+1:]
+	}
+
+	return FieldError{
+		Field:   field,
+		Rule:    fe.Tag(),
+		Message: field + " " + ruleMessage(fe),
+	}
+}
+
+// ruleMessage phrases the rule a field broke
+func ruleMessage(fe validator.FieldError) string {
+	collection := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "device_state":
+		return "must be one of " + deviceStateNames()
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "max":
+		if collection {
+			return fmt.Sprintf("must contain at most %s items", fe.Param())
+		}
+		return fmt.Sprintf("must be at most %s characters long", fe.Param())
+	case "min":
+		if collection {
+			return fmt.Sprintf("must contain at least %s items", fe.Param())
+		}
+		return fmt.Sprintf("must be at least %s characters long", fe.Param())
+	default:
+		return "is invalid"
+	}
+}
+
+// deviceStateNames lists the accepted state names, comma separated
+func deviceStateNames() string {
+	names := make([]string, len(DeviceStates))
+	for i, state := range DeviceStates {
+		names[i] = state.String()
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead"
+)
+
+// Webhook is an endpoint device events are posted to. An empty EventTypes
+// list subscribes to every event type. Secret signs the payloads and is
+// only shown when the webhook is created.
+type Webhook struct {
+	ID         uuid.UUID      `json:"id" db:"id"`
+	UserID     uuid.UUID      `json:"user_id" db:"user_id"`
+	URL        string         `json:"url" db:"url" example:"https://tickets.example.com/hooks/devices"`
+	Secret     string         `json:"secret,omitempty" db:"secret" example:"6f1c0a..."`
+	EventTypes pq.StringArray `json:"event_types" db:"event_types" swaggertype:"array,string" example:"state_changed"`
+	Active     bool           `json:"active" db:"active" example:"true"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookDelivery is the delivery of one event to one webhook. NextAttemptAt
+// is nil once the event was delivered or given up on.
+type WebhookDelivery struct {
+	ID             uuid.UUID             `json:"id" db:"id"`
+	WebhookID      uuid.UUID             `json:"webhook_id" db:"webhook_id"`
+	EventID        uuid.UUID             `json:"event_id" db:"event_id"`
+	EventType      DeviceEventType       `json:"event_type" db:"event_type" example:"state_changed"`
+	Payload        json.RawMessage       `json:"payload" db:"payload" swaggertype:"object"`
+	Status         WebhookDeliveryStatus `json:"status" db:"status" example:"pending"`
+	Attempts       int                   `json:"attempts" db:"attempts" example:"2"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	LastStatusCode *int                  `json:"last_status_code,omitempty" db:"last_status_code" example:"503"`
+	LastError      *string               `json:"last_error,omitempty" db:"last_error" example:"unexpected status 503"`
+	CreatedAt      time.Time             `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty" db:"delivered_at"`
+}
+
+// PendingWebhookDelivery is a delivery claimed for an attempt, along with
+// where to send it
+type PendingWebhookDelivery struct {
+	WebhookDelivery
+	URL    string `db:"url"`
+	Secret string `db:"secret"`
+}
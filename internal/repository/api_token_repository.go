@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+const apiTokenColumns = "id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at"
+
+var ErrAPITokenNotFound = errors.New("api token not found")
+
+type APITokenRepositoryInterface interface {
+	CreateToken(token *model.APIToken, hash string) (*model.APIToken, error)
+	GetTokens(userID uuid.UUID) ([]model.APIToken, error)
+	DeleteToken(id string, userID uuid.UUID) error
+	UseToken(hash string, now time.Time) (*model.APIToken, error)
+}
+
+type APITokenRepository struct {
+	db *sqlx.DB
+}
+
+func NewAPITokenRepository(db *sqlx.DB) *APITokenRepository {
+	return &APITokenRepository{db: db}
+}
+
+// CreateToken stores token under hash
+func (r *APITokenRepository) CreateToken(token *model.APIToken, hash string) (*model.APIToken, error) {
+	var created model.APIToken
+	query := `
+        INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING ` + apiTokenColumns
+	err := r.db.Get(&created, query, token.UserID, token.Name, hash, token.Prefix, token.Scopes, token.ExpiresAt, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// GetTokens lists the tokens of a user, expired ones included, newest first
+func (r *APITokenRepository) GetTokens(userID uuid.UUID) ([]model.APIToken, error) {
+	tokens := []model.APIToken{}
+	query := `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC, id`
+	if err := r.db.Select(&tokens, query, userID); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// DeleteToken revokes a token owned by userID
+func (r *APITokenRepository) DeleteToken(id string, userID uuid.UUID) error {
+	result, err := r.db.Exec(`DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrAPITokenNotFound
+	}
+
+	return nil
+}
+
+// UseToken retrieves the unexpired token stored under hash and records it
+// was used at now
+func (r *APITokenRepository) UseToken(hash string, now time.Time) (*model.APIToken, error) {
+	var token model.APIToken
+	query := `
+        UPDATE api_tokens SET last_used_at = $2
+        WHERE token_hash = $1 AND (expires_at IS NULL OR expires_at > $2)
+        RETURNING ` + apiTokenColumns
+	if err := r.db.Get(&token, query, hash, now); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAPITokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var apiTokenRowColumns = []string{"id", "user_id", "name", "prefix", "scopes", "expires_at", "last_used_at", "created_at"}
+
+func TestAPITokenRepository_CreateToken(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewAPITokenRepository(db)
+
+	userID := uuid.New()
+	expiresAt := time.Now().Add(24 * time.Hour).UTC()
+	token := &model.APIToken{
+		UserID:    userID,
+		Name:      "ci",
+		Prefix:    "drt_abcdef",
+		Scopes:    pq.StringArray{"devices:read"},
+		ExpiresAt: &expiresAt,
+	}
+
+	tokenID := uuid.New()
+	rows := sqlmock.NewRows(apiTokenRowColumns).
+		AddRow(tokenID, userID, "ci", "drt_abcdef", "{devices:read}", expiresAt, nil, time.Now())
+	mock.ExpectQuery(`INSERT INTO api_tokens`).
+		WithArgs(userID, "ci", "hash", "drt_abcdef", token.Scopes, token.ExpiresAt, sqlmock.AnyArg()).
+		WillReturnRows(rows)
+
+	created, err := repo.CreateToken(token, "hash")
+
+	require.NoError(t, err)
+	assert.Equal(t, tokenID, created.ID)
+	assert.Equal(t, pq.StringArray{"devices:read"}, created.Scopes)
+	assert.Nil(t, created.LastUsedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPITokenRepository_GetTokens(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewAPITokenRepository(db)
+	userID := uuid.New()
+
+	rows := sqlmock.NewRows(apiTokenRowColumns).
+		AddRow(uuid.New(), userID, "ci", "drt_abcdef", "{devices:read,devices:operate}", nil, time.Now(), time.Now())
+	mock.ExpectQuery(`SELECT (.+) FROM api_tokens WHERE user_id = \$1 ORDER BY created_at DESC, id`).
+		WithArgs(userID).
+		WillReturnRows(rows)
+
+	tokens, err := repo.GetTokens(userID)
+
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, pq.StringArray{"devices:read", "devices:operate"}, tokens[0].Scopes)
+	assert.Nil(t, tokens[0].ExpiresAt)
+	assert.NotNil(t, tokens[0].LastUsedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPITokenRepository_DeleteToken(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewAPITokenRepository(db)
+	userID := uuid.New()
+	tokenID := uuid.NewString()
+
+	t.Run("revoked", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM api_tokens WHERE id = \$1 AND user_id = \$2`).
+			WithArgs(tokenID, userID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.DeleteToken(tokenID, userID))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM api_tokens`).
+			WithArgs(tokenID, userID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.Equal(t, ErrAPITokenNotFound, repo.DeleteToken(tokenID, userID))
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPITokenRepository_UseToken(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewAPITokenRepository(db)
+	now := time.Now().UTC()
+
+	t.Run("live token", func(t *testing.T) {
+		rows := sqlmock.NewRows(apiTokenRowColumns).
+			AddRow(uuid.New(), uuid.New(), "ci", "drt_abcdef", "{devices:read}", nil, now, now)
+		mock.ExpectQuery(`UPDATE api_tokens SET last_used_at = \$2\s+WHERE token_hash = \$1 AND \(expires_at IS NULL OR expires_at > \$2\)`).
+			WithArgs("hash", now).
+			WillReturnRows(rows)
+
+		token, err := repo.UseToken("hash", now)
+
+		require.NoError(t, err)
+		require.NotNil(t, token.LastUsedAt)
+		assert.True(t, token.LastUsedAt.Equal(now))
+	})
+
+	t.Run("unknown or expired token", func(t *testing.T) {
+		mock.ExpectQuery(`UPDATE api_tokens`).
+			WithArgs("hash", now).
+			WillReturnError(sql.ErrNoRows)
+
+		token, err := repo.UseToken("hash", now)
+
+		assert.Equal(t, ErrAPITokenNotFound, err)
+		assert.Nil(t, token)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
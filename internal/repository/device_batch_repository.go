@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+// DeviceBatchRepositoryInterface reads the devices, assignments and audit
+// trails of many devices in one query each, for callers such as the GraphQL
+// dataloaders that would otherwise query once per device
+type DeviceBatchRepositoryInterface interface {
+	GetDevicesByIDs(ids []uuid.UUID) ([]model.Device, error)
+	GetAssignmentsByDeviceIDs(deviceIDs []uuid.UUID) ([]model.Assignment, error)
+	GetDeviceHistories(deviceIDs []uuid.UUID, limit, offset int) ([]model.DeviceEvent, map[uuid.UUID]int, error)
+}
+
+// GetDevicesByIDs retrieves the devices with the given ids, trashed ones
+// included, in no particular order. Unknown ids are skipped.
+func (r *DeviceRepository) GetDevicesByIDs(ids []uuid.UUID) ([]model.Device, error) {
+	devices := []model.Device{}
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE id = ANY($1)`
+	if err := sqlx.Select(r.queryer(), &devices, query, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// GetAssignmentsByDeviceIDs retrieves every assignment of the given devices,
+// latest checkout first
+func (r *DeviceRepository) GetAssignmentsByDeviceIDs(deviceIDs []uuid.UUID) ([]model.Assignment, error) {
+	assignments := []model.Assignment{}
+	query := `
+        SELECT id, device_id, user_id, checked_out_at, checked_in_at
+        FROM device_assignments
+        WHERE device_id = ANY($1)
+        ORDER BY checked_out_at DESC, id
+    `
+	if err := sqlx.Select(r.queryer(), &assignments, query, pq.Array(deviceIDs)); err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+// deviceHistoryRow is an audit trail entry along with the size of the trail
+// it belongs to
+type deviceHistoryRow struct {
+	model.DeviceEvent
+	Total int `db:"total"`
+}
+
+// GetDeviceHistories retrieves the same page of the audit trail of each of
+// the given devices, newest first, along with the total number of events of
+// every device that has any
+func (r *DeviceRepository) GetDeviceHistories(deviceIDs []uuid.UUID, limit, offset int) ([]model.DeviceEvent, map[uuid.UUID]int, error) {
+	rows := []deviceHistoryRow{}
+	query := `
+        SELECT id, device_id, user_id, type, before, after, created_at, total
+        FROM (
+            SELECT id, device_id, user_id, type, before, after, created_at,
+                ROW_NUMBER() OVER (PARTITION BY device_id ORDER BY created_at DESC, id) AS position,
+                COUNT(*) OVER (PARTITION BY device_id) AS total
+            FROM device_events
+            WHERE device_id = ANY($1)
+        ) ranked
+        WHERE position > $2 AND position <= $2 + $3
+        ORDER BY device_id, position
+    `
+	if err := sqlx.Select(r.queryer(), &rows, query, pq.Array(deviceIDs), offset, limit); err != nil {
+		return nil, nil, err
+	}
+
+	events := make([]model.DeviceEvent, len(rows))
+	totals := map[uuid.UUID]int{}
+	for i, row := range rows {
+		events[i] = row.DeviceEvent
+		totals[row.DeviceID] = row.Total
+	}
+
+	return events, totals, nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceRepository_GetDevicesByIDs(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+	active, trashed := uuid.New(), uuid.New()
+	now := time.Now()
+
+	t.Run("trashed devices included", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "name", "brand", "state", "version", "created_at", "updated_at", "deleted_at"}).
+			AddRow(active, "Pixel 8", "Google", model.StateAvailable, 1, now, now, nil).
+			AddRow(trashed, "iPhone 15", "Apple", model.StateRetired, 3, now, now, now)
+
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = ANY\(\$1\)$`).
+			WithArgs(pq.Array([]uuid.UUID{active, trashed})).
+			WillReturnRows(rows)
+
+		devices, err := repo.GetDevicesByIDs([]uuid.UUID{active, trashed})
+
+		assert.NoError(t, err)
+		assert.Len(t, devices, 2)
+		assert.Nil(t, devices[0].DeletedAt)
+		assert.NotNil(t, devices[1].DeletedAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = ANY\(\$1\)`).
+			WillReturnError(fmt.Errorf("database error"))
+
+		devices, err := repo.GetDevicesByIDs([]uuid.UUID{active})
+
+		assert.Error(t, err)
+		assert.Nil(t, devices)
+	})
+}
+
+func TestDeviceRepository_GetAssignmentsByDeviceIDs(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+	deviceID := uuid.New()
+	userID := uuid.New()
+	now := time.Now()
+
+	rows := sqlmock.NewRows([]string{"id", "device_id", "user_id", "checked_out_at", "checked_in_at"}).
+		AddRow(uuid.New(), deviceID, userID, now, nil).
+		AddRow(uuid.New(), deviceID, userID, now.Add(-time.Hour), now.Add(-time.Minute))
+
+	mock.ExpectQuery(`SELECT (.+) FROM device_assignments WHERE device_id = ANY\(\$1\) ORDER BY checked_out_at DESC, id`).
+		WithArgs(pq.Array([]uuid.UUID{deviceID})).
+		WillReturnRows(rows)
+
+	assignments, err := repo.GetAssignmentsByDeviceIDs([]uuid.UUID{deviceID})
+
+	assert.NoError(t, err)
+	assert.Len(t, assignments, 2)
+	assert.Nil(t, assignments[0].CheckedInAt)
+	assert.NotNil(t, assignments[1].CheckedInAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeviceRepository_GetDeviceHistories(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+	first, second := uuid.New(), uuid.New()
+	now := time.Now()
+
+	t.Run("page of each trail", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "device_id", "user_id", "type", "before", "after", "created_at", "total"}).
+			AddRow(uuid.New(), first, nil, model.DeviceEventUpdated, []byte(`{"name":"Pixel"}`), []byte(`{"name":"Pixel 8"}`), now, 5).
+			AddRow(uuid.New(), second, nil, model.DeviceEventCreated, nil, []byte(`{"name":"iPhone 15"}`), now, 1)
+
+		mock.ExpectQuery(`ROW_NUMBER\(\) OVER \(PARTITION BY device_id ORDER BY created_at DESC, id\)(.+)WHERE position > \$2 AND position <= \$2 \+ \$3`).
+			WithArgs(pq.Array([]uuid.UUID{first, second}), 0, 20).
+			WillReturnRows(rows)
+
+		events, totals, err := repo.GetDeviceHistories([]uuid.UUID{first, second}, 20, 0)
+
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+		assert.Equal(t, map[uuid.UUID]int{first: 5, second: 1}, totals)
+		assert.JSONEq(t, `{"name":"Pixel 8"}`, string(*events[0].After))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery(`FROM device_events`).
+			WillReturnError(fmt.Errorf("database error"))
+
+		events, totals, err := repo.GetDeviceHistories([]uuid.UUID{first}, 20, 0)
+
+		assert.Error(t, err)
+		assert.Nil(t, events)
+		assert.Nil(t, totals)
+	})
+}
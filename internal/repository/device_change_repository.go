@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+// DeviceChangesChannel is the channel the devices trigger notifies on
+const DeviceChangesChannel = "device_changes"
+
+// listenerPingInterval is how often an idle listener checks its connection
+const listenerPingInterval = 90 * time.Second
+
+// DeviceChangeFilter narrows device changes to some brands and states. Empty
+// lists match everything.
+type DeviceChangeFilter struct {
+	Brands []string
+	States []model.DeviceState
+}
+
+// Matches reports whether change concerns a device the filter selects
+func (f DeviceChangeFilter) Matches(change model.DeviceChange) bool {
+	if len(f.Brands) > 0 && !slices.Contains(f.Brands, change.Device.Brand) {
+		return false
+	}
+	if len(f.States) > 0 && !slices.Contains(f.States, change.Device.State) {
+		return false
+	}
+	return true
+}
+
+// ChangeHorizon marks how far the change log is known to be complete. Seq is
+// the last sequence number handed out and Writers the virtual transaction ids
+// of the transactions writing device_changes once it was read. A writer locks
+// the table before taking its number, so every transaction holding a number
+// up to Seq is among them until it ends.
+type ChangeHorizon struct {
+	Seq     int64
+	Writers []string
+}
+
+type DeviceChangeRepositoryInterface interface {
+	GetChangesSince(seq, until int64, filter DeviceChangeFilter, limit int) ([]model.DeviceChange, error)
+	GetChangeHorizon() (ChangeHorizon, error)
+	HorizonSettled(horizon ChangeHorizon) (bool, error)
+	DeleteChangesBefore(cutoff time.Time) (int64, error)
+}
+
+type DeviceChangeRepository struct {
+	db *sqlx.DB
+}
+
+func NewDeviceChangeRepository(db *sqlx.DB) *DeviceChangeRepository {
+	return &DeviceChangeRepository{db: db}
+}
+
+// deviceChangeRow is a device_changes row, whose device is stored as JSON
+type deviceChangeRow struct {
+	Seq    int64                 `db:"seq"`
+	Type   model.DeviceEventType `db:"type"`
+	Device json.RawMessage       `db:"device"`
+}
+
+// GetChangesSince retrieves up to limit changes matching filter that come
+// after seq and no later than until, oldest first
+func (r *DeviceChangeRepository) GetChangesSince(seq, until int64, filter DeviceChangeFilter, limit int) ([]model.DeviceChange, error) {
+	qb := &queryBuilder{}
+	qb.Where("seq > ?", seq)
+	qb.Where("seq <= ?", until)
+	if len(filter.Brands) > 0 {
+		qb.Where("device->>'brand' = ANY(?)", pq.Array(filter.Brands))
+	}
+	if len(filter.States) > 0 {
+		qb.Where("(device->>'state')::int = ANY(?)", stateArray(filter.States))
+	}
+
+	query, args := qb.Build("SELECT seq, type, device FROM device_changes", "ORDER BY seq LIMIT ?", limit)
+
+	rows := []deviceChangeRow{}
+	if err := r.db.Select(&rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	changes := make([]model.DeviceChange, len(rows))
+	for i, row := range rows {
+		changes[i] = model.DeviceChange{Seq: row.Seq, Type: row.Type}
+		if err := json.Unmarshal(row.Device, &changes[i].Device); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+// GetChangeHorizon returns the last sequence number handed out. Numbers are
+// taken before commit, so changes up to it may still be in flight.
+func (r *DeviceChangeRepository) GetChangeHorizon() (ChangeHorizon, error) {
+	var horizon ChangeHorizon
+
+	// the sequence is read before the writers, so any transaction that had
+	// taken a number by then still holds its lock on the table
+	if err := r.db.Get(&horizon.Seq, "SELECT COALESCE(pg_sequence_last_value(pg_get_serial_sequence('device_changes', 'seq')::regclass), 0)"); err != nil {
+		return ChangeHorizon{}, err
+	}
+
+	var writers pq.StringArray
+	query := `
+        SELECT COALESCE(array_agg(virtualtransaction), '{}')
+        FROM pg_locks
+        WHERE locktype = 'relation' AND relation = 'device_changes'::regclass
+          AND mode = 'RowExclusiveLock' AND granted
+    `
+	if err := r.db.Get(&writers, query); err != nil {
+		return ChangeHorizon{}, err
+	}
+	horizon.Writers = writers
+
+	return horizon, nil
+}
+
+// HorizonSettled reports whether every writer of the horizon has finished,
+// after which no change up to its Seq can still appear. Only transactions
+// that wrote devices are waited on, but one left open holds the horizon
+// back until it ends.
+func (r *DeviceChangeRepository) HorizonSettled(horizon ChangeHorizon) (bool, error) {
+	if len(horizon.Writers) == 0 {
+		return true, nil
+	}
+
+	var settled bool
+	query := `
+        SELECT NOT EXISTS (
+            SELECT 1 FROM pg_locks
+            WHERE locktype = 'relation' AND relation = 'device_changes'::regclass
+              AND virtualtransaction = ANY($1)
+        )
+    `
+	err := r.db.Get(&settled, query, pq.Array(horizon.Writers))
+	return settled, err
+}
+
+// DeleteChangesBefore removes the changes recorded before cutoff. Streams
+// can no longer resume from them.
+func (r *DeviceChangeRepository) DeleteChangesBefore(cutoff time.Time) (int64, error) {
+	result, err := r.db.Exec("DELETE FROM device_changes WHERE created_at < $1", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeviceChangeListener receives the device changes announced by any replica
+type DeviceChangeListener struct {
+	listener *pq.Listener
+}
+
+func NewDeviceChangeListener(listener *pq.Listener) *DeviceChangeListener {
+	return &DeviceChangeListener{listener: listener}
+}
+
+// Listen hands every announced change to publish until ctx is done. The
+// listener reconnects on its own, but notifications sent while it was away
+// are lost, so reset is called whenever that may have happened.
+func (l *DeviceChangeListener) Listen(ctx context.Context, publish func(model.DeviceChange), reset func()) error {
+	defer l.listener.Close()
+
+	if err := l.listener.Listen(DeviceChangesChannel); err != nil {
+		return err
+	}
+
+	ping := time.NewTicker(listenerPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case notification := <-l.listener.Notify:
+			if notification == nil {
+				reset()
+				continue
+			}
+
+			var change model.DeviceChange
+			if err := json.Unmarshal([]byte(notification.Extra), &change); err != nil {
+				// the table still has the change, let streams resume from it
+				reset()
+				continue
+			}
+			publish(change)
+		case <-ping.C:
+			go l.listener.Ping()
+		}
+	}
+}
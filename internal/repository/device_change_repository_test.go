@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test GetChangesSince
+
+func TestDeviceChangeRepository_GetChangesSince(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceChangeRepository(db)
+
+	deviceID := uuid.New()
+	device := `{"id":"` + deviceID.String() + `","name":"iPhone 15","brand":"Apple","state":2,"version":3,"created_at":"2025-01-01T09:00:00+00:00","updated_at":"2025-01-02T09:00:00.5+00:00","deleted_at":null}`
+
+	mock.ExpectQuery(`SELECT seq, type, device FROM device_changes WHERE seq > \$1 AND seq <= \$2 AND device->>'brand' = ANY\(\$3\) AND \(device->>'state'\)::int = ANY\(\$4\) ORDER BY seq LIMIT \$5`).
+		WithArgs(int64(41), int64(50), pq.Array([]string{"Apple"}), pq.Int64Array{2}, 200).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "type", "device"}).
+			AddRow(int64(42), "state_changed", []byte(device)))
+
+	changes, err := repo.GetChangesSince(41, 50, DeviceChangeFilter{
+		Brands: []string{"Apple"},
+		States: []model.DeviceState{model.StateInUse},
+	}, 200)
+
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, int64(42), changes[0].Seq)
+	assert.Equal(t, model.DeviceEventStateChanged, changes[0].Type)
+	assert.Equal(t, deviceID, changes[0].Device.ID)
+	assert.Equal(t, model.StateInUse, changes[0].Device.State)
+	assert.Equal(t, 3, changes[0].Device.Version)
+	assert.Nil(t, changes[0].Device.DeletedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test GetChangeHorizon
+
+func TestDeviceChangeRepository_GetChangeHorizon(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceChangeRepository(db)
+
+	mock.ExpectQuery(`SELECT COALESCE\(pg_sequence_last_value\(pg_get_serial_sequence\('device_changes', 'seq'\)::regclass\), 0\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(int64(42)))
+	mock.ExpectQuery(`SELECT COALESCE\(array_agg\(virtualtransaction\), '\{\}'\) FROM pg_locks WHERE locktype = 'relation' AND relation = 'device_changes'::regclass AND mode = 'RowExclusiveLock' AND granted`).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow("{3/17,5/2}"))
+
+	horizon, err := repo.GetChangeHorizon()
+
+	assert.NoError(t, err)
+	assert.Equal(t, ChangeHorizon{Seq: 42, Writers: []string{"3/17", "5/2"}}, horizon)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test HorizonSettled
+
+func TestDeviceChangeRepository_HorizonSettled(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceChangeRepository(db)
+
+	t.Run("writers still running", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT NOT EXISTS \( SELECT 1 FROM pg_locks WHERE locktype = 'relation' AND relation = 'device_changes'::regclass AND virtualtransaction = ANY\(\$1\) \)`).
+			WithArgs(pq.Array([]string{"3/17", "5/2"})).
+			WillReturnRows(sqlmock.NewRows([]string{"settled"}).AddRow(false))
+
+		settled, err := repo.HorizonSettled(ChangeHorizon{Seq: 42, Writers: []string{"3/17", "5/2"}})
+
+		assert.NoError(t, err)
+		assert.False(t, settled)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("no writers", func(t *testing.T) {
+		settled, err := repo.HorizonSettled(ChangeHorizon{Seq: 42})
+
+		assert.NoError(t, err)
+		assert.True(t, settled)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// Test DeleteChangesBefore
+
+func TestDeviceChangeRepository_DeleteChangesBefore(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceChangeRepository(db)
+
+	cutoff := time.Now().UTC().Add(-7 * 24 * time.Hour)
+
+	mock.ExpectExec(`DELETE FROM device_changes WHERE created_at < \$1`).
+		WithArgs(cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 12))
+
+	deleted, err := repo.DeleteChangesBefore(cutoff)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test DeviceChangeFilter
+
+func TestDeviceChangeFilter_Matches(t *testing.T) {
+	change := model.DeviceChange{Device: model.Device{Brand: "Apple", State: model.StateAvailable}}
+
+	assert.True(t, DeviceChangeFilter{}.Matches(change))
+	assert.True(t, DeviceChangeFilter{Brands: []string{"Google", "Apple"}}.Matches(change))
+	assert.False(t, DeviceChangeFilter{Brands: []string{"Google"}}.Matches(change))
+	assert.True(t, DeviceChangeFilter{States: []model.DeviceState{model.StateAvailable}}.Matches(change))
+	assert.False(t, DeviceChangeFilter{Brands: []string{"Apple"}, States: []model.DeviceState{model.StateInUse}}.Matches(change))
+}
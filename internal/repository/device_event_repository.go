@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+// GetDeviceHistory retrieves a page of the device audit trail, newest first,
+// along with the total number of events
+func (r *DeviceRepository) GetDeviceHistory(id string, limit, offset int) ([]model.DeviceEvent, int, error) {
+	events := []model.DeviceEvent{}
+	var total int
+
+	if err := sqlx.Get(r.queryer(), &total, "SELECT COUNT(*) FROM device_events WHERE device_id = $1", id); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+        SELECT id, device_id, user_id, type, before, after, created_at
+        FROM device_events
+        WHERE device_id = $1
+        ORDER BY created_at DESC, id
+        LIMIT $2 OFFSET $3
+    `
+	if err := sqlx.Select(r.queryer(), &events, query, id, limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
+
+// recordDeviceEvent appends an entry to the device audit trail as part of the
+// transaction performing the change, and queues it in the webhook outbox
+// along with the device as the change left it. A nil actorID is stored as
+// NULL for changes not made by a user.
+func recordDeviceEvent(tx *sqlx.Tx, eventType model.DeviceEventType, actorID uuid.UUID, before, after *model.Device) error {
+	deviceID := deviceIDOf(before, after)
+	beforeFields, afterFields := model.DiffDevices(before, after)
+
+	beforeJSON, err := marshalFields(beforeFields)
+	if err != nil {
+		return err
+	}
+
+	afterJSON, err := marshalFields(afterFields)
+	if err != nil {
+		return err
+	}
+
+	snapshot := after
+	if snapshot == nil {
+		snapshot = before
+	}
+	deviceJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	var userID *uuid.UUID
+	if actorID != uuid.Nil {
+		userID = &actorID
+	}
+
+	query := `
+        WITH event AS (
+            INSERT INTO device_events (device_id, user_id, type, before, after)
+            VALUES ($1, $2, $3, $4, $5)
+            RETURNING id, created_at
+        )
+        INSERT INTO webhook_outbox (event_id, event_type, payload)
+        SELECT id, $3, jsonb_build_object(
+            'id', id,
+            'type', $3::text,
+            'device_id', $1::uuid,
+            'user_id', $2::uuid,
+            'before', $4::jsonb,
+            'after', $5::jsonb,
+            'device', $6::jsonb,
+            'occurred_at', created_at AT TIME ZONE 'UTC'
+        )
+        FROM event
+    `
+	_, err = tx.Exec(query, deviceID, userID, eventType, beforeJSON, afterJSON, string(deviceJSON))
+	return err
+}
+
+func deviceIDOf(before, after *model.Device) uuid.UUID {
+	if after != nil {
+		return after.ID
+	}
+	return before.ID
+}
+
+// marshalFields encodes an audit diff, storing empty diffs as NULL
+func marshalFields(fields map[string]interface{}) (sql.NullString, error) {
+	if len(fields) == 0 {
+		return sql.NullString{}, nil
+	}
+
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test GetDeviceHistory
+
+func TestDeviceRepository_GetDeviceHistory(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+	now := time.Now()
+
+	t.Run("page of events", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM device_events WHERE device_id = \$1`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+		rows := sqlmock.NewRows([]string{"id", "device_id", "user_id", "type", "before", "after", "created_at"}).
+			AddRow(uuid.New(), deviceID, userID, model.DeviceEventStateChanged, []byte(`{"state":"available"}`), []byte(`{"state":"inactive"}`), now).
+			AddRow(uuid.New(), deviceID, nil, model.DeviceEventCreated, nil, []byte(`{"name":"Pixel 8","brand":"Google","state":"available"}`), now)
+
+		mock.ExpectQuery(`SELECT (.+) FROM device_events WHERE device_id = \$1 ORDER BY created_at DESC, id LIMIT \$2 OFFSET \$3`).
+			WithArgs(deviceID.String(), 2, 0).
+			WillReturnRows(rows)
+
+		events, total, err := repo.GetDeviceHistory(deviceID.String(), 2, 0)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Len(t, events, 2)
+		assert.Equal(t, userID, *events[0].UserID)
+		assert.JSONEq(t, `{"state":"inactive"}`, string(*events[0].After))
+		assert.Nil(t, events[1].UserID)
+		assert.Nil(t, events[1].Before)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM device_events WHERE device_id = \$1`).
+			WithArgs(deviceID.String()).
+			WillReturnError(fmt.Errorf("database error"))
+
+		events, total, err := repo.GetDeviceHistory(deviceID.String(), 20, 0)
+
+		assert.Error(t, err)
+		assert.Nil(t, events)
+		assert.Zero(t, total)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// Test recordDeviceEvent
+
+func TestRecordDeviceEvent_SystemActor(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	device := &model.Device{ID: uuid.New(), Name: "Pixel 8", Brand: "Google", State: model.StateAvailable}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO device_events`).
+		WithArgs(device.ID, nil, model.DeviceEventCreated, nil, `{"brand":"Google","name":"Pixel 8","state":"available"}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Beginx()
+	assert.NoError(t, err)
+	assert.NoError(t, recordDeviceEvent(tx, model.DeviceEventCreated, uuid.Nil, nil, device))
+	assert.NoError(t, tx.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordDeviceEvent_QueuesWebhookEvent(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	before := &model.Device{ID: uuid.New(), Name: "Pixel 8", Brand: "Google", State: model.StateAvailable, Version: 2}
+	snapshot, _ := json.Marshal(before)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`WITH event AS \(\s*INSERT INTO device_events (.+) RETURNING id, created_at\s*\)\s*INSERT INTO webhook_outbox \(event_id, event_type, payload\)`).
+		WithArgs(before.ID, sqlmock.AnyArg(), model.DeviceEventDeleted, sqlmock.AnyArg(), nil, string(snapshot)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Beginx()
+	assert.NoError(t, err)
+	assert.NoError(t, recordDeviceEvent(tx, model.DeviceEventDeleted, uuid.New(), before, nil))
+	assert.NoError(t, tx.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
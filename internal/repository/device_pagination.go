@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+var (
+	ErrInvalidSort   = errors.New("invalid sort field")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// deviceSortColumns lists the columns devices can be ordered by
+var deviceSortColumns = []string{"name", "brand", "state", "created_at", "updated_at"}
+
+// DeviceSort describes the order of a device listing
+type DeviceSort struct {
+	Field      string
+	Descending bool
+}
+
+// DefaultDeviceSort keeps the newest devices first
+var DefaultDeviceSort = DeviceSort{Field: "created_at", Descending: true}
+
+// ParseDeviceSort parses a sort parameter such as "name" or "-created_at",
+// where a leading dash means descending order
+func ParseDeviceSort(str string) (DeviceSort, error) {
+	sort := DeviceSort{Field: str}
+	if strings.HasPrefix(str, "-") {
+		sort = DeviceSort{Field: str[1:], Descending: true}
+	}
+
+	if !sort.valid() {
+		return DeviceSort{}, fmt.Errorf("%w: %s", ErrInvalidSort, str)
+	}
+
+	return sort, nil
+}
+
+// valid reports whether the sort field is a sortable column
+func (s DeviceSort) valid() bool {
+	for _, column := range deviceSortColumns {
+		if column == s.Field {
+			return true
+		}
+	}
+	return false
+}
+
+func (s DeviceSort) String() string {
+	if s.Descending {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// DevicePage is a single page of a device listing
+type DevicePage struct {
+	Devices    []model.Device
+	NextCursor string
+	Total      *int
+}
+
+// deviceCursor is the decoded form of the opaque cursor handed to clients.
+// It remembers the sort it was issued for so it cannot be replayed against
+// a different ordering.
+type deviceCursor struct {
+	Sort  string    `json:"s"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+// encodeDeviceCursor builds the cursor pointing right after device
+func encodeDeviceCursor(sort DeviceSort, device model.Device) string {
+	data, _ := json.Marshal(deviceCursor{
+		Sort:  sort.String(),
+		Value: sortValue(sort.Field, device),
+		ID:    device.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeDeviceCursor parses a cursor and checks it was issued for sort
+func decodeDeviceCursor(cursor string, sort DeviceSort) (*deviceCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var decoded deviceCursor
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if decoded.Sort != sort.String() || decoded.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &decoded, nil
+}
+
+// sortValue returns the value of the sort column for device as sent to postgres
+func sortValue(field string, device model.Device) string {
+	switch field {
+	case "name":
+		return device.Name
+	case "brand":
+		return device.Brand
+	case "state":
+		return strconv.Itoa(int(device.State))
+	case "updated_at":
+		return device.UpdatedAt.Format(time.RFC3339Nano)
+	default:
+		return device.CreatedAt.Format(time.RFC3339Nano)
+	}
+}
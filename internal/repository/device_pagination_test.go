@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test ParseDeviceSort
+
+func TestParseDeviceSort(t *testing.T) {
+	t.Run("ascending", func(t *testing.T) {
+		sort, err := ParseDeviceSort("name")
+
+		assert.NoError(t, err)
+		assert.Equal(t, DeviceSort{Field: "name"}, sort)
+	})
+
+	t.Run("descending", func(t *testing.T) {
+		sort, err := ParseDeviceSort("-updated_at")
+
+		assert.NoError(t, err)
+		assert.Equal(t, DeviceSort{Field: "updated_at", Descending: true}, sort)
+		assert.Equal(t, "-updated_at", sort.String())
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		_, err := ParseDeviceSort("id; DROP TABLE devices")
+
+		assert.ErrorIs(t, err, ErrInvalidSort)
+	})
+}
+
+// Test keyset pagination
+
+func TestDeviceRepository_GetDevicesPagination(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+
+	now := time.Now()
+	firstID := uuid.New()
+	secondID := uuid.New()
+	sort := DeviceSort{Field: "name"}
+
+	t.Run("first page returns a cursor", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"}).
+			AddRow(firstID, "Galaxy S23", "Samsung", model.StateAvailable, now, now).
+			AddRow(secondID, "iPhone 15", "Apple", model.StateAvailable, now, now).
+			AddRow(uuid.New(), "Pixel 8", "Google", model.StateAvailable, now, now)
+
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE deleted_at IS NULL ORDER BY name ASC, id ASC LIMIT \$1`).
+			WithArgs(3).
+			WillReturnRows(rows)
+
+		page, err := repo.GetDevices(DeviceFilter{Sort: sort, Limit: 2})
+
+		assert.NoError(t, err)
+		assert.Len(t, page.Devices, 2)
+		assert.NotEmpty(t, page.NextCursor)
+		assert.Nil(t, page.Total)
+
+		cursor, err := decodeDeviceCursor(page.NextCursor, sort)
+		assert.NoError(t, err)
+		assert.Equal(t, "iPhone 15", cursor.Value)
+		assert.Equal(t, secondID, cursor.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("next page continues after the cursor", func(t *testing.T) {
+		cursor := encodeDeviceCursor(sort, model.Device{ID: secondID, Name: "iPhone 15"})
+		rows := sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"}).
+			AddRow(uuid.New(), "Pixel 8", "Google", model.StateAvailable, now, now)
+
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE deleted_at IS NULL AND \(name, id\) > \(\$1, \$2\) ORDER BY name ASC, id ASC LIMIT \$3`).
+			WithArgs("iPhone 15", secondID, 3).
+			WillReturnRows(rows)
+
+		page, err := repo.GetDevices(DeviceFilter{Sort: sort, Limit: 2, Cursor: cursor})
+
+		assert.NoError(t, err)
+		assert.Len(t, page.Devices, 1)
+		assert.Empty(t, page.NextCursor)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("descending sort with total", func(t *testing.T) {
+		brand := "Apple"
+		cursor := encodeDeviceCursor(DefaultDeviceSort, model.Device{ID: firstID, CreatedAt: now})
+
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM devices WHERE deleted_at IS NULL AND brand = ANY\(\$1\)`).
+			WithArgs(pq.Array([]string{brand})).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE deleted_at IS NULL AND brand = ANY\(\$1\) AND \(created_at, id\) < \(\$2, \$3\) ORDER BY created_at DESC, id DESC LIMIT \$4`).
+			WithArgs(pq.Array([]string{brand}), now.Format(time.RFC3339Nano), firstID, 6).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"}))
+
+		page, err := repo.GetDevices(DeviceFilter{Brands: []string{brand}, Limit: 5, Cursor: cursor, IncludeTotal: true})
+
+		assert.NoError(t, err)
+		assert.Empty(t, page.Devices)
+		assert.Equal(t, 7, *page.Total)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("malformed cursor", func(t *testing.T) {
+		page, err := repo.GetDevices(DeviceFilter{Sort: sort, Limit: 2, Cursor: "not a cursor"})
+
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+		assert.Nil(t, page)
+	})
+
+	t.Run("cursor issued for another sort", func(t *testing.T) {
+		cursor := encodeDeviceCursor(DeviceSort{Field: "brand"}, model.Device{ID: firstID, Brand: "Apple"})
+
+		page, err := repo.GetDevices(DeviceFilter{Sort: sort, Limit: 2, Cursor: cursor})
+
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+		assert.Nil(t, page)
+	})
+
+	t.Run("unknown sort field", func(t *testing.T) {
+		page, err := repo.GetDevices(DeviceFilter{Sort: DeviceSort{Field: "serial"}})
+
+		assert.ErrorIs(t, err, ErrInvalidSort)
+		assert.Nil(t, page)
+	})
+}
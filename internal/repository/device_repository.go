@@ -2,80 +2,234 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/loopsFreitag/DeviceRegistry/internal/model"
 )
 
+var (
+	ErrDeviceNotFound      = errors.New("device not found")
+	ErrDeviceInUse         = errors.New("device is already in use")
+	ErrDeviceNotAvailable  = errors.New("device is not available")
+	ErrDeviceNotCheckedOut = errors.New("device is not checked out")
+	ErrNotAssignee         = errors.New("device is checked out by another user")
+	ErrVersionMismatch     = errors.New("device has been modified since it was read")
+	ErrDeviceReserved      = errors.New("device is reserved by another user")
+)
+
 type DeviceRepositoryInterface interface {
-	GetDevices(filter DeviceFilter) ([]model.Device, error)
+	GetDevices(filter DeviceFilter) (*DevicePage, error)
 	GetDeviceByID(id string) (*model.Device, error)
-	CreateDevice(device *model.Device) (*model.Device, error)
-	UpdateDevice(device *model.Device) (*model.Device, error)
-	DeleteDevice(id string) error
+	SearchDevices(text string, limit int) ([]model.DeviceSearchResult, error)
+	CreateDevice(device *model.Device, actorID uuid.UUID) (*model.Device, error)
+	UpdateDevice(device *model.Device, actorID uuid.UUID) (*model.Device, error)
+	DeleteDevice(id string, version int, actorID uuid.UUID) error
+	GetDeletedDevices() ([]model.Device, error)
+	RestoreDevice(id string, actorID uuid.UUID) (*model.Device, error)
+	PurgeDeletedDevices(olderThan time.Duration) (int64, error)
+	GetDeviceHistory(id string, limit, offset int) ([]model.DeviceEvent, int, error)
+	CheckoutDevice(id string, userID uuid.UUID) (*model.Assignment, error)
+	CheckinDevice(id string, userID uuid.UUID) (*model.Assignment, error)
+	WithTx(fn func(repo DeviceRepositoryInterface) error) error
 }
 
+// deviceColumns lists the columns scanned into model.Device. Queries name
+// them explicitly because devices also carries columns that are only used
+// inside postgres, such as the search vector.
+const deviceColumns = "id, name, brand, state, version, created_at, updated_at, deleted_at"
+
 type DeviceRepository struct {
 	db *sqlx.DB
+	tx *sqlx.Tx
 }
 
 func NewDeviceRepository(db *sqlx.DB) *DeviceRepository {
 	return &DeviceRepository{db: db}
 }
 
-// DeviceFilter holds the query filters
+// WithTx runs fn with a repository bound to a single transaction, so every
+// write made through it commits or rolls back together
+func (r *DeviceRepository) WithTx(fn func(repo DeviceRepositoryInterface) error) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		return fn(&DeviceRepository{db: r.db, tx: tx})
+	})
+}
+
+// inTx runs fn in the bound transaction, or in a new one when unbound
+func (r *DeviceRepository) inTx(fn func(tx *sqlx.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return withTx(r.db, fn)
+}
+
+// queryer returns the bound transaction, or the pool when unbound
+func (r *DeviceRepository) queryer() sqlx.Ext {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// DeviceFilter holds the query filters and pagination options. Empty lists
+// and nil bounds are ignored, a zero Sort falls back to DefaultDeviceSort
+// and a zero Limit returns every match.
 type DeviceFilter struct {
-	Brand *string
-	State *model.DeviceState
+	Brands         []string
+	ExcludeBrands  []string
+	States         []model.DeviceState
+	ExcludeStates  []model.DeviceState
+	NamePrefix     string
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	UpdatedAfter   *time.Time
+	UpdatedBefore  *time.Time
+	IncludeDeleted bool
+	Sort           DeviceSort
+	Limit          int
+	Cursor         string
+	IncludeTotal   bool
 }
 
-// GetDevices retrieves devices with optional filters
-func (r *DeviceRepository) GetDevices(filter DeviceFilter) ([]model.Device, error) {
-	var devices []model.Device
+// where turns the filters into conditions on a query builder
+func (f DeviceFilter) where() *queryBuilder {
+	qb := &queryBuilder{}
+
+	if !f.IncludeDeleted {
+		qb.Where("deleted_at IS NULL")
+	}
+	if len(f.Brands) > 0 {
+		qb.Where("brand = ANY(?)", pq.Array(f.Brands))
+	}
+	if len(f.ExcludeBrands) > 0 {
+		qb.Where("brand <> ALL(?)", pq.Array(f.ExcludeBrands))
+	}
+	if len(f.States) > 0 {
+		qb.Where("state = ANY(?)", stateArray(f.States))
+	}
+	if len(f.ExcludeStates) > 0 {
+		qb.Where("state <> ALL(?)", stateArray(f.ExcludeStates))
+	}
+	if f.NamePrefix != "" {
+		qb.Where(`lower(name) LIKE ? ESCAPE '\'`, likePrefix(strings.ToLower(f.NamePrefix)))
+	}
+	if f.CreatedAfter != nil {
+		qb.Where("created_at >= ?", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		qb.Where("created_at < ?", *f.CreatedBefore)
+	}
+	if f.UpdatedAfter != nil {
+		qb.Where("updated_at >= ?", *f.UpdatedAfter)
+	}
+	if f.UpdatedBefore != nil {
+		qb.Where("updated_at < ?", *f.UpdatedBefore)
+	}
+
+	return qb
+}
+
+// stateArray converts states to a postgres integer array
+func stateArray(states []model.DeviceState) pq.Int64Array {
+	values := make(pq.Int64Array, len(states))
+	for i, state := range states {
+		values[i] = int64(state)
+	}
+	return values
+}
 
-	query := "SELECT * FROM devices WHERE 1=1"
-	args := []interface{}{}
-	argCount := 1
+// GetDevices retrieves a page of devices with optional filters, using keyset
+// pagination over the sort column and the id as a tiebreaker
+func (r *DeviceRepository) GetDevices(filter DeviceFilter) (*DevicePage, error) {
+	sort := filter.Sort
+	if sort.Field == "" {
+		sort = DefaultDeviceSort
+	}
+	if !sort.valid() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSort, sort.Field)
+	}
 
-	if filter.Brand != nil {
-		query += fmt.Sprintf(" AND brand = $%d", argCount)
-		args = append(args, *filter.Brand)
-		argCount++
+	qb := filter.where()
+	page := &DevicePage{}
+
+	if filter.IncludeTotal {
+		var total int
+		query, args := qb.Build("SELECT COUNT(*) FROM devices", "")
+		if err := sqlx.Get(r.queryer(), &total, query, args...); err != nil {
+			return nil, err
+		}
+		page.Total = &total
 	}
 
-	if filter.State != nil {
-		query += fmt.Sprintf(" AND state = $%d", argCount)
-		args = append(args, *filter.State)
-		argCount++
+	direction, comparison := "ASC", ">"
+	if sort.Descending {
+		direction, comparison = "DESC", "<"
 	}
 
-	query += " ORDER BY created_at DESC"
+	if filter.Cursor != "" {
+		cursor, err := decodeDeviceCursor(filter.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
 
-	err := r.db.Select(&devices, query, args...)
-	return devices, err
+		qb.Where(fmt.Sprintf("(%s, id) %s (?, ?)", sort.Field, comparison), cursor.Value, cursor.ID)
+	}
+
+	tail := fmt.Sprintf("ORDER BY %s %s, id %s", sort.Field, direction, direction)
+	var tailArgs []interface{}
+
+	// fetch one extra row to know whether there is a next page
+	if filter.Limit > 0 {
+		tail += " LIMIT ?"
+		tailArgs = append(tailArgs, filter.Limit+1)
+	}
+
+	var devices []model.Device
+	query, args := qb.Build("SELECT "+deviceColumns+" FROM devices", tail, tailArgs...)
+	if err := sqlx.Select(r.queryer(), &devices, query, args...); err != nil {
+		return nil, err
+	}
+
+	if filter.Limit > 0 && len(devices) > filter.Limit {
+		devices = devices[:filter.Limit]
+		page.NextCursor = encodeDeviceCursor(sort, devices[len(devices)-1])
+	}
+
+	page.Devices = devices
+	return page, nil
 }
 
 // GetDeviceByID retrieves a device by its ID
 func (r *DeviceRepository) GetDeviceByID(id string) (*model.Device, error) {
 	var device model.Device
-	query := "SELECT * FROM devices WHERE id = $1"
-	err := r.db.Get(&device, query, id)
+	query := "SELECT " + deviceColumns + " FROM devices WHERE id = $1 AND deleted_at IS NULL"
+	err := sqlx.Get(r.queryer(), &device, query, id)
 	if err != nil {
 		return nil, err
 	}
 	return &device, nil
 }
 
-// CreateDevice creates a new device
-func (r *DeviceRepository) CreateDevice(device *model.Device) (*model.Device, error) {
-	query := `
+// CreateDevice creates a new device and records who created it
+func (r *DeviceRepository) CreateDevice(device *model.Device, actorID uuid.UUID) (*model.Device, error) {
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		query := `
         INSERT INTO devices (name, brand, state)
         VALUES ($1, $2, $3)
-        RETURNING id, name, brand, state, created_at, updated_at
-    `
+        RETURNING ` + deviceColumns
+		if err := tx.QueryRowx(query, device.Name, device.Brand, device.State).StructScan(device); err != nil {
+			return err
+		}
 
-	err := r.db.QueryRowx(query, device.Name, device.Brand, device.State).StructScan(device)
+		return recordDeviceEvent(tx, model.DeviceEventCreated, actorID, nil, device)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -83,16 +237,30 @@ func (r *DeviceRepository) CreateDevice(device *model.Device) (*model.Device, er
 	return device, nil
 }
 
-// UpdateDevice updates an existing device
-func (r *DeviceRepository) UpdateDevice(device *model.Device) (*model.Device, error) {
-	query := `
+// UpdateDevice updates an existing device and records the changed fields.
+// A non-zero device.Version must match the stored version.
+func (r *DeviceRepository) UpdateDevice(device *model.Device, actorID uuid.UUID) (*model.Device, error) {
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		before, err := lockDevice(tx, device.ID.String())
+		if err != nil {
+			return err
+		}
+
+		if err := checkVersion(before, device.Version); err != nil {
+			return err
+		}
+
+		query := `
         UPDATE devices 
         SET name = $1, brand = $2, state = $3
         WHERE id = $4
-        RETURNING id, name, brand, state, created_at, updated_at
-    `
+        RETURNING ` + deviceColumns
+		if err := tx.QueryRowx(query, device.Name, device.Brand, device.State, device.ID).StructScan(device); err != nil {
+			return err
+		}
 
-	err := r.db.QueryRowx(query, device.Name, device.Brand, device.State, device.ID).StructScan(device)
+		return recordDeviceEvent(tx, updateEventType(before, device), actorID, before, device)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -100,22 +268,251 @@ func (r *DeviceRepository) UpdateDevice(device *model.Device) (*model.Device, er
 	return device, nil
 }
 
-// DeleteDevice deletes a device by its ID
-func (r *DeviceRepository) DeleteDevice(id string) error {
-	query := "DELETE FROM devices WHERE id = $1"
-	result, err := r.db.Exec(query, id)
+// DeleteDevice moves a device to the trash and records who deleted it. A
+// non-zero version must match the stored version.
+func (r *DeviceRepository) DeleteDevice(id string, version int, actorID uuid.UUID) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		before, err := lockDevice(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := checkVersion(before, version); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec("UPDATE devices SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL", id)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return ErrDeviceNotFound
+		}
+
+		return recordDeviceEvent(tx, model.DeviceEventDeleted, actorID, before, nil)
+	})
+}
+
+// GetDeletedDevices retrieves the devices in the trash, most recently deleted first
+func (r *DeviceRepository) GetDeletedDevices() ([]model.Device, error) {
+	devices := []model.Device{}
+
+	query := "SELECT " + deviceColumns + " FROM devices WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC"
+	err := sqlx.Select(r.queryer(), &devices, query)
+	return devices, err
+}
+
+// RestoreDevice takes a device out of the trash and records who restored it
+func (r *DeviceRepository) RestoreDevice(id string, actorID uuid.UUID) (*model.Device, error) {
+	var device model.Device
+
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		query := `
+        UPDATE devices
+        SET deleted_at = NULL
+        WHERE id = $1 AND deleted_at IS NOT NULL
+        RETURNING ` + deviceColumns
+		if err := tx.QueryRowx(query, id).StructScan(&device); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrDeviceNotFound
+			}
+			return err
+		}
+
+		return recordDeviceEvent(tx, model.DeviceEventRestored, actorID, nil, &device)
+	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	return &device, nil
+}
+
+// PurgeDeletedDevices permanently removes devices that have been in the
+// trash for longer than olderThan and returns how many were removed. Their
+// history is kept. The cutoff is computed by the database, which also sets
+// deleted_at, so both sides share the same clock.
+func (r *DeviceRepository) PurgeDeletedDevices(olderThan time.Duration) (int64, error) {
+	query := `
+        DELETE FROM devices
+        WHERE deleted_at IS NOT NULL
+          AND deleted_at < CURRENT_TIMESTAMP - make_interval(secs => $1)
+    `
+	result, err := r.queryer().Exec(query, olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// CheckoutDevice atomically moves an available device to in-use and opens
+// an assignment for the given user
+func (r *DeviceRepository) CheckoutDevice(id string, userID uuid.UUID) (*model.Assignment, error) {
+	var assignment model.Assignment
+
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		before, err := lockDevice(tx, id)
+		if err != nil {
+			return err
+		}
+
+		switch before.State {
+		case model.StateAvailable:
+		case model.StateInUse:
+			return ErrDeviceInUse
+		default:
+			return ErrDeviceNotAvailable
+		}
+
+		if err := checkReservation(tx, id, userID); err != nil {
+			return err
+		}
+
+		if err := setDeviceState(tx, before, model.StateInUse, userID); err != nil {
+			return err
+		}
+
+		query := `
+        INSERT INTO device_assignments (device_id, user_id)
+        VALUES ($1, $2)
+        RETURNING id, device_id, user_id, checked_out_at, checked_in_at
+    `
+		return tx.QueryRowx(query, id, userID).StructScan(&assignment)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return &assignment, nil
+}
+
+// CheckinDevice closes the open assignment held by the given user and moves
+// the device back to available
+func (r *DeviceRepository) CheckinDevice(id string, userID uuid.UUID) (*model.Assignment, error) {
+	var assignment model.Assignment
+
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		before, err := lockDevice(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if before.State != model.StateInUse {
+			return ErrDeviceNotCheckedOut
+		}
+
+		query := `
+        SELECT id, device_id, user_id, checked_out_at, checked_in_at
+        FROM device_assignments
+        WHERE device_id = $1 AND checked_in_at IS NULL
+    `
+		if err := tx.Get(&assignment, query, id); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrDeviceNotCheckedOut
+			}
+			return err
+		}
+
+		if assignment.UserID != userID {
+			return ErrNotAssignee
+		}
+
+		query = `
+        UPDATE device_assignments
+        SET checked_in_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING id, device_id, user_id, checked_out_at, checked_in_at
+    `
+		if err := tx.QueryRowx(query, assignment.ID).StructScan(&assignment); err != nil {
+			return err
+		}
+
+		return setDeviceState(tx, before, model.StateAvailable, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &assignment, nil
+}
+
+// lockDevice reads a device while holding a row lock until the surrounding
+// transaction ends
+func lockDevice(tx *sqlx.Tx, id string) (*model.Device, error) {
+	var device model.Device
+
+	err := tx.Get(&device, "SELECT "+deviceColumns+" FROM devices WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+
+	return &device, nil
+}
+
+// checkReservation fails when someone other than userID holds a reservation
+// of the locked device covering now. Inserting a reservation takes a key
+// share lock on the device row, so none can appear until the caller commits.
+func checkReservation(tx *sqlx.Tx, id string, userID uuid.UUID) error {
+	var holder uuid.UUID
+
+	query := `
+        SELECT user_id
+        FROM device_reservations
+        WHERE device_id = $1 AND starts_at <= CURRENT_TIMESTAMP AND ends_at > CURRENT_TIMESTAMP
+    `
+	err := tx.Get(&holder, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("device not found")
+	if holder != userID {
+		return ErrDeviceReserved
 	}
+	return nil
+}
 
+// checkVersion fails when an expected version is given and the locked
+// device has moved past it
+func checkVersion(device *model.Device, version int) error {
+	if version != 0 && device.Version != version {
+		return ErrVersionMismatch
+	}
 	return nil
 }
+
+// setDeviceState moves a locked device to a new state and records the change
+func setDeviceState(tx *sqlx.Tx, before *model.Device, state model.DeviceState, actorID uuid.UUID) error {
+	after := *before
+
+	query := `
+        UPDATE devices
+        SET state = $1
+        WHERE id = $2
+        RETURNING ` + deviceColumns
+	if err := tx.QueryRowx(query, state, before.ID).StructScan(&after); err != nil {
+		return err
+	}
+
+	return recordDeviceEvent(tx, model.DeviceEventStateChanged, actorID, before, &after)
+}
+
+// updateEventType tells a pure state change apart from other edits
+func updateEventType(before, after *model.Device) model.DeviceEventType {
+	if before.State != after.State && before.Name == after.Name && before.Brand == after.Brand {
+		return model.DeviceEventStateChanged
+	}
+	return model.DeviceEventUpdated
+}
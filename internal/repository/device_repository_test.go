@@ -8,6 +8,7 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/loopsFreitag/DeviceRegistry/internal/model"
 	"github.com/stretchr/testify/assert"
 )
@@ -21,6 +22,7 @@ func TestDeviceRepository_CreateDevice(t *testing.T) {
 	repo := NewDeviceRepository(db)
 
 	deviceID := uuid.New()
+	actorID := uuid.New()
 	now := time.Now()
 	device := &model.Device{
 		Name:  "iPhone 15",
@@ -32,11 +34,16 @@ func TestDeviceRepository_CreateDevice(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"}).
 			AddRow(deviceID, device.Name, device.Brand, device.State, now, now)
 
+		mock.ExpectBegin()
 		mock.ExpectQuery(`INSERT INTO devices`).
 			WithArgs(device.Name, device.Brand, device.State).
 			WillReturnRows(rows)
+		mock.ExpectExec(`INSERT INTO device_events`).
+			WithArgs(deviceID, actorID, model.DeviceEventCreated, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
 
-		result, err := repo.CreateDevice(device)
+		result, err := repo.CreateDevice(device, actorID)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, result)
@@ -48,11 +55,13 @@ func TestDeviceRepository_CreateDevice(t *testing.T) {
 	})
 
 	t.Run("database error", func(t *testing.T) {
+		mock.ExpectBegin()
 		mock.ExpectQuery(`INSERT INTO devices`).
 			WithArgs(device.Name, device.Brand, device.State).
 			WillReturnError(fmt.Errorf("database error"))
+		mock.ExpectRollback()
 
-		result, err := repo.CreateDevice(device)
+		result, err := repo.CreateDevice(device, actorID)
 
 		assert.Error(t, err)
 		assert.Nil(t, result)
@@ -75,7 +84,7 @@ func TestDeviceRepository_GetDeviceByID(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"}).
 			AddRow(deviceID, "iPhone 15", "Apple", model.StateAvailable, now, now)
 
-		mock.ExpectQuery(`SELECT \* FROM devices WHERE id`).
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL`).
 			WithArgs(deviceID.String()).
 			WillReturnRows(rows)
 
@@ -90,7 +99,7 @@ func TestDeviceRepository_GetDeviceByID(t *testing.T) {
 	})
 
 	t.Run("device not found", func(t *testing.T) {
-		mock.ExpectQuery(`SELECT \* FROM devices WHERE id`).
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL`).
 			WithArgs(deviceID.String()).
 			WillReturnError(sql.ErrNoRows)
 
@@ -103,7 +112,7 @@ func TestDeviceRepository_GetDeviceByID(t *testing.T) {
 	})
 
 	t.Run("database error", func(t *testing.T) {
-		mock.ExpectQuery(`SELECT \* FROM devices WHERE id`).
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL`).
 			WithArgs(deviceID.String()).
 			WillReturnError(fmt.Errorf("database error"))
 
@@ -130,14 +139,14 @@ func TestDeviceRepository_GetDevices(t *testing.T) {
 			AddRow(uuid.New(), "iPhone 15", "Apple", model.StateAvailable, now, now).
 			AddRow(uuid.New(), "Galaxy S23", "Samsung", model.StateInUse, now, now)
 
-		mock.ExpectQuery(`SELECT \* FROM devices WHERE 1=1 ORDER BY created_at DESC`).
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE deleted_at IS NULL ORDER BY created_at DESC`).
 			WillReturnRows(rows)
 
 		filter := DeviceFilter{}
-		devices, err := repo.GetDevices(filter)
+		page, err := repo.GetDevices(filter)
 
 		assert.NoError(t, err)
-		assert.Len(t, devices, 2)
+		assert.Len(t, page.Devices, 2)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
@@ -146,16 +155,16 @@ func TestDeviceRepository_GetDevices(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"}).
 			AddRow(uuid.New(), "iPhone 15", "Apple", model.StateAvailable, now, now)
 
-		mock.ExpectQuery(`SELECT \* FROM devices WHERE 1=1 AND brand = \$1 ORDER BY created_at DESC`).
-			WithArgs(brand).
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE deleted_at IS NULL AND brand = ANY\(\$1\) ORDER BY created_at DESC`).
+			WithArgs(pq.Array([]string{brand})).
 			WillReturnRows(rows)
 
-		filter := DeviceFilter{Brand: &brand}
-		devices, err := repo.GetDevices(filter)
+		filter := DeviceFilter{Brands: []string{brand}}
+		page, err := repo.GetDevices(filter)
 
 		assert.NoError(t, err)
-		assert.Len(t, devices, 1)
-		assert.Equal(t, "Apple", devices[0].Brand)
+		assert.Len(t, page.Devices, 1)
+		assert.Equal(t, "Apple", page.Devices[0].Brand)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
@@ -164,16 +173,16 @@ func TestDeviceRepository_GetDevices(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"}).
 			AddRow(uuid.New(), "Galaxy S23", "Samsung", model.StateInUse, now, now)
 
-		mock.ExpectQuery(`SELECT \* FROM devices WHERE 1=1 AND state = \$1 ORDER BY created_at DESC`).
-			WithArgs(state).
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE deleted_at IS NULL AND state = ANY\(\$1\) ORDER BY created_at DESC`).
+			WithArgs(pq.Int64Array{int64(state)}).
 			WillReturnRows(rows)
 
-		filter := DeviceFilter{State: &state}
-		devices, err := repo.GetDevices(filter)
+		filter := DeviceFilter{States: []model.DeviceState{state}}
+		page, err := repo.GetDevices(filter)
 
 		assert.NoError(t, err)
-		assert.Len(t, devices, 1)
-		assert.Equal(t, model.StateInUse, devices[0].State)
+		assert.Len(t, page.Devices, 1)
+		assert.Equal(t, model.StateInUse, page.Devices[0].State)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
@@ -183,43 +192,86 @@ func TestDeviceRepository_GetDevices(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"}).
 			AddRow(uuid.New(), "iPhone 14", "Apple", model.StateInUse, now, now)
 
-		mock.ExpectQuery(`SELECT \* FROM devices WHERE 1=1 AND brand = \$1 AND state = \$2 ORDER BY created_at DESC`).
-			WithArgs(brand, state).
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE deleted_at IS NULL AND brand = ANY\(\$1\) AND state = ANY\(\$2\) ORDER BY created_at DESC`).
+			WithArgs(pq.Array([]string{brand}), pq.Int64Array{int64(state)}).
 			WillReturnRows(rows)
 
-		filter := DeviceFilter{Brand: &brand, State: &state}
-		devices, err := repo.GetDevices(filter)
+		filter := DeviceFilter{Brands: []string{brand}, States: []model.DeviceState{state}}
+		page, err := repo.GetDevices(filter)
 
 		assert.NoError(t, err)
-		assert.Len(t, devices, 1)
-		assert.Equal(t, "Apple", devices[0].Brand)
-		assert.Equal(t, model.StateInUse, devices[0].State)
+		assert.Len(t, page.Devices, 1)
+		assert.Equal(t, "Apple", page.Devices[0].Brand)
+		assert.Equal(t, model.StateInUse, page.Devices[0].State)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("empty result", func(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"})
 
-		mock.ExpectQuery(`SELECT \* FROM devices WHERE 1=1 ORDER BY created_at DESC`).
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE deleted_at IS NULL ORDER BY created_at DESC`).
 			WillReturnRows(rows)
 
 		filter := DeviceFilter{}
-		devices, err := repo.GetDevices(filter)
+		page, err := repo.GetDevices(filter)
 
 		assert.NoError(t, err)
-		assert.Len(t, devices, 0)
+		assert.Len(t, page.Devices, 0)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("database error", func(t *testing.T) {
-		mock.ExpectQuery(`SELECT \* FROM devices WHERE 1=1 ORDER BY created_at DESC`).
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE deleted_at IS NULL ORDER BY created_at DESC`).
 			WillReturnError(fmt.Errorf("database error"))
 
 		filter := DeviceFilter{}
-		devices, err := repo.GetDevices(filter)
+		page, err := repo.GetDevices(filter)
 
 		assert.Error(t, err)
-		assert.Len(t, devices, 0)
+		assert.Nil(t, page)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeviceRepository_GetDevicesRichFilters(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+
+	t.Run("multiple and negated values", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE deleted_at IS NULL AND brand = ANY\(\$1\) AND state <> ALL\(\$2\) ORDER BY created_at DESC, id DESC`).
+			WithArgs(pq.Array([]string{"Apple", "Dell"}), pq.Int64Array{int64(model.StateInactive)}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"}))
+
+		filter := DeviceFilter{
+			Brands:        []string{"Apple", "Dell"},
+			ExcludeStates: []model.DeviceState{model.StateInactive},
+		}
+		page, err := repo.GetDevices(filter)
+
+		assert.NoError(t, err)
+		assert.Empty(t, page.Devices)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("name prefix and date ranges", func(t *testing.T) {
+		after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		before := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE deleted_at IS NULL AND brand <> ALL\(\$1\) AND lower\(name\) LIKE \$2 ESCAPE '\\' AND created_at >= \$3 AND updated_at < \$4 ORDER BY`).
+			WithArgs(pq.Array([]string{"Samsung"}), `iphone\_%`, after, before).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"}))
+
+		filter := DeviceFilter{
+			ExcludeBrands: []string{"Samsung"},
+			NamePrefix:    "iPhone_",
+			CreatedAfter:  &after,
+			UpdatedBefore: &before,
+		}
+		_, err := repo.GetDevices(filter)
+
+		assert.NoError(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
@@ -233,6 +285,7 @@ func TestDeviceRepository_UpdateDevice(t *testing.T) {
 	repo := NewDeviceRepository(db)
 
 	deviceID := uuid.New()
+	actorID := uuid.New()
 	createdAt := time.Now().Add(-24 * time.Hour)
 	updatedAt := time.Now()
 
@@ -240,46 +293,67 @@ func TestDeviceRepository_UpdateDevice(t *testing.T) {
 		ID:    deviceID,
 		Name:  "iPhone 15",
 		Brand: "Apple",
-		State: model.StateInUse,
+		State: model.StateMaintenance,
+	}
+
+	lockedRows := func(state model.DeviceState) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"}).
+			AddRow(deviceID, "iPhone 15", "Apple", state, createdAt, createdAt)
 	}
 
 	t.Run("successful update", func(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"}).
 			AddRow(deviceID, device.Name, device.Brand, device.State, createdAt, updatedAt)
 
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(lockedRows(model.StateAvailable))
 		mock.ExpectQuery(`UPDATE devices SET name = \$1, brand = \$2, state = \$3 WHERE id = \$4`).
 			WithArgs(device.Name, device.Brand, device.State, device.ID).
 			WillReturnRows(rows)
+		mock.ExpectExec(`INSERT INTO device_events`).
+			WithArgs(deviceID, actorID, model.DeviceEventStateChanged,
+				`{"state":"available"}`, `{"state":"maintenance"}`, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
 
-		result, err := repo.UpdateDevice(device)
+		result, err := repo.UpdateDevice(device, actorID)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, result)
 		assert.Equal(t, deviceID, result.ID)
 		assert.Equal(t, "iPhone 15", result.Name)
-		assert.Equal(t, model.StateInUse, result.State)
+		assert.Equal(t, model.StateMaintenance, result.State)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("device not found", func(t *testing.T) {
-		mock.ExpectQuery(`UPDATE devices SET name = \$1, brand = \$2, state = \$3 WHERE id = \$4`).
-			WithArgs(device.Name, device.Brand, device.State, device.ID).
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
 			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
 
-		result, err := repo.UpdateDevice(device)
+		result, err := repo.UpdateDevice(device, actorID)
 
 		assert.Error(t, err)
 		assert.Nil(t, result)
-		assert.Equal(t, sql.ErrNoRows, err)
+		assert.Equal(t, ErrDeviceNotFound, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("database error", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(lockedRows(model.StateMaintenance))
 		mock.ExpectQuery(`UPDATE devices SET name = \$1, brand = \$2, state = \$3 WHERE id = \$4`).
 			WithArgs(device.Name, device.Brand, device.State, device.ID).
 			WillReturnError(fmt.Errorf("database error"))
+		mock.ExpectRollback()
 
-		result, err := repo.UpdateDevice(device)
+		result, err := repo.UpdateDevice(device, actorID)
 
 		assert.Error(t, err)
 		assert.Nil(t, result)
@@ -296,24 +370,41 @@ func TestDeviceRepository_DeleteDevice(t *testing.T) {
 	repo := NewDeviceRepository(db)
 
 	deviceID := uuid.New()
+	actorID := uuid.New()
+	now := time.Now()
+
+	lockedRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"}).
+			AddRow(deviceID, "iPhone 15", "Apple", model.StateAvailable, now, now)
+	}
 
 	t.Run("successful deletion", func(t *testing.T) {
-		mock.ExpectExec(`DELETE FROM devices WHERE id`).
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(lockedRows())
+		mock.ExpectExec(`UPDATE devices SET deleted_at = CURRENT_TIMESTAMP WHERE id = \$1 AND deleted_at IS NULL`).
 			WithArgs(deviceID.String()).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO device_events`).
+			WithArgs(deviceID, actorID, model.DeviceEventDeleted, sqlmock.AnyArg(), sql.NullString{}, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
 
-		err := repo.DeleteDevice(deviceID.String())
+		err := repo.DeleteDevice(deviceID.String(), 0, actorID)
 
 		assert.NoError(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("device not found", func(t *testing.T) {
-		mock.ExpectExec(`DELETE FROM devices WHERE id`).
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
 			WithArgs(deviceID.String()).
-			WillReturnResult(sqlmock.NewResult(0, 0))
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
 
-		err := repo.DeleteDevice(deviceID.String())
+		err := repo.DeleteDevice(deviceID.String(), 0, actorID)
 
 		assert.Error(t, err)
 		assert.Equal(t, "device not found", err.Error())
@@ -321,24 +412,395 @@ func TestDeviceRepository_DeleteDevice(t *testing.T) {
 	})
 
 	t.Run("database error", func(t *testing.T) {
-		mock.ExpectExec(`DELETE FROM devices WHERE id`).
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(lockedRows())
+		mock.ExpectExec(`UPDATE devices SET deleted_at = CURRENT_TIMESTAMP WHERE id = \$1 AND deleted_at IS NULL`).
 			WithArgs(deviceID.String()).
 			WillReturnError(fmt.Errorf("database error"))
+		mock.ExpectRollback()
 
-		err := repo.DeleteDevice(deviceID.String())
+		err := repo.DeleteDevice(deviceID.String(), 0, actorID)
 
 		assert.Error(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("error getting rows affected", func(t *testing.T) {
-		mock.ExpectExec(`DELETE FROM devices WHERE id`).
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(lockedRows())
+		mock.ExpectExec(`UPDATE devices SET deleted_at = CURRENT_TIMESTAMP WHERE id = \$1 AND deleted_at IS NULL`).
 			WithArgs(deviceID.String()).
 			WillReturnResult(sqlmock.NewErrorResult(fmt.Errorf("rows affected error")))
+		mock.ExpectRollback()
 
-		err := repo.DeleteDevice(deviceID.String())
+		err := repo.DeleteDevice(deviceID.String(), 0, actorID)
 
 		assert.Error(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
+
+// Test soft delete and trash
+
+func TestDeviceRepository_GetDevicesIncludeDeleted(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at", "deleted_at"}).
+		AddRow(uuid.New(), "iPhone 15", "Apple", model.StateAvailable, now, now, nil).
+		AddRow(uuid.New(), "Galaxy S23", "Samsung", model.StateInactive, now, now, now)
+
+	mock.ExpectQuery(`SELECT (.+) FROM devices ORDER BY created_at DESC`).
+		WillReturnRows(rows)
+
+	page, err := repo.GetDevices(DeviceFilter{IncludeDeleted: true})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Devices, 2)
+	assert.Nil(t, page.Devices[0].DeletedAt)
+	assert.NotNil(t, page.Devices[1].DeletedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeviceRepository_GetDeletedDevices(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at", "deleted_at"}).
+		AddRow(uuid.New(), "Galaxy S23", "Samsung", model.StateInactive, now, now, now)
+
+	mock.ExpectQuery(`SELECT (.+) FROM devices WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC`).
+		WillReturnRows(rows)
+
+	devices, err := repo.GetDeletedDevices()
+
+	assert.NoError(t, err)
+	assert.Len(t, devices, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeviceRepository_RestoreDevice(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+
+	deviceID := uuid.New()
+	actorID := uuid.New()
+	now := time.Now()
+
+	t.Run("successful restore", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`UPDATE devices SET deleted_at = NULL WHERE id = \$1 AND deleted_at IS NOT NULL`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at", "deleted_at"}).
+				AddRow(deviceID, "Galaxy S23", "Samsung", model.StateInactive, now, now, nil))
+		mock.ExpectExec(`INSERT INTO device_events`).
+			WithArgs(deviceID, actorID, model.DeviceEventRestored, sql.NullString{}, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		device, err := repo.RestoreDevice(deviceID.String(), actorID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, deviceID, device.ID)
+		assert.Nil(t, device.DeletedAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("device not in trash", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`UPDATE devices SET deleted_at = NULL WHERE id = \$1 AND deleted_at IS NOT NULL`).
+			WithArgs(deviceID.String()).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		device, err := repo.RestoreDevice(deviceID.String(), actorID)
+
+		assert.Equal(t, ErrDeviceNotFound, err)
+		assert.Nil(t, device)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeviceRepository_PurgeDeletedDevices(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+
+	mock.ExpectExec(`DELETE FROM devices WHERE deleted_at IS NOT NULL AND deleted_at < CURRENT_TIMESTAMP - make_interval\(secs => \$1\)`).
+		WithArgs(float64(30 * 24 * 60 * 60)).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	purged, err := repo.PurgeDeletedDevices(30 * 24 * time.Hour)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test optimistic concurrency
+
+func TestDeviceRepository_VersionCheck(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+
+	deviceID := uuid.New()
+	actorID := uuid.New()
+	now := time.Now()
+
+	lockedRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "brand", "state", "version", "created_at", "updated_at"}).
+			AddRow(deviceID, "Pixel 8", "Google", model.StateAvailable, 3, now, now)
+	}
+
+	t.Run("update with the current version", func(t *testing.T) {
+		device := &model.Device{ID: deviceID, Name: "Pixel 8 Pro", Brand: "Google", State: model.StateAvailable, Version: 3}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(lockedRows())
+		mock.ExpectQuery(`UPDATE devices SET name = \$1, brand = \$2, state = \$3 WHERE id = \$4 RETURNING id, name, brand, state, version`).
+			WithArgs(device.Name, device.Brand, device.State, device.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "state", "version", "created_at", "updated_at"}).
+				AddRow(deviceID, "Pixel 8 Pro", "Google", model.StateAvailable, 4, now, now))
+		mock.ExpectExec(`INSERT INTO device_events`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		result, err := repo.UpdateDevice(device, actorID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 4, result.Version)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("update with a stale version", func(t *testing.T) {
+		device := &model.Device{ID: deviceID, Name: "Pixel 8 Pro", Brand: "Google", State: model.StateAvailable, Version: 2}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(lockedRows())
+		mock.ExpectRollback()
+
+		result, err := repo.UpdateDevice(device, actorID)
+
+		assert.ErrorIs(t, err, ErrVersionMismatch)
+		assert.Nil(t, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("delete with a stale version", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(lockedRows())
+		mock.ExpectRollback()
+
+		err := repo.DeleteDevice(deviceID.String(), 1, actorID)
+
+		assert.ErrorIs(t, err, ErrVersionMismatch)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// deviceRows builds a single device row for queries selecting every column
+func deviceRows(id uuid.UUID, state model.DeviceState, at time.Time) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at"}).
+		AddRow(id, "Pixel 8", "Google", state, at, at)
+}
+
+// Test CheckoutDevice
+
+func TestDeviceRepository_CheckoutDevice(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+	now := time.Now()
+
+	t.Run("successful checkout", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(deviceRows(deviceID, model.StateAvailable, now))
+		mock.ExpectQuery(`SELECT user_id FROM device_reservations WHERE device_id = \$1 AND starts_at <= CURRENT_TIMESTAMP AND ends_at > CURRENT_TIMESTAMP`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(userID))
+		mock.ExpectQuery(`UPDATE devices SET state = \$1 WHERE id = \$2`).
+			WithArgs(model.StateInUse, deviceID).
+			WillReturnRows(deviceRows(deviceID, model.StateInUse, now))
+		mock.ExpectExec(`INSERT INTO device_events`).
+			WithArgs(deviceID, userID, model.DeviceEventStateChanged,
+				`{"state":"available"}`, `{"state":"in-use"}`, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`INSERT INTO device_assignments`).
+			WithArgs(deviceID.String(), userID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "device_id", "user_id", "checked_out_at", "checked_in_at"}).
+				AddRow(uuid.New(), deviceID, userID, now, nil))
+		mock.ExpectCommit()
+
+		assignment, err := repo.CheckoutDevice(deviceID.String(), userID)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, assignment)
+		assert.Equal(t, deviceID, assignment.DeviceID)
+		assert.Equal(t, userID, assignment.UserID)
+		assert.Nil(t, assignment.CheckedInAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("device already in use", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(deviceRows(deviceID, model.StateInUse, now))
+		mock.ExpectRollback()
+
+		assignment, err := repo.CheckoutDevice(deviceID.String(), userID)
+
+		assert.Equal(t, ErrDeviceInUse, err)
+		assert.Nil(t, assignment)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reserved by another user", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(deviceRows(deviceID, model.StateAvailable, now))
+		mock.ExpectQuery(`SELECT user_id FROM device_reservations`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(uuid.New()))
+		mock.ExpectRollback()
+
+		assignment, err := repo.CheckoutDevice(deviceID.String(), userID)
+
+		assert.Equal(t, ErrDeviceReserved, err)
+		assert.Nil(t, assignment)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("device inactive", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(deviceRows(deviceID, model.StateInactive, now))
+		mock.ExpectRollback()
+
+		assignment, err := repo.CheckoutDevice(deviceID.String(), userID)
+
+		assert.Equal(t, ErrDeviceNotAvailable, err)
+		assert.Nil(t, assignment)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("device not found", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		assignment, err := repo.CheckoutDevice(deviceID.String(), userID)
+
+		assert.Equal(t, ErrDeviceNotFound, err)
+		assert.Nil(t, assignment)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// Test CheckinDevice
+
+func TestDeviceRepository_CheckinDevice(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+	assignmentID := uuid.New()
+	checkedOutAt := time.Now().Add(-2 * time.Hour)
+	checkedInAt := time.Now()
+
+	t.Run("successful checkin", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(deviceRows(deviceID, model.StateInUse, checkedOutAt))
+		mock.ExpectQuery(`SELECT id, device_id, user_id, checked_out_at, checked_in_at FROM device_assignments`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "device_id", "user_id", "checked_out_at", "checked_in_at"}).
+				AddRow(assignmentID, deviceID, userID, checkedOutAt, nil))
+		mock.ExpectQuery(`UPDATE device_assignments SET checked_in_at = CURRENT_TIMESTAMP WHERE id = \$1`).
+			WithArgs(assignmentID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "device_id", "user_id", "checked_out_at", "checked_in_at"}).
+				AddRow(assignmentID, deviceID, userID, checkedOutAt, checkedInAt))
+		mock.ExpectQuery(`UPDATE devices SET state = \$1 WHERE id = \$2`).
+			WithArgs(model.StateAvailable, deviceID).
+			WillReturnRows(deviceRows(deviceID, model.StateAvailable, checkedOutAt))
+		mock.ExpectExec(`INSERT INTO device_events`).
+			WithArgs(deviceID, userID, model.DeviceEventStateChanged,
+				`{"state":"in-use"}`, `{"state":"available"}`, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assignment, err := repo.CheckinDevice(deviceID.String(), userID)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, assignment)
+		assert.NotNil(t, assignment.CheckedInAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("device not checked out", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(deviceRows(deviceID, model.StateAvailable, checkedOutAt))
+		mock.ExpectRollback()
+
+		assignment, err := repo.CheckinDevice(deviceID.String(), userID)
+
+		assert.Equal(t, ErrDeviceNotCheckedOut, err)
+		assert.Nil(t, assignment)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("checked out by another user", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM devices WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(deviceRows(deviceID, model.StateInUse, checkedOutAt))
+		mock.ExpectQuery(`SELECT id, device_id, user_id, checked_out_at, checked_in_at FROM device_assignments`).
+			WithArgs(deviceID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "device_id", "user_id", "checked_out_at", "checked_in_at"}).
+				AddRow(assignmentID, deviceID, uuid.New(), checkedOutAt, nil))
+		mock.ExpectRollback()
+
+		assignment, err := repo.CheckinDevice(deviceID.String(), userID)
+
+		assert.Equal(t, ErrNotAssignee, err)
+		assert.Nil(t, assignment)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
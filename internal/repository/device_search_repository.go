@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+// searchDevicesQuery matches devices either through the full-text vector or
+// by trigram word similarity, which tolerates typos such as "Lenvo". Full-text
+// hits rank above fuzzy ones; the best similarity breaks ties.
+const searchDevicesQuery = `
+        SELECT ` + deviceColumns + `,
+               ts_rank(search_vector, websearch_to_tsquery('simple', $1)) +
+               greatest(word_similarity($1, name), word_similarity($1, brand)) AS rank
+        FROM devices
+        WHERE deleted_at IS NULL
+          AND (search_vector @@ websearch_to_tsquery('simple', $1)
+               OR $1 <% name
+               OR $1 <% brand)
+        ORDER BY rank DESC, id
+        LIMIT $2
+    `
+
+// SearchDevices returns up to limit devices matching text, best match first
+func (r *DeviceRepository) SearchDevices(text string, limit int) ([]model.DeviceSearchResult, error) {
+	results := []model.DeviceSearchResult{}
+	err := sqlx.Select(r.queryer(), &results, searchDevicesQuery, text, limit)
+	return results, err
+}
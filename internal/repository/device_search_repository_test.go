@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test SearchDevices
+
+func TestDeviceRepository_SearchDevices(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+
+	now := time.Now()
+
+	t.Run("ranked results", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "name", "brand", "state", "created_at", "updated_at", "deleted_at", "rank"}).
+			AddRow(uuid.New(), "ThinkPad X1 Carbon", "Lenovo", model.StateAvailable, now, now, nil, 0.91).
+			AddRow(uuid.New(), "ThinkPad T14", "Lenovo", model.StateInUse, now, now, nil, 0.42)
+
+		mock.ExpectQuery(`SELECT (.+) AS rank FROM devices WHERE deleted_at IS NULL AND \(search_vector @@ websearch_to_tsquery\('simple', \$1\) OR \$1 <% name OR \$1 <% brand\) ORDER BY rank DESC, id LIMIT \$2`).
+			WithArgs("thinkpad x1", 20).
+			WillReturnRows(rows)
+
+		results, err := repo.SearchDevices("thinkpad x1", 20)
+
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.Equal(t, "ThinkPad X1 Carbon", results[0].Name)
+		assert.Equal(t, 0.91, results[0].Rank)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) AS rank FROM devices`).
+			WithArgs("Lenvo", 20).
+			WillReturnError(fmt.Errorf("database error"))
+
+		results, err := repo.SearchDevices("Lenvo", 20)
+
+		assert.Error(t, err)
+		assert.Empty(t, results)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+const idempotencyKeyColumns = "user_id, key, fingerprint, status_code, response_header, response_body, created_at, expires_at"
+
+type IdempotencyRepositoryInterface interface {
+	Claim(key *model.IdempotencyKey) (claimed bool, existing *model.IdempotencyKey, err error)
+	Complete(userID uuid.UUID, key string, status int, header json.RawMessage, body []byte) error
+	Release(userID uuid.UUID, key string) error
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+type IdempotencyRepository struct {
+	db *sqlx.DB
+}
+
+func NewIdempotencyRepository(db *sqlx.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Claim stores key unless the user already holds a key with the same name
+// that is still live at key.CreatedAt, in which case that one is returned
+// instead. Expired keys are taken over as if they did not exist.
+func (r *IdempotencyRepository) Claim(key *model.IdempotencyKey) (bool, *model.IdempotencyKey, error) {
+	query := `
+        INSERT INTO idempotency_keys (user_id, key, fingerprint, created_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (user_id, key) DO UPDATE
+        SET fingerprint = EXCLUDED.fingerprint,
+            status_code = NULL,
+            response_header = '{}',
+            response_body = '',
+            created_at = EXCLUDED.created_at,
+            expires_at = EXCLUDED.expires_at
+        WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+        RETURNING user_id
+    `
+	var userID uuid.UUID
+	err := r.db.Get(&userID, query, key.UserID, key.Key, key.Fingerprint, key.CreatedAt, key.ExpiresAt)
+	if err == nil {
+		return true, nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, nil, err
+	}
+
+	var existing model.IdempotencyKey
+	query = `SELECT ` + idempotencyKeyColumns + ` FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+	if err := r.db.Get(&existing, query, key.UserID, key.Key); err != nil {
+		return false, nil, err
+	}
+
+	return false, &existing, nil
+}
+
+// Complete stores the response of the request that claimed the key
+func (r *IdempotencyRepository) Complete(userID uuid.UUID, key string, status int, header json.RawMessage, body []byte) error {
+	query := `
+        UPDATE idempotency_keys
+        SET status_code = $3, response_header = $4, response_body = $5
+        WHERE user_id = $1 AND key = $2
+    `
+	_, err := r.db.Exec(query, userID, key, status, header, body)
+	return err
+}
+
+// Release forgets a claimed key so the request can be retried
+func (r *IdempotencyRepository) Release(userID uuid.UUID, key string) error {
+	_, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`, userID, key)
+	return err
+}
+
+// DeleteExpired removes the keys that expired before now
+func (r *IdempotencyRepository) DeleteExpired(now time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
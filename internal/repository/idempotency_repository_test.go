@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var idempotencyKeyTableColumns = []string{"user_id", "key", "fingerprint", "status_code", "response_header", "response_body", "created_at", "expires_at"}
+
+// Test Claim
+
+func TestIdempotencyRepository_Claim(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewIdempotencyRepository(db)
+
+	now := time.Now().UTC()
+	key := &model.IdempotencyKey{
+		UserID:      uuid.New(),
+		Key:         "create-device-1",
+		Fingerprint: "abc",
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(24 * time.Hour),
+	}
+
+	t.Run("new key", func(t *testing.T) {
+		mock.ExpectQuery(`INSERT INTO idempotency_keys (.+) ON CONFLICT \(user_id, key\) DO UPDATE`).
+			WithArgs(key.UserID, key.Key, key.Fingerprint, key.CreatedAt, key.ExpiresAt).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(key.UserID))
+
+		claimed, existing, err := repo.Claim(key)
+
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+		assert.Nil(t, existing)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("live key", func(t *testing.T) {
+		mock.ExpectQuery(`INSERT INTO idempotency_keys`).
+			WithArgs(key.UserID, key.Key, key.Fingerprint, key.CreatedAt, key.ExpiresAt).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+		mock.ExpectQuery(`SELECT (.+) FROM idempotency_keys WHERE user_id = \$1 AND key = \$2`).
+			WithArgs(key.UserID, key.Key).
+			WillReturnRows(sqlmock.NewRows(idempotencyKeyTableColumns).
+				AddRow(key.UserID, key.Key, "abc", 201, []byte(`{"Etag":["\"1\""]}`), []byte(`{}`), now, key.ExpiresAt))
+
+		claimed, existing, err := repo.Claim(key)
+
+		assert.NoError(t, err)
+		assert.False(t, claimed)
+		assert.True(t, existing.Completed())
+		assert.Equal(t, 201, *existing.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// Test Complete and Release
+
+func TestIdempotencyRepository_CompleteAndRelease(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewIdempotencyRepository(db)
+	userID := uuid.New()
+	header := json.RawMessage(`{"Content-Type":["application/json"]}`)
+
+	mock.ExpectExec(`UPDATE idempotency_keys`).
+		WithArgs(userID, "key", 201, header, []byte(`{"id":"1"}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM idempotency_keys WHERE user_id = \$1 AND key = \$2`).
+		WithArgs(userID, "key").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.Complete(userID, "key", 201, header, []byte(`{"id":"1"}`)))
+	assert.NoError(t, repo.Release(userID, "key"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotencyRepository_DeleteExpired(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewIdempotencyRepository(db)
+	now := time.Now().UTC()
+
+	mock.ExpectExec(`DELETE FROM idempotency_keys WHERE expires_at <= \$1`).
+		WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	deleted, err := repo.DeleteExpired(now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// queryBuilder assembles a WHERE clause out of conditions written with ?
+// placeholders. Values only ever travel as bind arguments; the conditions
+// themselves must be constants or built from whitelisted column names.
+type queryBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+// Where adds a condition joined to the previous ones with AND
+func (b *queryBuilder) Where(condition string, args ...interface{}) *queryBuilder {
+	b.conditions = append(b.conditions, condition)
+	b.args = append(b.args, args...)
+	return b
+}
+
+// Build renders base followed by the WHERE clause and tail, numbering the
+// placeholders for postgres. tailArgs bind the placeholders used in tail.
+func (b *queryBuilder) Build(base, tail string, tailArgs ...interface{}) (string, []interface{}) {
+	query := base
+	if len(b.conditions) > 0 {
+		query += " WHERE " + strings.Join(b.conditions, " AND ")
+	}
+	if tail != "" {
+		query += " " + tail
+	}
+
+	args := make([]interface{}, 0, len(b.args)+len(tailArgs))
+	args = append(args, b.args...)
+	args = append(args, tailArgs...)
+
+	return sqlx.Rebind(sqlx.DOLLAR, query), args
+}
+
+// likePrefix escapes the LIKE wildcards in prefix and appends one, so the
+// prefix is matched literally
+func likePrefix(prefix string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return escaper.Replace(prefix) + "%"
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test queryBuilder
+
+func TestQueryBuilder_Build(t *testing.T) {
+	t.Run("no conditions", func(t *testing.T) {
+		qb := &queryBuilder{}
+
+		query, args := qb.Build("SELECT * FROM devices", "ORDER BY name")
+
+		assert.Equal(t, "SELECT * FROM devices ORDER BY name", query)
+		assert.Empty(t, args)
+	})
+
+	t.Run("numbers placeholders across conditions and tail", func(t *testing.T) {
+		qb := &queryBuilder{}
+		qb.Where("deleted_at IS NULL").
+			Where("brand = ?", "Apple").
+			Where("(name, id) > (?, ?)", "iPhone", "id")
+
+		query, args := qb.Build("SELECT * FROM devices", "LIMIT ?", 10)
+
+		assert.Equal(t, "SELECT * FROM devices WHERE deleted_at IS NULL AND brand = $1 AND (name, id) > ($2, $3) LIMIT $4", query)
+		assert.Equal(t, []interface{}{"Apple", "iPhone", "id", 10}, args)
+	})
+
+	t.Run("builds are independent", func(t *testing.T) {
+		qb := &queryBuilder{}
+		qb.Where("brand = ?", "Apple")
+
+		_, countArgs := qb.Build("SELECT COUNT(*) FROM devices", "")
+		_, pageArgs := qb.Build("SELECT * FROM devices", "LIMIT ?", 10)
+
+		assert.Equal(t, []interface{}{"Apple"}, countArgs)
+		assert.Equal(t, []interface{}{"Apple", 10}, pageArgs)
+	})
+}
+
+func TestLikePrefix(t *testing.T) {
+	assert.Equal(t, "iph%", likePrefix("iph"))
+	assert.Equal(t, `50\%\_off\\%`, likePrefix(`50%_off\`))
+}
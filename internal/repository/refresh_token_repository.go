@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+const refreshTokenColumns = "id, family_id, user_id, expires_at, used_at, revoked_at, created_at"
+
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+type RefreshTokenRepositoryInterface interface {
+	CreateRefreshToken(token *model.RefreshToken, hash string) (*model.RefreshToken, error)
+	GetRefreshToken(hash string) (*model.RefreshToken, error)
+	UseRefreshToken(hash string, now time.Time) (*model.RefreshToken, error)
+	RevokeRefreshFamily(familyID uuid.UUID, now time.Time) error
+	RevokeUserRefreshTokens(userID uuid.UUID, now time.Time) error
+	DeleteExpired(now time.Time) (int64, error)
+	WithTx(fn func(repo RefreshTokenRepositoryInterface) error) error
+}
+
+type RefreshTokenRepository struct {
+	db *sqlx.DB
+	tx *sqlx.Tx
+}
+
+func NewRefreshTokenRepository(db *sqlx.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+// WithTx runs fn with a repository bound to a single transaction, so every
+// write made through it commits or rolls back together
+func (r *RefreshTokenRepository) WithTx(fn func(repo RefreshTokenRepositoryInterface) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+	return withTx(r.db, func(tx *sqlx.Tx) error {
+		return fn(&RefreshTokenRepository{db: r.db, tx: tx})
+	})
+}
+
+// queryer returns the bound transaction, or the pool when unbound
+func (r *RefreshTokenRepository) queryer() sqlx.Ext {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// CreateRefreshToken stores token under hash
+func (r *RefreshTokenRepository) CreateRefreshToken(token *model.RefreshToken, hash string) (*model.RefreshToken, error) {
+	var created model.RefreshToken
+	query := `
+        INSERT INTO refresh_tokens (family_id, user_id, token_hash, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING ` + refreshTokenColumns
+	err := sqlx.Get(r.queryer(), &created, query, token.FamilyID, token.UserID, hash, token.ExpiresAt, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// GetRefreshToken retrieves the token stored under hash, whatever its state
+func (r *RefreshTokenRepository) GetRefreshToken(hash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE token_hash = $1`
+	if err := sqlx.Get(r.queryer(), &token, query, hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// UseRefreshToken marks the token stored under hash used at now and
+// returns it, as long as it is unused, unrevoked and unexpired. Only one of
+// concurrent uses succeeds.
+func (r *RefreshTokenRepository) UseRefreshToken(hash string, now time.Time) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	query := `
+        UPDATE refresh_tokens SET used_at = $2
+        WHERE token_hash = $1 AND used_at IS NULL AND revoked_at IS NULL AND expires_at > $2
+        RETURNING ` + refreshTokenColumns
+	if err := sqlx.Get(r.queryer(), &token, query, hash, now); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RevokeRefreshFamily revokes every token of a family that isn't already
+func (r *RefreshTokenRepository) RevokeRefreshFamily(familyID uuid.UUID, now time.Time) error {
+	_, err := r.queryer().Exec(`UPDATE refresh_tokens SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL`, familyID, now)
+	return err
+}
+
+// RevokeUserRefreshTokens revokes every token of a user that isn't already
+func (r *RefreshTokenRepository) RevokeUserRefreshTokens(userID uuid.UUID, now time.Time) error {
+	_, err := r.queryer().Exec(`UPDATE refresh_tokens SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL`, userID, now)
+	return err
+}
+
+// DeleteExpired removes the tokens that expired before now
+func (r *RefreshTokenRepository) DeleteExpired(now time.Time) (int64, error) {
+	result, err := r.queryer().Exec(`DELETE FROM refresh_tokens WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var refreshTokenRowColumns = []string{"id", "family_id", "user_id", "expires_at", "used_at", "revoked_at", "created_at"}
+
+func TestRefreshTokenRepository_CreateRefreshToken(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewRefreshTokenRepository(db)
+
+	familyID, userID := uuid.New(), uuid.New()
+	expiresAt := time.Now().Add(720 * time.Hour).UTC()
+	tokenID := uuid.New()
+
+	rows := sqlmock.NewRows(refreshTokenRowColumns).
+		AddRow(tokenID, familyID, userID, expiresAt, nil, nil, time.Now())
+	mock.ExpectQuery(`INSERT INTO refresh_tokens`).
+		WithArgs(familyID, userID, "hash", expiresAt, sqlmock.AnyArg()).
+		WillReturnRows(rows)
+
+	created, err := repo.CreateRefreshToken(&model.RefreshToken{FamilyID: familyID, UserID: userID, ExpiresAt: expiresAt}, "hash")
+
+	require.NoError(t, err)
+	assert.Equal(t, tokenID, created.ID)
+	assert.Equal(t, familyID, created.FamilyID)
+	assert.Nil(t, created.UsedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_UseRefreshToken(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewRefreshTokenRepository(db)
+	now := time.Now().UTC()
+
+	t.Run("live token", func(t *testing.T) {
+		rows := sqlmock.NewRows(refreshTokenRowColumns).
+			AddRow(uuid.New(), uuid.New(), uuid.New(), now.Add(time.Hour), now, nil, now.Add(-time.Hour))
+		mock.ExpectQuery(`UPDATE refresh_tokens SET used_at = \$2\s+WHERE token_hash = \$1 AND used_at IS NULL AND revoked_at IS NULL AND expires_at > \$2`).
+			WithArgs("hash", now).
+			WillReturnRows(rows)
+
+		token, err := repo.UseRefreshToken("hash", now)
+
+		require.NoError(t, err)
+		require.NotNil(t, token.UsedAt)
+		assert.True(t, token.UsedAt.Equal(now))
+	})
+
+	t.Run("used, revoked, expired or unknown token", func(t *testing.T) {
+		mock.ExpectQuery(`UPDATE refresh_tokens`).
+			WithArgs("hash", now).
+			WillReturnError(sql.ErrNoRows)
+
+		token, err := repo.UseRefreshToken("hash", now)
+
+		assert.Equal(t, ErrRefreshTokenNotFound, err)
+		assert.Nil(t, token)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_GetRefreshToken(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewRefreshTokenRepository(db)
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) FROM refresh_tokens WHERE token_hash = \$1`).
+			WithArgs("hash").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetRefreshToken("hash")
+
+		assert.Equal(t, ErrRefreshTokenNotFound, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_RevokeRefreshFamily(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewRefreshTokenRepository(db)
+	familyID := uuid.New()
+	now := time.Now().UTC()
+
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = \$2 WHERE family_id = \$1 AND revoked_at IS NULL`).
+		WithArgs(familyID, now).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	assert.NoError(t, repo.RevokeRefreshFamily(familyID, now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_RevokeUserRefreshTokens(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewRefreshTokenRepository(db)
+	userID := uuid.New()
+	now := time.Now().UTC()
+
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = \$2 WHERE user_id = \$1 AND revoked_at IS NULL`).
+		WithArgs(userID, now).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	assert.NoError(t, repo.RevokeUserRefreshTokens(userID, now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_WithTx(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewRefreshTokenRepository(db)
+	now := time.Now().UTC()
+	familyID, userID := uuid.New(), uuid.New()
+
+	useRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows(refreshTokenRowColumns).
+			AddRow(uuid.New(), familyID, userID, now.Add(time.Hour), now, nil, now.Add(-time.Hour))
+	}
+	rotate := func(repo RefreshTokenRepositoryInterface) error {
+		used, err := repo.UseRefreshToken("hash", now)
+		if err != nil {
+			return err
+		}
+		_, err = repo.CreateRefreshToken(&model.RefreshToken{FamilyID: used.FamilyID, UserID: used.UserID, ExpiresAt: now.Add(time.Hour)}, "successor")
+		return err
+	}
+
+	t.Run("commits together", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`UPDATE refresh_tokens SET used_at`).WithArgs("hash", now).WillReturnRows(useRows())
+		mock.ExpectQuery(`INSERT INTO refresh_tokens`).
+			WithArgs(familyID, userID, "successor", now.Add(time.Hour), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows(refreshTokenRowColumns).AddRow(uuid.New(), familyID, userID, now.Add(time.Hour), nil, nil, now))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.WithTx(rotate))
+	})
+
+	t.Run("rolls the use back when the successor isn't stored", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`UPDATE refresh_tokens SET used_at`).WithArgs("hash", now).WillReturnRows(useRows())
+		mock.ExpectQuery(`INSERT INTO refresh_tokens`).WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, repo.WithTx(rotate), sql.ErrConnDone)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
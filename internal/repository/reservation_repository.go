@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+// Postgres error codes raised by the reservation constraints
+const (
+	pgForeignKeyViolation = "23503"
+	pgExclusionViolation  = "23P01"
+)
+
+var (
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrReservationConflict = errors.New("reservation overlaps an existing reservation")
+)
+
+type ReservationRepositoryInterface interface {
+	GetReservationsByDeviceID(deviceID string, after time.Time) ([]model.Reservation, error)
+	GetReservationByID(id string) (*model.Reservation, error)
+	GetActiveReservation(deviceID string, at time.Time) (*model.Reservation, error)
+	CreateReservation(reservation *model.Reservation) (*model.Reservation, error)
+	DeleteReservation(id string) error
+}
+
+type ReservationRepository struct {
+	db *sqlx.DB
+}
+
+func NewReservationRepository(db *sqlx.DB) *ReservationRepository {
+	return &ReservationRepository{db: db}
+}
+
+// GetReservationsByDeviceID lists the device reservations that end after the given time
+func (r *ReservationRepository) GetReservationsByDeviceID(deviceID string, after time.Time) ([]model.Reservation, error) {
+	reservations := []model.Reservation{}
+
+	query := `
+        SELECT id, device_id, user_id, starts_at, ends_at, created_at
+        FROM device_reservations
+        WHERE device_id = $1 AND ends_at > $2
+        ORDER BY starts_at
+    `
+	err := r.db.Select(&reservations, query, deviceID, after)
+	return reservations, err
+}
+
+// GetReservationByID retrieves a reservation by its ID
+func (r *ReservationRepository) GetReservationByID(id string) (*model.Reservation, error) {
+	var reservation model.Reservation
+
+	query := `
+        SELECT id, device_id, user_id, starts_at, ends_at, created_at
+        FROM device_reservations
+        WHERE id = $1
+    `
+	if err := r.db.Get(&reservation, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReservationNotFound
+		}
+		return nil, err
+	}
+
+	return &reservation, nil
+}
+
+// GetActiveReservation retrieves the reservation whose window contains the given time
+func (r *ReservationRepository) GetActiveReservation(deviceID string, at time.Time) (*model.Reservation, error) {
+	var reservation model.Reservation
+
+	query := `
+        SELECT id, device_id, user_id, starts_at, ends_at, created_at
+        FROM device_reservations
+        WHERE device_id = $1 AND starts_at <= $2 AND ends_at > $2
+    `
+	if err := r.db.Get(&reservation, query, deviceID, at); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReservationNotFound
+		}
+		return nil, err
+	}
+
+	return &reservation, nil
+}
+
+// CreateReservation creates a new reservation, relying on the exclusion
+// constraint to reject overlapping windows
+func (r *ReservationRepository) CreateReservation(reservation *model.Reservation) (*model.Reservation, error) {
+	query := `
+        INSERT INTO device_reservations (device_id, user_id, starts_at, ends_at)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, device_id, user_id, starts_at, ends_at, created_at
+    `
+	err := r.db.QueryRowx(query, reservation.DeviceID, reservation.UserID, reservation.StartsAt, reservation.EndsAt).
+		StructScan(reservation)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case pgExclusionViolation:
+				return nil, ErrReservationConflict
+			case pgForeignKeyViolation:
+				return nil, ErrDeviceNotFound
+			}
+		}
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// DeleteReservation deletes a reservation by its ID
+func (r *ReservationRepository) DeleteReservation(id string) error {
+	result, err := r.db.Exec("DELETE FROM device_reservations WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrReservationNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var reservationColumns = []string{"id", "device_id", "user_id", "starts_at", "ends_at", "created_at"}
+
+// Test CreateReservation
+
+func TestReservationRepository_CreateReservation(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewReservationRepository(db)
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+	startsAt := time.Now().Add(24 * time.Hour)
+	endsAt := startsAt.Add(4 * time.Hour)
+
+	newReservation := func() *model.Reservation {
+		return &model.Reservation{
+			DeviceID: deviceID,
+			UserID:   userID,
+			StartsAt: startsAt,
+			EndsAt:   endsAt,
+		}
+	}
+
+	t.Run("successful creation", func(t *testing.T) {
+		reservationID := uuid.New()
+		rows := sqlmock.NewRows(reservationColumns).
+			AddRow(reservationID, deviceID, userID, startsAt, endsAt, time.Now())
+
+		mock.ExpectQuery(`INSERT INTO device_reservations`).
+			WithArgs(deviceID, userID, startsAt, endsAt).
+			WillReturnRows(rows)
+
+		result, err := repo.CreateReservation(newReservation())
+
+		assert.NoError(t, err)
+		assert.Equal(t, reservationID, result.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("overlapping reservation", func(t *testing.T) {
+		mock.ExpectQuery(`INSERT INTO device_reservations`).
+			WithArgs(deviceID, userID, startsAt, endsAt).
+			WillReturnError(&pq.Error{Code: pgExclusionViolation})
+
+		result, err := repo.CreateReservation(newReservation())
+
+		assert.Equal(t, ErrReservationConflict, err)
+		assert.Nil(t, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown device", func(t *testing.T) {
+		mock.ExpectQuery(`INSERT INTO device_reservations`).
+			WithArgs(deviceID, userID, startsAt, endsAt).
+			WillReturnError(&pq.Error{Code: pgForeignKeyViolation})
+
+		result, err := repo.CreateReservation(newReservation())
+
+		assert.Equal(t, ErrDeviceNotFound, err)
+		assert.Nil(t, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery(`INSERT INTO device_reservations`).
+			WithArgs(deviceID, userID, startsAt, endsAt).
+			WillReturnError(fmt.Errorf("database error"))
+
+		result, err := repo.CreateReservation(newReservation())
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// Test GetActiveReservation
+
+func TestReservationRepository_GetActiveReservation(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewReservationRepository(db)
+
+	deviceID := uuid.New()
+	now := time.Now()
+
+	t.Run("active reservation found", func(t *testing.T) {
+		rows := sqlmock.NewRows(reservationColumns).
+			AddRow(uuid.New(), deviceID, uuid.New(), now.Add(-time.Hour), now.Add(time.Hour), now)
+
+		mock.ExpectQuery(`SELECT (.+) FROM device_reservations WHERE device_id = \$1 AND starts_at <= \$2 AND ends_at > \$2`).
+			WithArgs(deviceID.String(), now).
+			WillReturnRows(rows)
+
+		reservation, err := repo.GetActiveReservation(deviceID.String(), now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, deviceID, reservation.DeviceID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("no active reservation", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) FROM device_reservations WHERE device_id = \$1 AND starts_at <= \$2 AND ends_at > \$2`).
+			WithArgs(deviceID.String(), now).
+			WillReturnError(sql.ErrNoRows)
+
+		reservation, err := repo.GetActiveReservation(deviceID.String(), now)
+
+		assert.Equal(t, ErrReservationNotFound, err)
+		assert.Nil(t, reservation)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// Test GetReservationsByDeviceID
+
+func TestReservationRepository_GetReservationsByDeviceID(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewReservationRepository(db)
+
+	deviceID := uuid.New()
+	now := time.Now()
+
+	rows := sqlmock.NewRows(reservationColumns).
+		AddRow(uuid.New(), deviceID, uuid.New(), now, now.Add(time.Hour), now).
+		AddRow(uuid.New(), deviceID, uuid.New(), now.Add(2*time.Hour), now.Add(3*time.Hour), now)
+
+	mock.ExpectQuery(`SELECT (.+) FROM device_reservations WHERE device_id = \$1 AND ends_at > \$2 ORDER BY starts_at`).
+		WithArgs(deviceID.String(), now).
+		WillReturnRows(rows)
+
+	reservations, err := repo.GetReservationsByDeviceID(deviceID.String(), now)
+
+	assert.NoError(t, err)
+	assert.Len(t, reservations, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test DeleteReservation
+
+func TestReservationRepository_DeleteReservation(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewReservationRepository(db)
+
+	reservationID := uuid.New()
+
+	t.Run("successful deletion", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM device_reservations WHERE id`).
+			WithArgs(reservationID.String()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.DeleteReservation(reservationID.String())
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reservation not found", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM device_reservations WHERE id`).
+			WithArgs(reservationID.String()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.DeleteReservation(reservationID.String())
+
+		assert.Equal(t, ErrReservationNotFound, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
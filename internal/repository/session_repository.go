@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+const sessionColumns = "id, user_id, user_agent, ip, created_at, expires_at"
+
+// SessionRepository keeps sessions in Postgres, so they survive restarts
+// and are shared by every replica. It implements model.SessionStore.
+type SessionRepository struct {
+	db  *sqlx.DB
+	now func() time.Time
+}
+
+func NewSessionRepository(db *sqlx.DB) *SessionRepository {
+	return &SessionRepository{
+		db:  db,
+		now: func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Create stores session under the hash of a new token and returns the token
+func (r *SessionRepository) Create(session *model.Session, duration time.Duration) (string, error) {
+	token, hash, err := model.NewSessionToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := r.now()
+	query := `
+        INSERT INTO sessions (token_hash, user_id, user_agent, ip, created_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING ` + sessionColumns
+	err = r.db.Get(session, query, hash, session.UserID, session.UserAgent, session.IP, now, now.Add(duration))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Get retrieves the live session identified by token
+func (r *SessionRepository) Get(token string) (*model.Session, error) {
+	var session model.Session
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE token_hash = $1 AND expires_at > $2`
+	err := r.db.Get(&session, query, model.HashSessionToken(token), r.now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// ListByUser retrieves the live sessions of a user, newest first
+func (r *SessionRepository) ListByUser(userID uuid.UUID) ([]model.Session, error) {
+	sessions := []model.Session{}
+	query := `
+        SELECT ` + sessionColumns + `
+        FROM sessions
+        WHERE user_id = $1 AND expires_at > $2
+        ORDER BY created_at DESC, id
+    `
+	if err := r.db.Select(&sessions, query, userID, r.now()); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Delete ends a session of a user
+func (r *SessionRepository) Delete(userID, id uuid.UUID) error {
+	result, err := r.db.Exec(`DELETE FROM sessions WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return model.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// DeleteByUser ends every session of a user
+func (r *SessionRepository) DeleteByUser(userID uuid.UUID) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM sessions WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Extend moves the expiry of a live session
+func (r *SessionRepository) Extend(id uuid.UUID, expiresAt time.Time) error {
+	result, err := r.db.Exec(`UPDATE sessions SET expires_at = $2 WHERE id = $1 AND expires_at > $3`, id, expiresAt, r.now())
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return model.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// DeleteExpired removes the sessions that expired before now
+func (r *SessionRepository) DeleteExpired(now time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM sessions WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
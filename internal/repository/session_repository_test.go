@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sessionTableColumns = []string{"id", "user_id", "user_agent", "ip", "created_at", "expires_at"}
+
+func newTestSessionRepository(t *testing.T, now time.Time) (*SessionRepository, sqlmock.Sqlmock) {
+	db, mock := setupMockDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	repo := NewSessionRepository(db)
+	repo.now = func() time.Time { return now }
+	return repo, mock
+}
+
+func TestSessionRepository_Create(t *testing.T) {
+	now := time.Now().UTC()
+	repo, mock := newTestSessionRepository(t, now)
+
+	session := model.NewSession(uuid.New(), "curl/8.5.0", "203.0.113.7")
+	id := uuid.New()
+
+	mock.ExpectQuery(`INSERT INTO sessions \(token_hash, user_id, user_agent, ip, created_at, expires_at\)`).
+		WithArgs(sqlmock.AnyArg(), session.UserID, "curl/8.5.0", "203.0.113.7", now, now.Add(time.Hour)).
+		WillReturnRows(sqlmock.NewRows(sessionTableColumns).
+			AddRow(id, session.UserID, "curl/8.5.0", "203.0.113.7", now, now.Add(time.Hour)))
+
+	token, err := repo.Create(session, time.Hour)
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, id, session.ID)
+	assert.Equal(t, now.Add(time.Hour), session.ExpiresAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepository_Get(t *testing.T) {
+	now := time.Now().UTC()
+	repo, mock := newTestSessionRepository(t, now)
+	userID := uuid.New()
+
+	t.Run("live session", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) FROM sessions WHERE token_hash = \$1 AND expires_at > \$2`).
+			WithArgs(model.HashSessionToken("token"), now).
+			WillReturnRows(sqlmock.NewRows(sessionTableColumns).
+				AddRow(uuid.New(), userID, "curl/8.5.0", "203.0.113.7", now, now.Add(time.Hour)))
+
+		session, err := repo.Get("token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, userID, session.UserID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown or expired", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) FROM sessions WHERE token_hash = \$1`).
+			WillReturnError(sql.ErrNoRows)
+
+		session, err := repo.Get("token")
+
+		assert.ErrorIs(t, err, model.ErrSessionNotFound)
+		assert.Nil(t, session)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) FROM sessions WHERE token_hash = \$1`).
+			WillReturnError(fmt.Errorf("database error"))
+
+		session, err := repo.Get("token")
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, model.ErrSessionNotFound)
+		assert.Nil(t, session)
+	})
+}
+
+func TestSessionRepository_ListByUser(t *testing.T) {
+	now := time.Now().UTC()
+	repo, mock := newTestSessionRepository(t, now)
+	userID := uuid.New()
+
+	mock.ExpectQuery(`SELECT (.+) FROM sessions WHERE user_id = \$1 AND expires_at > \$2 ORDER BY created_at DESC, id`).
+		WithArgs(userID, now).
+		WillReturnRows(sqlmock.NewRows(sessionTableColumns).
+			AddRow(uuid.New(), userID, "curl/8.5.0", "203.0.113.7", now, now.Add(time.Hour)).
+			AddRow(uuid.New(), userID, "Mozilla/5.0", "198.51.100.2", now.Add(-time.Hour), now.Add(time.Minute)))
+
+	sessions, err := repo.ListByUser(userID)
+
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, "Mozilla/5.0", sessions[1].UserAgent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepository_Delete(t *testing.T) {
+	repo, mock := newTestSessionRepository(t, time.Now().UTC())
+	userID, id := uuid.New(), uuid.New()
+
+	t.Run("own session", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM sessions WHERE id = \$1 AND user_id = \$2`).
+			WithArgs(id, userID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.Delete(userID, id))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown or someone else's session", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM sessions WHERE id = \$1 AND user_id = \$2`).
+			WithArgs(id, userID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.ErrorIs(t, repo.Delete(userID, id), model.ErrSessionNotFound)
+	})
+}
+
+func TestSessionRepository_DeleteByUser(t *testing.T) {
+	repo, mock := newTestSessionRepository(t, time.Now().UTC())
+	userID := uuid.New()
+
+	mock.ExpectExec(`DELETE FROM sessions WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	deleted, err := repo.DeleteByUser(userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepository_Extend(t *testing.T) {
+	now := time.Now().UTC()
+	repo, mock := newTestSessionRepository(t, now)
+	id := uuid.New()
+
+	t.Run("live session", func(t *testing.T) {
+		mock.ExpectExec(`UPDATE sessions SET expires_at = \$2 WHERE id = \$1 AND expires_at > \$3`).
+			WithArgs(id, now.Add(time.Hour), now).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.Extend(id, now.Add(time.Hour)))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("expired or ended", func(t *testing.T) {
+		mock.ExpectExec(`UPDATE sessions SET expires_at`).
+			WithArgs(id, now.Add(time.Hour), now).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.ErrorIs(t, repo.Extend(id, now.Add(time.Hour)), model.ErrSessionNotFound)
+	})
+}
+
+func TestSessionRepository_DeleteExpired(t *testing.T) {
+	now := time.Now().UTC()
+	repo, mock := newTestSessionRepository(t, now)
+
+	mock.ExpectExec(`DELETE FROM sessions WHERE expires_at <= \$1`).
+		WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	deleted, err := repo.DeleteExpired(now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+// withTx runs fn inside a transaction, committing when fn succeeds and
+// rolling back otherwise
+func withTx(db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test WithTx
+
+func TestDeviceRepository_WithTx(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewDeviceRepository(db)
+
+	actorID := uuid.New()
+	now := time.Now()
+
+	createdRows := func(id uuid.UUID, name string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "brand", "state", "version", "created_at", "updated_at"}).
+			AddRow(id, name, "Lenovo", model.StateAvailable, 1, now, now)
+	}
+
+	t.Run("writes share one transaction", func(t *testing.T) {
+		firstID, secondID := uuid.New(), uuid.New()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO devices`).
+			WithArgs("ThinkPad T14", "Lenovo", model.StateAvailable).
+			WillReturnRows(createdRows(firstID, "ThinkPad T14"))
+		mock.ExpectExec(`INSERT INTO device_events`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`INSERT INTO devices`).
+			WithArgs("ThinkPad T16", "Lenovo", model.StateAvailable).
+			WillReturnRows(createdRows(secondID, "ThinkPad T16"))
+		mock.ExpectExec(`INSERT INTO device_events`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.WithTx(func(txRepo DeviceRepositoryInterface) error {
+			for _, name := range []string{"ThinkPad T14", "ThinkPad T16"} {
+				if _, err := txRepo.CreateDevice(&model.Device{Name: name, Brand: "Lenovo", State: model.StateAvailable}, actorID); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("a failing write rolls back the others", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO devices`).
+			WithArgs("ThinkPad T14", "Lenovo", model.StateAvailable).
+			WillReturnRows(createdRows(uuid.New(), "ThinkPad T14"))
+		mock.ExpectExec(`INSERT INTO device_events`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`INSERT INTO devices`).
+			WithArgs("ThinkPad T16", "Lenovo", model.StateAvailable).
+			WillReturnError(fmt.Errorf("database error"))
+		mock.ExpectRollback()
+
+		err := repo.WithTx(func(txRepo DeviceRepositoryInterface) error {
+			for _, name := range []string{"ThinkPad T14", "ThinkPad T16"} {
+				if _, err := txRepo.CreateDevice(&model.Device{Name: name, Brand: "Lenovo", State: model.StateAvailable}, actorID); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		assert.EqualError(t, err, "database error")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -3,21 +3,41 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/loopsFreitag/DeviceRegistry/internal/model"
 )
 
 var (
 	ErrUserNotFound      = errors.New("user not found")
 	ErrUserAlreadyExists = errors.New("user with this email already exists")
+	ErrUserAlreadyLinked = errors.New("user is already linked to another single sign-on account")
 )
 
+const pgUniqueViolation = "23505"
+
+// userEmailConstraints are the unique constraints on the email of users: the
+// original one, and the one ignoring case
+var userEmailConstraints = map[string]bool{
+	"users_email_key":       true,
+	"idx_users_email_lower": true,
+}
+
+// userColumns are the columns a model.User is scanned from
+const userColumns = "id, email, password_hash, role, oidc_subject, created_at, updated_at"
+
 type UserRepository interface {
 	Create(user *model.User) error
 	GetByID(id uuid.UUID) (*model.User, error)
 	GetByEmail(email string) (*model.User, error)
+	GetByIDs(ids []uuid.UUID) ([]model.User, error)
+	List(limit, offset int) ([]model.User, int, error)
+	UpdateRole(id uuid.UUID, role model.Role) (*model.User, error)
+	GetByOIDCSubject(subject string) (*model.User, error)
+	LinkOIDCSubject(id uuid.UUID, subject string) (*model.User, error)
 }
 
 type userRepository struct {
@@ -30,16 +50,16 @@ func NewUserRepository(db *sqlx.DB) UserRepository {
 
 func (r *userRepository) Create(user *model.User) error {
 	query := `
-		INSERT INTO users (id, email, password_hash, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, email, created_at, updated_at
+		INSERT INTO users (id, email, password_hash, role, oidc_subject, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, email, role, oidc_subject, created_at, updated_at
 	`
 
-	err := r.db.QueryRowx(query, user.ID, user.Email, user.PasswordHash, user.CreatedAt, user.UpdatedAt).
+	err := r.db.QueryRowx(query, user.ID, user.Email, user.PasswordHash, user.Role, user.OIDCSubject, user.CreatedAt, user.UpdatedAt).
 		StructScan(user)
 	if err != nil {
-		// Check for unique constraint violation
-		if err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"` {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation && userEmailConstraints[pqErr.Constraint] {
 			return ErrUserAlreadyExists
 		}
 		return err
@@ -50,7 +70,7 @@ func (r *userRepository) Create(user *model.User) error {
 
 func (r *userRepository) GetByID(id uuid.UUID) (*model.User, error) {
 	user := &model.User{}
-	query := `SELECT id, email, password_hash, created_at, updated_at FROM users WHERE id = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
 	err := r.db.Get(user, query, id)
 	if err != nil {
@@ -63,9 +83,11 @@ func (r *userRepository) GetByID(id uuid.UUID) (*model.User, error) {
 	return user, nil
 }
 
+// GetByEmail retrieves the user registered with email, ignoring case, as
+// providers and users don't agree on it
 func (r *userRepository) GetByEmail(email string) (*model.User, error) {
 	user := &model.User{}
-	query := `SELECT id, email, password_hash, created_at, updated_at FROM users WHERE email = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE lower(email) = lower($1)`
 
 	err := r.db.Get(user, query, email)
 	if err != nil {
@@ -77,3 +99,90 @@ func (r *userRepository) GetByEmail(email string) (*model.User, error) {
 
 	return user, nil
 }
+
+// GetByIDs retrieves the users with the given ids, in no particular order.
+// Unknown ids are skipped.
+func (r *userRepository) GetByIDs(ids []uuid.UUID) ([]model.User, error) {
+	users := []model.User{}
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ANY($1)`
+
+	if err := r.db.Select(&users, query, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// List retrieves a page of users, oldest first, along with their total
+// number
+func (r *userRepository) List(limit, offset int) ([]model.User, int, error) {
+	users := []model.User{}
+	var total int
+
+	if err := r.db.Get(&total, "SELECT COUNT(*) FROM users"); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users ORDER BY created_at, id LIMIT $1 OFFSET $2`
+	if err := r.db.Select(&users, query, limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// UpdateRole changes the role of a user and returns the updated user
+func (r *userRepository) UpdateRole(id uuid.UUID, role model.Role) (*model.User, error) {
+	user := &model.User{}
+	query := `
+		UPDATE users SET role = $2, updated_at = $3
+		WHERE id = $1
+		RETURNING ` + userColumns
+
+	err := r.db.Get(user, query, id, role, time.Now().UTC())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetByOIDCSubject retrieves the user linked to a single sign-on account
+func (r *userRepository) GetByOIDCSubject(subject string) (*model.User, error) {
+	user := &model.User{}
+	query := `SELECT ` + userColumns + ` FROM users WHERE oidc_subject = $1`
+
+	err := r.db.Get(user, query, subject)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// LinkOIDCSubject links a user to a single sign-on account and drops their
+// password, so whoever registered the email can't keep signing in with it.
+// Users already linked to an account are left alone.
+func (r *userRepository) LinkOIDCSubject(id uuid.UUID, subject string) (*model.User, error) {
+	user := &model.User{}
+	query := `
+		UPDATE users SET oidc_subject = $2, password_hash = '', updated_at = $3
+		WHERE id = $1 AND oidc_subject IS NULL
+		RETURNING ` + userColumns
+
+	err := r.db.Get(user, query, id, subject, time.Now().UTC())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserAlreadyLinked
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
@@ -1,12 +1,14 @@
 package repository
 
 import (
+	"database/sql"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/loopsFreitag/DeviceRegistry/internal/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -30,16 +32,17 @@ func TestUserRepository_Create(t *testing.T) {
 		ID:           uuid.New(),
 		Email:        "test@example.com",
 		PasswordHash: "hashedpassword",
+		Role:         model.RoleViewer,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
 
 	t.Run("successful creation", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "email", "created_at", "updated_at"}).
-			AddRow(user.ID, user.Email, user.CreatedAt, user.UpdatedAt)
+		rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at", "updated_at"}).
+			AddRow(user.ID, user.Email, user.Role, user.CreatedAt, user.UpdatedAt)
 
 		mock.ExpectQuery(`INSERT INTO users`).
-			WithArgs(user.ID, user.Email, user.PasswordHash, user.CreatedAt, user.UpdatedAt).
+			WithArgs(user.ID, user.Email, user.PasswordHash, user.Role, user.OIDCSubject, user.CreatedAt, user.UpdatedAt).
 			WillReturnRows(rows)
 
 		err := repo.Create(user)
@@ -48,13 +51,24 @@ func TestUserRepository_Create(t *testing.T) {
 	})
 
 	t.Run("duplicate email error", func(t *testing.T) {
+		for _, constraint := range []string{"users_email_key", "idx_users_email_lower"} {
+			mock.ExpectQuery(`INSERT INTO users`).
+				WithArgs(user.ID, user.Email, user.PasswordHash, user.Role, user.OIDCSubject, user.CreatedAt, user.UpdatedAt).
+				WillReturnError(&pq.Error{Code: pgUniqueViolation, Constraint: constraint})
+
+			err := repo.Create(user)
+			assert.Equal(t, ErrUserAlreadyExists, err, constraint)
+		}
+	})
+
+	t.Run("other unique violation", func(t *testing.T) {
 		mock.ExpectQuery(`INSERT INTO users`).
-			WithArgs(user.ID, user.Email, user.PasswordHash, user.CreatedAt, user.UpdatedAt).
-			WillReturnError(ErrUserAlreadyExists)
+			WithArgs(user.ID, user.Email, user.PasswordHash, user.Role, user.OIDCSubject, user.CreatedAt, user.UpdatedAt).
+			WillReturnError(&pq.Error{Code: pgUniqueViolation, Constraint: "users_oidc_subject_key"})
 
 		err := repo.Create(user)
 		assert.Error(t, err)
-		assert.Equal(t, ErrUserAlreadyExists, err)
+		assert.NotEqual(t, ErrUserAlreadyExists, err)
 	})
 }
 
@@ -66,8 +80,8 @@ func TestUserRepository_GetByID(t *testing.T) {
 	userID := uuid.New()
 
 	t.Run("user found", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "created_at", "updated_at"}).
-			AddRow(userID, "test@example.com", "hashedpassword", time.Now(), time.Now())
+		rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "role", "created_at", "updated_at"}).
+			AddRow(userID, "test@example.com", "hashedpassword", "operator", time.Now(), time.Now())
 
 		mock.ExpectQuery(`SELECT (.+) FROM users WHERE id`).
 			WithArgs(userID).
@@ -78,6 +92,7 @@ func TestUserRepository_GetByID(t *testing.T) {
 		assert.NotNil(t, user)
 		assert.Equal(t, userID, user.ID)
 		assert.Equal(t, "test@example.com", user.Email)
+		assert.Equal(t, model.RoleOperator, user.Role)
 	})
 
 	t.Run("user not found", func(t *testing.T) {
@@ -104,18 +119,18 @@ func TestUserRepository_GetByEmail(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "created_at", "updated_at"}).
 			AddRow(userID, email, "hashedpassword", time.Now(), time.Now())
 
-		mock.ExpectQuery(`SELECT (.+) FROM users WHERE email`).
-			WithArgs(email).
+		mock.ExpectQuery(`SELECT (.+) FROM users WHERE lower\(email\) = lower\(\$1\)`).
+			WithArgs("Test@Example.com").
 			WillReturnRows(rows)
 
-		user, err := repo.GetByEmail(email)
+		user, err := repo.GetByEmail("Test@Example.com")
 		assert.NoError(t, err)
 		assert.NotNil(t, user)
 		assert.Equal(t, email, user.Email)
 	})
 
 	t.Run("user not found", func(t *testing.T) {
-		mock.ExpectQuery(`SELECT (.+) FROM users WHERE email`).
+		mock.ExpectQuery(`SELECT (.+) FROM users WHERE lower\(email\)`).
 			WithArgs(email).
 			WillReturnError(ErrUserNotFound)
 
@@ -124,3 +139,167 @@ func TestUserRepository_GetByEmail(t *testing.T) {
 		assert.Nil(t, user)
 	})
 }
+
+func TestUserRepository_GetByIDs(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+	first, second := uuid.New(), uuid.New()
+
+	t.Run("users found", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "created_at", "updated_at"}).
+			AddRow(first, "first@example.com", "hashedpassword", time.Now(), time.Now())
+
+		mock.ExpectQuery(`SELECT (.+) FROM users WHERE id = ANY\(\$1\)`).
+			WithArgs(pq.Array([]uuid.UUID{first, second})).
+			WillReturnRows(rows)
+
+		users, err := repo.GetByIDs([]uuid.UUID{first, second})
+		assert.NoError(t, err)
+		assert.Len(t, users, 1)
+		assert.Equal(t, first, users[0].ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) FROM users WHERE id = ANY\(\$1\)`).
+			WillReturnError(sql.ErrConnDone)
+
+		users, err := repo.GetByIDs([]uuid.UUID{first})
+		assert.Error(t, err)
+		assert.Nil(t, users)
+	})
+}
+
+func TestUserRepository_List(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	t.Run("page of users", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "role", "created_at", "updated_at"}).
+			AddRow(uuid.New(), "second@example.com", "hashedpassword", "viewer", time.Now(), time.Now())
+		mock.ExpectQuery(`SELECT (.+) FROM users ORDER BY created_at, id LIMIT \$1 OFFSET \$2`).
+			WithArgs(1, 1).
+			WillReturnRows(rows)
+
+		users, total, err := repo.List(1, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		require.Len(t, users, 1)
+		assert.Equal(t, "second@example.com", users[0].Email)
+		assert.Equal(t, model.RoleViewer, users[0].Role)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users`).
+			WillReturnError(sql.ErrConnDone)
+
+		users, _, err := repo.List(20, 0)
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.Nil(t, users)
+	})
+}
+
+func TestUserRepository_UpdateRole(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+	userID := uuid.New()
+
+	t.Run("role updated", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "role", "created_at", "updated_at"}).
+			AddRow(userID, "test@example.com", "hashedpassword", "admin", time.Now(), time.Now())
+		mock.ExpectQuery(`UPDATE users SET role = \$2, updated_at = \$3\s+WHERE id = \$1\s+RETURNING`).
+			WithArgs(userID, model.RoleAdmin, sqlmock.AnyArg()).
+			WillReturnRows(rows)
+
+		user, err := repo.UpdateRole(userID, model.RoleAdmin)
+		require.NoError(t, err)
+		assert.Equal(t, model.RoleAdmin, user.Role)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mock.ExpectQuery(`UPDATE users SET role`).
+			WithArgs(userID, model.RoleAdmin, sqlmock.AnyArg()).
+			WillReturnError(sql.ErrNoRows)
+
+		user, err := repo.UpdateRole(userID, model.RoleAdmin)
+		assert.Equal(t, ErrUserNotFound, err)
+		assert.Nil(t, user)
+	})
+}
+
+func TestUserRepository_GetByOIDCSubject(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+	userID := uuid.New()
+
+	t.Run("user found", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "role", "oidc_subject", "created_at", "updated_at"}).
+			AddRow(userID, "test@example.com", "", "viewer", "idp-subject", time.Now(), time.Now())
+
+		mock.ExpectQuery(`SELECT (.+) FROM users WHERE oidc_subject = \$1`).
+			WithArgs("idp-subject").
+			WillReturnRows(rows)
+
+		user, err := repo.GetByOIDCSubject("idp-subject")
+		require.NoError(t, err)
+		assert.Equal(t, userID, user.ID)
+		require.NotNil(t, user.OIDCSubject)
+		assert.Equal(t, "idp-subject", *user.OIDCSubject)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) FROM users WHERE oidc_subject = \$1`).
+			WithArgs("idp-subject").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetByOIDCSubject("idp-subject")
+		assert.Equal(t, ErrUserNotFound, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_LinkOIDCSubject(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+	userID := uuid.New()
+
+	t.Run("linked", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "role", "oidc_subject", "created_at", "updated_at"}).
+			AddRow(userID, "test@example.com", "", "operator", "idp-subject", time.Now(), time.Now())
+
+		mock.ExpectQuery(`UPDATE users SET oidc_subject = \$2, password_hash = '', updated_at = \$3\s+WHERE id = \$1 AND oidc_subject IS NULL`).
+			WithArgs(userID, "idp-subject", sqlmock.AnyArg()).
+			WillReturnRows(rows)
+
+		user, err := repo.LinkOIDCSubject(userID, "idp-subject")
+		require.NoError(t, err)
+		assert.Empty(t, user.PasswordHash)
+		assert.Equal(t, model.RoleOperator, user.Role)
+	})
+
+	t.Run("linked to another account", func(t *testing.T) {
+		mock.ExpectQuery(`UPDATE users SET oidc_subject`).
+			WithArgs(userID, "idp-subject", sqlmock.AnyArg()).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.LinkOIDCSubject(userID, "idp-subject")
+		assert.Equal(t, ErrUserAlreadyLinked, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+const (
+	webhookColumns         = "id, user_id, url, secret, event_types, active, created_at, updated_at"
+	webhookDeliveryColumns = "id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at"
+)
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+type WebhookRepositoryInterface interface {
+	GetWebhooks(userID uuid.UUID) ([]model.Webhook, error)
+	GetWebhookByID(id string, userID uuid.UUID) (*model.Webhook, error)
+	CreateWebhook(webhook *model.Webhook) (*model.Webhook, error)
+	UpdateWebhook(webhook *model.Webhook) (*model.Webhook, error)
+	DeleteWebhook(id string, userID uuid.UUID) error
+	GetDeliveries(webhookID string, limit, offset int) ([]model.WebhookDelivery, int, error)
+	FanOutEvents(limit int, now time.Time) (int64, error)
+	ClaimDueDeliveries(now, leaseUntil time.Time, limit int) ([]model.PendingWebhookDelivery, error)
+	MarkDelivered(id uuid.UUID, statusCode int, at time.Time) error
+	MarkFailed(id uuid.UUID, statusCode *int, reason string, retryAt *time.Time) error
+}
+
+type WebhookRepository struct {
+	db *sqlx.DB
+}
+
+func NewWebhookRepository(db *sqlx.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// GetWebhooks lists the webhooks of a user, oldest first
+func (r *WebhookRepository) GetWebhooks(userID uuid.UUID) ([]model.Webhook, error) {
+	webhooks := []model.Webhook{}
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE user_id = $1 ORDER BY created_at, id`
+	if err := r.db.Select(&webhooks, query, userID); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// GetWebhookByID retrieves a webhook owned by userID
+func (r *WebhookRepository) GetWebhookByID(id string, userID uuid.UUID) (*model.Webhook, error) {
+	var webhook model.Webhook
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1 AND user_id = $2`
+	if err := r.db.Get(&webhook, query, id, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// CreateWebhook stores a new webhook
+func (r *WebhookRepository) CreateWebhook(webhook *model.Webhook) (*model.Webhook, error) {
+	var created model.Webhook
+	query := `
+        INSERT INTO webhooks (user_id, url, secret, event_types, active)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING ` + webhookColumns
+	if err := r.db.Get(&created, query, webhook.UserID, webhook.URL, webhook.Secret, webhook.EventTypes, webhook.Active); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateWebhook replaces the url, event types and active flag of a webhook
+// owned by webhook.UserID. The secret is kept.
+func (r *WebhookRepository) UpdateWebhook(webhook *model.Webhook) (*model.Webhook, error) {
+	var updated model.Webhook
+	query := `
+        UPDATE webhooks
+        SET url = $1, event_types = $2, active = $3
+        WHERE id = $4 AND user_id = $5
+        RETURNING ` + webhookColumns
+	err := r.db.Get(&updated, query, webhook.URL, webhook.EventTypes, webhook.Active, webhook.ID, webhook.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteWebhook removes a webhook owned by userID along with its deliveries
+func (r *WebhookRepository) DeleteWebhook(id string, userID uuid.UUID) error {
+	result, err := r.db.Exec("DELETE FROM webhooks WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// GetDeliveries retrieves a page of the deliveries of a webhook, newest
+// first, along with their total number
+func (r *WebhookRepository) GetDeliveries(webhookID string, limit, offset int) ([]model.WebhookDelivery, int, error) {
+	deliveries := []model.WebhookDelivery{}
+	var total int
+
+	if err := r.db.Get(&total, "SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1", webhookID); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+        SELECT ` + webhookDeliveryColumns + `
+        FROM webhook_deliveries
+        WHERE webhook_id = $1
+        ORDER BY created_at DESC, id
+        LIMIT $2 OFFSET $3
+    `
+	if err := r.db.Select(&deliveries, query, webhookID, limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
+
+// FanOutEvents takes up to limit events off the outbox and creates a
+// delivery, due at now, for every active webhook subscribed to them. Both
+// happen in one statement, so an event is never lost nor fanned out twice.
+// It returns the number of deliveries created.
+func (r *WebhookRepository) FanOutEvents(limit int, now time.Time) (int64, error) {
+	query := `
+        WITH events AS (
+            DELETE FROM webhook_outbox
+            WHERE id IN (
+                SELECT id FROM webhook_outbox
+                ORDER BY id
+                LIMIT $1
+                FOR UPDATE SKIP LOCKED
+            )
+            RETURNING id, event_id, event_type, payload
+        )
+        INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, next_attempt_at, created_at)
+        SELECT w.id, e.event_id, e.event_type, e.payload, $2, $2
+        FROM events e
+        JOIN webhooks w ON w.active AND (cardinality(w.event_types) = 0 OR e.event_type = ANY(w.event_types))
+        ORDER BY e.id
+    `
+	result, err := r.db.Exec(query, limit, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ClaimDueDeliveries picks up to limit pending deliveries of active webhooks
+// due at now and counts the attempt about to be made. They are pushed back
+// to leaseUntil so no other replica attempts them meanwhile, and are retried
+// then should this one die before recording the outcome.
+func (r *WebhookRepository) ClaimDueDeliveries(now, leaseUntil time.Time, limit int) ([]model.PendingWebhookDelivery, error) {
+	deliveries := []model.PendingWebhookDelivery{}
+	query := `
+        UPDATE webhook_deliveries d
+        SET attempts = d.attempts + 1, next_attempt_at = $2
+        FROM webhooks w
+        WHERE w.id = d.webhook_id AND d.id IN (
+            SELECT due.id FROM webhook_deliveries due
+            JOIN webhooks active ON active.id = due.webhook_id AND active.active
+            WHERE due.status = 'pending' AND due.next_attempt_at <= $1
+            ORDER BY due.next_attempt_at
+            LIMIT $3
+            FOR UPDATE OF due SKIP LOCKED
+        )
+        RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+            d.next_attempt_at, d.last_status_code, d.last_error, d.created_at, d.delivered_at,
+            w.url, w.secret
+    `
+	if err := r.db.Select(&deliveries, query, now, leaseUntil, limit); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// MarkDelivered records a successful attempt
+func (r *WebhookRepository) MarkDelivered(id uuid.UUID, statusCode int, at time.Time) error {
+	query := `
+        UPDATE webhook_deliveries
+        SET status = 'delivered', last_status_code = $1, last_error = NULL, next_attempt_at = NULL, delivered_at = $2
+        WHERE id = $3
+    `
+	_, err := r.db.Exec(query, statusCode, at, id)
+	return err
+}
+
+// MarkFailed records a failed attempt. The delivery is retried at retryAt,
+// or dead-lettered when retryAt is nil. statusCode is nil when no response
+// was received.
+func (r *WebhookRepository) MarkFailed(id uuid.UUID, statusCode *int, reason string, retryAt *time.Time) error {
+	query := `
+        UPDATE webhook_deliveries
+        SET status = CASE WHEN $3::timestamp IS NULL THEN 'dead' ELSE 'pending' END,
+            last_status_code = $1, last_error = $2, next_attempt_at = $3
+        WHERE id = $4
+    `
+	_, err := r.db.Exec(query, statusCode, reason, retryAt, id)
+	return err
+}
@@ -0,0 +1,276 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	webhookRowColumns         = []string{"id", "user_id", "url", "secret", "event_types", "active", "created_at", "updated_at"}
+	webhookDeliveryRowColumns = []string{"id", "webhook_id", "event_id", "event_type", "payload", "status", "attempts", "next_attempt_at", "last_status_code", "last_error", "created_at", "delivered_at"}
+)
+
+func TestWebhookRepository_CreateWebhook(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewWebhookRepository(db)
+
+	userID := uuid.New()
+	webhook := &model.Webhook{
+		UserID:     userID,
+		URL:        "https://example.com/hooks",
+		Secret:     "secret",
+		EventTypes: pq.StringArray{"created", "state_changed"},
+		Active:     true,
+	}
+
+	t.Run("successful creation", func(t *testing.T) {
+		webhookID := uuid.New()
+		rows := sqlmock.NewRows(webhookRowColumns).
+			AddRow(webhookID, userID, webhook.URL, webhook.Secret, "{created,state_changed}", true, time.Now(), time.Now())
+
+		mock.ExpectQuery(`INSERT INTO webhooks`).
+			WithArgs(userID, webhook.URL, webhook.Secret, webhook.EventTypes, true).
+			WillReturnRows(rows)
+
+		result, err := repo.CreateWebhook(webhook)
+
+		assert.NoError(t, err)
+		assert.Equal(t, webhookID, result.ID)
+		assert.Equal(t, pq.StringArray{"created", "state_changed"}, result.EventTypes)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery(`INSERT INTO webhooks`).
+			WillReturnError(fmt.Errorf("database error"))
+
+		result, err := repo.CreateWebhook(webhook)
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestWebhookRepository_GetWebhookByID(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewWebhookRepository(db)
+
+	webhookID := uuid.New()
+	userID := uuid.New()
+
+	t.Run("found", func(t *testing.T) {
+		rows := sqlmock.NewRows(webhookRowColumns).
+			AddRow(webhookID, userID, "https://example.com/hooks", "secret", "{}", true, time.Now(), time.Now())
+
+		mock.ExpectQuery(`SELECT .+ FROM webhooks WHERE id = \$1 AND user_id = \$2`).
+			WithArgs(webhookID.String(), userID).
+			WillReturnRows(rows)
+
+		result, err := repo.GetWebhookByID(webhookID.String(), userID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, webhookID, result.ID)
+		assert.Empty(t, result.EventTypes)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT .+ FROM webhooks WHERE id = \$1 AND user_id = \$2`).
+			WithArgs(webhookID.String(), userID).
+			WillReturnError(sql.ErrNoRows)
+
+		result, err := repo.GetWebhookByID(webhookID.String(), userID)
+
+		assert.Equal(t, ErrWebhookNotFound, err)
+		assert.Nil(t, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestWebhookRepository_UpdateWebhook(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewWebhookRepository(db)
+
+	webhook := &model.Webhook{
+		ID:         uuid.New(),
+		UserID:     uuid.New(),
+		URL:        "https://example.com/other",
+		EventTypes: pq.StringArray{},
+		Active:     false,
+	}
+
+	t.Run("successful update", func(t *testing.T) {
+		rows := sqlmock.NewRows(webhookRowColumns).
+			AddRow(webhook.ID, webhook.UserID, webhook.URL, "secret", "{}", false, time.Now(), time.Now())
+
+		mock.ExpectQuery(`UPDATE webhooks\s+SET url = \$1, event_types = \$2, active = \$3\s+WHERE id = \$4 AND user_id = \$5`).
+			WithArgs(webhook.URL, webhook.EventTypes, false, webhook.ID, webhook.UserID).
+			WillReturnRows(rows)
+
+		result, err := repo.UpdateWebhook(webhook)
+
+		assert.NoError(t, err)
+		assert.False(t, result.Active)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery(`UPDATE webhooks`).
+			WillReturnError(sql.ErrNoRows)
+
+		result, err := repo.UpdateWebhook(webhook)
+
+		assert.Equal(t, ErrWebhookNotFound, err)
+		assert.Nil(t, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestWebhookRepository_DeleteWebhook(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewWebhookRepository(db)
+
+	webhookID := uuid.New().String()
+	userID := uuid.New()
+
+	t.Run("deleted", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM webhooks WHERE id = \$1 AND user_id = \$2`).
+			WithArgs(webhookID, userID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.DeleteWebhook(webhookID, userID))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM webhooks`).
+			WithArgs(webhookID, userID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.Equal(t, ErrWebhookNotFound, repo.DeleteWebhook(webhookID, userID))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestWebhookRepository_GetDeliveries(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewWebhookRepository(db)
+
+	webhookID := uuid.New()
+	deliveryID := uuid.New()
+	nextAttempt := time.Now().Add(time.Minute)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM webhook_deliveries WHERE webhook_id = \$1`).
+		WithArgs(webhookID.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(`SELECT .+ FROM webhook_deliveries\s+WHERE webhook_id = \$1\s+ORDER BY created_at DESC, id\s+LIMIT \$2 OFFSET \$3`).
+		WithArgs(webhookID.String(), 1, 2).
+		WillReturnRows(sqlmock.NewRows(webhookDeliveryRowColumns).
+			AddRow(deliveryID, webhookID, uuid.New(), "created", []byte(`{"type":"created"}`), "pending", 2, nextAttempt, 503, "unexpected status 503", time.Now(), nil))
+
+	deliveries, total, err := repo.GetDeliveries(webhookID.String(), 1, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, deliveryID, deliveries[0].ID)
+		assert.Equal(t, model.WebhookDeliveryPending, deliveries[0].Status)
+		assert.Equal(t, 503, *deliveries[0].LastStatusCode)
+		assert.Nil(t, deliveries[0].DeliveredAt)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepository_FanOutEvents(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewWebhookRepository(db)
+	now := time.Now().UTC()
+
+	mock.ExpectExec(`WITH events AS \(\s+DELETE FROM webhook_outbox.+FOR UPDATE SKIP LOCKED.+INSERT INTO webhook_deliveries`).
+		WithArgs(100, now).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	created, err := repo.FanOutEvents(100, now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepository_ClaimDueDeliveries(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewWebhookRepository(db)
+
+	now := time.Now().UTC()
+	lease := now.Add(time.Minute)
+	deliveryID := uuid.New()
+
+	columns := append(append([]string{}, webhookDeliveryRowColumns...), "url", "secret")
+	mock.ExpectQuery(`UPDATE webhook_deliveries d\s+SET attempts = d.attempts \+ 1, next_attempt_at = \$2.+FOR UPDATE OF due SKIP LOCKED`).
+		WithArgs(now, lease, 20).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(deliveryID, uuid.New(), uuid.New(), "updated", []byte(`{}`), "pending", 1, lease, nil, nil, now, nil, "https://example.com/hooks", "secret"))
+
+	deliveries, err := repo.ClaimDueDeliveries(now, lease, 20)
+
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, deliveryID, deliveries[0].ID)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, "https://example.com/hooks", deliveries[0].URL)
+		assert.Equal(t, "secret", deliveries[0].Secret)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepository_MarkFailed(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := NewWebhookRepository(db)
+	deliveryID := uuid.New()
+
+	t.Run("scheduled for retry", func(t *testing.T) {
+		status := 503
+		retryAt := time.Now().UTC().Add(time.Minute)
+
+		mock.ExpectExec(`UPDATE webhook_deliveries\s+SET status = CASE WHEN \$3::timestamp IS NULL THEN 'dead' ELSE 'pending' END`).
+			WithArgs(&status, "unexpected status 503", &retryAt, deliveryID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.MarkFailed(deliveryID, &status, "unexpected status 503", &retryAt))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("dead-lettered", func(t *testing.T) {
+		mock.ExpectExec(`UPDATE webhook_deliveries`).
+			WithArgs(nil, "connection refused", nil, deliveryID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.MarkFailed(deliveryID, nil, "connection refused", nil))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
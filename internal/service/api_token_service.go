@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+)
+
+var (
+	ErrAPITokenNotFound   = wrapError(KindNotFound, "api_token_not_found", repository.ErrAPITokenNotFound)
+	ErrInvalidAPIToken    = newError(KindUnauthorized, "invalid_api_token", "invalid, expired or revoked API token")
+	ErrInvalidTokenExpiry = newError(KindValidation, "invalid_token_expiry", "expires_at must be in the future")
+	ErrScopeNotAllowed    = newError(KindForbidden, "scope_not_allowed", "your role does not allow one of the scopes")
+	ErrInsufficientScope  = newError(KindForbidden, "insufficient_scope", "the API token was not granted this scope")
+	ErrSessionRequired    = newError(KindForbidden, "session_required", "this needs a login session, not an API token")
+)
+
+type APITokenServiceInterface interface {
+	GetTokens(userID uuid.UUID) ([]model.APIToken, error)
+	CreateToken(token *model.APIToken, role model.Role) (*model.APIToken, error)
+	RevokeToken(id string, userID uuid.UUID) error
+	Authenticate(token string) (*model.APIToken, error)
+}
+
+type APITokenService struct {
+	repo repository.APITokenRepositoryInterface
+	now  func() time.Time
+}
+
+func NewAPITokenService(repo repository.APITokenRepositoryInterface) *APITokenService {
+	return &APITokenService{
+		repo: repo,
+		now:  func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// GetTokens lists the API tokens of a user, newest first
+func (s *APITokenService) GetTokens(userID uuid.UUID) ([]model.APIToken, error) {
+	tokens, err := s.repo.GetTokens(userID)
+	return tokens, domainError(err)
+}
+
+// CreateToken issues an API token for token.UserID, whose role is role.
+// Scopes must be allowed by the role. This is the only time the token
+// itself is returned.
+func (s *APITokenService) CreateToken(token *model.APIToken, role model.Role) (*model.APIToken, error) {
+	for _, scope := range token.Scopes {
+		if !role.Can(model.Permission(scope)) {
+			return nil, ErrScopeNotAllowed
+		}
+	}
+	if token.ExpiresAt != nil {
+		if !token.ExpiresAt.After(s.now()) {
+			return nil, ErrInvalidTokenExpiry
+		}
+		expiresAt := token.ExpiresAt.UTC()
+		token.ExpiresAt = &expiresAt
+	}
+
+	secret, hash, prefix, err := model.NewAPIToken()
+	if err != nil {
+		return nil, err
+	}
+	token.Prefix = prefix
+
+	created, err := s.repo.CreateToken(token, hash)
+	if err != nil {
+		return nil, domainError(err)
+	}
+
+	created.Token = secret
+	return created, nil
+}
+
+// RevokeToken deletes an API token owned by the user. Requests made with it
+// are rejected from then on.
+func (s *APITokenService) RevokeToken(id string, userID uuid.UUID) error {
+	return domainError(s.repo.DeleteToken(id, userID))
+}
+
+// Authenticate returns the live API token matching token, recording that it
+// was used
+func (s *APITokenService) Authenticate(token string) (*model.APIToken, error) {
+	if !strings.HasPrefix(token, model.APITokenPrefix) {
+		return nil, ErrInvalidAPIToken
+	}
+
+	found, err := s.repo.UseToken(model.HashAPIToken(token), s.now())
+	if errors.Is(err, repository.ErrAPITokenNotFound) {
+		return nil, ErrInvalidAPIToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAPITokenRepository is a mock implementation of the API token repository
+type MockAPITokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPITokenRepository) CreateToken(token *model.APIToken, hash string) (*model.APIToken, error) {
+	args := m.Called(token, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.APIToken), args.Error(1)
+}
+
+func (m *MockAPITokenRepository) GetTokens(userID uuid.UUID) ([]model.APIToken, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.APIToken), args.Error(1)
+}
+
+func (m *MockAPITokenRepository) DeleteToken(id string, userID uuid.UUID) error {
+	args := m.Called(id, userID)
+	return args.Error(0)
+}
+
+func (m *MockAPITokenRepository) UseToken(hash string, now time.Time) (*model.APIToken, error) {
+	args := m.Called(hash, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.APIToken), args.Error(1)
+}
+
+func TestAPITokenService_CreateToken(t *testing.T) {
+	userID := uuid.New()
+
+	t.Run("issues a token", func(t *testing.T) {
+		repo := new(MockAPITokenRepository)
+		service := NewAPITokenService(repo)
+
+		var stored *model.APIToken
+		var hash string
+		repo.On("CreateToken", mock.AnythingOfType("*model.APIToken"), mock.AnythingOfType("string")).
+			Run(func(args mock.Arguments) {
+				stored = args.Get(0).(*model.APIToken)
+				hash = args.String(1)
+			}).
+			Return(&model.APIToken{ID: uuid.New(), UserID: userID, Name: "ci"}, nil).Once()
+
+		created, err := service.CreateToken(&model.APIToken{
+			UserID: userID,
+			Name:   "ci",
+			Scopes: pq.StringArray{"devices:read", "devices:operate"},
+		}, model.RoleOperator)
+
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(created.Token, model.APITokenPrefix))
+		assert.True(t, strings.HasPrefix(created.Token, stored.Prefix))
+		assert.Equal(t, model.HashAPIToken(created.Token), hash)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("scope beyond the role", func(t *testing.T) {
+		service := NewAPITokenService(new(MockAPITokenRepository))
+
+		created, err := service.CreateToken(&model.APIToken{
+			UserID: userID,
+			Name:   "ci",
+			Scopes: pq.StringArray{"devices:manage"},
+		}, model.RoleOperator)
+
+		assert.ErrorIs(t, err, ErrScopeNotAllowed)
+		assert.Nil(t, created)
+	})
+
+	t.Run("expiry in the past", func(t *testing.T) {
+		service := NewAPITokenService(new(MockAPITokenRepository))
+		expiresAt := time.Now().Add(-time.Minute)
+
+		created, err := service.CreateToken(&model.APIToken{
+			UserID:    userID,
+			Name:      "ci",
+			Scopes:    pq.StringArray{"devices:read"},
+			ExpiresAt: &expiresAt,
+		}, model.RoleViewer)
+
+		assert.ErrorIs(t, err, ErrInvalidTokenExpiry)
+		assert.Nil(t, created)
+	})
+}
+
+func TestAPITokenService_Authenticate(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("live token", func(t *testing.T) {
+		repo := new(MockAPITokenRepository)
+		service := NewAPITokenService(repo)
+		service.now = func() time.Time { return now }
+
+		found := &model.APIToken{ID: uuid.New(), UserID: uuid.New()}
+		repo.On("UseToken", model.HashAPIToken("drt_secret"), now).Return(found, nil).Once()
+
+		token, err := service.Authenticate("drt_secret")
+
+		require.NoError(t, err)
+		assert.Equal(t, found.ID, token.ID)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("unknown, expired or revoked token", func(t *testing.T) {
+		repo := new(MockAPITokenRepository)
+		service := NewAPITokenService(repo)
+		service.now = func() time.Time { return now }
+
+		repo.On("UseToken", model.HashAPIToken("drt_secret"), now).Return(nil, repository.ErrAPITokenNotFound).Once()
+
+		token, err := service.Authenticate("drt_secret")
+
+		assert.ErrorIs(t, err, ErrInvalidAPIToken)
+		assert.Nil(t, token)
+	})
+
+	t.Run("wrapped not found", func(t *testing.T) {
+		repo := new(MockAPITokenRepository)
+		service := NewAPITokenService(repo)
+		service.now = func() time.Time { return now }
+
+		repo.On("UseToken", model.HashAPIToken("drt_secret"), now).
+			Return(nil, fmt.Errorf("use token: %w", repository.ErrAPITokenNotFound)).Once()
+
+		token, err := service.Authenticate("drt_secret")
+
+		assert.ErrorIs(t, err, ErrInvalidAPIToken)
+		assert.Nil(t, token)
+	})
+
+	t.Run("not an API token", func(t *testing.T) {
+		repo := new(MockAPITokenRepository)
+		service := NewAPITokenService(repo)
+
+		token, err := service.Authenticate("session-id")
+
+		assert.ErrorIs(t, err, ErrInvalidAPIToken)
+		assert.Nil(t, token)
+		repo.AssertNotCalled(t, "UseToken", mock.Anything, mock.Anything)
+	})
+}
+
+func TestAPITokenService_RevokeToken(t *testing.T) {
+	repo := new(MockAPITokenRepository)
+	service := NewAPITokenService(repo)
+	userID := uuid.New()
+
+	repo.On("DeleteToken", "missing", userID).Return(repository.ErrAPITokenNotFound).Once()
+
+	assert.ErrorIs(t, service.RevokeToken("missing", userID), ErrAPITokenNotFound)
+}
@@ -1,7 +1,6 @@
 package service
 
 import (
-	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,9 +10,12 @@ import (
 )
 
 var (
-	ErrInvalidCredentials = errors.New("invalid email or password")
-	ErrUserAlreadyExists  = repository.ErrUserAlreadyExists
-	ErrUserNotFound       = repository.ErrUserNotFound
+	ErrInvalidCredentials = newError(KindUnauthorized, "invalid_credentials", "invalid email or password")
+	ErrUserAlreadyExists  = wrapError(KindConflict, "user_already_exists", repository.ErrUserAlreadyExists)
+	ErrUserNotFound       = wrapError(KindNotFound, "user_not_found", repository.ErrUserNotFound)
+	ErrForbidden          = newError(KindForbidden, "forbidden", "your role does not allow this")
+	ErrInvalidRole        = newError(KindInvalid, "invalid_role", "role must be viewer, operator or admin")
+	ErrOwnRole            = newError(KindForbidden, "own_role", "you cannot change your own role")
 )
 
 // i love how go auto matches interface with implementations
@@ -21,6 +23,8 @@ type AuthServiceInterface interface {
 	CreateUser(email, password string) (*model.User, error)
 	Login(email, password string) (*model.User, error)
 	GetUserByID(userID uuid.UUID) (*model.User, error)
+	ListUsers(limit, offset int) ([]model.User, int, error)
+	UpdateUserRole(userID uuid.UUID, role model.Role, actorID uuid.UUID) (*model.User, error)
 }
 
 type AuthService struct {
@@ -44,13 +48,14 @@ func (s *AuthService) CreateUser(email, password string) (*model.User, error) {
 		ID:           uuid.New(),
 		Email:        email,
 		PasswordHash: string(hashedPassword),
+		Role:         model.RoleViewer,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
 
 	err = s.userRepo.Create(user)
 	if err != nil {
-		return nil, err
+		return nil, domainError(err)
 	}
 
 	return user, nil
@@ -75,5 +80,29 @@ func (s *AuthService) Login(email, password string) (*model.User, error) {
 }
 
 func (s *AuthService) GetUserByID(userID uuid.UUID) (*model.User, error) {
-	return s.userRepo.GetByID(userID)
+	user, err := s.userRepo.GetByID(userID)
+	return user, domainError(err)
+}
+
+// ListUsers retrieves a page of users along with their total number
+func (s *AuthService) ListUsers(limit, offset int) ([]model.User, int, error) {
+	users, total, err := s.userRepo.List(limit, offset)
+	if err != nil {
+		return nil, 0, domainError(err)
+	}
+	return users, total, nil
+}
+
+// UpdateUserRole gives a user another role on behalf of actorID. Users
+// cannot change their own role, so the last admin cannot lock everyone out.
+func (s *AuthService) UpdateUserRole(userID uuid.UUID, role model.Role, actorID uuid.UUID) (*model.User, error) {
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	if userID == actorID {
+		return nil, ErrOwnRole
+	}
+
+	user, err := s.userRepo.UpdateRole(userID, role)
+	return user, domainError(err)
 }
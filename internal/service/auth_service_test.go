@@ -38,6 +38,46 @@ func (m *MockUserRepository) GetByEmail(email string) (*model.User, error) {
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
+func (m *MockUserRepository) GetByIDs(ids []uuid.UUID) ([]model.User, error) {
+	args := m.Called(ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) List(limit, offset int) ([]model.User, int, error) {
+	args := m.Called(limit, offset)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]model.User), args.Int(1), args.Error(2)
+}
+
+func (m *MockUserRepository) UpdateRole(id uuid.UUID, role model.Role) (*model.User, error) {
+	args := m.Called(id, role)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetByOIDCSubject(subject string) (*model.User, error) {
+	args := m.Called(subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) LinkOIDCSubject(id uuid.UUID, subject string) (*model.User, error) {
+	args := m.Called(id, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
 func (m *MockUserRepository) Update(user *model.User) error {
 	args := m.Called(user)
 	return args.Error(0)
@@ -63,6 +103,7 @@ func TestAuthService_CreateUser(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotNil(t, user)
 		assert.Equal(t, email, user.Email)
+		assert.Equal(t, model.RoleViewer, user.Role)
 		assert.NotEmpty(t, user.ID)
 		assert.NotEmpty(t, user.PasswordHash)
 
@@ -192,3 +233,75 @@ func TestAuthService_GetUserByID(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+func TestAuthService_ListUsers(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewAuthService(mockRepo)
+
+	t.Run("page of users", func(t *testing.T) {
+		users := []model.User{{ID: uuid.New(), Email: "a@example.com"}, {ID: uuid.New(), Email: "b@example.com"}}
+		mockRepo.On("List", 2, 0).Return(users, 5, nil).Once()
+
+		page, total, err := service.ListUsers(2, 0)
+
+		assert.NoError(t, err)
+		assert.Equal(t, users, page)
+		assert.Equal(t, 5, total)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo.On("List", 20, 0).Return(nil, 0, repository.ErrUserNotFound).Once()
+
+		page, total, err := service.ListUsers(20, 0)
+
+		assert.ErrorIs(t, err, ErrUserNotFound)
+		var domain *Error
+		assert.ErrorAs(t, err, &domain)
+		assert.Nil(t, page)
+		assert.Zero(t, total)
+	})
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthService_UpdateUserRole(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewAuthService(mockRepo)
+
+	adminID, userID := uuid.New(), uuid.New()
+
+	t.Run("role updated", func(t *testing.T) {
+		updated := &model.User{ID: userID, Email: "test@example.com", Role: model.RoleOperator}
+		mockRepo.On("UpdateRole", userID, model.RoleOperator).Return(updated, nil).Once()
+
+		user, err := service.UpdateUserRole(userID, model.RoleOperator, adminID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, model.RoleOperator, user.Role)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("unknown role", func(t *testing.T) {
+		user, err := service.UpdateUserRole(userID, model.Role("owner"), adminID)
+
+		assert.ErrorIs(t, err, ErrInvalidRole)
+		assert.Nil(t, user)
+	})
+
+	t.Run("own role", func(t *testing.T) {
+		user, err := service.UpdateUserRole(adminID, model.RoleViewer, adminID)
+
+		assert.ErrorIs(t, err, ErrOwnRole)
+		assert.Nil(t, user)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockRepo.On("UpdateRole", userID, model.RoleAdmin).Return(nil, repository.ErrUserNotFound).Once()
+
+		user, err := service.UpdateUserRole(userID, model.RoleAdmin, adminID)
+
+		assert.ErrorIs(t, err, ErrUserNotFound)
+		assert.Nil(t, user)
+		mockRepo.AssertExpectations(t)
+	})
+}
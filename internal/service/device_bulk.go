@@ -0,0 +1,133 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+)
+
+// BulkOperationType is the kind of write a bulk operation performs
+type BulkOperationType string
+
+const (
+	BulkCreate BulkOperationType = "create"
+	BulkUpdate BulkOperationType = "update"
+	BulkDelete BulkOperationType = "delete"
+)
+
+var (
+	ErrInvalidBulkOperation = newError(KindInvalid, "invalid_bulk_operation", "invalid bulk operation")
+	ErrBulkAborted          = newError(KindAborted, "bulk_aborted", "not applied")
+)
+
+// BulkOperation is a single write in a bulk request. Device carries the
+// fields for create and update, ID and Version identify the device for
+// update and delete. Version is the one the caller read, and is required
+// so a bulk write never overwrites a change it hasn't seen.
+type BulkOperation struct {
+	Type    BulkOperationType
+	ID      string
+	Version int
+	Device  *model.Device
+}
+
+// BulkResult is the outcome of the operation with the same index
+type BulkResult struct {
+	Device *model.Device
+	Err    error
+}
+
+// BulkDevices applies the operations in order on behalf of the given user.
+// In atomic mode they share one transaction: the first failure rolls every
+// operation back and the others report ErrBulkAborted. Otherwise each one
+// stands on its own. The returned error is only set when the transaction
+// itself fails.
+func (s *DeviceService) BulkDevices(operations []BulkOperation, atomic bool, userID uuid.UUID) ([]BulkResult, error) {
+	results := make([]BulkResult, len(operations))
+
+	if !atomic {
+		for i, operation := range operations {
+			results[i] = s.applyBulkOperation(operation, userID)
+		}
+		return results, nil
+	}
+
+	failed := -1
+	err := s.repo.WithTx(func(repo repository.DeviceRepositoryInterface) error {
+		txService := *s
+		txService.repo = repo
+
+		for i, operation := range operations {
+			results[i] = txService.applyBulkOperation(operation, userID)
+			if results[i].Err != nil {
+				failed = i
+				return results[i].Err
+			}
+		}
+		return nil
+	})
+
+	if failed >= 0 {
+		for i := range results {
+			if i != failed {
+				results[i] = BulkResult{Err: fmt.Errorf("%w: operation %d failed", ErrBulkAborted, failed)}
+			}
+		}
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// applyBulkOperation runs one operation through the regular service methods
+// so bulk writes follow the same rules as single ones
+func (s *DeviceService) applyBulkOperation(operation BulkOperation, userID uuid.UUID) BulkResult {
+	switch operation.Type {
+	case BulkCreate:
+		if operation.Device == nil {
+			return BulkResult{Err: fmt.Errorf("%w: create needs a device", ErrInvalidBulkOperation)}
+		}
+
+		device, err := s.CreateDevice(operation.Device, userID)
+		return BulkResult{Device: device, Err: err}
+
+	case BulkUpdate:
+		if operation.Device == nil {
+			return BulkResult{Err: fmt.Errorf("%w: update needs a device", ErrInvalidBulkOperation)}
+		}
+
+		id, err := uuid.Parse(operation.ID)
+		if err != nil {
+			return BulkResult{Err: fmt.Errorf("%w: invalid id %q", ErrInvalidBulkOperation, operation.ID)}
+		}
+		if operation.Version < 1 {
+			return BulkResult{Err: ErrVersionRequired}
+		}
+
+		update := *operation.Device
+		update.ID = id
+		update.Version = operation.Version
+
+		// bulk operations take the manage permission
+		device, err := s.UpdateDevice(&update, userID, AllFields)
+		return BulkResult{Device: device, Err: err}
+
+	case BulkDelete:
+		if _, err := uuid.Parse(operation.ID); err != nil {
+			return BulkResult{Err: fmt.Errorf("%w: invalid id %q", ErrInvalidBulkOperation, operation.ID)}
+		}
+		if operation.Version < 1 {
+			return BulkResult{Err: ErrVersionRequired}
+		}
+
+		return BulkResult{Err: s.DeleteDevice(operation.ID, operation.Version, userID)}
+
+	default:
+		return BulkResult{Err: fmt.Errorf("%w: unknown op %q", ErrInvalidBulkOperation, operation.Type)}
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Test BulkDevices
+
+func TestBulkDevices_BestEffort(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
+	newDevice := &model.Device{Name: "Pixel 8", Brand: "Google", State: model.StateAvailable}
+	created := &model.Device{ID: uuid.New(), Name: "Pixel 8", Brand: "Google", State: model.StateAvailable, Version: 1}
+	missingID := uuid.New()
+	deletedID := uuid.New()
+
+	mockRepo.On("CreateDevice", newDevice, userID).Return(created, nil)
+	mockRepo.On("GetDeviceByID", missingID.String()).Return(nil, sql.ErrNoRows)
+	mockRepo.On("GetDeviceByID", deletedID.String()).Return(&model.Device{ID: deletedID, State: model.StateAvailable, Version: 2}, nil)
+	mockRepo.On("DeleteDevice", deletedID.String(), 2, userID).Return(nil)
+
+	results, err := service.BulkDevices([]BulkOperation{
+		{Type: BulkCreate, Device: newDevice},
+		{Type: BulkUpdate, ID: missingID.String(), Version: 1, Device: &model.Device{Name: "Galaxy S24", Brand: "Samsung"}},
+		{Type: BulkDelete, ID: deletedID.String(), Version: 2},
+	}, false, userID)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, created, results[0].Device)
+	assert.Error(t, results[1].Err)
+	assert.NoError(t, results[2].Err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "WithTx")
+}
+
+func TestBulkDevices_AtomicAbortsOnFailure(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
+	newDevice := &model.Device{Name: "Pixel 8", Brand: "Google", State: model.StateAvailable}
+	created := &model.Device{ID: uuid.New(), Name: "Pixel 8", Brand: "Google", State: model.StateAvailable, Version: 1}
+
+	mockRepo.On("WithTx").Return(nil)
+	mockRepo.On("CreateDevice", newDevice, userID).Return(created, nil)
+
+	results, err := service.BulkDevices([]BulkOperation{
+		{Type: BulkCreate, Device: newDevice},
+		{Type: BulkCreate, Device: &model.Device{Brand: "Google"}},
+		{Type: BulkCreate, Device: &model.Device{Name: "Pixel 9", Brand: "Google"}},
+	}, true, userID)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.ErrorIs(t, results[0].Err, ErrBulkAborted)
+	assert.Nil(t, results[0].Device)
+	assert.ErrorIs(t, results[1].Err, ErrDeviceNameRequired)
+	assert.ErrorIs(t, results[2].Err, ErrBulkAborted)
+	mockRepo.AssertNumberOfCalls(t, "CreateDevice", 1)
+}
+
+func TestBulkDevices_AtomicSuccess(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
+	newDevice := &model.Device{Name: "Pixel 8", Brand: "Google", State: model.StateAvailable}
+	created := &model.Device{ID: uuid.New(), Name: "Pixel 8", Brand: "Google", State: model.StateAvailable, Version: 1}
+
+	mockRepo.On("WithTx").Return(nil)
+	mockRepo.On("CreateDevice", newDevice, userID).Return(created, nil)
+
+	results, err := service.BulkDevices([]BulkOperation{{Type: BulkCreate, Device: newDevice}}, true, userID)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, created, results[0].Device)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBulkDevices_Versions(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
+	staleID := uuid.New()
+	mockRepo.On("GetDeviceByID", staleID.String()).Return(&model.Device{ID: staleID, Name: "Pixel 8", Brand: "Google", State: model.StateAvailable, Version: 3}, nil)
+
+	results, err := service.BulkDevices([]BulkOperation{
+		{Type: BulkUpdate, ID: uuid.NewString(), Device: &model.Device{Name: "Pixel 9", Brand: "Google"}},
+		{Type: BulkDelete, ID: uuid.NewString()},
+		{Type: BulkUpdate, ID: staleID.String(), Version: 2, Device: &model.Device{Name: "Pixel 9", Brand: "Google", State: model.StateAvailable}},
+	}, false, userID)
+
+	assert.NoError(t, err)
+	assert.ErrorIs(t, results[0].Err, ErrVersionRequired)
+	assert.ErrorIs(t, results[1].Err, ErrVersionRequired)
+	assert.ErrorIs(t, results[2].Err, ErrVersionMismatch)
+	mockRepo.AssertNotCalled(t, "UpdateDevice", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "DeleteDevice", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBulkDevices_InvalidOperation(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	results, err := service.BulkDevices([]BulkOperation{
+		{Type: "replace"},
+		{Type: BulkUpdate, ID: "not-a-uuid", Device: &model.Device{Name: "x", Brand: "y"}},
+		{Type: BulkCreate},
+	}, false, uuid.New())
+
+	assert.NoError(t, err)
+	for _, result := range results {
+		assert.ErrorIs(t, result.Err, ErrInvalidBulkOperation)
+	}
+	mockRepo.AssertNotCalled(t, "GetDeviceByID")
+}
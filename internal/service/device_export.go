@@ -0,0 +1,95 @@
+package service
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+)
+
+const exportPageSize = 200
+
+var exportHeader = []string{"id", "name", "brand", "state", "created_at", "updated_at", "deleted_at"}
+
+// formulaPrefixes are the leading characters that make spreadsheet apps
+// read a CSV cell as a formula. XLSX cells are written as strings, which are
+// never evaluated, so they need no escaping.
+const formulaPrefixes = "=+-@\t\r"
+
+// ExportDevices writes every device matching filter to w. The filter limit
+// and cursor are ignored; devices are read page by page so large
+// inventories are never held in memory at once.
+func (s *DeviceService) ExportDevices(w io.Writer, filter repository.DeviceFilter, format SpreadsheetFormat) error {
+	writer, err := newSpreadsheetWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.WriteRow(exportHeader); err != nil {
+		return err
+	}
+
+	filter.Limit = exportPageSize
+	filter.Cursor = ""
+	filter.IncludeTotal = false
+
+	for {
+		page, err := s.repo.GetDevices(filter)
+		if err != nil {
+			return err
+		}
+
+		for _, device := range page.Devices {
+			if err := writer.WriteRow(exportRow(device)); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	return writer.Close()
+}
+
+func exportRow(device model.Device) []string {
+	deletedAt := ""
+	if device.DeletedAt != nil {
+		deletedAt = device.DeletedAt.UTC().Format(time.RFC3339)
+	}
+
+	return []string{
+		device.ID.String(),
+		device.Name,
+		device.Brand,
+		device.State.String(),
+		device.CreatedAt.UTC().Format(time.RFC3339),
+		device.UpdatedAt.UTC().Format(time.RFC3339),
+		deletedAt,
+	}
+}
+
+// escapeCell keeps user supplied text from running as a formula when a CSV
+// export is opened, by quoting it the way spreadsheet apps do. Text that
+// already starts with a quote is quoted too, so unescapeCell can tell the
+// two apart.
+func escapeCell(value string) string {
+	if value != "" && (value[0] == '\'' || strings.ContainsRune(formulaPrefixes, rune(value[0]))) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeCell undoes escapeCell, so CSV exports import back unchanged. It
+// only strips a quote escapeCell could have added, so a quote typed at the
+// start of a hand written cell is kept.
+func unescapeCell(value string) string {
+	if len(value) > 1 && value[0] == '\'' && (value[1] == '\'' || strings.ContainsRune(formulaPrefixes, rune(value[1]))) {
+		return value[1:]
+	}
+	return value
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Test ExportDevices
+
+func TestExportDevices_CSVFollowsCursor(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	first := model.Device{ID: uuid.New(), Name: "ThinkPad X1", Brand: "Lenovo", State: model.StateAvailable, CreatedAt: createdAt, UpdatedAt: createdAt}
+	second := model.Device{ID: uuid.New(), Name: "Pixel 8", Brand: "Google", State: model.StateInUse, CreatedAt: createdAt, UpdatedAt: createdAt}
+
+	mockRepo.On("GetDevices", mock.MatchedBy(func(filter repository.DeviceFilter) bool {
+		return filter.Cursor == "" && filter.Limit == exportPageSize && filter.Brands[0] == "Lenovo"
+	})).Return(&repository.DevicePage{Devices: []model.Device{first}, NextCursor: "next"}, nil)
+	mockRepo.On("GetDevices", mock.MatchedBy(func(filter repository.DeviceFilter) bool {
+		return filter.Cursor == "next"
+	})).Return(&repository.DevicePage{Devices: []model.Device{second}}, nil)
+
+	var out bytes.Buffer
+	err := service.ExportDevices(&out, repository.DeviceFilter{Brands: []string{"Lenovo"}, Limit: 5, Cursor: "stale"}, FormatCSV)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "id,name,brand,state,created_at,updated_at,deleted_at\n"+
+		first.ID.String()+",ThinkPad X1,Lenovo,available,2024-03-01T12:00:00Z,2024-03-01T12:00:00Z,\n"+
+		second.ID.String()+",Pixel 8,Google,in-use,2024-03-01T12:00:00Z,2024-03-01T12:00:00Z,\n", out.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExportDevices_XLSXRoundTrip(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	device := model.Device{ID: uuid.New(), Name: "ThinkPad X1", Brand: "Lenovo", State: model.StateMaintenance}
+	mockRepo.On("GetDevices", mock.Anything).Return(&repository.DevicePage{Devices: []model.Device{device}}, nil)
+
+	var out bytes.Buffer
+	assert.NoError(t, service.ExportDevices(&out, repository.DeviceFilter{}, FormatXLSX))
+
+	result, err := service.ImportDevices(&out, FormatXLSX, false, uuid.New())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Valid)
+	assert.Equal(t, "ThinkPad X1", result.Preview[0].Name)
+	assert.Equal(t, model.StateMaintenance, result.Preview[0].State)
+}
+
+func TestExportDevices_EscapesFormulas(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	device := model.Device{ID: uuid.New(), Name: `=HYPERLINK("https://evil.example","x")`, Brand: "'=Lenovo", State: model.StateAvailable}
+	mockRepo.On("GetDevices", mock.Anything).Return(&repository.DevicePage{Devices: []model.Device{device}}, nil)
+
+	t.Run("csv", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.NoError(t, service.ExportDevices(&out, repository.DeviceFilter{}, FormatCSV))
+
+		records, err := csv.NewReader(bytes.NewReader(out.Bytes())).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, `'=HYPERLINK("https://evil.example","x")`, records[1][1])
+		assert.Equal(t, "''=Lenovo", records[1][2])
+
+		result, err := service.ImportDevices(&out, FormatCSV, false, uuid.New())
+		assert.NoError(t, err)
+		assert.Equal(t, device.Name, result.Preview[0].Name)
+		assert.Equal(t, device.Brand, result.Preview[0].Brand)
+	})
+
+	t.Run("xlsx cells are kept as they are", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.NoError(t, service.ExportDevices(&out, repository.DeviceFilter{}, FormatXLSX))
+
+		rows, err := readSpreadsheet(bytes.NewReader(out.Bytes()), FormatXLSX)
+		assert.NoError(t, err)
+		assert.Equal(t, device.Name, rows[1].Cells[1])
+		assert.Equal(t, device.Brand, rows[1].Cells[2])
+
+		result, err := service.ImportDevices(&out, FormatXLSX, false, uuid.New())
+		assert.NoError(t, err)
+		assert.Equal(t, device.Name, result.Preview[0].Name)
+		assert.Equal(t, device.Brand, result.Preview[0].Brand)
+	})
+}
+
+func TestEscapeCell(t *testing.T) {
+	for value, want := range map[string]string{
+		"Pixel 8":  "Pixel 8",
+		"":         "",
+		"'":        "''",
+		"=1+1":     "'=1+1",
+		"+1":       "'+1",
+		"-1":       "'-1",
+		"@SUM(A1)": "'@SUM(A1)",
+		"\tx":      "'\tx",
+		"\rx":      "'\rx",
+		"'quoted":  "''quoted",
+		"'=x":      "''=x",
+	} {
+		assert.Equal(t, want, escapeCell(value), value)
+		assert.Equal(t, value, unescapeCell(escapeCell(value)), value)
+	}
+}
+
+func TestUnescapeCell_KeepsHandWrittenQuotes(t *testing.T) {
+	for _, value := range []string{"'quoted", "'", "it's", "'42"} {
+		assert.Equal(t, value, unescapeCell(value), value)
+	}
+}
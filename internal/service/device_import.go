@@ -0,0 +1,225 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+const (
+	maxImportRows     = 5000
+	importPreviewRows = 10
+)
+
+var (
+	ErrUnreadableImport    = newError(KindValidation, "unreadable_import", "cannot read the file")
+	ErrEmptyImport         = newError(KindValidation, "empty_import", "the file has no header row")
+	ErrMissingImportColumn = newError(KindValidation, "missing_import_column", "required column not found")
+	ErrTooManyImportRows   = newError(KindValidation, "too_many_import_rows", fmt.Sprintf("at most %d rows can be imported at once", maxImportRows))
+)
+
+// importColumnAliases maps normalized header names to the device field they
+// fill. Headers are lowercased and spaces, dashes and underscores removed.
+var importColumnAliases = map[string]string{
+	"name":         "name",
+	"device":       "name",
+	"devicename":   "name",
+	"model":        "name",
+	"brand":        "brand",
+	"make":         "brand",
+	"manufacturer": "brand",
+	"vendor":       "brand",
+	"state":        "state",
+	"status":       "state",
+}
+
+// ImportColumn describes how a column of the file was mapped. Field is
+// empty for columns that are ignored.
+type ImportColumn struct {
+	Index  int    `json:"index" example:"0"`
+	Header string `json:"header" example:"Manufacturer"`
+	Field  string `json:"field,omitempty" example:"brand"`
+}
+
+// ImportRowError is a validation error on a row of the file. Row is the
+// spreadsheet row number, so the header is row 1.
+type ImportRowError struct {
+	Row     int    `json:"row" example:"4"`
+	Column  string `json:"column,omitempty" example:"State"`
+	Message string `json:"message" example:"invalid device state: broken"`
+}
+
+// DeviceImport is the outcome of reading an import file. Nothing is written
+// unless the import was committed and every row was valid.
+type DeviceImport struct {
+	Columns   []ImportColumn   `json:"columns"`
+	Rows      int              `json:"rows" example:"120"`
+	Valid     int              `json:"valid" example:"118"`
+	Errors    []ImportRowError `json:"errors"`
+	Preview   []model.Device   `json:"preview"`
+	Committed bool             `json:"committed" example:"false"`
+	Created   int              `json:"created" example:"0"`
+
+	rows    []int
+	devices []model.Device
+}
+
+// ImportDevices reads devices from a CSV or XLSX file. Without commit it
+// only reports the column mapping and row errors. With commit the devices
+// are created in a single transaction, and only if every row is valid.
+func (s *DeviceService) ImportDevices(r io.Reader, format SpreadsheetFormat, commit bool, userID uuid.UUID) (*DeviceImport, error) {
+	result, err := parseDeviceImport(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	if !commit || len(result.Errors) > 0 || len(result.devices) == 0 {
+		return result, nil
+	}
+
+	operations := make([]BulkOperation, len(result.devices))
+	for i := range result.devices {
+		operations[i] = BulkOperation{Type: BulkCreate, Device: &result.devices[i]}
+	}
+
+	results, err := s.BulkDevices(operations, true, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, item := range results {
+		if item.Err != nil && !errors.Is(item.Err, ErrBulkAborted) {
+			result.Errors = append(result.Errors, ImportRowError{Row: result.rows[i], Message: item.Err.Error()})
+		}
+	}
+	if len(result.Errors) > 0 {
+		result.Valid -= len(result.Errors)
+		return result, nil
+	}
+
+	result.Committed = true
+	result.Created = len(results)
+	return result, nil
+}
+
+// parseDeviceImport maps the header row to device fields and validates
+// every other row
+func parseDeviceImport(r io.Reader, format SpreadsheetFormat) (*DeviceImport, error) {
+	rows, err := readSpreadsheet(r, format)
+	if err != nil {
+		if errors.Is(err, ErrUnsupportedFormat) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w as %s: %v", ErrUnreadableImport, format, err)
+	}
+
+	if len(rows) == 0 {
+		return nil, ErrEmptyImport
+	}
+
+	result := &DeviceImport{Errors: []ImportRowError{}, Preview: []model.Device{}}
+	fields := map[string]int{}
+	for i, header := range rows[0].Cells {
+		column := ImportColumn{Index: i, Header: strings.TrimSpace(header)}
+		if field, ok := importColumnAliases[normalizeHeader(header)]; ok {
+			if _, seen := fields[field]; !seen {
+				column.Field = field
+				fields[field] = i
+			}
+		}
+		result.Columns = append(result.Columns, column)
+	}
+
+	for _, field := range []string{"name", "brand"} {
+		if _, ok := fields[field]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingImportColumn, field)
+		}
+	}
+
+	if len(rows)-1 > maxImportRows {
+		return nil, ErrTooManyImportRows
+	}
+
+	for _, row := range rows[1:] {
+		result.Rows++
+
+		device, rowErrors := parseImportRow(row.Cells, row.Number, fields, result.Columns)
+		if len(rowErrors) > 0 {
+			result.Errors = append(result.Errors, rowErrors...)
+			continue
+		}
+
+		result.Valid++
+		result.rows = append(result.rows, row.Number)
+		result.devices = append(result.devices, device)
+		if len(result.Preview) < importPreviewRows {
+			result.Preview = append(result.Preview, device)
+		}
+	}
+
+	return result, nil
+}
+
+// parseImportRow builds the device described by row. An empty state means
+// the device is available; in-use is refused, as only checkout sets it.
+func parseImportRow(row []string, rowNumber int, fields map[string]int, columns []ImportColumn) (model.Device, []ImportRowError) {
+	var rowErrors []ImportRowError
+
+	value := func(field string) (string, string) {
+		index, ok := fields[field]
+		if !ok {
+			return "", ""
+		}
+		if index >= len(row) {
+			return "", columns[index].Header
+		}
+		return strings.TrimSpace(row[index]), columns[index].Header
+	}
+
+	device := model.Device{State: model.StateAvailable}
+
+	name, header := value("name")
+	if name == "" {
+		rowErrors = append(rowErrors, ImportRowError{Row: rowNumber, Column: header, Message: ErrDeviceNameRequired.Error()})
+	}
+	device.Name = name
+
+	brand, header := value("brand")
+	if brand == "" {
+		rowErrors = append(rowErrors, ImportRowError{Row: rowNumber, Column: header, Message: ErrDeviceBrandRequired.Error()})
+	}
+	device.Brand = brand
+
+	if state, header := value("state"); state != "" {
+		parsed, err := model.ParseDeviceState(strings.ToLower(state))
+		if err != nil {
+			rowErrors = append(rowErrors, ImportRowError{Row: rowNumber, Column: header, Message: err.Error()})
+		} else if parsed == model.StateInUse {
+			rowErrors = append(rowErrors, ImportRowError{Row: rowNumber, Column: header, Message: ErrCheckoutRequired.Error()})
+		}
+		device.State = parsed
+	}
+
+	if len(rowErrors) > 0 {
+		return device, rowErrors
+	}
+
+	// the remaining rules, such as length limits, are the ones the API enforces
+	var invalid *model.ValidationError
+	if errors.As(model.Validate(&device), &invalid) {
+		for _, field := range invalid.Fields {
+			_, header := value(field.Field)
+			rowErrors = append(rowErrors, ImportRowError{Row: rowNumber, Column: header, Message: field.Message})
+		}
+	}
+
+	return device, rowErrors
+}
+
+func normalizeHeader(header string) string {
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(header)))
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xuri/excelize/v2"
+)
+
+// Test ImportDevices
+
+func TestImportDevices_PreviewMapsColumns(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	file := "\ufeffDevice Name,Manufacturer,Status,Serial\n" +
+		"ThinkPad X1,Lenovo,Maintenance,SN1\n" +
+		"\n" +
+		"iPhone 15,,available,SN2\n" +
+		"Pixel 8,Google,broken,SN3\n" +
+		"Fold," + strings.Repeat("a", 256) + "\n" +
+		"Pixel 9,Google,in-use,SN4\n" +
+		"Galaxy S24,Samsung\n"
+
+	result, err := service.ImportDevices(strings.NewReader(file), FormatCSV, false, uuid.New())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []ImportColumn{
+		{Index: 0, Header: "Device Name", Field: "name"},
+		{Index: 1, Header: "Manufacturer", Field: "brand"},
+		{Index: 2, Header: "Status", Field: "state"},
+		{Index: 3, Header: "Serial"},
+	}, result.Columns)
+	assert.Equal(t, 6, result.Rows)
+	assert.Equal(t, 2, result.Valid)
+	assert.Equal(t, []ImportRowError{
+		{Row: 4, Column: "Manufacturer", Message: "device brand cannot be empty"},
+		{Row: 5, Column: "Status", Message: "invalid device state: broken"},
+		{Row: 6, Column: "Manufacturer", Message: "brand must be at most 255 characters long"},
+		{Row: 7, Column: "Status", Message: "devices move in and out of use through checkout and checkin"},
+	}, result.Errors)
+	assert.Len(t, result.Preview, 2)
+	assert.Equal(t, model.StateMaintenance, result.Preview[0].State)
+	assert.Equal(t, model.StateAvailable, result.Preview[1].State)
+	assert.False(t, result.Committed)
+	mockRepo.AssertNotCalled(t, "CreateDevice")
+}
+
+func TestImportDevices_CommitCreatesInOneTransaction(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
+
+	mockRepo.On("WithTx").Return(nil)
+	mockRepo.On("CreateDevice", mock.AnythingOfType("*model.Device"), userID).
+		Return(&model.Device{ID: uuid.New()}, nil).Twice()
+
+	file := "name,brand\nThinkPad X1,Lenovo\nPixel 8,Google\n"
+
+	result, err := service.ImportDevices(strings.NewReader(file), FormatCSV, true, userID)
+
+	assert.NoError(t, err)
+	assert.True(t, result.Committed)
+	assert.Equal(t, 2, result.Created)
+	assert.Empty(t, result.Errors)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestImportDevices_CommitSkippedWithRowErrors(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	file := "name,brand\nThinkPad X1,Lenovo\n,Google\n"
+
+	result, err := service.ImportDevices(strings.NewReader(file), FormatCSV, true, uuid.New())
+
+	assert.NoError(t, err)
+	assert.False(t, result.Committed)
+	assert.Len(t, result.Errors, 1)
+	mockRepo.AssertNotCalled(t, "WithTx")
+}
+
+func TestImportDevices_FileErrors(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	_, err := service.ImportDevices(strings.NewReader(""), FormatCSV, false, uuid.New())
+	assert.ErrorIs(t, err, ErrEmptyImport)
+
+	_, err = service.ImportDevices(strings.NewReader("name,serial\nThinkPad,SN1\n"), FormatCSV, false, uuid.New())
+	assert.ErrorIs(t, err, ErrMissingImportColumn)
+
+	_, err = service.ImportDevices(strings.NewReader("not a workbook"), FormatXLSX, false, uuid.New())
+	assert.ErrorIs(t, err, ErrUnreadableImport)
+}
+
+func TestImportDevices_XLSX(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	workbook := excelize.NewFile()
+	workbook.SetSheetRow("Sheet1", "A1", &[]interface{}{"Brand", "Name"})
+	workbook.SetSheetRow("Sheet1", "A2", &[]interface{}{"Apple", "MacBook Pro"})
+	var file bytes.Buffer
+	assert.NoError(t, workbook.Write(&file))
+
+	result, err := service.ImportDevices(&file, FormatXLSX, false, uuid.New())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Valid)
+	assert.Equal(t, "MacBook Pro", result.Preview[0].Name)
+	assert.Equal(t, "Apple", result.Preview[0].Brand)
+}
+
+func TestImportDevices_XLSXUnzipLimit(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	// a cell that compresses to next to nothing but unzips past the limit
+	workbook := excelize.NewFile()
+	workbook.SetSheetRow("Sheet1", "A1", &[]interface{}{"Name", "Brand"})
+	workbook.SetSheetRow("Sheet1", "A2", &[]interface{}{strings.Repeat("a", 30000), "Apple"})
+	var file bytes.Buffer
+	assert.NoError(t, workbook.Write(&file))
+	assert.Less(t, file.Len(), 16<<10)
+
+	limit, xmlLimit := xlsxUnzipSizeLimit, xlsxUnzipXMLSizeLimit
+	xlsxUnzipSizeLimit, xlsxUnzipXMLSizeLimit = 16<<10, 16<<10
+	t.Cleanup(func() { xlsxUnzipSizeLimit, xlsxUnzipXMLSizeLimit = limit, xmlLimit })
+
+	_, err := service.ImportDevices(&file, FormatXLSX, false, uuid.New())
+
+	assert.ErrorIs(t, err, ErrUnreadableImport)
+}
+
+func TestSpreadsheetFormatFromFilename(t *testing.T) {
+	format, err := SpreadsheetFormatFromFilename("inventory.XLSX")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatXLSX, format)
+
+	_, err = SpreadsheetFormatFromFilename("inventory.ods")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+// PatchType identifies the format of a partial update document by its media type
+type PatchType string
+
+const (
+	MergePatch PatchType = "application/merge-patch+json"
+	JSONPatch  PatchType = "application/json-patch+json"
+)
+
+var (
+	ErrInvalidPatch     = newError(KindValidation, "invalid_patch", "invalid patch")
+	ErrUnsupportedPatch = newError(KindUnsupported, "unsupported_patch", "unsupported patch type")
+)
+
+// PatchDevice applies a merge patch (RFC 7396) or JSON patch (RFC 6902) to the
+// current representation of a device, then saves it through UpdateDevice so
+// the usual update rules still apply. A non-zero version is the version the
+// caller expects to patch.
+func (s *DeviceService) PatchDevice(id string, patch []byte, patchType PatchType, version int, userID uuid.UUID, fields DeviceFields) (*model.Device, error) {
+	existing, err := s.repo.GetDeviceByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+
+	if version != 0 && version != existing.Version {
+		return nil, ErrVersionMismatch
+	}
+
+	patched, err := applyDevicePatch(existing, patch, patchType)
+	if err != nil {
+		return nil, err
+	}
+
+	if patched.Name == "" {
+		return nil, fmt.Errorf("%w: device name cannot be empty", ErrInvalidPatch)
+	}
+	if patched.Brand == "" {
+		return nil, fmt.Errorf("%w: device brand cannot be empty", ErrInvalidPatch)
+	}
+
+	// the patch was computed against this version, so refuse to save it over
+	// a newer one even when the caller did not ask for a precondition
+	patched.Version = existing.Version
+
+	return s.UpdateDevice(patched, userID, fields)
+}
+
+// applyDevicePatch returns a copy of device with patch applied to its JSON form
+func applyDevicePatch(device *model.Device, patch []byte, patchType PatchType) (*model.Device, error) {
+	original, err := json.Marshal(device)
+	if err != nil {
+		return nil, err
+	}
+
+	var document []byte
+	switch patchType {
+	case MergePatch:
+		document, err = jsonpatch.MergePatch(original, patch)
+	case JSONPatch:
+		var operations jsonpatch.Patch
+		operations, err = jsonpatch.DecodePatch(patch)
+		if err == nil {
+			document, err = operations.Apply(original)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedPatch, patchType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err.Error())
+	}
+
+	var patched model.Device
+	if err := json.Unmarshal(document, &patched); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err.Error())
+	}
+
+	if patched.ID != device.ID {
+		return nil, fmt.Errorf("%w: id cannot be changed", ErrInvalidPatch)
+	}
+
+	return &patched, nil
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Test PatchDevice
+
+func TestPatchDevice_MergePatchState(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
+	existing := &model.Device{ID: uuid.New(), Name: "ThinkPad X1", Brand: "Lenovo", State: model.StateAvailable}
+
+	mockRepo.On("GetDeviceByID", existing.ID.String()).Return(existing, nil)
+	mockRepo.On("UpdateDevice", mock.MatchedBy(func(device *model.Device) bool {
+		return device.ID == existing.ID && device.Name == "ThinkPad X1" && device.Brand == "Lenovo" && device.State == model.StateMaintenance
+	}), userID).Return(&model.Device{ID: existing.ID, Name: "ThinkPad X1", Brand: "Lenovo", State: model.StateMaintenance}, nil)
+
+	result, err := service.PatchDevice(existing.ID.String(), []byte(`{"state":"maintenance"}`), MergePatch, 0, userID, AllFields)
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.StateMaintenance, result.State)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchDevice_JSONPatch(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
+	existing := &model.Device{ID: uuid.New(), Name: "ThinkPad X1", Brand: "Lenovo", State: model.StateAvailable}
+
+	mockRepo.On("GetDeviceByID", existing.ID.String()).Return(existing, nil)
+	mockRepo.On("UpdateDevice", mock.MatchedBy(func(device *model.Device) bool {
+		return device.Name == "ThinkPad X1 Carbon" && device.State == model.StateAvailable
+	}), userID).Return(&model.Device{ID: existing.ID, Name: "ThinkPad X1 Carbon", Brand: "Lenovo"}, nil)
+
+	patch := `[{"op":"test","path":"/name","value":"ThinkPad X1"},{"op":"replace","path":"/name","value":"ThinkPad X1 Carbon"}]`
+	result, err := service.PatchDevice(existing.ID.String(), []byte(patch), JSONPatch, 0, userID, AllFields)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ThinkPad X1 Carbon", result.Name)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchDevice_CannotRenameWhenInUse(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	existing := &model.Device{ID: uuid.New(), Name: "ThinkPad X1", Brand: "Lenovo", State: model.StateInUse}
+
+	mockRepo.On("GetDeviceByID", existing.ID.String()).Return(existing, nil)
+
+	_, err := service.PatchDevice(existing.ID.String(), []byte(`{"name":"Renamed"}`), MergePatch, 0, uuid.New(), AllFields)
+
+	assert.ErrorIs(t, err, ErrDeviceLockedInUse)
+	assert.Equal(t, "cannot update name: device is currently in use", err.Error())
+	mockRepo.AssertNotCalled(t, "UpdateDevice")
+}
+
+func TestPatchDevice_InvalidPatches(t *testing.T) {
+	tests := map[string]struct {
+		patch     string
+		patchType PatchType
+	}{
+		"malformed merge patch": {`{"state":`, MergePatch},
+		"unknown state":         {`{"state":"broken"}`, MergePatch},
+		"removed name":          {`{"name":null}`, MergePatch},
+		"changed id":            {`{"id":"` + uuid.NewString() + `"}`, MergePatch},
+		"failed test operation": {`[{"op":"test","path":"/name","value":"Other"}]`, JSONPatch},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockRepo := new(MockDeviceRepository)
+			mockReservations := new(MockReservationRepository)
+			service := NewDeviceService(mockRepo, mockReservations)
+
+			existing := &model.Device{ID: uuid.New(), Name: "ThinkPad X1", Brand: "Lenovo", State: model.StateAvailable}
+			mockRepo.On("GetDeviceByID", existing.ID.String()).Return(existing, nil)
+
+			_, err := service.PatchDevice(existing.ID.String(), []byte(tt.patch), tt.patchType, 0, uuid.New(), AllFields)
+
+			assert.ErrorIs(t, err, ErrInvalidPatch)
+			mockRepo.AssertNotCalled(t, "UpdateDevice")
+		})
+	}
+}
+
+func TestPatchDevice_UnsupportedType(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	existing := &model.Device{ID: uuid.New(), Name: "ThinkPad X1", Brand: "Lenovo"}
+	mockRepo.On("GetDeviceByID", existing.ID.String()).Return(existing, nil)
+
+	_, err := service.PatchDevice(existing.ID.String(), []byte(`{}`), PatchType("text/plain"), 0, uuid.New(), AllFields)
+
+	assert.ErrorIs(t, err, ErrUnsupportedPatch)
+}
+
+func TestPatchDevice_DeviceNotFound(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	mockRepo.On("GetDeviceByID", deviceID.String()).Return(nil, sql.ErrNoRows)
+
+	_, err := service.PatchDevice(deviceID.String(), []byte(`{"state":"lost"}`), MergePatch, 0, uuid.New(), AllFields)
+
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+}
+
+func TestPatchDevice_StaleVersion(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	existing := &model.Device{ID: uuid.New(), Name: "ThinkPad X1", Brand: "Lenovo", Version: 5}
+	mockRepo.On("GetDeviceByID", existing.ID.String()).Return(existing, nil)
+
+	_, err := service.PatchDevice(existing.ID.String(), []byte(`{"state":"lost"}`), MergePatch, 4, uuid.New(), AllFields)
+
+	assert.ErrorIs(t, err, ErrVersionMismatch)
+	mockRepo.AssertNotCalled(t, "UpdateDevice")
+}
+
+func TestPatchDevice_SavesAgainstPatchedVersion(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
+	existing := &model.Device{ID: uuid.New(), Name: "ThinkPad X1", Brand: "Lenovo", State: model.StateAvailable, Version: 5}
+
+	mockRepo.On("GetDeviceByID", existing.ID.String()).Return(existing, nil)
+	mockRepo.On("UpdateDevice", mock.MatchedBy(func(device *model.Device) bool {
+		return device.Version == 5
+	}), userID).Return(&model.Device{ID: existing.ID, Version: 6}, nil)
+
+	// a patch touching version must not be able to skip the check
+	result, err := service.PatchDevice(existing.ID.String(), []byte(`{"version":1}`), MergePatch, 0, userID, AllFields)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 6, result.Version)
+	mockRepo.AssertExpectations(t)
+}
@@ -2,80 +2,285 @@
 package service
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/loopsFreitag/DeviceRegistry/internal/model"
 	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
 )
 
+var (
+	ErrDeviceNotFound      = wrapError(KindNotFound, "device_not_found", repository.ErrDeviceNotFound)
+	ErrDeviceInUse         = wrapError(KindConflict, "device_in_use", repository.ErrDeviceInUse)
+	ErrDeviceNotAvailable  = wrapError(KindConflict, "device_not_available", repository.ErrDeviceNotAvailable)
+	ErrDeviceNotCheckedOut = wrapError(KindConflict, "device_not_checked_out", repository.ErrDeviceNotCheckedOut)
+	ErrNotAssignee         = wrapError(KindForbidden, "not_assignee", repository.ErrNotAssignee)
+	ErrInvalidSort         = wrapError(KindInvalid, "invalid_sort", repository.ErrInvalidSort)
+	ErrInvalidCursor       = wrapError(KindInvalid, "invalid_cursor", repository.ErrInvalidCursor)
+	ErrVersionMismatch     = wrapError(KindPrecondition, "version_mismatch", repository.ErrVersionMismatch)
+	ErrVersionRequired     = newError(KindPreconditionRequired, "precondition_required", "the version of the device is required")
+	ErrDeviceReserved      = wrapError(KindConflict, "device_reserved", repository.ErrDeviceReserved)
+	ErrDeviceLockedInUse   = newError(KindConflict, "device_locked", "device is currently in use")
+	ErrCheckoutRequired    = newError(KindConflict, "checkout_required", "devices move in and out of use through checkout and checkin")
+	ErrStateOnly           = newError(KindForbidden, "state_only", "only the state of the device can be changed")
+	ErrDeviceNameRequired  = newError(KindValidation, "device_name_required", "device name cannot be empty")
+	ErrDeviceBrandRequired = newError(KindValidation, "device_brand_required", "device brand cannot be empty")
+	ErrSearchQueryRequired = newError(KindInvalid, "search_query_required", "search query cannot be empty")
+	ErrNegativePurgeAge    = newError(KindInvalid, "negative_purge_age", "purge age cannot be negative")
+)
+
+// DeviceFields is what an update may change on a device
+type DeviceFields int
+
+const (
+	// StateOnly is what operators may change
+	StateOnly DeviceFields = iota
+	// AllFields also covers the name and brand, for those who manage devices
+	AllFields
+)
+
+// EditableFields returns what the caller in ctx may change on a device
+func EditableFields(ctx context.Context) DeviceFields {
+	if model.AllowedFromContext(ctx, model.PermissionManageDevices) {
+		return AllFields
+	}
+	return StateOnly
+}
+
 // i love how go auto matches interface with implementations
 type DeviceServiceInterface interface {
-	GetDevices(filter repository.DeviceFilter) ([]model.Device, error)
+	GetDevices(filter repository.DeviceFilter) (*repository.DevicePage, error)
 	GetDeviceByID(id string) (*model.Device, error)
-	CreateDevice(device *model.Device) (*model.Device, error)
-	UpdateDevice(device *model.Device) (*model.Device, error)
-	DeleteDevice(id string) error
+	SearchDevices(query string, limit int) ([]model.DeviceSearchResult, error)
+	CreateDevice(device *model.Device, userID uuid.UUID) (*model.Device, error)
+	UpdateDevice(device *model.Device, userID uuid.UUID, fields DeviceFields) (*model.Device, error)
+	DeleteDevice(id string, version int, userID uuid.UUID) error
+	GetDeletedDevices() ([]model.Device, error)
+	RestoreDevice(id string, userID uuid.UUID) (*model.Device, error)
+	PurgeDeletedDevices(olderThan time.Duration) (int64, error)
+	GetDeviceHistory(id string, limit, offset int) ([]model.DeviceEvent, int, error)
+	PatchDevice(id string, patch []byte, patchType PatchType, version int, userID uuid.UUID, fields DeviceFields) (*model.Device, error)
+	CheckoutDevice(id string, userID uuid.UUID) (*model.Assignment, error)
+	CheckinDevice(id string, userID uuid.UUID) (*model.Assignment, error)
+	BulkDevices(operations []BulkOperation, atomic bool, userID uuid.UUID) ([]BulkResult, error)
+	ImportDevices(r io.Reader, format SpreadsheetFormat, commit bool, userID uuid.UUID) (*DeviceImport, error)
+	ExportDevices(w io.Writer, filter repository.DeviceFilter, format SpreadsheetFormat) error
 }
 
 type DeviceService struct {
-	repo repository.DeviceRepositoryInterface
+	repo         repository.DeviceRepositoryInterface
+	reservations repository.ReservationRepositoryInterface
+	transitions  StateTransitions
 }
 
-func NewDeviceService(repo repository.DeviceRepositoryInterface) *DeviceService {
-	return &DeviceService{repo: repo}
+// DeviceServiceOption is a functional option to configure the DeviceService.
+type DeviceServiceOption func(*DeviceService)
+
+// WithStateTransitions replaces the default state transition table.
+func WithStateTransitions(transitions StateTransitions) DeviceServiceOption {
+	return func(s *DeviceService) {
+		s.transitions = transitions
+	}
+}
+
+func NewDeviceService(repo repository.DeviceRepositoryInterface, reservations repository.ReservationRepositoryInterface, opts ...DeviceServiceOption) *DeviceService {
+	s := &DeviceService{
+		repo:         repo,
+		reservations: reservations,
+		transitions:  DefaultStateTransitions,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-// GetDevices retrieves devices with optional filters
-func (s *DeviceService) GetDevices(filter repository.DeviceFilter) ([]model.Device, error) {
-	return s.repo.GetDevices(filter)
+// GetDevices retrieves a page of devices with optional filters
+func (s *DeviceService) GetDevices(filter repository.DeviceFilter) (*repository.DevicePage, error) {
+	page, err := s.repo.GetDevices(filter)
+	return page, domainError(err)
 }
 
 // GetDeviceByID retrieves a device by its ID
 func (s *DeviceService) GetDeviceByID(id string) (*model.Device, error) {
-	return s.repo.GetDeviceByID(id)
+	device, err := s.repo.GetDeviceByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeviceNotFound
+	}
+	return device, domainError(err)
 }
 
-// CreateDevice creates a new device
-func (s *DeviceService) CreateDevice(device *model.Device) (*model.Device, error) {
+// SearchDevices runs a ranked, typo tolerant search over the devices
+func (s *DeviceService) SearchDevices(query string, limit int) ([]model.DeviceSearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrSearchQueryRequired
+	}
+
+	results, err := s.repo.SearchDevices(query, limit)
+	return results, domainError(err)
+}
+
+// CreateDevice creates a new device on behalf of the given user
+func (s *DeviceService) CreateDevice(device *model.Device, userID uuid.UUID) (*model.Device, error) {
 	if device.Name == "" {
-		return nil, fmt.Errorf("device name cannot be empty")
+		return nil, ErrDeviceNameRequired
 	}
 	if device.Brand == "" {
-		return nil, fmt.Errorf("device brand cannot be empty")
+		return nil, ErrDeviceBrandRequired
+	}
+	if err := model.Validate(device); err != nil {
+		return nil, validationError(err)
+	}
+	if device.State == model.StateInUse {
+		return nil, fmt.Errorf("cannot create device: %w", ErrCheckoutRequired)
 	}
 
-	return s.repo.CreateDevice(device)
+	created, err := s.repo.CreateDevice(device, userID)
+	return created, domainError(err)
 }
 
-// UpdateDevice updates an existing device
-func (s *DeviceService) UpdateDevice(device *model.Device) (*model.Device, error) {
-	existingDevice, err := s.repo.GetDeviceByID(device.ID.String())
+// UpdateDevice updates an existing device on behalf of the given user, who
+// may only change the given fields. A non-zero device.Version is the version
+// the caller expects to overwrite.
+func (s *DeviceService) UpdateDevice(device *model.Device, userID uuid.UUID, fields DeviceFields) (*model.Device, error) {
+	if err := model.Validate(device); err != nil {
+		return nil, validationError(err)
+	}
+
+	existingDevice, err := s.GetDeviceByID(device.ID.String())
 	if err != nil {
-		return nil, fmt.Errorf("device not found")
+		return nil, err
+	}
+
+	if device.Version != 0 && device.Version != existingDevice.Version {
+		return nil, ErrVersionMismatch
+	}
+
+	if fields == StateOnly && (device.Name != existingDevice.Name || device.Brand != existingDevice.Brand) {
+		return nil, ErrStateOnly
 	}
 
 	if existingDevice.State == model.StateInUse {
 		if device.Name != existingDevice.Name {
-			return nil, fmt.Errorf("cannot update name: device is currently in use")
+			return nil, fmt.Errorf("cannot update name: %w", ErrDeviceLockedInUse)
 		}
 		if device.Brand != existingDevice.Brand {
-			return nil, fmt.Errorf("cannot update brand: device is currently in use")
+			return nil, fmt.Errorf("cannot update brand: %w", ErrDeviceLockedInUse)
 		}
 	}
 
-	return s.repo.UpdateDevice(device)
+	// Going in and out of use opens and closes an assignment, which only
+	// checkout and checkin do
+	if device.State != existingDevice.State &&
+		(device.State == model.StateInUse || existingDevice.State == model.StateInUse) {
+		return nil, fmt.Errorf("cannot change state: %w", ErrCheckoutRequired)
+	}
+
+	if err := s.transitions.Check(existingDevice.State, device.State); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.repo.UpdateDevice(device, userID)
+	return updated, domainError(err)
 }
 
-// DeleteDevice deletes a device by its ID
-func (s *DeviceService) DeleteDevice(id string) error {
-	device, err := s.repo.GetDeviceByID(id)
+// DeleteDevice deletes a device by its ID on behalf of the given user. A
+// non-zero version is the version the caller expects to delete.
+func (s *DeviceService) DeleteDevice(id string, version int, userID uuid.UUID) error {
+	device, err := s.GetDeviceByID(id)
 	if err != nil {
-		return fmt.Errorf("device not found")
+		return err
+	}
+
+	if version != 0 && version != device.Version {
+		return ErrVersionMismatch
 	}
 
 	if device.State == model.StateInUse {
-		return fmt.Errorf("cannot delete device: device is currently in use")
+		return fmt.Errorf("cannot delete device: %w", ErrDeviceLockedInUse)
+	}
+
+	return domainError(s.repo.DeleteDevice(id, version, userID))
+}
+
+// GetDeletedDevices lists the devices currently in the trash
+func (s *DeviceService) GetDeletedDevices() ([]model.Device, error) {
+	devices, err := s.repo.GetDeletedDevices()
+	return devices, domainError(err)
+}
+
+// RestoreDevice brings a device back from the trash on behalf of the given user
+func (s *DeviceService) RestoreDevice(id string, userID uuid.UUID) (*model.Device, error) {
+	device, err := s.repo.RestoreDevice(id, userID)
+	return device, domainError(err)
+}
+
+// PurgeDeletedDevices permanently removes devices trashed longer than olderThan
+func (s *DeviceService) PurgeDeletedDevices(olderThan time.Duration) (int64, error) {
+	if olderThan < 0 {
+		return 0, ErrNegativePurgeAge
+	}
+
+	purged, err := s.repo.PurgeDeletedDevices(olderThan)
+	return purged, domainError(err)
+}
+
+// GetDeviceHistory retrieves a page of the device audit trail
+func (s *DeviceService) GetDeviceHistory(id string, limit, offset int) ([]model.DeviceEvent, int, error) {
+	events, total, err := s.repo.GetDeviceHistory(id, limit, offset)
+	return events, total, domainError(err)
+}
+
+// CheckoutDevice assigns an available device to the user and marks it in use.
+// The repository only checks out available devices, under a row lock, so
+// the move out of available is the one the transition table must allow.
+func (s *DeviceService) CheckoutDevice(id string, userID uuid.UUID) (*model.Assignment, error) {
+	if err := s.transitions.Check(model.StateAvailable, model.StateInUse); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkReservation(id, userID); err != nil {
+		return nil, err
+	}
+
+	assignment, err := s.repo.CheckoutDevice(id, userID)
+	return assignment, domainError(err)
+}
+
+// CheckinDevice releases a device held by the user and marks it available.
+// Only devices in use are checked in, so that move must be allowed.
+func (s *DeviceService) CheckinDevice(id string, userID uuid.UUID) (*model.Assignment, error) {
+	if err := s.transitions.Check(model.StateInUse, model.StateAvailable); err != nil {
+		return nil, err
+	}
+
+	assignment, err := s.repo.CheckinDevice(id, userID)
+	return assignment, domainError(err)
+}
+
+// checkReservation fails when another user holds a reservation covering now.
+// It turns most requests away early; the repository checks again under the
+// device lock, which is what keeps a reservation made meanwhile from being
+// missed.
+func (s *DeviceService) checkReservation(id string, userID uuid.UUID) error {
+	reservation, err := s.reservations.GetActiveReservation(id, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, repository.ErrReservationNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if reservation.UserID != userID {
+		return ErrDeviceReserved
 	}
 
-	return s.repo.DeleteDevice(id)
+	return nil
 }
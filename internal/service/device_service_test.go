@@ -1,8 +1,10 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,9 +20,12 @@ type MockDeviceRepository struct {
 	mock.Mock
 }
 
-func (m *MockDeviceRepository) GetDevices(filter repository.DeviceFilter) ([]model.Device, error) {
+func (m *MockDeviceRepository) GetDevices(filter repository.DeviceFilter) (*repository.DevicePage, error) {
 	args := m.Called(filter)
-	return args.Get(0).([]model.Device), args.Error(1)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.DevicePage), args.Error(1)
 }
 
 func (m *MockDeviceRepository) GetDeviceByID(id string) (*model.Device, error) {
@@ -31,24 +36,84 @@ func (m *MockDeviceRepository) GetDeviceByID(id string) (*model.Device, error) {
 	return args.Get(0).(*model.Device), args.Error(1)
 }
 
-func (m *MockDeviceRepository) CreateDevice(device *model.Device) (*model.Device, error) {
-	args := m.Called(device)
+func (m *MockDeviceRepository) SearchDevices(text string, limit int) ([]model.DeviceSearchResult, error) {
+	args := m.Called(text, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.DeviceSearchResult), args.Error(1)
+}
+
+func (m *MockDeviceRepository) CreateDevice(device *model.Device, actorID uuid.UUID) (*model.Device, error) {
+	args := m.Called(device, actorID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.Device), args.Error(1)
 }
 
-func (m *MockDeviceRepository) UpdateDevice(device *model.Device) (*model.Device, error) {
-	args := m.Called(device)
+func (m *MockDeviceRepository) UpdateDevice(device *model.Device, actorID uuid.UUID) (*model.Device, error) {
+	args := m.Called(device, actorID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.Device), args.Error(1)
 }
 
-func (m *MockDeviceRepository) DeleteDevice(id string) error {
-	args := m.Called(id)
+func (m *MockDeviceRepository) DeleteDevice(id string, version int, actorID uuid.UUID) error {
+	args := m.Called(id, version, actorID)
+	return args.Error(0)
+}
+
+func (m *MockDeviceRepository) GetDeletedDevices() ([]model.Device, error) {
+	args := m.Called()
+	return args.Get(0).([]model.Device), args.Error(1)
+}
+
+func (m *MockDeviceRepository) RestoreDevice(id string, actorID uuid.UUID) (*model.Device, error) {
+	args := m.Called(id, actorID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Device), args.Error(1)
+}
+
+func (m *MockDeviceRepository) PurgeDeletedDevices(olderThan time.Duration) (int64, error) {
+	args := m.Called(olderThan)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockDeviceRepository) GetDeviceHistory(id string, limit, offset int) ([]model.DeviceEvent, int, error) {
+	args := m.Called(id, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).([]model.DeviceEvent), args.Int(1), args.Error(2)
+}
+
+func (m *MockDeviceRepository) CheckoutDevice(id string, userID uuid.UUID) (*model.Assignment, error) {
+	args := m.Called(id, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Assignment), args.Error(1)
+}
+
+func (m *MockDeviceRepository) CheckinDevice(id string, userID uuid.UUID) (*model.Assignment, error) {
+	args := m.Called(id, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Assignment), args.Error(1)
+}
+
+// WithTx runs fn against the mock itself, so expectations set on the
+// repository also cover calls made inside the transaction
+func (m *MockDeviceRepository) WithTx(fn func(repo repository.DeviceRepositoryInterface) error) error {
+	args := m.Called()
+	if err := fn(m); err != nil {
+		return err
+	}
 	return args.Error(0)
 }
 
@@ -56,7 +121,10 @@ func (m *MockDeviceRepository) DeleteDevice(id string) error {
 
 func TestCreateDevice_Success(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
 
 	device := &model.Device{
 		Name:  "iPhone 15",
@@ -73,9 +141,9 @@ func TestCreateDevice_Success(t *testing.T) {
 		UpdatedAt: time.Now(),
 	}
 
-	mockRepo.On("CreateDevice", device).Return(expectedDevice, nil)
+	mockRepo.On("CreateDevice", device, userID).Return(expectedDevice, nil)
 
-	result, err := service.CreateDevice(device)
+	result, err := service.CreateDevice(device, userID)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
@@ -85,7 +153,10 @@ func TestCreateDevice_Success(t *testing.T) {
 
 func TestCreateDevice_EmptyName(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
 
 	device := &model.Device{
 		Name:  "",
@@ -93,7 +164,7 @@ func TestCreateDevice_EmptyName(t *testing.T) {
 		State: model.StateAvailable,
 	}
 
-	result, err := service.CreateDevice(device)
+	result, err := service.CreateDevice(device, userID)
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -103,7 +174,10 @@ func TestCreateDevice_EmptyName(t *testing.T) {
 
 func TestCreateDevice_EmptyBrand(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
 
 	device := &model.Device{
 		Name:  "iPhone 15",
@@ -111,7 +185,7 @@ func TestCreateDevice_EmptyBrand(t *testing.T) {
 		State: model.StateAvailable,
 	}
 
-	result, err := service.CreateDevice(device)
+	result, err := service.CreateDevice(device, userID)
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -119,11 +193,32 @@ func TestCreateDevice_EmptyBrand(t *testing.T) {
 	mockRepo.AssertNotCalled(t, "CreateDevice")
 }
 
+func TestCreateDevice_InUse(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	device := &model.Device{
+		Name:  "iPhone 15",
+		Brand: "Apple",
+		State: model.StateInUse,
+	}
+
+	result, err := service.CreateDevice(device, uuid.New())
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrCheckoutRequired)
+	mockRepo.AssertNotCalled(t, "CreateDevice")
+}
+
 // Test UpdateDevice
 
 func TestUpdateDevice_Success(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
 
 	deviceID := uuid.New()
 	existingDevice := &model.Device{
@@ -137,13 +232,13 @@ func TestUpdateDevice_Success(t *testing.T) {
 		ID:    deviceID,
 		Name:  "iPhone 15",
 		Brand: "Apple",
-		State: model.StateInUse,
+		State: model.StateMaintenance,
 	}
 
 	mockRepo.On("GetDeviceByID", deviceID.String()).Return(existingDevice, nil)
-	mockRepo.On("UpdateDevice", updatedDevice).Return(updatedDevice, nil)
+	mockRepo.On("UpdateDevice", updatedDevice, userID).Return(updatedDevice, nil)
 
-	result, err := service.UpdateDevice(updatedDevice)
+	result, err := service.UpdateDevice(updatedDevice, userID, AllFields)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
@@ -153,7 +248,10 @@ func TestUpdateDevice_Success(t *testing.T) {
 
 func TestUpdateDevice_CannotUpdateNameWhenInUse(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
 
 	deviceID := uuid.New()
 	existingDevice := &model.Device{
@@ -172,7 +270,7 @@ func TestUpdateDevice_CannotUpdateNameWhenInUse(t *testing.T) {
 
 	mockRepo.On("GetDeviceByID", deviceID.String()).Return(existingDevice, nil)
 
-	result, err := service.UpdateDevice(updatedDevice)
+	result, err := service.UpdateDevice(updatedDevice, userID, AllFields)
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -182,7 +280,10 @@ func TestUpdateDevice_CannotUpdateNameWhenInUse(t *testing.T) {
 
 func TestUpdateDevice_CannotUpdateBrandWhenInUse(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
 
 	deviceID := uuid.New()
 	existingDevice := &model.Device{
@@ -201,7 +302,7 @@ func TestUpdateDevice_CannotUpdateBrandWhenInUse(t *testing.T) {
 
 	mockRepo.On("GetDeviceByID", deviceID.String()).Return(existingDevice, nil)
 
-	result, err := service.UpdateDevice(updatedDevice)
+	result, err := service.UpdateDevice(updatedDevice, userID, AllFields)
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -209,9 +310,64 @@ func TestUpdateDevice_CannotUpdateBrandWhenInUse(t *testing.T) {
 	mockRepo.AssertNotCalled(t, "UpdateDevice")
 }
 
-func TestUpdateDevice_CanUpdateStateWhenInUse(t *testing.T) {
+func TestUpdateDevice_StateOnly(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
+
+	deviceID := uuid.New()
+	existingDevice := &model.Device{
+		ID:    deviceID,
+		Name:  "iPhone 14",
+		Brand: "Apple",
+		State: model.StateAvailable,
+	}
+	mockRepo.On("GetDeviceByID", deviceID.String()).Return(existingDevice, nil)
+
+	t.Run("renaming is refused", func(t *testing.T) {
+		result, err := service.UpdateDevice(&model.Device{ID: deviceID, Name: "iPhone 15", Brand: "Apple", State: model.StateAvailable}, userID, StateOnly)
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, ErrStateOnly)
+		mockRepo.AssertNotCalled(t, "UpdateDevice", mock.Anything, mock.Anything)
+	})
+
+	t.Run("the state can change", func(t *testing.T) {
+		updatedDevice := &model.Device{ID: deviceID, Name: "iPhone 14", Brand: "Apple", State: model.StateMaintenance}
+		mockRepo.On("UpdateDevice", updatedDevice, userID).Return(updatedDevice, nil).Once()
+
+		result, err := service.UpdateDevice(updatedDevice, userID, StateOnly)
+
+		assert.NoError(t, err)
+		assert.Equal(t, model.StateMaintenance, result.State)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestEditableFields(t *testing.T) {
+	operator := &model.User{ID: uuid.New(), Role: model.RoleOperator}
+	admin := &model.User{ID: uuid.New(), Role: model.RoleAdmin}
+	operateToken := &model.APIToken{Scopes: []string{string(model.PermissionOperateDevices)}}
+
+	withUser := func(user *model.User) context.Context {
+		return context.WithValue(context.Background(), model.UserContextKey, user)
+	}
+
+	assert.Equal(t, StateOnly, EditableFields(context.Background()))
+	assert.Equal(t, StateOnly, EditableFields(withUser(operator)))
+	assert.Equal(t, AllFields, EditableFields(withUser(admin)))
+	// an admin token scoped to operating devices is held to the state
+	assert.Equal(t, StateOnly, EditableFields(context.WithValue(withUser(admin), model.APITokenContextKey, operateToken)))
+}
+
+func TestUpdateDevice_CannotLeaveInUse(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
 
 	deviceID := uuid.New()
 	existingDevice := &model.Device{
@@ -225,23 +381,24 @@ func TestUpdateDevice_CanUpdateStateWhenInUse(t *testing.T) {
 		ID:    deviceID,
 		Name:  "iPhone 14",
 		Brand: "Apple",
-		State: model.StateAvailable, // Only changing state
+		State: model.StateAvailable, // Only checkin frees the device
 	}
 
 	mockRepo.On("GetDeviceByID", deviceID.String()).Return(existingDevice, nil)
-	mockRepo.On("UpdateDevice", updatedDevice).Return(updatedDevice, nil)
 
-	result, err := service.UpdateDevice(updatedDevice)
+	result, err := service.UpdateDevice(updatedDevice, userID, AllFields)
 
-	assert.NoError(t, err)
-	assert.NotNil(t, result)
-	assert.Equal(t, model.StateAvailable, result.State)
-	mockRepo.AssertExpectations(t)
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrCheckoutRequired)
+	mockRepo.AssertNotCalled(t, "UpdateDevice")
 }
 
 func TestUpdateDevice_DeviceNotFound(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
 
 	deviceID := uuid.New()
 	updatedDevice := &model.Device{
@@ -253,7 +410,7 @@ func TestUpdateDevice_DeviceNotFound(t *testing.T) {
 
 	mockRepo.On("GetDeviceByID", deviceID.String()).Return(nil, sql.ErrNoRows)
 
-	result, err := service.UpdateDevice(updatedDevice)
+	result, err := service.UpdateDevice(updatedDevice, userID, AllFields)
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -261,11 +418,31 @@ func TestUpdateDevice_DeviceNotFound(t *testing.T) {
 	mockRepo.AssertNotCalled(t, "UpdateDevice")
 }
 
+func TestUpdateDevice_RepositoryFailure(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	device := &model.Device{ID: uuid.New(), Name: "iPhone 15", Brand: "Apple"}
+	outage := errors.New("connection refused")
+
+	mockRepo.On("GetDeviceByID", device.ID.String()).Return(nil, outage)
+
+	_, err := service.UpdateDevice(device, uuid.New(), AllFields)
+
+	assert.Equal(t, outage, err)
+	assert.NotErrorIs(t, err, ErrDeviceNotFound)
+	mockRepo.AssertNotCalled(t, "UpdateDevice")
+}
+
 // Test DeleteDevice
 
 func TestDeleteDevice_Success(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
 
 	deviceID := uuid.New()
 	device := &model.Device{
@@ -276,9 +453,9 @@ func TestDeleteDevice_Success(t *testing.T) {
 	}
 
 	mockRepo.On("GetDeviceByID", deviceID.String()).Return(device, nil)
-	mockRepo.On("DeleteDevice", deviceID.String()).Return(nil)
+	mockRepo.On("DeleteDevice", deviceID.String(), 0, userID).Return(nil)
 
-	err := service.DeleteDevice(deviceID.String())
+	err := service.DeleteDevice(deviceID.String(), 0, userID)
 
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
@@ -286,7 +463,10 @@ func TestDeleteDevice_Success(t *testing.T) {
 
 func TestDeleteDevice_CannotDeleteInUseDevice(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
 
 	deviceID := uuid.New()
 	device := &model.Device{
@@ -298,7 +478,7 @@ func TestDeleteDevice_CannotDeleteInUseDevice(t *testing.T) {
 
 	mockRepo.On("GetDeviceByID", deviceID.String()).Return(device, nil)
 
-	err := service.DeleteDevice(deviceID.String())
+	err := service.DeleteDevice(deviceID.String(), 0, userID)
 
 	assert.Error(t, err)
 	assert.Equal(t, "cannot delete device: device is currently in use", err.Error())
@@ -307,13 +487,16 @@ func TestDeleteDevice_CannotDeleteInUseDevice(t *testing.T) {
 
 func TestDeleteDevice_DeviceNotFound(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
 
 	deviceID := uuid.New()
 
 	mockRepo.On("GetDeviceByID", deviceID.String()).Return(nil, sql.ErrNoRows)
 
-	err := service.DeleteDevice(deviceID.String())
+	err := service.DeleteDevice(deviceID.String(), 0, userID)
 
 	assert.Error(t, err)
 	assert.Equal(t, "device not found", err.Error())
@@ -324,7 +507,8 @@ func TestDeleteDevice_DeviceNotFound(t *testing.T) {
 
 func TestGetDeviceByID_Success(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
 
 	deviceID := uuid.New()
 	expectedDevice := &model.Device{
@@ -346,7 +530,8 @@ func TestGetDeviceByID_Success(t *testing.T) {
 
 func TestGetDeviceByID_NotFound(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
 
 	deviceID := uuid.New()
 
@@ -363,7 +548,8 @@ func TestGetDeviceByID_NotFound(t *testing.T) {
 
 func TestGetDevices_Success(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
 
 	expectedDevices := []model.Device{
 		{
@@ -381,19 +567,20 @@ func TestGetDevices_Success(t *testing.T) {
 	}
 
 	filter := repository.DeviceFilter{}
-	mockRepo.On("GetDevices", filter).Return(expectedDevices, nil)
+	mockRepo.On("GetDevices", filter).Return(&repository.DevicePage{Devices: expectedDevices}, nil)
 
 	result, err := service.GetDevices(filter)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
-	assert.Len(t, result, 2)
+	assert.Len(t, result.Devices, 2)
 	mockRepo.AssertExpectations(t)
 }
 
 func TestGetDevices_WithBrandFilter(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
 
 	brand := "Apple"
 	expectedDevices := []model.Device{
@@ -405,40 +592,42 @@ func TestGetDevices_WithBrandFilter(t *testing.T) {
 		},
 	}
 
-	filter := repository.DeviceFilter{Brand: &brand}
-	mockRepo.On("GetDevices", filter).Return(expectedDevices, nil)
+	filter := repository.DeviceFilter{Brands: []string{brand}}
+	mockRepo.On("GetDevices", filter).Return(&repository.DevicePage{Devices: expectedDevices}, nil)
 
 	result, err := service.GetDevices(filter)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
-	assert.Len(t, result, 1)
-	assert.Equal(t, "Apple", result[0].Brand)
+	assert.Len(t, result.Devices, 1)
+	assert.Equal(t, "Apple", result.Devices[0].Brand)
 	mockRepo.AssertExpectations(t)
 }
 
 func TestGetDevices_EmptyResult(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
 
 	expectedDevices := []model.Device{}
 	filter := repository.DeviceFilter{}
-	mockRepo.On("GetDevices", filter).Return(expectedDevices, nil)
+	mockRepo.On("GetDevices", filter).Return(&repository.DevicePage{Devices: expectedDevices}, nil)
 
 	result, err := service.GetDevices(filter)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
-	assert.Len(t, result, 0)
+	assert.Len(t, result.Devices, 0)
 	mockRepo.AssertExpectations(t)
 }
 
 func TestGetDevices_RepositoryError(t *testing.T) {
 	mockRepo := new(MockDeviceRepository)
-	service := NewDeviceService(mockRepo)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
 
 	filter := repository.DeviceFilter{}
-	mockRepo.On("GetDevices", filter).Return([]model.Device{}, errors.New("database error"))
+	mockRepo.On("GetDevices", filter).Return(nil, errors.New("database error"))
 
 	_, err := service.GetDevices(filter)
 
@@ -446,3 +635,446 @@ func TestGetDevices_RepositoryError(t *testing.T) {
 	assert.Equal(t, "database error", err.Error())
 	mockRepo.AssertExpectations(t)
 }
+
+func TestGetDevices_Paginated(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	total := 3
+	filter := repository.DeviceFilter{Sort: repository.DeviceSort{Field: "name"}, Limit: 1, IncludeTotal: true}
+	page := &repository.DevicePage{
+		Devices:    []model.Device{{ID: uuid.New(), Name: "Galaxy S23", Brand: "Samsung"}},
+		NextCursor: "next",
+		Total:      &total,
+	}
+	mockRepo.On("GetDevices", filter).Return(page, nil)
+
+	result, err := service.GetDevices(filter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "next", result.NextCursor)
+	assert.Equal(t, 3, *result.Total)
+	mockRepo.AssertExpectations(t)
+}
+
+// Test CheckoutDevice
+
+func TestCheckoutDevice_Success(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+	assignment := &model.Assignment{
+		ID:           uuid.New(),
+		DeviceID:     deviceID,
+		UserID:       userID,
+		CheckedOutAt: time.Now(),
+	}
+
+	mockReservations.On("GetActiveReservation", deviceID.String(), mock.AnythingOfType("time.Time")).
+		Return(nil, repository.ErrReservationNotFound)
+	mockRepo.On("CheckoutDevice", deviceID.String(), userID).Return(assignment, nil)
+
+	result, err := service.CheckoutDevice(deviceID.String(), userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, userID, result.UserID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCheckoutDevice_AlreadyInUse(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+
+	mockReservations.On("GetActiveReservation", deviceID.String(), mock.AnythingOfType("time.Time")).
+		Return(nil, repository.ErrReservationNotFound)
+	mockRepo.On("CheckoutDevice", deviceID.String(), userID).Return(nil, repository.ErrDeviceInUse)
+
+	result, err := service.CheckoutDevice(deviceID.String(), userID)
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrDeviceInUse)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCheckoutDevice_TransitionNotAllowed(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations, WithStateTransitions(StateTransitions{
+		model.StateAvailable: {model.StateMaintenance},
+		model.StateInUse:     {model.StateAvailable},
+	}))
+
+	result, err := service.CheckoutDevice(uuid.New().String(), uuid.New())
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrInvalidStateTransition)
+	mockRepo.AssertNotCalled(t, "CheckoutDevice", mock.Anything, mock.Anything)
+}
+
+// Test CheckinDevice
+
+func TestCheckinDevice_Success(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+	checkedInAt := time.Now()
+	assignment := &model.Assignment{
+		ID:           uuid.New(),
+		DeviceID:     deviceID,
+		UserID:       userID,
+		CheckedOutAt: checkedInAt.Add(-time.Hour),
+		CheckedInAt:  &checkedInAt,
+	}
+
+	mockRepo.On("CheckinDevice", deviceID.String(), userID).Return(assignment, nil)
+
+	result, err := service.CheckinDevice(deviceID.String(), userID)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, result.CheckedInAt)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCheckinDevice_NotAssignee(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+
+	mockRepo.On("CheckinDevice", deviceID.String(), userID).Return(nil, repository.ErrNotAssignee)
+
+	result, err := service.CheckinDevice(deviceID.String(), userID)
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrNotAssignee)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCheckinDevice_TransitionNotAllowed(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations, WithStateTransitions(StateTransitions{
+		model.StateAvailable: {model.StateInUse},
+		model.StateInUse:     {model.StateMaintenance},
+	}))
+
+	result, err := service.CheckinDevice(uuid.New().String(), uuid.New())
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrInvalidStateTransition)
+	mockRepo.AssertNotCalled(t, "CheckinDevice", mock.Anything, mock.Anything)
+}
+
+func TestUpdateDevice_CannotEnterInUse(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	userID := uuid.New()
+
+	deviceID := uuid.New()
+	existingDevice := &model.Device{
+		ID:    deviceID,
+		Name:  "Pixel 8",
+		Brand: "Google",
+		State: model.StateAvailable,
+	}
+
+	updatedDevice := &model.Device{
+		ID:    deviceID,
+		Name:  "Pixel 8",
+		Brand: "Google",
+		State: model.StateInUse,
+	}
+
+	mockRepo.On("GetDeviceByID", deviceID.String()).Return(existingDevice, nil)
+
+	result, err := service.UpdateDevice(updatedDevice, userID, AllFields)
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrCheckoutRequired)
+	mockRepo.AssertNotCalled(t, "UpdateDevice")
+	mockReservations.AssertNotCalled(t, "GetActiveReservation")
+}
+
+func TestCheckoutDevice_ReservedByAnotherUser(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+	reservation := &model.Reservation{
+		ID:       uuid.New(),
+		DeviceID: deviceID,
+		UserID:   uuid.New(),
+		StartsAt: time.Now().Add(-time.Hour),
+		EndsAt:   time.Now().Add(time.Hour),
+	}
+
+	mockReservations.On("GetActiveReservation", deviceID.String(), mock.AnythingOfType("time.Time")).
+		Return(reservation, nil)
+
+	result, err := service.CheckoutDevice(deviceID.String(), userID)
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrDeviceReserved)
+	mockRepo.AssertNotCalled(t, "CheckoutDevice")
+}
+
+func TestCheckoutDevice_ReservedMeanwhile(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+
+	mockReservations.On("GetActiveReservation", deviceID.String(), mock.AnythingOfType("time.Time")).
+		Return(nil, repository.ErrReservationNotFound)
+	mockRepo.On("CheckoutDevice", deviceID.String(), userID).Return(nil, repository.ErrDeviceReserved)
+
+	result, err := service.CheckoutDevice(deviceID.String(), userID)
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrDeviceReserved)
+	assert.Equal(t, KindConflict, ErrorKindOf(err))
+}
+
+func TestCheckoutDevice_ReservedBySameUser(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+	reservation := &model.Reservation{
+		ID:       uuid.New(),
+		DeviceID: deviceID,
+		UserID:   userID,
+		StartsAt: time.Now().Add(-time.Hour),
+		EndsAt:   time.Now().Add(time.Hour),
+	}
+	assignment := &model.Assignment{
+		ID:       uuid.New(),
+		DeviceID: deviceID,
+		UserID:   userID,
+	}
+
+	mockReservations.On("GetActiveReservation", deviceID.String(), mock.AnythingOfType("time.Time")).
+		Return(reservation, nil)
+	mockRepo.On("CheckoutDevice", deviceID.String(), userID).Return(assignment, nil)
+
+	result, err := service.CheckoutDevice(deviceID.String(), userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, userID, result.UserID)
+	mockRepo.AssertExpectations(t)
+}
+
+// Test GetDeviceHistory
+
+func TestGetDeviceHistory_Success(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	events := []model.DeviceEvent{
+		{ID: uuid.New(), DeviceID: deviceID, Type: model.DeviceEventDeleted},
+		{ID: uuid.New(), DeviceID: deviceID, Type: model.DeviceEventCreated},
+	}
+
+	mockRepo.On("GetDeviceHistory", deviceID.String(), 20, 0).Return(events, 2, nil)
+
+	result, total, err := service.GetDeviceHistory(deviceID.String(), 20, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Len(t, result, 2)
+	mockRepo.AssertExpectations(t)
+}
+
+// Test trash
+
+func TestRestoreDevice_Success(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+	device := &model.Device{ID: deviceID, Name: "iPhone 15", Brand: "Apple", State: model.StateAvailable}
+
+	mockRepo.On("RestoreDevice", deviceID.String(), userID).Return(device, nil)
+
+	result, err := service.RestoreDevice(deviceID.String(), userID)
+
+	assert.NoError(t, err)
+	assert.Nil(t, result.DeletedAt)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestoreDevice_NotInTrash(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+
+	mockRepo.On("RestoreDevice", deviceID.String(), userID).Return(nil, repository.ErrDeviceNotFound)
+
+	result, err := service.RestoreDevice(deviceID.String(), userID)
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPurgeDeletedDevices_Success(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	mockRepo.On("PurgeDeletedDevices", 7*24*time.Hour).Return(int64(3), nil)
+
+	purged, err := service.PurgeDeletedDevices(7 * 24 * time.Hour)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPurgeDeletedDevices_NegativeAge(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	_, err := service.PurgeDeletedDevices(-time.Hour)
+
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "PurgeDeletedDevices")
+}
+
+// Test SearchDevices
+
+func TestSearchDevices_Success(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	results := []model.DeviceSearchResult{
+		{Device: model.Device{ID: uuid.New(), Name: "ThinkPad X1 Carbon", Brand: "Lenovo"}, Rank: 0.9},
+	}
+
+	mockRepo.On("SearchDevices", "Lenvo", 20).Return(results, nil)
+
+	result, err := service.SearchDevices("  Lenvo ", 20)
+
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSearchDevices_EmptyQuery(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	_, err := service.SearchDevices("   ", 20)
+
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "SearchDevices")
+}
+
+// Test optimistic concurrency
+
+func TestUpdateDevice_StaleVersion(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	existing := &model.Device{ID: deviceID, Name: "iPhone 15", Brand: "Apple", State: model.StateAvailable, Version: 3}
+	device := &model.Device{ID: deviceID, Name: "iPhone 15 Pro", Brand: "Apple", State: model.StateAvailable, Version: 2}
+
+	mockRepo.On("GetDeviceByID", deviceID.String()).Return(existing, nil)
+
+	_, err := service.UpdateDevice(device, uuid.New(), AllFields)
+
+	assert.ErrorIs(t, err, ErrVersionMismatch)
+	mockRepo.AssertNotCalled(t, "UpdateDevice")
+}
+
+func TestDeleteDevice_StaleVersion(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	existing := &model.Device{ID: deviceID, Name: "iPhone 15", Brand: "Apple", State: model.StateAvailable, Version: 3}
+
+	mockRepo.On("GetDeviceByID", deviceID.String()).Return(existing, nil)
+
+	err := service.DeleteDevice(deviceID.String(), 1, uuid.New())
+
+	assert.ErrorIs(t, err, ErrVersionMismatch)
+	mockRepo.AssertNotCalled(t, "DeleteDevice")
+}
+
+func TestDeleteDevice_MatchingVersion(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+	existing := &model.Device{ID: deviceID, Name: "iPhone 15", Brand: "Apple", State: model.StateAvailable, Version: 3}
+
+	mockRepo.On("GetDeviceByID", deviceID.String()).Return(existing, nil)
+	mockRepo.On("DeleteDevice", deviceID.String(), 3, userID).Return(nil)
+
+	err := service.DeleteDevice(deviceID.String(), 3, userID)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateDevice_ValidationFailed(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	device := &model.Device{
+		Name:  strings.Repeat("a", 256),
+		Brand: "Apple",
+		State: model.DeviceState(9),
+	}
+
+	result, err := service.CreateDevice(device, uuid.New())
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrValidationFailed)
+	assert.Equal(t, KindValidation, ErrorKindOf(err))
+
+	var invalid *model.ValidationError
+	assert.True(t, errors.As(err, &invalid))
+	assert.Len(t, invalid.Fields, 2)
+	assert.Equal(t, "name", invalid.Fields[0].Field)
+	assert.Equal(t, "state", invalid.Fields[1].Field)
+	mockRepo.AssertNotCalled(t, "CreateDevice", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var ErrUnsupportedFormat = newError(KindInvalid, "unsupported_format", "unsupported spreadsheet format")
+
+// SpreadsheetFormat is a file format devices can be imported from and
+// exported to
+type SpreadsheetFormat string
+
+const (
+	FormatCSV  SpreadsheetFormat = "csv"
+	FormatXLSX SpreadsheetFormat = "xlsx"
+)
+
+const xlsxSheet = "Devices"
+
+// xlsxUnzipSizeLimit caps how large an uploaded workbook may get once
+// unzipped, so a small upload can't inflate without bound, and
+// xlsxUnzipXMLSizeLimit how much of it is unzipped into memory rather than
+// a temporary file
+var (
+	xlsxUnzipSizeLimit    int64 = 64 << 20
+	xlsxUnzipXMLSizeLimit int64 = 16 << 20
+)
+
+// ParseSpreadsheetFormat parses a format name such as "csv" or "xlsx"
+func ParseSpreadsheetFormat(str string) (SpreadsheetFormat, error) {
+	switch format := SpreadsheetFormat(strings.ToLower(strings.TrimSpace(str))); format {
+	case FormatCSV, FormatXLSX:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, str)
+	}
+}
+
+// SpreadsheetFormatFromFilename picks the format from a file extension
+func SpreadsheetFormatFromFilename(filename string) (SpreadsheetFormat, error) {
+	return ParseSpreadsheetFormat(strings.TrimPrefix(filepath.Ext(filename), "."))
+}
+
+// ContentType is the media type files of this format are served with
+func (f SpreadsheetFormat) ContentType() string {
+	if f == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// spreadsheetRow is a row read from a file. Number is the row as the user
+// sees it in a spreadsheet program, starting at 1.
+type spreadsheetRow struct {
+	Number int
+	Cells  []string
+}
+
+// readSpreadsheet returns every non-blank row of a CSV file or of the first
+// sheet of an XLSX workbook. Rows may have different lengths. CSV cells are
+// unescaped the way the CSV writer escapes them.
+func readSpreadsheet(r io.Reader, format SpreadsheetFormat) ([]spreadsheetRow, error) {
+	var rows []spreadsheetRow
+
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		for {
+			cells, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			for i := range cells {
+				cells[i] = unescapeCell(cells[i])
+			}
+			line, _ := reader.FieldPos(0)
+			rows = append(rows, spreadsheetRow{Number: line, Cells: cells})
+		}
+		// Excel prefixes the CSV files it saves with a byte order mark
+		if len(rows) > 0 && len(rows[0].Cells) > 0 {
+			rows[0].Cells[0] = strings.TrimPrefix(rows[0].Cells[0], "\ufeff")
+		}
+
+	case FormatXLSX:
+		workbook, err := excelize.OpenReader(r, excelize.Options{
+			UnzipSizeLimit:    xlsxUnzipSizeLimit,
+			UnzipXMLSizeLimit: xlsxUnzipXMLSizeLimit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer workbook.Close()
+
+		sheets := workbook.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, nil
+		}
+		cells, err := workbook.GetRows(sheets[0])
+		if err != nil {
+			return nil, err
+		}
+		for i, row := range cells {
+			rows = append(rows, spreadsheetRow{Number: i + 1, Cells: row})
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+
+	filtered := rows[:0]
+	for _, row := range rows {
+		if !blankRow(row.Cells) {
+			filtered = append(filtered, row)
+		}
+	}
+	return filtered, nil
+}
+
+func blankRow(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// spreadsheetWriter writes rows one at a time and flushes them on Close
+type spreadsheetWriter interface {
+	WriteRow(row []string) error
+	Close() error
+}
+
+func newSpreadsheetWriter(w io.Writer, format SpreadsheetFormat) (spreadsheetWriter, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{writer: csv.NewWriter(w)}, nil
+
+	case FormatXLSX:
+		workbook := excelize.NewFile()
+		if err := workbook.SetSheetName("Sheet1", xlsxSheet); err != nil {
+			return nil, err
+		}
+		stream, err := workbook.NewStreamWriter(xlsxSheet)
+		if err != nil {
+			return nil, err
+		}
+		return &xlsxWriter{out: w, workbook: workbook, stream: stream}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+// WriteRow writes row with every cell escaped, so none runs as a formula
+// when the file is opened in a spreadsheet app
+func (c *csvWriter) WriteRow(row []string) error {
+	escaped := make([]string, len(row))
+	for i, value := range row {
+		escaped[i] = escapeCell(value)
+	}
+	return c.writer.Write(escaped)
+}
+
+func (c *csvWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+type xlsxWriter struct {
+	out      io.Writer
+	workbook *excelize.File
+	stream   *excelize.StreamWriter
+	rows     int
+}
+
+func (x *xlsxWriter) WriteRow(row []string) error {
+	x.rows++
+	cell, err := excelize.CoordinatesToCellName(1, x.rows)
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(row))
+	for i, value := range row {
+		values[i] = value
+	}
+	return x.stream.SetRow(cell, values)
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.workbook.Close()
+
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+	return x.workbook.Write(x.out)
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// subscriptionBuffer is how many changes a subscriber may fall behind
+	// before it is dropped. Dropped subscribers resume from the change log.
+	subscriptionBuffer = 64
+	// settleInterval is how often the stream checks whether the changes it
+	// waits on have settled, and retries after a failure
+	settleInterval = 100 * time.Millisecond
+	// followPageSize is how many changes the stream reads from the log at once
+	followPageSize = 200
+)
+
+// settleWarnAfter is how long the stream waits on a horizon before warning
+// that an open transaction holds it back
+var settleWarnAfter = 30 * time.Second
+
+// DeviceChangeSource delivers the device changes written by any replica. It
+// calls reset when changes may have been missed.
+type DeviceChangeSource interface {
+	Listen(ctx context.Context, publish func(model.DeviceChange), reset func()) error
+}
+
+type DeviceStreamInterface interface {
+	Ready() <-chan struct{}
+	Subscribe(filter repository.DeviceChangeFilter) *DeviceSubscription
+	Unsubscribe(sub *DeviceSubscription)
+	GetChangesSince(seq int64, filter repository.DeviceChangeFilter, limit int) ([]model.DeviceChange, error)
+}
+
+// DeviceStream fans the device changes written by any replica out to the
+// subscribers of this replica, in sequence order. Sequence numbers are taken
+// before commit, so transactions may commit out of order: a change is only
+// published, or replayed, once every change before it has committed or
+// rolled back. Subscribers can then skip what they already got by sequence
+// number and resume after the last one without missing anything.
+type DeviceStream struct {
+	repo  repository.DeviceChangeRepositoryInterface
+	wake  chan struct{}
+	ready chan struct{}
+
+	mu   sync.Mutex
+	subs map[*DeviceSubscription]struct{}
+	// settled is the sequence number up to which every change is published
+	settled int64
+}
+
+// DeviceSubscription receives the changes matching its filter. Its channel
+// is closed when the subscriber is dropped, after which it should resume
+// from the last change it got.
+type DeviceSubscription struct {
+	filter  repository.DeviceChangeFilter
+	changes chan model.DeviceChange
+}
+
+// Changes returns the channel the subscription receives changes on
+func (s *DeviceSubscription) Changes() <-chan model.DeviceChange {
+	return s.changes
+}
+
+func NewDeviceStream(repo repository.DeviceChangeRepositoryInterface) *DeviceStream {
+	return &DeviceStream{
+		repo:  repo,
+		wake:  make(chan struct{}, 1),
+		ready: make(chan struct{}),
+		subs:  map[*DeviceSubscription]struct{}{},
+	}
+}
+
+// Run follows the change log until ctx is done or source stops, then drops
+// every subscriber. The changes source announces only tell the stream when
+// to look; what is published is read back from the log.
+func (s *DeviceStream) Run(ctx context.Context, source DeviceChangeSource) error {
+	defer s.dropAll()
+
+	ctx, cancel := context.WithCancel(ctx)
+	followed := make(chan struct{})
+	go func() {
+		defer close(followed)
+		s.follow(ctx)
+	}()
+
+	err := source.Listen(ctx, func(model.DeviceChange) { s.poke() }, s.poke)
+	cancel()
+	<-followed
+
+	return err
+}
+
+// Ready is closed once the stream knows where the change log stands.
+// Subscribers must wait for it before catching up, or the replay would stop
+// short of what the stream goes on to publish.
+func (s *DeviceStream) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Subscribe registers a subscriber for the changes matching filter
+func (s *DeviceStream) Subscribe(filter repository.DeviceChangeFilter) *DeviceSubscription {
+	sub := &DeviceSubscription{
+		filter:  filter,
+		changes: make(chan model.DeviceChange, subscriptionBuffer),
+	}
+
+	s.mu.Lock()
+	s.subs[sub] = struct{}{}
+	s.mu.Unlock()
+
+	return sub
+}
+
+// Unsubscribe removes a subscriber, if it was not dropped already
+func (s *DeviceStream) Unsubscribe(sub *DeviceSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drop(sub)
+}
+
+// publish hands change to the matching subscribers and marks it settled.
+// Subscribers whose buffer is full are dropped rather than holding up the
+// others.
+func (s *DeviceStream) publish(change model.DeviceChange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.settled = change.Seq
+	for sub := range s.subs {
+		if !sub.filter.Matches(change) {
+			continue
+		}
+
+		select {
+		case sub.changes <- change:
+		default:
+			s.drop(sub)
+		}
+	}
+}
+
+// GetChangesSince retrieves the logged changes matching filter after seq, so
+// a subscriber can catch up on what it missed. It stops at the last settled
+// change; the subscription delivers the rest.
+func (s *DeviceStream) GetChangesSince(seq int64, filter repository.DeviceChangeFilter, limit int) ([]model.DeviceChange, error) {
+	s.mu.Lock()
+	settled := s.settled
+	s.mu.Unlock()
+
+	return s.repo.GetChangesSince(seq, settled, filter, limit)
+}
+
+// poke tells follow the change log may have grown
+func (s *DeviceStream) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// follow publishes the logged changes up to each horizon once it has
+// settled. The first horizon only marks where the stream starts. Nothing
+// after a horizon is published while a transaction that wrote devices before
+// it is still open, so a long one is logged once it held the stream back for
+// settleWarnAfter.
+func (s *DeviceStream) follow(ctx context.Context) {
+	ticker := time.NewTicker(settleInterval)
+	defer ticker.Stop()
+
+	var pending *repository.ChangeHorizon
+	var waitingSince time.Time
+	warned := false
+	stale := true
+	for {
+		if pending == nil && stale {
+			horizon, err := s.repo.GetChangeHorizon()
+			if err != nil {
+				log.Error("failed to read the device change horizon. err: ", err.Error())
+			} else {
+				pending, stale = &horizon, false
+				waitingSince, warned = time.Now(), false
+			}
+		}
+
+		if pending != nil {
+			settled, err := s.repo.HorizonSettled(*pending)
+			if err != nil {
+				log.Error("failed to check the device change horizon. err: ", err.Error())
+			} else if !settled && !warned && time.Since(waitingSince) >= settleWarnAfter {
+				log.Warnf("device changes up to %d have waited %s on open transactions %v; the stream is held back until they end",
+					pending.Seq, time.Since(waitingSince).Round(time.Second), pending.Writers)
+				warned = true
+			} else if settled {
+				if warned {
+					log.Infof("device changes up to %d settled after %s", pending.Seq, time.Since(waitingSince).Round(time.Second))
+					warned = false
+				}
+				if err := s.catchUp(pending.Seq); err != nil {
+					log.Error("failed to read device changes. err: ", err.Error())
+				} else {
+					pending = nil
+					if stale {
+						continue
+					}
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+			stale = true
+		case <-ticker.C:
+		}
+	}
+}
+
+// catchUp publishes the logged changes after the last settled one up to
+// until, which must have settled
+func (s *DeviceStream) catchUp(until int64) error {
+	select {
+	case <-s.ready:
+	default:
+		s.mu.Lock()
+		s.settled = until
+		s.mu.Unlock()
+		close(s.ready)
+		return nil
+	}
+
+	s.mu.Lock()
+	seq := s.settled
+	s.mu.Unlock()
+
+	for seq < until {
+		changes, err := s.repo.GetChangesSince(seq, until, repository.DeviceChangeFilter{}, followPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			s.publish(change)
+		}
+		if len(changes) < followPageSize {
+			break
+		}
+		seq = changes[len(changes)-1].Seq
+	}
+
+	// rolled back changes leave gaps that are settled too
+	s.mu.Lock()
+	if s.settled < until {
+		s.settled = until
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// dropAll drops every subscriber
+func (s *DeviceStream) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sub := range s.subs {
+		s.drop(sub)
+	}
+}
+
+// drop removes sub and closes its channel. The caller holds s.mu.
+func (s *DeviceStream) drop(sub *DeviceSubscription) {
+	if _, ok := s.subs[sub]; !ok {
+		return
+	}
+	delete(s.subs, sub)
+	close(sub.changes)
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockDeviceChangeRepository is a mock implementation of the device change repository
+type MockDeviceChangeRepository struct {
+	mock.Mock
+}
+
+func (m *MockDeviceChangeRepository) GetChangesSince(seq, until int64, filter repository.DeviceChangeFilter, limit int) ([]model.DeviceChange, error) {
+	args := m.Called(seq, until, filter, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.DeviceChange), args.Error(1)
+}
+
+func (m *MockDeviceChangeRepository) GetChangeHorizon() (repository.ChangeHorizon, error) {
+	args := m.Called()
+	return args.Get(0).(repository.ChangeHorizon), args.Error(1)
+}
+
+func (m *MockDeviceChangeRepository) HorizonSettled(horizon repository.ChangeHorizon) (bool, error) {
+	args := m.Called(horizon)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDeviceChangeRepository) DeleteChangesBefore(cutoff time.Time) (int64, error) {
+	args := m.Called(cutoff)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// fakeChangeSource hands the changes it is given to the stream it runs
+type fakeChangeSource struct {
+	changes chan model.DeviceChange
+	resets  chan struct{}
+}
+
+func (f *fakeChangeSource) Listen(ctx context.Context, publish func(model.DeviceChange), reset func()) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case change := <-f.changes:
+			publish(change)
+		case <-f.resets:
+			reset()
+		}
+	}
+}
+
+func deviceChange(seq int64, brand string, state model.DeviceState) model.DeviceChange {
+	return model.DeviceChange{
+		Seq:    seq,
+		Type:   model.DeviceEventUpdated,
+		Device: model.Device{Name: "Device", Brand: brand, State: state},
+	}
+}
+
+// Test publish
+
+func TestDeviceStream_PublishFiltersChanges(t *testing.T) {
+	stream := NewDeviceStream(new(MockDeviceChangeRepository))
+
+	all := stream.Subscribe(repository.DeviceChangeFilter{})
+	apple := stream.Subscribe(repository.DeviceChangeFilter{Brands: []string{"Apple"}})
+
+	stream.publish(deviceChange(1, "Google", model.StateAvailable))
+	stream.publish(deviceChange(2, "Apple", model.StateInUse))
+
+	assert.Equal(t, int64(1), (<-all.Changes()).Seq)
+	assert.Equal(t, int64(2), (<-all.Changes()).Seq)
+	assert.Equal(t, int64(2), (<-apple.Changes()).Seq)
+	assert.Len(t, apple.Changes(), 0)
+}
+
+func TestDeviceStream_DropsSlowSubscribers(t *testing.T) {
+	stream := NewDeviceStream(new(MockDeviceChangeRepository))
+
+	slow := stream.Subscribe(repository.DeviceChangeFilter{})
+
+	for seq := int64(1); seq <= subscriptionBuffer+1; seq++ {
+		stream.publish(deviceChange(seq, "Apple", model.StateAvailable))
+	}
+
+	received := 0
+	for range slow.Changes() {
+		received++
+	}
+	assert.Equal(t, subscriptionBuffer, received)
+
+	// unsubscribing a dropped subscriber is harmless
+	stream.Unsubscribe(slow)
+}
+
+// Test Run
+
+func TestDeviceStream_Run(t *testing.T) {
+	mockRepo := new(MockDeviceChangeRepository)
+	stream := NewDeviceStream(mockRepo)
+	source := &fakeChangeSource{changes: make(chan model.DeviceChange), resets: make(chan struct{})}
+
+	start := repository.ChangeHorizon{Seq: 5, Writers: []string{"3/17"}}
+	next := repository.ChangeHorizon{Seq: 8, Writers: []string{"3/21", "5/2"}}
+	all := repository.DeviceChangeFilter{}
+
+	// the stream starts after the changes already settled
+	mockRepo.On("GetChangeHorizon").Return(start, nil).Once()
+	mockRepo.On("HorizonSettled", start).Return(true, nil).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- stream.Run(ctx, source) }()
+	<-stream.Ready()
+
+	// 8 committed first, but 6 and 7 were still in flight; 7 rolled back
+	mockRepo.On("GetChangeHorizon").Return(next, nil)
+	mockRepo.On("HorizonSettled", next).Return(false, nil).Once()
+	mockRepo.On("HorizonSettled", next).Return(true, nil)
+	mockRepo.On("GetChangesSince", int64(5), int64(8), all, followPageSize).
+		Return([]model.DeviceChange{deviceChange(6, "Apple", model.StateAvailable), deviceChange(8, "Apple", model.StateInUse)}, nil).Once()
+
+	sub := stream.Subscribe(all)
+	source.changes <- deviceChange(8, "Apple", model.StateInUse)
+
+	assert.Equal(t, int64(6), (<-sub.Changes()).Seq)
+	assert.Equal(t, int64(8), (<-sub.Changes()).Seq)
+
+	// a reset only makes the stream look again
+	source.resets <- struct{}{}
+	assert.Eventually(t, func() bool {
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
+		return stream.settled == 8
+	}, time.Second, 10*time.Millisecond)
+	assert.Len(t, sub.Changes(), 0)
+
+	// stopping the stream drops subscribers
+	cancel()
+	assert.NoError(t, <-done)
+	_, open := <-sub.Changes()
+	assert.False(t, open)
+}
+
+func TestDeviceStream_RunSettlesGaps(t *testing.T) {
+	mockRepo := new(MockDeviceChangeRepository)
+	stream := NewDeviceStream(mockRepo)
+	source := &fakeChangeSource{changes: make(chan model.DeviceChange), resets: make(chan struct{})}
+
+	start := repository.ChangeHorizon{Seq: 5, Writers: []string{"3/17"}}
+	next := repository.ChangeHorizon{Seq: 7, Writers: []string{"3/21", "5/2"}}
+	filter := repository.DeviceChangeFilter{Brands: []string{"Apple"}}
+
+	mockRepo.On("GetChangeHorizon").Return(start, nil).Once()
+	mockRepo.On("HorizonSettled", start).Return(true, nil).Once()
+	mockRepo.On("GetChangeHorizon").Return(next, nil)
+	mockRepo.On("HorizonSettled", next).Return(true, nil)
+	mockRepo.On("GetChangesSince", int64(5), int64(7), repository.DeviceChangeFilter{}, followPageSize).
+		Return([]model.DeviceChange{}, nil).Once()
+	mockRepo.On("GetChangesSince", int64(3), int64(7), filter, 200).
+		Return([]model.DeviceChange{}, nil).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stream.Run(ctx, source)
+	<-stream.Ready()
+
+	source.resets <- struct{}{}
+
+	// replays go up to the settled horizon even though nothing was published
+	assert.Eventually(t, func() bool {
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
+		return stream.settled == 7
+	}, time.Second, 10*time.Millisecond)
+
+	_, err := stream.GetChangesSince(3, filter, 200)
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeviceStream_RunWarnsWhenHeldBack(t *testing.T) {
+	defer func(after time.Duration) { settleWarnAfter = after }(settleWarnAfter)
+	settleWarnAfter = 0
+
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	mockRepo := new(MockDeviceChangeRepository)
+	stream := NewDeviceStream(mockRepo)
+	source := &fakeChangeSource{changes: make(chan model.DeviceChange), resets: make(chan struct{})}
+
+	start := repository.ChangeHorizon{Seq: 5, Writers: []string{"3/17"}}
+	mockRepo.On("GetChangeHorizon").Return(start, nil).Once()
+	mockRepo.On("HorizonSettled", start).Return(false, nil).Twice()
+	mockRepo.On("HorizonSettled", start).Return(true, nil).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- stream.Run(ctx, source) }()
+	<-stream.Ready()
+	cancel()
+	assert.NoError(t, <-done)
+
+	var warnings []string
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == log.WarnLevel {
+			warnings = append(warnings, entry.Message)
+		}
+	}
+	// one warning per horizon, however long it waits
+	if assert.Len(t, warnings, 1) {
+		assert.Contains(t, warnings[0], "device changes up to 5 have waited")
+		assert.Contains(t, warnings[0], "3/17")
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+// Test GetChangesSince
+
+func TestDeviceStream_GetChangesSince(t *testing.T) {
+	mockRepo := new(MockDeviceChangeRepository)
+	stream := NewDeviceStream(mockRepo)
+
+	filter := repository.DeviceChangeFilter{States: []model.DeviceState{model.StateInUse}}
+	expected := []model.DeviceChange{deviceChange(5, "Apple", model.StateInUse)}
+
+	// the replay stops at the last settled change
+	stream.settled = 9
+	mockRepo.On("GetChangesSince", int64(4), int64(9), filter, 200).Return(expected, nil)
+
+	changes, err := stream.GetChangesSince(4, filter, 200)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, changes)
+	mockRepo.AssertExpectations(t)
+}
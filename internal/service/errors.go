@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+// ErrValidationFailed matches every payload rejected by model.Validate. The
+// offending fields travel in the wrapped *model.ValidationError.
+var ErrValidationFailed = newError(KindValidation, "validation_failed", "validation failed")
+
+// ErrorKind classifies domain errors so every transport reports them the
+// same way
+type ErrorKind string
+
+const (
+	KindInvalid      ErrorKind = "invalid"
+	KindValidation   ErrorKind = "validation"
+	KindUnauthorized ErrorKind = "unauthorized"
+	KindForbidden    ErrorKind = "forbidden"
+	KindNotFound     ErrorKind = "not_found"
+	KindConflict     ErrorKind = "conflict"
+	KindPrecondition ErrorKind = "precondition"
+	// KindPreconditionRequired is for writes that must say which version
+	// they expect to overwrite and didn't
+	KindPreconditionRequired ErrorKind = "precondition_required"
+	KindUnsupported          ErrorKind = "unsupported"
+	KindAborted              ErrorKind = "aborted"
+)
+
+// Error is a domain error. Code is a stable, machine readable identifier
+// clients can rely on; Message is meant for humans and may change. Err is
+// the underlying repository error, if any.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches any domain error with the same code, so a sentinel still
+// matches the copies domainError makes of it
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+func newError(kind ErrorKind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// wrapError turns a repository sentinel into a domain error
+func wrapError(kind ErrorKind, code string, err error) *Error {
+	return &Error{Kind: kind, Code: code, Message: err.Error(), Err: err}
+}
+
+// repositoryErrors are the domain errors that stand for repository sentinels
+var repositoryErrors = []*Error{
+	ErrDeviceNotFound,
+	ErrDeviceInUse,
+	ErrDeviceNotAvailable,
+	ErrDeviceNotCheckedOut,
+	ErrNotAssignee,
+	ErrInvalidSort,
+	ErrInvalidCursor,
+	ErrVersionMismatch,
+	ErrDeviceReserved,
+	ErrReservationNotFound,
+	ErrReservationConflict,
+	ErrUserAlreadyExists,
+	ErrUserNotFound,
+	ErrWebhookNotFound,
+	ErrAPITokenNotFound,
+	ErrUserAlreadyLinked,
+}
+
+// domainError translates repository errors into domain errors, keeping
+// their message. Other errors are returned unchanged.
+func domainError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var domain *Error
+	if errors.As(err, &domain) {
+		return err
+	}
+
+	for _, known := range repositoryErrors {
+		if errors.Is(err, known.Err) {
+			return &Error{Kind: known.Kind, Code: known.Code, Message: err.Error(), Err: err}
+		}
+	}
+
+	return err
+}
+
+// validationError wraps the field errors of model.Validate into a domain error
+func validationError(err error) error {
+	var invalid *model.ValidationError
+	if !errors.As(err, &invalid) {
+		return err
+	}
+	return &Error{Kind: KindValidation, Code: ErrValidationFailed.Code, Message: invalid.Error(), Err: invalid}
+}
+
+// ErrorKindOf returns the kind of the domain error in err's chain, or "" for
+// unexpected errors
+func ErrorKindOf(err error) ErrorKind {
+	var domain *Error
+	if errors.As(err, &domain) {
+		return domain.Kind
+	}
+	return ""
+}
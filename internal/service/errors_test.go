@@ -0,0 +1,40 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test domain errors
+
+func TestDomainError_TranslatesRepositoryErrors(t *testing.T) {
+	err := domainError(fmt.Errorf("%w: weight", repository.ErrInvalidSort))
+
+	assert.ErrorIs(t, err, ErrInvalidSort)
+	assert.ErrorIs(t, err, repository.ErrInvalidSort)
+	assert.Equal(t, "invalid sort field: weight", err.Error())
+	assert.Equal(t, KindInvalid, ErrorKindOf(err))
+}
+
+func TestDomainError_KeepsOtherErrors(t *testing.T) {
+	outage := errors.New("connection refused")
+
+	assert.Nil(t, domainError(nil))
+	assert.Equal(t, outage, domainError(outage))
+	assert.Equal(t, ErrorKind(""), ErrorKindOf(outage))
+
+	wrapped := fmt.Errorf("cannot update name: %w", ErrDeviceLockedInUse)
+	assert.Equal(t, wrapped, domainError(wrapped))
+	assert.Equal(t, KindConflict, ErrorKindOf(wrapped))
+}
+
+func TestError_IsMatchesByCode(t *testing.T) {
+	copy := &Error{Kind: KindNotFound, Code: ErrDeviceNotFound.Code, Message: "gone"}
+
+	assert.ErrorIs(t, copy, ErrDeviceNotFound)
+	assert.NotErrorIs(t, copy, ErrReservationNotFound)
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+)
+
+// minRSAKeyBits is the smallest RSA key accepted for signing
+const minRSAKeyBits = 2048
+
+// signingKey is a private key along with the id and algorithm it is
+// published under
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+// KeySet holds the keys access tokens are signed and verified with. The
+// first key signs; the others only verify, so tokens signed before a
+// rotation stay valid until they expire. Ed25519 keys sign with EdDSA and
+// RSA keys with RS256.
+type KeySet struct {
+	keys []signingKey
+}
+
+// NewKeySet builds a key set out of Ed25519 and RSA private keys, the
+// signing one first
+func NewKeySet(keys ...crypto.Signer) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one key is needed")
+	}
+
+	set := &KeySet{}
+	seen := make(map[string]bool)
+	for _, private := range keys {
+		key, err := newSigningKey(private)
+		if err != nil {
+			return nil, err
+		}
+		if seen[key.id] {
+			return nil, fmt.Errorf("key %s is listed twice", key.id)
+		}
+		seen[key.id] = true
+		set.keys = append(set.keys, key)
+	}
+	return set, nil
+}
+
+// LoadKeySet reads a key set from PEM encoded private key files, PKCS #8 or
+// PKCS #1 for RSA, the signing one first
+func LoadKeySet(paths []string) (*KeySet, error) {
+	keys := make([]crypto.Signer, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := parsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(keys...)
+}
+
+// GenerateKeySet returns a key set made of a new Ed25519 key. Tokens signed
+// with it can't be verified once the process exits, nor by other replicas.
+func GenerateKeySet() (*KeySet, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(private)
+}
+
+// JWKS returns the public keys of the set
+func (k *KeySet) JWKS() model.JSONWebKeySet {
+	set := model.JSONWebKeySet{Keys: make([]model.JSONWebKey, len(k.keys))}
+	for i, key := range k.keys {
+		jwk := publicJWK(key.private.Public())
+		jwk.KeyID = key.id
+		jwk.Use = "sig"
+		jwk.Algorithm = key.method.Alg()
+		set.Keys[i] = jwk
+	}
+	return set
+}
+
+// signer returns the key new tokens are signed with
+func (k *KeySet) signer() signingKey {
+	return k.keys[0]
+}
+
+// verificationKey is the jwt.Keyfunc picking the public key a token was
+// signed with by its "kid" header
+func (k *KeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+	for _, key := range k.keys {
+		if key.id != id {
+			continue
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("key %s does not sign with %s", id, token.Method.Alg())
+		}
+		return key.private.Public(), nil
+	}
+	return nil, fmt.Errorf("unknown key %q", id)
+}
+
+func newSigningKey(private crypto.Signer) (signingKey, error) {
+	var method jwt.SigningMethod
+	switch key := private.(type) {
+	case ed25519.PrivateKey:
+		method = jwt.SigningMethodEdDSA
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return signingKey{}, fmt.Errorf("RSA keys need at least %d bits", minRSAKeyBits)
+		}
+		method = jwt.SigningMethodRS256
+	default:
+		return signingKey{}, fmt.Errorf("unsupported key type %T, use Ed25519 or RSA", private)
+	}
+
+	return signingKey{id: thumbprint(publicJWK(private.Public())), method: method, private: private}, nil
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T, use Ed25519 or RSA", key)
+	}
+	return signer, nil
+}
+
+// publicJWK describes the public key of a signing key, without id, use or
+// algorithm
+func publicJWK(public crypto.PublicKey) model.JSONWebKey {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch key := public.(type) {
+	case ed25519.PublicKey:
+		return model.JSONWebKey{KeyType: "OKP", Curve: "Ed25519", X: encode(key)}
+	case *rsa.PublicKey:
+		return model.JSONWebKey{KeyType: "RSA", N: encode(key.N.Bytes()), E: encode(big.NewInt(int64(key.E)).Bytes())}
+	default:
+		return model.JSONWebKey{}
+	}
+}
+
+// thumbprint is the RFC 7638 thumbprint of a public key, used as its id so
+// every replica loading the same key publishes it under the same id
+func thumbprint(jwk model.JSONWebKey) string {
+	// the required members only, in lexicographic order
+	var members interface{}
+	switch jwk.KeyType {
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	default:
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	}
+
+	canonical, _ := json.Marshal(members)
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
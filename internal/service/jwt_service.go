@@ -0,0 +1,259 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultJWTIssuer       = "deviceregistry"
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrTokenModeDisabled   = newError(KindInvalid, "token_mode_disabled", "token mode is not enabled on this server")
+	ErrInvalidAccessToken  = newError(KindUnauthorized, "invalid_access_token", "invalid or expired access token")
+	ErrInvalidRefreshToken = newError(KindUnauthorized, "invalid_refresh_token", "invalid, expired or revoked refresh token")
+	ErrRefreshTokenReused  = newError(KindUnauthorized, "refresh_token_reused", "refresh token was already used, so the login it belongs to was revoked")
+)
+
+type JWTServiceInterface interface {
+	IssueTokens(user *model.User) (*model.TokenPair, error)
+	RefreshTokens(refreshToken string) (*model.TokenPair, error)
+	VerifyAccessToken(token string) (*model.AccessToken, error)
+	RevokeLogin(familyID uuid.UUID) error
+	RevokeUserLogins(userID uuid.UUID) error
+	JWKS() model.JSONWebKeySet
+}
+
+type JWTServiceOption func(*JWTService)
+
+// WithIssuer sets the issuer, and audience, of access tokens
+func WithIssuer(issuer string) JWTServiceOption {
+	return func(s *JWTService) {
+		if issuer != "" {
+			s.issuer = issuer
+		}
+	}
+}
+
+// WithAccessTokenTTL sets how long access tokens last
+func WithAccessTokenTTL(ttl time.Duration) JWTServiceOption {
+	return func(s *JWTService) {
+		if ttl > 0 {
+			s.accessTTL = ttl
+		}
+	}
+}
+
+// WithRefreshTokenTTL sets how long refresh tokens last. Every refresh
+// hands out a token that lasts this long again.
+func WithRefreshTokenTTL(ttl time.Duration) JWTServiceOption {
+	return func(s *JWTService) {
+		if ttl > 0 {
+			s.refreshTTL = ttl
+		}
+	}
+}
+
+// accessClaims are the claims of an access token. Subject is the user id
+// and sid the refresh token family, which stands for the login.
+type accessClaims struct {
+	jwt.RegisteredClaims
+	Role     model.Role `json:"role"`
+	FamilyID string     `json:"sid"`
+}
+
+// JWTService issues short lived JWT access tokens, which anyone holding the
+// public keys can verify, along with rotating refresh tokens
+type JWTService struct {
+	keys       *KeySet
+	refresh    repository.RefreshTokenRepositoryInterface
+	users      repository.UserRepository
+	issuer     string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	now        func() time.Time
+}
+
+func NewJWTService(keys *KeySet, refresh repository.RefreshTokenRepositoryInterface, users repository.UserRepository, opts ...JWTServiceOption) *JWTService {
+	s := &JWTService{
+		keys:       keys,
+		refresh:    refresh,
+		users:      users,
+		issuer:     defaultJWTIssuer,
+		accessTTL:  defaultAccessTokenTTL,
+		refreshTTL: defaultRefreshTokenTTL,
+		now:        func() time.Time { return time.Now().UTC() },
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// IssueTokens starts a login of user in token mode
+func (s *JWTService) IssueTokens(user *model.User) (*model.TokenPair, error) {
+	return s.issue(s.refresh, user, uuid.New())
+}
+
+// RefreshTokens trades a refresh token for a new pair. The refresh token
+// can't be used again: presenting it a second time revokes every token of
+// its family, as one of the two parties presenting it must have stolen it.
+// The token is used up in the same transaction its successor is stored in,
+// so a failed refresh leaves it usable rather than ending the login.
+func (s *JWTService) RefreshTokens(refreshToken string) (*model.TokenPair, error) {
+	now := s.now()
+	hash := model.HashSessionToken(refreshToken)
+
+	var pair *model.TokenPair
+	err := s.refresh.WithTx(func(refresh repository.RefreshTokenRepositoryInterface) error {
+		used, err := refresh.UseRefreshToken(hash, now)
+		if err != nil {
+			return err
+		}
+
+		user, err := s.users.GetByID(used.UserID)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		pair, err = s.issue(refresh, user, used.FamilyID)
+		return err
+	})
+	if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+		return nil, s.checkReuse(hash, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+// VerifyAccessToken checks the signature, issuer, audience and expiry of
+// an access token and returns what it asserts
+func (s *JWTService) VerifyAccessToken(token string) (*model.AccessToken, error) {
+	var claims accessClaims
+	_, err := jwt.ParseWithClaims(token, &claims, s.keys.verificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(s.now),
+	)
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+	familyID, err := uuid.Parse(claims.FamilyID)
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+
+	return &model.AccessToken{
+		ID:        claims.ID,
+		UserID:    userID,
+		FamilyID:  familyID,
+		Role:      claims.Role,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// RevokeLogin revokes the refresh tokens of a login, so it can't be
+// refreshed anymore. Its access tokens stay valid until they expire.
+func (s *JWTService) RevokeLogin(familyID uuid.UUID) error {
+	return s.refresh.RevokeRefreshFamily(familyID, s.now())
+}
+
+// RevokeUserLogins revokes the refresh tokens of every login of a user
+func (s *JWTService) RevokeUserLogins(userID uuid.UUID) error {
+	return s.refresh.RevokeUserRefreshTokens(userID, s.now())
+}
+
+// JWKS returns the public keys access tokens may be signed with
+func (s *JWTService) JWKS() model.JSONWebKeySet {
+	return s.keys.JWKS()
+}
+
+// issue hands out a new access token and a refresh token of familyID,
+// storing the refresh token through refresh
+func (s *JWTService) issue(refresh repository.RefreshTokenRepositoryInterface, user *model.User, familyID uuid.UUID) (*model.TokenPair, error) {
+	now := s.now()
+
+	refreshToken, hash, err := model.NewSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	_, err = refresh.CreateRefreshToken(&model.RefreshToken{
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		ExpiresAt: now.Add(s.refreshTTL),
+	}, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	key := s.keys.signer()
+	token := jwt.NewWithClaims(key.method, accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    s.issuer,
+			Audience:  jwt.ClaimStrings{s.issuer},
+			Subject:   user.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL)),
+		},
+		Role:     user.Role,
+		FamilyID: familyID.String(),
+	})
+	token.Header["kid"] = key.id
+
+	accessToken, err := token.SignedString(key.private)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TokenPair{
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(s.accessTTL.Seconds()),
+		RefreshToken:     refreshToken,
+		RefreshExpiresIn: int(s.refreshTTL.Seconds()),
+	}, nil
+}
+
+// checkReuse tells why the refresh token stored under hash couldn't be
+// used, revoking its family if it had been already
+func (s *JWTService) checkReuse(hash string, now time.Time) error {
+	token, err := s.refresh.GetRefreshToken(hash)
+	if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+	if token.UsedAt == nil || token.RevokedAt != nil {
+		return ErrInvalidRefreshToken
+	}
+
+	if err := s.refresh.RevokeRefreshFamily(token.FamilyID, now); err != nil {
+		return err
+	}
+	log.Warnf("refresh token reused, revoked token family %s of user %s", token.FamilyID, token.UserID)
+	return ErrRefreshTokenReused
+}
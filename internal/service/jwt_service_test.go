@@ -0,0 +1,372 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockRefreshTokenRepository is a mock implementation of the refresh token repository
+type MockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenRepository) CreateRefreshToken(token *model.RefreshToken, hash string) (*model.RefreshToken, error) {
+	args := m.Called(token, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) GetRefreshToken(hash string) (*model.RefreshToken, error) {
+	args := m.Called(hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) UseRefreshToken(hash string, now time.Time) (*model.RefreshToken, error) {
+	args := m.Called(hash, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) RevokeRefreshFamily(familyID uuid.UUID, now time.Time) error {
+	args := m.Called(familyID, now)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeUserRefreshTokens(userID uuid.UUID, now time.Time) error {
+	args := m.Called(userID, now)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) DeleteExpired(now time.Time) (int64, error) {
+	args := m.Called(now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) WithTx(fn func(repo repository.RefreshTokenRepositoryInterface) error) error {
+	args := m.Called()
+	if err := fn(m); err != nil {
+		return err
+	}
+	return args.Error(0)
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func newTestJWTService(keys *KeySet, refresh *MockRefreshTokenRepository, users *MockUserRepository, now time.Time) *JWTService {
+	service := NewJWTService(keys, refresh, users, WithAccessTokenTTL(5*time.Minute), WithRefreshTokenTTL(24*time.Hour))
+	service.now = func() time.Time { return now }
+	return service
+}
+
+func TestJWTService_IssueAndVerify(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	user := &model.User{ID: uuid.New(), Email: "ci@example.com", Role: model.RoleOperator}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	for name, key := range map[string]crypto.Signer{"EdDSA": newEd25519Key(t), "RS256": rsaKey} {
+		t.Run(name, func(t *testing.T) {
+			keys, err := NewKeySet(key)
+			require.NoError(t, err)
+
+			refresh := new(MockRefreshTokenRepository)
+			var stored *model.RefreshToken
+			var hash string
+			refresh.On("CreateRefreshToken", mock.AnythingOfType("*model.RefreshToken"), mock.AnythingOfType("string")).
+				Run(func(args mock.Arguments) {
+					stored = args.Get(0).(*model.RefreshToken)
+					hash = args.String(1)
+				}).
+				Return(&model.RefreshToken{ID: uuid.New()}, nil).Once()
+
+			service := newTestJWTService(keys, refresh, new(MockUserRepository), now)
+			pair, err := service.IssueTokens(user)
+
+			require.NoError(t, err)
+			assert.Equal(t, "Bearer", pair.TokenType)
+			assert.Equal(t, 300, pair.ExpiresIn)
+			assert.Equal(t, 86400, pair.RefreshExpiresIn)
+			assert.Equal(t, model.HashSessionToken(pair.RefreshToken), hash)
+			assert.Equal(t, user.ID, stored.UserID)
+			assert.True(t, stored.ExpiresAt.Equal(now.Add(24*time.Hour)))
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(pair.AccessToken, &accessClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, name, parsed.Header["alg"])
+			assert.Equal(t, keys.JWKS().Keys[0].KeyID, parsed.Header["kid"])
+
+			access, err := service.VerifyAccessToken(pair.AccessToken)
+
+			require.NoError(t, err)
+			assert.Equal(t, user.ID, access.UserID)
+			assert.Equal(t, stored.FamilyID, access.FamilyID)
+			assert.Equal(t, model.RoleOperator, access.Role)
+			assert.True(t, access.ExpiresAt.Equal(now.Add(5*time.Minute)))
+		})
+	}
+}
+
+func TestJWTService_VerifyAccessToken_Rejects(t *testing.T) {
+	now := time.Now().UTC()
+	user := &model.User{ID: uuid.New(), Role: model.RoleViewer}
+
+	keys, err := NewKeySet(newEd25519Key(t))
+	require.NoError(t, err)
+	otherKeys, err := NewKeySet(newEd25519Key(t))
+	require.NoError(t, err)
+
+	refresh := new(MockRefreshTokenRepository)
+	refresh.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(&model.RefreshToken{}, nil)
+
+	issue := func(service *JWTService) string {
+		pair, err := service.IssueTokens(user)
+		require.NoError(t, err)
+		return pair.AccessToken
+	}
+
+	service := newTestJWTService(keys, refresh, new(MockUserRepository), now)
+
+	t.Run("unknown key", func(t *testing.T) {
+		token := issue(newTestJWTService(otherKeys, refresh, new(MockUserRepository), now))
+		_, err := service.VerifyAccessToken(token)
+		assert.ErrorIs(t, err, ErrInvalidAccessToken)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		token := issue(newTestJWTService(keys, refresh, new(MockUserRepository), now.Add(-time.Hour)))
+		_, err := service.VerifyAccessToken(token)
+		assert.ErrorIs(t, err, ErrInvalidAccessToken)
+	})
+
+	t.Run("other issuer", func(t *testing.T) {
+		other := NewJWTService(keys, refresh, new(MockUserRepository), WithIssuer("elsewhere"))
+		_, err := service.VerifyAccessToken(issue(other))
+		assert.ErrorIs(t, err, ErrInvalidAccessToken)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		token := issue(service)
+		header, rest, _ := strings.Cut(token, ".")
+		_, err := service.VerifyAccessToken(header + ".e30." + rest[strings.Index(rest, ".")+1:])
+		assert.ErrorIs(t, err, ErrInvalidAccessToken)
+	})
+
+	t.Run("none algorithm", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+			"sub": user.ID.String(), "iss": defaultJWTIssuer, "aud": defaultJWTIssuer, "exp": now.Add(time.Minute).Unix(),
+		}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		require.NoError(t, err)
+
+		_, err = service.VerifyAccessToken(token)
+		assert.ErrorIs(t, err, ErrInvalidAccessToken)
+	})
+}
+
+func TestJWTService_KeyRotation(t *testing.T) {
+	now := time.Now().UTC()
+	user := &model.User{ID: uuid.New(), Role: model.RoleViewer}
+	oldKey, newKey := newEd25519Key(t), newEd25519Key(t)
+
+	refresh := new(MockRefreshTokenRepository)
+	refresh.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(&model.RefreshToken{}, nil)
+
+	before, err := NewKeySet(oldKey)
+	require.NoError(t, err)
+	pair, err := newTestJWTService(before, refresh, new(MockUserRepository), now).IssueTokens(user)
+	require.NoError(t, err)
+
+	after, err := NewKeySet(newKey, oldKey)
+	require.NoError(t, err)
+	service := newTestJWTService(after, refresh, new(MockUserRepository), now)
+
+	jwks := service.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, before.JWKS().Keys[0], jwks.Keys[1])
+	assert.Equal(t, "OKP", jwks.Keys[0].KeyType)
+	assert.Equal(t, "EdDSA", jwks.Keys[0].Algorithm)
+
+	_, err = service.VerifyAccessToken(pair.AccessToken)
+	assert.NoError(t, err, "tokens signed before the rotation stay valid")
+
+	rotated, err := service.IssueTokens(user)
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(rotated.AccessToken, &accessClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, jwks.Keys[0].KeyID, parsed.Header["kid"])
+}
+
+func TestJWTService_RefreshTokens(t *testing.T) {
+	now := time.Now().UTC()
+	user := &model.User{ID: uuid.New(), Role: model.RoleAdmin}
+	familyID := uuid.New()
+	hash := model.HashSessionToken("refresh")
+
+	keys, err := NewKeySet(newEd25519Key(t))
+	require.NoError(t, err)
+
+	t.Run("rotates", func(t *testing.T) {
+		refresh := new(MockRefreshTokenRepository)
+		users := new(MockUserRepository)
+		used := now
+		refresh.On("WithTx").Return(nil).Once()
+		refresh.On("UseRefreshToken", hash, now).Return(&model.RefreshToken{ID: uuid.New(), FamilyID: familyID, UserID: user.ID, UsedAt: &used}, nil).Once()
+		users.On("GetByID", user.ID).Return(user, nil).Once()
+
+		var successor *model.RefreshToken
+		refresh.On("CreateRefreshToken", mock.AnythingOfType("*model.RefreshToken"), mock.AnythingOfType("string")).
+			Run(func(args mock.Arguments) { successor = args.Get(0).(*model.RefreshToken) }).
+			Return(&model.RefreshToken{}, nil).Once()
+
+		pair, err := newTestJWTService(keys, refresh, users, now).RefreshTokens("refresh")
+
+		require.NoError(t, err)
+		assert.NotEqual(t, "refresh", pair.RefreshToken)
+		assert.Equal(t, familyID, successor.FamilyID)
+		refresh.AssertExpectations(t)
+		users.AssertExpectations(t)
+	})
+
+	t.Run("reuse revokes the family", func(t *testing.T) {
+		refresh := new(MockRefreshTokenRepository)
+		used := now.Add(-time.Minute)
+		refresh.On("WithTx").Return(nil).Once()
+		refresh.On("UseRefreshToken", hash, now).Return(nil, repository.ErrRefreshTokenNotFound).Once()
+		refresh.On("GetRefreshToken", hash).Return(&model.RefreshToken{FamilyID: familyID, UserID: user.ID, UsedAt: &used}, nil).Once()
+		refresh.On("RevokeRefreshFamily", familyID, now).Return(nil).Once()
+
+		_, err := newTestJWTService(keys, refresh, new(MockUserRepository), now).RefreshTokens("refresh")
+
+		assert.ErrorIs(t, err, ErrRefreshTokenReused)
+		refresh.AssertExpectations(t)
+	})
+
+	t.Run("revoked or expired", func(t *testing.T) {
+		refresh := new(MockRefreshTokenRepository)
+		used := now.Add(-time.Minute)
+		refresh.On("WithTx").Return(nil).Once()
+		refresh.On("UseRefreshToken", hash, now).Return(nil, repository.ErrRefreshTokenNotFound).Once()
+		refresh.On("GetRefreshToken", hash).Return(&model.RefreshToken{FamilyID: familyID, UsedAt: &used, RevokedAt: &used}, nil).Once()
+
+		_, err := newTestJWTService(keys, refresh, new(MockUserRepository), now).RefreshTokens("refresh")
+
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+		refresh.AssertNotCalled(t, "RevokeRefreshFamily", mock.Anything, mock.Anything)
+	})
+
+	t.Run("failed rotation leaves the token usable", func(t *testing.T) {
+		refresh := new(MockRefreshTokenRepository)
+		users := new(MockUserRepository)
+		refresh.On("WithTx").Return(nil).Once()
+		refresh.On("UseRefreshToken", hash, now).Return(&model.RefreshToken{ID: uuid.New(), FamilyID: familyID, UserID: user.ID}, nil).Once()
+		users.On("GetByID", user.ID).Return(user, nil).Once()
+		refresh.On("CreateRefreshToken", mock.AnythingOfType("*model.RefreshToken"), mock.AnythingOfType("string")).
+			Return(nil, errors.New("connection reset")).Once()
+
+		_, err := newTestJWTService(keys, refresh, users, now).RefreshTokens("refresh")
+
+		// the error is returned through WithTx, which rolls the use back
+		assert.EqualError(t, err, "connection reset")
+		refresh.AssertNotCalled(t, "GetRefreshToken", mock.Anything)
+		refresh.AssertExpectations(t)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		refresh := new(MockRefreshTokenRepository)
+		refresh.On("WithTx").Return(nil).Once()
+		refresh.On("UseRefreshToken", hash, now).Return(nil, repository.ErrRefreshTokenNotFound).Once()
+		refresh.On("GetRefreshToken", hash).Return(nil, repository.ErrRefreshTokenNotFound).Once()
+
+		_, err := newTestJWTService(keys, refresh, new(MockUserRepository), now).RefreshTokens("refresh")
+
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+}
+
+func TestJWTService_RevokeLogins(t *testing.T) {
+	now := time.Now().UTC()
+	keys, err := NewKeySet(newEd25519Key(t))
+	require.NoError(t, err)
+
+	t.Run("one login", func(t *testing.T) {
+		refresh := new(MockRefreshTokenRepository)
+		familyID := uuid.New()
+		refresh.On("RevokeRefreshFamily", familyID, now).Return(nil).Once()
+
+		assert.NoError(t, newTestJWTService(keys, refresh, new(MockUserRepository), now).RevokeLogin(familyID))
+		refresh.AssertExpectations(t)
+	})
+
+	t.Run("every login of a user", func(t *testing.T) {
+		refresh := new(MockRefreshTokenRepository)
+		userID := uuid.New()
+		refresh.On("RevokeUserRefreshTokens", userID, now).Return(nil).Once()
+
+		assert.NoError(t, newTestJWTService(keys, refresh, new(MockUserRepository), now).RevokeUserLogins(userID))
+		refresh.AssertExpectations(t)
+	})
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+
+	der, err := x509.MarshalPKCS8PrivateKey(newEd25519Key(t))
+	require.NoError(t, err)
+	path := filepath.Join(dir, "ed25519.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	smallPath := filepath.Join(dir, "small.pem")
+	require.NoError(t, os.WriteFile(smallPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(small)}), 0o600))
+
+	t.Run("ed25519", func(t *testing.T) {
+		keys, err := LoadKeySet([]string{path})
+		require.NoError(t, err)
+		assert.Len(t, keys.JWKS().Keys, 1)
+	})
+
+	t.Run("same key twice", func(t *testing.T) {
+		_, err := LoadKeySet([]string{path, path})
+		assert.Error(t, err)
+	})
+
+	t.Run("weak RSA key", func(t *testing.T) {
+		_, err := LoadKeySet([]string{smallPath})
+		assert.ErrorContains(t, err, "2048")
+	})
+
+	t.Run("no keys", func(t *testing.T) {
+		_, err := LoadKeySet(nil)
+		assert.Error(t, err)
+	})
+}
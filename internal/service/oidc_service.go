@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// oidcRandomBytes is the length of the random state and nonce of a login
+const oidcRandomBytes = 32
+
+var (
+	ErrUserAlreadyLinked   = wrapError(KindConflict, "user_already_linked", repository.ErrUserAlreadyLinked)
+	ErrOIDCStateMismatch   = newError(KindUnauthorized, "invalid_oidc_state", "the single sign-on login expired or was started elsewhere, try again")
+	ErrOIDCLoginFailed     = newError(KindUnauthorized, "oidc_login_failed", "the identity provider did not confirm the login")
+	ErrOIDCEmailUnverified = newError(KindForbidden, "oidc_email_unverified", "the identity provider has not verified your email")
+	ErrOIDCNoRole          = newError(KindForbidden, "oidc_no_role", "none of your groups is allowed into the registry")
+)
+
+// OIDCConfig describes the identity provider and how its users become
+// registry users. GroupRoles maps the groups listed in the GroupsClaim of
+// ID tokens to roles; users get the highest role their groups map to, or
+// DefaultRole. An empty DefaultRole turns away users none of whose groups
+// is mapped.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	GroupsClaim  string
+	GroupRoles   map[string]model.Role
+	DefaultRole  model.Role
+}
+
+// OIDCLogin is a login waiting for the identity provider to send the user
+// back. It stays with the browser in between, and is only good for the
+// callback carrying the same state.
+type OIDCLogin struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+type OIDCServiceInterface interface {
+	StartLogin(ctx context.Context) (*OIDCLogin, string, error)
+	FinishLogin(ctx context.Context, login *OIDCLogin, state, code string) (*model.User, error)
+}
+
+// OIDCService signs users in with an OpenID Connect identity provider, using
+// the authorization code flow with PKCE. Users are provisioned on their
+// first login, or linked by their verified email if they already exist.
+type OIDCService struct {
+	config OIDCConfig
+	users  repository.UserRepository
+	now    func() time.Time
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func NewOIDCService(config OIDCConfig, users repository.UserRepository) (*OIDCService, error) {
+	if config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("issuer, client-id and redirect-url are required")
+	}
+	if config.DefaultRole != "" && !config.DefaultRole.Valid() {
+		return nil, fmt.Errorf("unknown default role %q", config.DefaultRole)
+	}
+	if config.DefaultRole == "" && len(config.GroupRoles) == 0 {
+		return nil, errors.New("without a default role, groups must be mapped to roles")
+	}
+	for group, role := range config.GroupRoles {
+		if !role.Valid() {
+			return nil, fmt.Errorf("group %s maps to unknown role %q", group, role)
+		}
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	return &OIDCService{
+		config: config,
+		users:  users,
+		now:    func() time.Time { return time.Now().UTC() },
+	}, nil
+}
+
+// StartLogin begins a login, returning it along with the URL of the
+// identity provider to send the user to
+func (s *OIDCService) StartLogin(ctx context.Context) (*OIDCLogin, string, error) {
+	provider, err := s.discover(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return nil, "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return nil, "", err
+	}
+	login := &OIDCLogin{State: state, Nonce: nonce, Verifier: oauth2.GenerateVerifier()}
+
+	url := s.oauth2Config(provider).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(login.Verifier))
+	return login, url, nil
+}
+
+// FinishLogin completes login with the state and authorization code the
+// identity provider sent the user back with, and returns the user it
+// authenticated
+func (s *OIDCService) FinishLogin(ctx context.Context, login *OIDCLogin, state, code string) (*model.User, error) {
+	if login == nil || state == "" || subtle.ConstantTimeCompare([]byte(login.State), []byte(state)) != 1 {
+		return nil, ErrOIDCStateMismatch
+	}
+
+	provider, err := s.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(login.Verifier))
+	if err != nil {
+		log.Warn("OIDC code exchange failed. err: ", err.Error())
+		return nil, ErrOIDCLoginFailed
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		log.Warn("OIDC token response carries no ID token")
+		return nil, ErrOIDCLoginFailed
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: s.config.ClientID, Now: s.now}).Verify(ctx, rawIDToken)
+	if err != nil {
+		log.Warn("OIDC ID token rejected. err: ", err.Error())
+		return nil, ErrOIDCLoginFailed
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(login.Nonce)) != 1 {
+		log.Warn("OIDC ID token nonce mismatch")
+		return nil, ErrOIDCLoginFailed
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	email, _ := claims["email"].(string)
+	if email == "" || !claimTrue(claims["email_verified"]) {
+		return nil, ErrOIDCEmailUnverified
+	}
+
+	return s.provision(idToken.Subject, email, claimStrings(claims[s.groupsClaim()]))
+}
+
+// provision returns the user linked to subject, linking or creating one by
+// email first if there is none, with the role their groups map to
+func (s *OIDCService) provision(subject, email string, groups []string) (*model.User, error) {
+	role, mapped := s.roleFor(groups)
+	if role == "" {
+		return nil, ErrOIDCNoRole
+	}
+
+	user, err := s.users.GetByOIDCSubject(subject)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		user, err = s.link(subject, email, role)
+	}
+	if err != nil {
+		return nil, domainError(err)
+	}
+
+	// Mapped groups make the identity provider the source of roles
+	if mapped && user.Role != role {
+		user, err = s.users.UpdateRole(user.ID, role)
+		if err != nil {
+			return nil, domainError(err)
+		}
+	}
+
+	return user, nil
+}
+
+// link links the user registered with email to subject, or creates one
+func (s *OIDCService) link(subject, email string, role model.Role) (*model.User, error) {
+	user, err := s.users.GetByEmail(email)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		now := s.now()
+		user = &model.User{
+			ID:          uuid.New(),
+			Email:       email,
+			Role:        role,
+			OIDCSubject: &subject,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := s.users.Create(user); err != nil {
+			return nil, err
+		}
+		log.Infof("provisioned user %s from single sign-on", user.ID)
+		return user, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("linked user %s to single sign-on", user.ID)
+	return s.users.LinkOIDCSubject(user.ID, subject)
+}
+
+// roleFor returns the highest role groups map to, and whether any group
+// mapping is configured at all. Without one, users keep the role they have.
+func (s *OIDCService) roleFor(groups []string) (model.Role, bool) {
+	if len(s.config.GroupRoles) == 0 {
+		return s.config.DefaultRole, false
+	}
+
+	role := s.config.DefaultRole
+	for _, group := range groups {
+		if mapped, ok := s.config.GroupRoles[group]; ok && (role == "" || mapped.Outranks(role)) {
+			role = mapped
+		}
+	}
+	return role, true
+}
+
+func (s *OIDCService) groupsClaim() string {
+	if s.config.GroupsClaim == "" {
+		return "groups"
+	}
+	return s.config.GroupsClaim
+}
+
+// discover fetches the configuration of the identity provider the first
+// time it is needed, so the registry starts even while it is unreachable
+func (s *OIDCService) discover(ctx context.Context) (*oidc.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider == nil {
+		provider, err := oidc.NewProvider(ctx, s.config.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+		}
+		s.provider = provider
+	}
+	return s.provider, nil
+}
+
+func (s *OIDCService) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     s.config.ClientID,
+		ClientSecret: s.config.ClientSecret,
+		RedirectURL:  s.config.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       s.config.Scopes,
+	}
+}
+
+func randomString() (string, error) {
+	b := make([]byte, oidcRandomBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// claimTrue reads a boolean claim, which some providers send as a string
+func claimTrue(claim interface{}) bool {
+	switch value := claim.(type) {
+	case bool:
+		return value
+	case string:
+		return strings.EqualFold(value, "true")
+	default:
+		return false
+	}
+}
+
+// claimStrings reads a claim holding a list of strings, or a single one
+func claimStrings(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
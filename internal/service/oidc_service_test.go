@@ -0,0 +1,323 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	mockIdPClientID     = "registry"
+	mockIdPClientSecret = "registry-secret"
+	mockIdPRedirectURL  = "http://registry.test/auth/oidc/callback"
+)
+
+// mockIdP is an OpenID Connect provider that signs in whoever has claims
+// set, without asking
+type mockIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	claims map[string]interface{}
+	codes  map[string]mockIdPGrant
+}
+
+// mockIdPGrant is what an authorization code was issued for
+type mockIdPGrant struct {
+	challenge string
+	nonce     string
+	claims    map[string]interface{}
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	idp := &mockIdP{key: key, codes: make(map[string]mockIdPGrant)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/keys", idp.keys)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// signIn sets the claims of the ID tokens issued from now on
+func (idp *mockIdP) signIn(claims map[string]interface{}) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.claims = claims
+}
+
+func (idp *mockIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                idp.URL,
+		"authorization_endpoint":                idp.URL + "/authorize",
+		"token_endpoint":                        idp.URL + "/token",
+		"jwks_uri":                              idp.URL + "/keys",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+// authorize sends the user straight back with a code
+func (idp *mockIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != mockIdPClientID || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	idp.mu.Lock()
+	code := uuid.NewString()
+	idp.codes[code] = mockIdPGrant{challenge: query.Get("code_challenge"), nonce: query.Get("nonce"), claims: idp.claims}
+	idp.mu.Unlock()
+
+	redirect, _ := url.Parse(query.Get("redirect_uri"))
+	redirect.RawQuery = url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if id, secret, _ := r.BasicAuth(); id != mockIdPClientID || secret != mockIdPClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	idp.mu.Lock()
+	grant, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   idp.URL,
+		"aud":   mockIdPClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": grant.nonce,
+	}
+	for name, value := range grant.claims {
+		claims[name] = value
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = "mock"
+	signed, _ := idToken.SignedString(idp.key)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func (idp *mockIdP) keys(w http.ResponseWriter, r *http.Request) {
+	jwk := publicJWK(idp.key.Public())
+	jwk.KeyID, jwk.Use, jwk.Algorithm = "mock", "sig", "RS256"
+	json.NewEncoder(w).Encode(model.JSONWebKeySet{Keys: []model.JSONWebKey{jwk}})
+}
+
+// login runs a login against idp up to the callback, which it returns the
+// state and code of
+func (idp *mockIdP) login(t *testing.T, service *OIDCService) (*OIDCLogin, string, string) {
+	login, authURL, err := service.StartLogin(context.Background())
+	require.NoError(t, err)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return login, callback.Query().Get("state"), callback.Query().Get("code")
+}
+
+func newTestOIDCService(t *testing.T, idp *mockIdP, users *MockUserRepository, groupRoles map[string]model.Role, defaultRole model.Role) *OIDCService {
+	service, err := NewOIDCService(OIDCConfig{
+		Issuer:       idp.URL,
+		ClientID:     mockIdPClientID,
+		ClientSecret: mockIdPClientSecret,
+		RedirectURL:  mockIdPRedirectURL,
+		GroupRoles:   groupRoles,
+		DefaultRole:  defaultRole,
+	}, users)
+	require.NoError(t, err)
+	return service
+}
+
+func TestOIDCService_Login(t *testing.T) {
+	idp := newMockIdP(t)
+	groupRoles := map[string]model.Role{"registry-operators": model.RoleOperator, "registry-admins": model.RoleAdmin}
+
+	t.Run("provisions new users", func(t *testing.T) {
+		users := new(MockUserRepository)
+		service := newTestOIDCService(t, idp, users, groupRoles, model.RoleViewer)
+		idp.signIn(map[string]interface{}{"sub": "alice", "email": "alice@example.com", "email_verified": true, "groups": []string{"staff", "registry-operators"}})
+
+		var created *model.User
+		users.On("GetByOIDCSubject", "alice").Return(nil, repository.ErrUserNotFound).Once()
+		users.On("GetByEmail", "alice@example.com").Return(nil, repository.ErrUserNotFound).Once()
+		users.On("Create", mock.AnythingOfType("*model.User")).
+			Run(func(args mock.Arguments) { created = args.Get(0).(*model.User) }).
+			Return(nil).Once()
+
+		login, state, code := idp.login(t, service)
+		user, err := service.FinishLogin(context.Background(), login, state, code)
+
+		require.NoError(t, err)
+		assert.Same(t, created, user)
+		assert.Equal(t, "alice@example.com", user.Email)
+		assert.Equal(t, model.RoleOperator, user.Role)
+		assert.Empty(t, user.PasswordHash)
+		require.NotNil(t, user.OIDCSubject)
+		assert.Equal(t, "alice", *user.OIDCSubject)
+		users.AssertExpectations(t)
+	})
+
+	t.Run("links existing users by email", func(t *testing.T) {
+		users := new(MockUserRepository)
+		service := newTestOIDCService(t, idp, users, nil, model.RoleViewer)
+		idp.signIn(map[string]interface{}{"sub": "bob", "email": "bob@example.com", "email_verified": "true"})
+
+		existing := &model.User{ID: uuid.New(), Email: "bob@example.com", Role: model.RoleAdmin}
+		users.On("GetByOIDCSubject", "bob").Return(nil, repository.ErrUserNotFound).Once()
+		users.On("GetByEmail", "bob@example.com").Return(existing, nil).Once()
+		users.On("LinkOIDCSubject", existing.ID, "bob").Return(existing, nil).Once()
+
+		login, state, code := idp.login(t, service)
+		user, err := service.FinishLogin(context.Background(), login, state, code)
+
+		require.NoError(t, err)
+		assert.Equal(t, model.RoleAdmin, user.Role, "without group mappings users keep their role")
+		users.AssertExpectations(t)
+	})
+
+	t.Run("syncs the role of linked users", func(t *testing.T) {
+		users := new(MockUserRepository)
+		service := newTestOIDCService(t, idp, users, groupRoles, model.RoleViewer)
+		idp.signIn(map[string]interface{}{"sub": "carol", "email": "carol@example.com", "email_verified": true, "groups": []string{"registry-operators", "registry-admins"}})
+
+		linked := &model.User{ID: uuid.New(), Email: "carol@example.com", Role: model.RoleViewer}
+		users.On("GetByOIDCSubject", "carol").Return(linked, nil).Once()
+		users.On("UpdateRole", linked.ID, model.RoleAdmin).Return(&model.User{ID: linked.ID, Role: model.RoleAdmin}, nil).Once()
+
+		login, state, code := idp.login(t, service)
+		user, err := service.FinishLogin(context.Background(), login, state, code)
+
+		require.NoError(t, err)
+		assert.Equal(t, model.RoleAdmin, user.Role)
+		users.AssertExpectations(t)
+	})
+
+	t.Run("unverified email", func(t *testing.T) {
+		users := new(MockUserRepository)
+		service := newTestOIDCService(t, idp, users, nil, model.RoleViewer)
+		idp.signIn(map[string]interface{}{"sub": "mallory", "email": "alice@example.com", "email_verified": false})
+
+		login, state, code := idp.login(t, service)
+		_, err := service.FinishLogin(context.Background(), login, state, code)
+
+		assert.ErrorIs(t, err, ErrOIDCEmailUnverified)
+		users.AssertNotCalled(t, "GetByEmail", mock.Anything)
+	})
+
+	t.Run("no mapped group without a default role", func(t *testing.T) {
+		users := new(MockUserRepository)
+		service := newTestOIDCService(t, idp, users, groupRoles, "")
+		idp.signIn(map[string]interface{}{"sub": "dave", "email": "dave@example.com", "email_verified": true, "groups": []string{"staff"}})
+
+		login, state, code := idp.login(t, service)
+		_, err := service.FinishLogin(context.Background(), login, state, code)
+
+		assert.ErrorIs(t, err, ErrOIDCNoRole)
+	})
+
+	t.Run("state mismatch", func(t *testing.T) {
+		service := newTestOIDCService(t, idp, new(MockUserRepository), nil, model.RoleViewer)
+		idp.signIn(map[string]interface{}{"sub": "alice", "email": "alice@example.com", "email_verified": true})
+
+		login, _, code := idp.login(t, service)
+		_, err := service.FinishLogin(context.Background(), login, "forged", code)
+
+		assert.ErrorIs(t, err, ErrOIDCStateMismatch)
+	})
+
+	t.Run("verifier of another login", func(t *testing.T) {
+		service := newTestOIDCService(t, idp, new(MockUserRepository), nil, model.RoleViewer)
+		idp.signIn(map[string]interface{}{"sub": "alice", "email": "alice@example.com", "email_verified": true})
+
+		login, state, code := idp.login(t, service)
+		other, _, _ := idp.login(t, service)
+		_, err := service.FinishLogin(context.Background(), &OIDCLogin{State: login.State, Nonce: login.Nonce, Verifier: other.Verifier}, state, code)
+
+		assert.ErrorIs(t, err, ErrOIDCLoginFailed)
+	})
+
+	t.Run("nonce of another login", func(t *testing.T) {
+		service := newTestOIDCService(t, idp, new(MockUserRepository), nil, model.RoleViewer)
+		idp.signIn(map[string]interface{}{"sub": "alice", "email": "alice@example.com", "email_verified": true})
+
+		login, state, code := idp.login(t, service)
+		login.Nonce = "replayed"
+		_, err := service.FinishLogin(context.Background(), login, state, code)
+
+		assert.ErrorIs(t, err, ErrOIDCLoginFailed)
+	})
+}
+
+func TestNewOIDCService_Config(t *testing.T) {
+	base := OIDCConfig{Issuer: "https://idp.example.com", ClientID: "registry", RedirectURL: mockIdPRedirectURL}
+
+	tests := []struct {
+		name   string
+		config func(OIDCConfig) OIDCConfig
+		valid  bool
+	}{
+		{"default role", func(c OIDCConfig) OIDCConfig { c.DefaultRole = model.RoleViewer; return c }, true},
+		{"group mapping only", func(c OIDCConfig) OIDCConfig {
+			c.GroupRoles = map[string]model.Role{"ops": model.RoleOperator}
+			return c
+		}, true},
+		{"neither", func(c OIDCConfig) OIDCConfig { return c }, false},
+		{"unknown role", func(c OIDCConfig) OIDCConfig { c.GroupRoles = map[string]model.Role{"ops": "owner"}; return c }, false},
+		{"no issuer", func(c OIDCConfig) OIDCConfig { c.Issuer = ""; c.DefaultRole = model.RoleViewer; return c }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewOIDCService(tt.config(base), new(MockUserRepository))
+			assert.Equal(t, tt.valid, err == nil, err)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+)
+
+var (
+	ErrReservationNotFound      = wrapError(KindNotFound, "reservation_not_found", repository.ErrReservationNotFound)
+	ErrReservationConflict      = wrapError(KindConflict, "reservation_conflict", repository.ErrReservationConflict)
+	ErrInvalidReservationWindow = newError(KindInvalid, "invalid_reservation_window", "reservation must end after it starts and in the future")
+	ErrNotReservationOwner      = newError(KindForbidden, "not_reservation_owner", "reservation belongs to another user")
+)
+
+type ReservationServiceInterface interface {
+	GetReservations(deviceID string) ([]model.Reservation, error)
+	CreateReservation(reservation *model.Reservation) (*model.Reservation, error)
+	CancelReservation(deviceID, id string, userID uuid.UUID) error
+}
+
+type ReservationService struct {
+	repo repository.ReservationRepositoryInterface
+}
+
+func NewReservationService(repo repository.ReservationRepositoryInterface) *ReservationService {
+	return &ReservationService{repo: repo}
+}
+
+// GetReservations lists the active and upcoming reservations of a device
+func (s *ReservationService) GetReservations(deviceID string) ([]model.Reservation, error) {
+	reservations, err := s.repo.GetReservationsByDeviceID(deviceID, time.Now().UTC())
+	return reservations, domainError(err)
+}
+
+// CreateReservation books a device for the reservation window
+func (s *ReservationService) CreateReservation(reservation *model.Reservation) (*model.Reservation, error) {
+	// Windows are stored without a time zone, so keep them all in UTC
+	reservation.StartsAt = reservation.StartsAt.UTC()
+	reservation.EndsAt = reservation.EndsAt.UTC()
+
+	if !reservation.EndsAt.After(reservation.StartsAt) || !reservation.EndsAt.After(time.Now().UTC()) {
+		return nil, ErrInvalidReservationWindow
+	}
+
+	created, err := s.repo.CreateReservation(reservation)
+	return created, domainError(err)
+}
+
+// CancelReservation deletes a reservation owned by the user
+func (s *ReservationService) CancelReservation(deviceID, id string, userID uuid.UUID) error {
+	reservation, err := s.repo.GetReservationByID(id)
+	if err != nil {
+		return domainError(err)
+	}
+
+	if reservation.DeviceID.String() != deviceID {
+		return ErrReservationNotFound
+	}
+
+	if reservation.UserID != userID {
+		return ErrNotReservationOwner
+	}
+
+	return domainError(s.repo.DeleteReservation(id))
+}
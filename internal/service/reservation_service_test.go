@@ -0,0 +1,209 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockReservationRepository is a mock implementation of the reservation repository
+type MockReservationRepository struct {
+	mock.Mock
+}
+
+func (m *MockReservationRepository) GetReservationsByDeviceID(deviceID string, after time.Time) ([]model.Reservation, error) {
+	args := m.Called(deviceID, after)
+	return args.Get(0).([]model.Reservation), args.Error(1)
+}
+
+func (m *MockReservationRepository) GetReservationByID(id string) (*model.Reservation, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Reservation), args.Error(1)
+}
+
+func (m *MockReservationRepository) GetActiveReservation(deviceID string, at time.Time) (*model.Reservation, error) {
+	args := m.Called(deviceID, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Reservation), args.Error(1)
+}
+
+func (m *MockReservationRepository) CreateReservation(reservation *model.Reservation) (*model.Reservation, error) {
+	args := m.Called(reservation)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Reservation), args.Error(1)
+}
+
+func (m *MockReservationRepository) DeleteReservation(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+// Test CreateReservation
+
+func TestCreateReservation_Success(t *testing.T) {
+	mockRepo := new(MockReservationRepository)
+	service := NewReservationService(mockRepo)
+
+	reservation := &model.Reservation{
+		DeviceID: uuid.New(),
+		UserID:   uuid.New(),
+		StartsAt: time.Now().Add(time.Hour),
+		EndsAt:   time.Now().Add(3 * time.Hour),
+	}
+
+	mockRepo.On("CreateReservation", reservation).Return(reservation, nil)
+
+	result, err := service.CreateReservation(reservation)
+
+	assert.NoError(t, err)
+	assert.Equal(t, time.UTC, result.StartsAt.Location())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateReservation_EndBeforeStart(t *testing.T) {
+	mockRepo := new(MockReservationRepository)
+	service := NewReservationService(mockRepo)
+
+	reservation := &model.Reservation{
+		DeviceID: uuid.New(),
+		UserID:   uuid.New(),
+		StartsAt: time.Now().Add(3 * time.Hour),
+		EndsAt:   time.Now().Add(time.Hour),
+	}
+
+	result, err := service.CreateReservation(reservation)
+
+	assert.Nil(t, result)
+	assert.Equal(t, ErrInvalidReservationWindow, err)
+	mockRepo.AssertNotCalled(t, "CreateReservation")
+}
+
+func TestCreateReservation_InThePast(t *testing.T) {
+	mockRepo := new(MockReservationRepository)
+	service := NewReservationService(mockRepo)
+
+	reservation := &model.Reservation{
+		DeviceID: uuid.New(),
+		UserID:   uuid.New(),
+		StartsAt: time.Now().Add(-3 * time.Hour),
+		EndsAt:   time.Now().Add(-time.Hour),
+	}
+
+	result, err := service.CreateReservation(reservation)
+
+	assert.Nil(t, result)
+	assert.Equal(t, ErrInvalidReservationWindow, err)
+	mockRepo.AssertNotCalled(t, "CreateReservation")
+}
+
+func TestCreateReservation_Overlap(t *testing.T) {
+	mockRepo := new(MockReservationRepository)
+	service := NewReservationService(mockRepo)
+
+	reservation := &model.Reservation{
+		DeviceID: uuid.New(),
+		UserID:   uuid.New(),
+		StartsAt: time.Now().Add(time.Hour),
+		EndsAt:   time.Now().Add(3 * time.Hour),
+	}
+
+	mockRepo.On("CreateReservation", reservation).Return(nil, repository.ErrReservationConflict)
+
+	result, err := service.CreateReservation(reservation)
+
+	assert.Nil(t, result)
+	assert.Equal(t, ErrReservationConflict, err)
+	mockRepo.AssertExpectations(t)
+}
+
+// Test CancelReservation
+
+func TestCancelReservation_Success(t *testing.T) {
+	mockRepo := new(MockReservationRepository)
+	service := NewReservationService(mockRepo)
+
+	userID := uuid.New()
+	reservation := &model.Reservation{
+		ID:       uuid.New(),
+		DeviceID: uuid.New(),
+		UserID:   userID,
+	}
+
+	mockRepo.On("GetReservationByID", reservation.ID.String()).Return(reservation, nil)
+	mockRepo.On("DeleteReservation", reservation.ID.String()).Return(nil)
+
+	err := service.CancelReservation(reservation.DeviceID.String(), reservation.ID.String(), userID)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCancelReservation_NotOwner(t *testing.T) {
+	mockRepo := new(MockReservationRepository)
+	service := NewReservationService(mockRepo)
+
+	reservation := &model.Reservation{
+		ID:       uuid.New(),
+		DeviceID: uuid.New(),
+		UserID:   uuid.New(),
+	}
+
+	mockRepo.On("GetReservationByID", reservation.ID.String()).Return(reservation, nil)
+
+	err := service.CancelReservation(reservation.DeviceID.String(), reservation.ID.String(), uuid.New())
+
+	assert.Equal(t, ErrNotReservationOwner, err)
+	mockRepo.AssertNotCalled(t, "DeleteReservation")
+}
+
+func TestCancelReservation_OtherDevice(t *testing.T) {
+	mockRepo := new(MockReservationRepository)
+	service := NewReservationService(mockRepo)
+
+	userID := uuid.New()
+	reservation := &model.Reservation{
+		ID:       uuid.New(),
+		DeviceID: uuid.New(),
+		UserID:   userID,
+	}
+
+	mockRepo.On("GetReservationByID", reservation.ID.String()).Return(reservation, nil)
+
+	err := service.CancelReservation(uuid.New().String(), reservation.ID.String(), userID)
+
+	assert.Equal(t, ErrReservationNotFound, err)
+	mockRepo.AssertNotCalled(t, "DeleteReservation")
+}
+
+// Test GetReservations
+
+func TestGetReservations_Success(t *testing.T) {
+	mockRepo := new(MockReservationRepository)
+	service := NewReservationService(mockRepo)
+
+	deviceID := uuid.New()
+	reservations := []model.Reservation{
+		{ID: uuid.New(), DeviceID: deviceID},
+	}
+
+	mockRepo.On("GetReservationsByDeviceID", deviceID.String(), mock.AnythingOfType("time.Time")).
+		Return(reservations, nil)
+
+	result, err := service.GetReservations(deviceID.String())
+
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	mockRepo.AssertExpectations(t)
+}
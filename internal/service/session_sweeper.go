@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// SessionSweeper removes expired sessions, which stores otherwise only
+// ignore. Every replica may run one.
+type SessionSweeper struct {
+	sessions model.SessionStore
+	now      func() time.Time
+}
+
+func NewSessionSweeper(sessions model.SessionStore) *SessionSweeper {
+	return &SessionSweeper{
+		sessions: sessions,
+		now:      func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Run sweeps every interval until ctx is done
+func (s *SessionSweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := s.Sweep(); err != nil {
+			log.Error("session sweep failed. err: ", err.Error())
+		}
+	}
+}
+
+// Sweep removes the sessions expired by now
+func (s *SessionSweeper) Sweep() (int64, error) {
+	swept, err := s.sessions.DeleteExpired(s.now())
+	if err != nil {
+		return 0, err
+	}
+
+	if swept > 0 {
+		log.Debugf("swept %d expired sessions", swept)
+	}
+	return swept, nil
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionSweeper_Sweep(t *testing.T) {
+	store := model.NewMemorySessionStore()
+	userID := uuid.New()
+
+	_, err := store.Create(model.NewSession(userID, "curl/8.5.0", "203.0.113.7"), time.Hour)
+	require.NoError(t, err)
+	_, err = store.Create(model.NewSession(userID, "curl/8.5.0", "203.0.113.7"), time.Minute)
+	require.NoError(t, err)
+
+	sweeper := NewSessionSweeper(store)
+	sweeper.now = func() time.Time { return time.Now().UTC().Add(30 * time.Minute) }
+
+	swept, err := sweeper.Sweep()
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), swept)
+
+	sessions, err := store.ListByUser(userID)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidStateTransition = newError(KindConflict, "invalid_state_transition", "invalid state transition")
+
+// StateTransitions maps each state to the states a device may move to from it
+type StateTransitions map[model.DeviceState][]model.DeviceState
+
+// DefaultStateTransitions is used when the config doesn't define its own
+// table. Moves into and out of in-use only happen through checkout and
+// checkin, which check available -> in-use and in-use -> available.
+var DefaultStateTransitions = StateTransitions{
+	model.StateInactive:    {model.StateAvailable, model.StateMaintenance, model.StateRetired},
+	model.StateAvailable:   {model.StateInUse, model.StateInactive, model.StateMaintenance, model.StateLost, model.StateRetired},
+	model.StateInUse:       {model.StateAvailable},
+	model.StateMaintenance: {model.StateAvailable, model.StateInactive, model.StateRetired},
+	model.StateLost:        {model.StateAvailable, model.StateInactive, model.StateRetired},
+	model.StateRetired:     {},
+}
+
+// Allows reports whether a device may move between the two states. Staying
+// in the same state is always allowed.
+func (t StateTransitions) Allows(from, to model.DeviceState) bool {
+	if from == to {
+		return true
+	}
+
+	for _, state := range t[from] {
+		if state == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check returns ErrInvalidStateTransition when the move isn't allowed
+func (t StateTransitions) Check(from, to model.DeviceState) error {
+	if !t.Allows(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidStateTransition, from, to)
+	}
+	return nil
+}
+
+// LoadStateTransitions reads the transition table from the
+// device.state-transitions config key, falling back to the defaults when
+// it's not set. Keys and values are state names, e.g.
+//
+//	device:
+//	  state-transitions:
+//	    retired: []
+//	    lost: [available, retired]
+func LoadStateTransitions() (StateTransitions, error) {
+	if !viper.IsSet("device.state-transitions") {
+		return DefaultStateTransitions, nil
+	}
+
+	transitions := StateTransitions{}
+	for from, targets := range viper.GetStringMapStringSlice("device.state-transitions") {
+		fromState, err := model.ParseDeviceState(from)
+		if err != nil {
+			return nil, err
+		}
+
+		transitions[fromState] = []model.DeviceState{}
+		for _, to := range targets {
+			toState, err := model.ParseDeviceState(to)
+			if err != nil {
+				return nil, err
+			}
+			transitions[fromState] = append(transitions[fromState], toState)
+		}
+	}
+
+	return transitions, nil
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateTransitions_Allows(t *testing.T) {
+	t.Run("same state", func(t *testing.T) {
+		assert.True(t, DefaultStateTransitions.Allows(model.StateRetired, model.StateRetired))
+	})
+
+	t.Run("allowed transition", func(t *testing.T) {
+		assert.True(t, DefaultStateTransitions.Allows(model.StateAvailable, model.StateMaintenance))
+	})
+
+	t.Run("retired is terminal", func(t *testing.T) {
+		for _, state := range model.DeviceStates {
+			if state != model.StateRetired {
+				assert.False(t, DefaultStateTransitions.Allows(model.StateRetired, state))
+			}
+		}
+	})
+
+	t.Run("unknown state", func(t *testing.T) {
+		assert.False(t, DefaultStateTransitions.Allows(model.StateAvailable, model.DeviceState(42)))
+	})
+}
+
+func TestStateTransitions_Check(t *testing.T) {
+	err := DefaultStateTransitions.Check(model.StateRetired, model.StateInUse)
+
+	assert.ErrorIs(t, err, ErrInvalidStateTransition)
+	assert.Equal(t, "invalid state transition: retired -> in-use", err.Error())
+}
+
+func TestLoadStateTransitions(t *testing.T) {
+	defer viper.Reset()
+
+	t.Run("defaults when unset", func(t *testing.T) {
+		viper.Reset()
+
+		transitions, err := LoadStateTransitions()
+
+		assert.NoError(t, err)
+		assert.Equal(t, DefaultStateTransitions, transitions)
+	})
+
+	t.Run("from config", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("device.state-transitions", map[string][]string{
+			"available": {"in-use", "lost"},
+			"in-use":    {"available"},
+			"lost":      {},
+		})
+
+		transitions, err := LoadStateTransitions()
+
+		assert.NoError(t, err)
+		assert.True(t, transitions.Allows(model.StateAvailable, model.StateLost))
+		assert.False(t, transitions.Allows(model.StateAvailable, model.StateRetired))
+		assert.False(t, transitions.Allows(model.StateLost, model.StateAvailable))
+	})
+
+	t.Run("unknown state name", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("device.state-transitions", map[string][]string{
+			"available": {"broken"},
+		})
+
+		transitions, err := LoadStateTransitions()
+
+		assert.Error(t, err)
+		assert.Nil(t, transitions)
+	})
+}
+
+func TestUpdateDevice_InvalidTransition(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations)
+
+	deviceID := uuid.New()
+	existingDevice := &model.Device{
+		ID:    deviceID,
+		Name:  "ThinkPad T480",
+		Brand: "Lenovo",
+		State: model.StateRetired,
+	}
+
+	updatedDevice := &model.Device{
+		ID:    deviceID,
+		Name:  "ThinkPad T480",
+		Brand: "Lenovo",
+		State: model.StateMaintenance,
+	}
+
+	mockRepo.On("GetDeviceByID", deviceID.String()).Return(existingDevice, nil)
+
+	result, err := service.UpdateDevice(updatedDevice, uuid.New(), AllFields)
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrInvalidStateTransition)
+	mockRepo.AssertNotCalled(t, "UpdateDevice")
+}
+
+func TestUpdateDevice_CustomTransitions(t *testing.T) {
+	mockRepo := new(MockDeviceRepository)
+	mockReservations := new(MockReservationRepository)
+	service := NewDeviceService(mockRepo, mockReservations, WithStateTransitions(StateTransitions{
+		model.StateRetired: {model.StateAvailable},
+	}))
+
+	userID := uuid.New()
+	deviceID := uuid.New()
+	existingDevice := &model.Device{
+		ID:    deviceID,
+		Name:  "ThinkPad T480",
+		Brand: "Lenovo",
+		State: model.StateRetired,
+	}
+
+	updatedDevice := &model.Device{
+		ID:    deviceID,
+		Name:  "ThinkPad T480",
+		Brand: "Lenovo",
+		State: model.StateAvailable,
+	}
+
+	mockRepo.On("GetDeviceByID", deviceID.String()).Return(existingDevice, nil)
+	mockRepo.On("UpdateDevice", updatedDevice, userID).Return(updatedDevice, nil)
+
+	result, err := service.UpdateDevice(updatedDevice, userID, AllFields)
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.StateAvailable, result.State)
+	mockRepo.AssertExpectations(t)
+}
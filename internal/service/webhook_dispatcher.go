@@ -0,0 +1,378 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+
+	defaultWebhookMaxAttempts  = 8
+	defaultWebhookRetryBackoff = 30 * time.Second
+	defaultWebhookTimeout      = 10 * time.Second
+	maxWebhookRetryDelay       = time.Hour
+	webhookFanOutBatch         = 100
+	webhookDeliveryBatch       = 20
+)
+
+// errWebhookAddressRefused is returned when a webhook URL leads to an address
+// deliveries may not be sent to
+var errWebhookAddressRefused = errors.New("webhook address refused")
+
+type WebhookDispatcherOption func(*WebhookDispatcher)
+
+// WithHTTPClient replaces the client deliveries are posted with. Its timeout
+// bounds a single attempt. It takes over from the default client, which
+// refuses internal addresses, so it is meant for tests.
+func WithHTTPClient(client *http.Client) WebhookDispatcherOption {
+	return func(d *WebhookDispatcher) {
+		d.client = client
+	}
+}
+
+// WithTimeout bounds a single attempt of the default client
+func WithTimeout(timeout time.Duration) WebhookDispatcherOption {
+	return func(d *WebhookDispatcher) {
+		if timeout > 0 {
+			d.client.Timeout = timeout
+		}
+	}
+}
+
+// WithMaxAttempts sets how many attempts a delivery gets before it is
+// dead-lettered
+func WithMaxAttempts(attempts int) WebhookDispatcherOption {
+	return func(d *WebhookDispatcher) {
+		if attempts > 0 {
+			d.maxAttempts = attempts
+		}
+	}
+}
+
+// WithRetryBackoff sets the delay before the first retry. Every further
+// retry waits twice as long, up to an hour.
+func WithRetryBackoff(backoff time.Duration) WebhookDispatcherOption {
+	return func(d *WebhookDispatcher) {
+		if backoff > 0 {
+			d.backoff = backoff
+		}
+	}
+}
+
+// WebhookDispatcher fans the events of the outbox out to the webhooks and
+// posts the resulting deliveries, retrying failures with exponential backoff
+type WebhookDispatcher struct {
+	repo        repository.WebhookRepositoryInterface
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	now         func() time.Time
+}
+
+func NewWebhookDispatcher(repo repository.WebhookRepositoryInterface, opts ...WebhookDispatcherOption) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		repo:        repo,
+		client:      newWebhookClient(),
+		maxAttempts: defaultWebhookMaxAttempts,
+		backoff:     defaultWebhookRetryBackoff,
+		now:         func() time.Time { return time.Now().UTC() },
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Run dispatches every interval until ctx is done
+func (d *WebhookDispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.Dispatch(ctx); err != nil {
+			log.Error("webhook dispatch failed. err: ", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch fans out the pending events, then attempts the deliveries that
+// are due
+func (d *WebhookDispatcher) Dispatch(ctx context.Context) error {
+	if _, err := d.repo.FanOutEvents(webhookFanOutBatch, d.now()); err != nil {
+		return err
+	}
+
+	now := d.now()
+	deliveries, err := d.repo.ClaimDueDeliveries(now, now.Add(d.lease()), webhookDeliveryBatch)
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery model.PendingWebhookDelivery) {
+			defer wg.Done()
+			if err := d.deliver(ctx, delivery); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(delivery)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// deliver makes one attempt at a claimed delivery and records its outcome
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery model.PendingWebhookDelivery) error {
+	statusCode, err := d.post(ctx, delivery)
+	if ctx.Err() != nil {
+		// shutting down: the claim runs out and another attempt is made
+		return nil
+	}
+
+	if err == nil {
+		return d.repo.MarkDelivered(delivery.ID, statusCode, d.now())
+	}
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+
+	var retryAt *time.Time
+	if delivery.Attempts < d.maxAttempts {
+		at := d.now().Add(d.retryDelay(delivery.Attempts))
+		retryAt = &at
+	}
+
+	return d.repo.MarkFailed(delivery.ID, code, d.failureReason(delivery, err), retryAt)
+}
+
+// failureReason describes a failed attempt for the delivery log, which the
+// webhook owner can read. Connection errors are summed up so the log does not
+// reveal anything about the network the dispatcher runs in; the details are
+// logged instead.
+func (d *WebhookDispatcher) failureReason(delivery model.PendingWebhookDelivery, err error) string {
+	var status *webhookStatusError
+	if errors.As(err, &status) {
+		return status.Error()
+	}
+
+	log.Warnf("webhook delivery %s failed. err: %s", delivery.ID, err.Error())
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errWebhookAddressRefused):
+		return "destination address not allowed"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "request timed out"
+	default:
+		return "request failed"
+	}
+}
+
+// post sends the delivery payload and returns the response status. Any
+// status outside 2xx is an error.
+func (d *WebhookDispatcher) post(ctx context.Context, delivery model.PendingWebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "DeviceRegistry-Webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, string(delivery.EventType))
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID.String())
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(delivery.Secret, d.now().Unix(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &webhookStatusError{code: resp.StatusCode}
+	}
+
+	return resp.StatusCode, nil
+}
+
+// webhookStatusError is a response outside 2xx
+type webhookStatusError struct {
+	code int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.code)
+}
+
+// newWebhookClient returns the client deliveries are posted with. Webhook
+// URLs are chosen by users, so it only connects to public addresses. The
+// check runs on the address being dialled, after DNS resolution, so a name
+// can't be pointed at an internal address once the webhook is saved.
+// Redirects are not followed and proxies are not used, as either would let
+// the request go elsewhere.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   defaultWebhookTimeout,
+		KeepAlive: 30 * time.Second,
+		Control:   refuseInternalAddresses,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   defaultWebhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// refuseInternalAddresses is a net.Dialer Control function that stops
+// connections to anything but public addresses
+func refuseInternalAddresses(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errWebhookAddressRefused
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !publicAddress(ip) {
+		return errWebhookAddressRefused
+	}
+	return nil
+}
+
+// refusedPrefixes are the address ranges webhook deliveries may not go to:
+// everything special purpose, reserved or not routed on the internet. The
+// IPv6 ranges that embed an IPv4 address (NAT64, 6to4, Teredo) are refused
+// whole, as the embedded address may be an internal one.
+var refusedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // this network
+	netip.MustParsePrefix("10.0.0.0/8"),      // private
+	netip.MustParsePrefix("100.64.0.0/10"),   // shared address space (CGNAT, some cloud metadata)
+	netip.MustParsePrefix("127.0.0.0/8"),     // loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // link-local, cloud metadata
+	netip.MustParsePrefix("172.16.0.0/12"),   // private
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("192.88.99.0/24"),  // 6to4 relay anycast
+	netip.MustParsePrefix("192.168.0.0/16"),  // private
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("224.0.0.0/4"),     // multicast
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, broadcast
+	netip.MustParsePrefix("::/96"),           // unspecified, loopback, IPv4-compatible
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local NAT64
+	netip.MustParsePrefix("100::/64"),        // discard
+	netip.MustParsePrefix("2001::/32"),       // Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4
+	netip.MustParsePrefix("fc00::/7"),        // unique local
+	netip.MustParsePrefix("fe80::/10"),       // link-local
+	netip.MustParsePrefix("ff00::/8"),        // multicast
+}
+
+// publicAddress reports whether ip may receive webhook deliveries: it must
+// not fall in any of the refused prefixes. IPv4-mapped IPv6 addresses are
+// checked as the IPv4 address they map.
+func publicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() {
+		return false
+	}
+	for _, prefix := range refusedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// internalHost reports whether host plainly names an internal address, so
+// such webhooks are refused when saved rather than on every delivery
+func internalHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	ip, err := netip.ParseAddr(strings.Trim(host, "[]"))
+	return err == nil && !publicAddress(ip)
+}
+
+// retryDelay is the wait after the given number of failed attempts
+func (d *WebhookDispatcher) retryDelay(attempts int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempts && delay < maxWebhookRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxWebhookRetryDelay)
+}
+
+// lease is how long a claimed delivery is kept from other dispatchers, long
+// enough for an attempt to time out
+func (d *WebhookDispatcher) lease() time.Duration {
+	timeout := d.client.Timeout
+	if timeout == 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return timeout + 30*time.Second
+}
+
+// SignWebhookPayload returns the signature header of a payload sent at
+// timestamp: "t=<unix seconds>,v1=<hex HMAC-SHA256 of '<t>.<payload>'>".
+// Receivers recompute it with the webhook secret and should reject old
+// timestamps to prevent replays.
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	t := strconv.FormatInt(timestamp, 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
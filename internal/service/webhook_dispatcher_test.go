@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newTestDispatcher returns a dispatcher that can reach test servers, which
+// listen on loopback addresses the default client refuses
+func newTestDispatcher(repo *MockWebhookRepository, now time.Time, opts ...WebhookDispatcherOption) *WebhookDispatcher {
+	opts = append([]WebhookDispatcherOption{WithHTTPClient(&http.Client{Timeout: time.Second})}, opts...)
+	d := NewWebhookDispatcher(repo, opts...)
+	d.now = func() time.Time { return now }
+	return d
+}
+
+func pendingDelivery(url string, attempts int) model.PendingWebhookDelivery {
+	return model.PendingWebhookDelivery{
+		WebhookDelivery: model.WebhookDelivery{
+			ID:        uuid.New(),
+			EventType: model.DeviceEventStateChanged,
+			Payload:   []byte(`{"type":"state_changed"}`),
+			Attempts:  attempts,
+		},
+		URL:    url,
+		Secret: "secret",
+	}
+}
+
+func TestDispatch_DeliversSignedPayload(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	delivery := pendingDelivery("", 1)
+
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	delivery.URL = server.URL
+
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("FanOutEvents", webhookFanOutBatch, now).Return(int64(1), nil)
+	mockRepo.On("ClaimDueDeliveries", now, mock.AnythingOfType("time.Time"), webhookDeliveryBatch).
+		Return([]model.PendingWebhookDelivery{delivery}, nil)
+	mockRepo.On("MarkDelivered", delivery.ID, http.StatusNoContent, now).Return(nil)
+
+	err := newTestDispatcher(mockRepo, now).Dispatch(context.Background())
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	if assert.NotNil(t, received) {
+		assert.Equal(t, http.MethodPost, received.Method)
+		assert.Equal(t, "application/json", received.Header.Get("Content-Type"))
+		assert.Equal(t, "state_changed", received.Header.Get(WebhookEventHeader))
+		assert.Equal(t, delivery.ID.String(), received.Header.Get(WebhookDeliveryHeader))
+		assert.Equal(t, SignWebhookPayload("secret", now.Unix(), body), received.Header.Get(WebhookSignatureHeader))
+		assert.JSONEq(t, `{"type":"state_changed"}`, string(body))
+	}
+}
+
+func TestDispatch_SchedulesRetryWithBackoff(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// third failed attempt: 10s, doubled twice
+	delivery := pendingDelivery(server.URL, 3)
+	retryAt := now.Add(40 * time.Second)
+	status := http.StatusServiceUnavailable
+
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("FanOutEvents", webhookFanOutBatch, now).Return(int64(0), nil)
+	mockRepo.On("ClaimDueDeliveries", now, mock.AnythingOfType("time.Time"), webhookDeliveryBatch).
+		Return([]model.PendingWebhookDelivery{delivery}, nil)
+	mockRepo.On("MarkFailed", delivery.ID, &status, "unexpected status 503", &retryAt).Return(nil)
+
+	err := newTestDispatcher(mockRepo, now, WithRetryBackoff(10*time.Second)).Dispatch(context.Background())
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDispatch_DeadLettersAfterMaxAttempts(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	delivery := pendingDelivery(server.URL, 3)
+	status := http.StatusInternalServerError
+
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("FanOutEvents", webhookFanOutBatch, now).Return(int64(0), nil)
+	mockRepo.On("ClaimDueDeliveries", now, mock.AnythingOfType("time.Time"), webhookDeliveryBatch).
+		Return([]model.PendingWebhookDelivery{delivery}, nil)
+	mockRepo.On("MarkFailed", delivery.ID, &status, "unexpected status 500", (*time.Time)(nil)).Return(nil)
+
+	err := newTestDispatcher(mockRepo, now, WithMaxAttempts(3)).Dispatch(context.Background())
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDispatch_UnreachableEndpoint(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	delivery := pendingDelivery(url, 1)
+
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("FanOutEvents", webhookFanOutBatch, now).Return(int64(0), nil)
+	mockRepo.On("ClaimDueDeliveries", now, mock.AnythingOfType("time.Time"), webhookDeliveryBatch).
+		Return([]model.PendingWebhookDelivery{delivery}, nil)
+	mockRepo.On("MarkFailed", delivery.ID, (*int)(nil), "request failed", mock.AnythingOfType("*time.Time")).Return(nil)
+
+	err := newTestDispatcher(mockRepo, now).Dispatch(context.Background())
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDispatch_RefusesInternalAddresses(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	delivery := pendingDelivery(server.URL, 1)
+
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("FanOutEvents", webhookFanOutBatch, now).Return(int64(0), nil)
+	mockRepo.On("ClaimDueDeliveries", now, mock.AnythingOfType("time.Time"), webhookDeliveryBatch).
+		Return([]model.PendingWebhookDelivery{delivery}, nil)
+	mockRepo.On("MarkFailed", delivery.ID, (*int)(nil), "destination address not allowed", mock.AnythingOfType("*time.Time")).Return(nil)
+
+	// the default client only connects to public addresses
+	d := NewWebhookDispatcher(mockRepo)
+	d.now = func() time.Time { return now }
+	err := d.Dispatch(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, requests)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDispatch_DoesNotFollowRedirects(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	delivery := pendingDelivery(server.URL, 1)
+	status := http.StatusTemporaryRedirect
+
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("FanOutEvents", webhookFanOutBatch, now).Return(int64(0), nil)
+	mockRepo.On("ClaimDueDeliveries", now, mock.AnythingOfType("time.Time"), webhookDeliveryBatch).
+		Return([]model.PendingWebhookDelivery{delivery}, nil)
+	mockRepo.On("MarkFailed", delivery.ID, &status, "unexpected status 307", mock.AnythingOfType("*time.Time")).Return(nil)
+
+	// the default client with the address check lifted, to reach the server
+	client := newWebhookClient()
+	client.Transport.(*http.Transport).DialContext = (&net.Dialer{}).DialContext
+
+	err := newTestDispatcher(mockRepo, now, WithHTTPClient(client)).Dispatch(context.Background())
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWithTimeout(t *testing.T) {
+	d := NewWebhookDispatcher(new(MockWebhookRepository), WithTimeout(3*time.Second))
+
+	assert.Equal(t, 3*time.Second, d.client.Timeout)
+	assert.Equal(t, 33*time.Second, d.lease())
+}
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:4700::1111", true},
+		{"100.63.255.255", true},
+		{"100.128.0.1", true},
+		{"198.20.0.1", true},
+		{"2003::1", true},
+		{"::ffff:93.184.216.34", true},
+
+		{"0.1.2.3", false},
+		{"10.1.2.3", false},
+		{"100.64.0.1", false},
+		{"100.100.100.200", false},
+		{"127.0.0.1", false},
+		{"169.254.169.254", false},
+		{"172.16.0.1", false},
+		{"192.0.0.170", false},
+		{"192.0.2.1", false},
+		{"192.88.99.1", false},
+		{"192.168.1.1", false},
+		{"198.18.0.1", false},
+		{"198.19.255.255", false},
+		{"198.51.100.1", false},
+		{"203.0.113.1", false},
+		{"224.0.0.1", false},
+		{"240.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::", false},
+		{"::1", false},
+		{"::10.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:100.100.100.200", false},
+		{"64:ff9b::10.0.0.1", false},
+		{"64:ff9b:1::1", false},
+		{"100::1", false},
+		{"2001::1", false},
+		{"2001:db8::1", false},
+		{"2002:a00:1::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"ff02::1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			assert.Equal(t, tt.public, publicAddress(netip.MustParseAddr(tt.address)))
+		})
+	}
+}
+
+func TestRefusedPrefixes(t *testing.T) {
+	// every prefix refuses its first and last address
+	for _, prefix := range refusedPrefixes {
+		t.Run(prefix.String(), func(t *testing.T) {
+			first := prefix.Masked().Addr()
+			assert.False(t, publicAddress(first), first.String())
+			assert.False(t, publicAddress(lastAddress(prefix)), lastAddress(prefix).String())
+		})
+	}
+}
+
+// lastAddress returns the highest address of prefix
+func lastAddress(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Masked().Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(bytes)*8; bit++ {
+		bytes[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
+
+func TestRetryDelay(t *testing.T) {
+	d := NewWebhookDispatcher(new(MockWebhookRepository), WithRetryBackoff(30*time.Second))
+
+	assert.Equal(t, 30*time.Second, d.retryDelay(1))
+	assert.Equal(t, time.Minute, d.retryDelay(2))
+	assert.Equal(t, 2*time.Minute, d.retryDelay(3))
+	assert.Equal(t, maxWebhookRetryDelay, d.retryDelay(20))
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	// echo -n '1700000000.{"a":1}' | openssl dgst -sha256 -hmac secret
+	signature := SignWebhookPayload("secret", 1700000000, []byte(`{"a":1}`))
+
+	assert.Equal(t, "t=1700000000,v1=49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686", signature)
+}
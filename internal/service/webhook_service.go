@@ -0,0 +1,117 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+)
+
+// webhookSecretBytes is the length of the random secret payloads are signed with
+const webhookSecretBytes = 32
+
+var (
+	ErrWebhookNotFound   = wrapError(KindNotFound, "webhook_not_found", repository.ErrWebhookNotFound)
+	ErrInvalidWebhookURL = newError(KindValidation, "invalid_webhook_url", "webhook url must be an absolute http or https URL to a public address")
+)
+
+type WebhookServiceInterface interface {
+	GetWebhooks(userID uuid.UUID) ([]model.Webhook, error)
+	GetWebhook(id string, userID uuid.UUID) (*model.Webhook, error)
+	CreateWebhook(webhook *model.Webhook) (*model.Webhook, error)
+	UpdateWebhook(webhook *model.Webhook) (*model.Webhook, error)
+	DeleteWebhook(id string, userID uuid.UUID) error
+	GetDeliveries(webhookID string, userID uuid.UUID, limit, offset int) ([]model.WebhookDelivery, int, error)
+}
+
+type WebhookService struct {
+	repo repository.WebhookRepositoryInterface
+}
+
+func NewWebhookService(repo repository.WebhookRepositoryInterface) *WebhookService {
+	return &WebhookService{repo: repo}
+}
+
+// GetWebhooks lists the webhooks of a user
+func (s *WebhookService) GetWebhooks(userID uuid.UUID) ([]model.Webhook, error) {
+	webhooks, err := s.repo.GetWebhooks(userID)
+	if err != nil {
+		return nil, domainError(err)
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+// GetWebhook retrieves a webhook owned by the user
+func (s *WebhookService) GetWebhook(id string, userID uuid.UUID) (*model.Webhook, error) {
+	webhook, err := s.repo.GetWebhookByID(id, userID)
+	if err != nil {
+		return nil, domainError(err)
+	}
+
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+// CreateWebhook registers a webhook for webhook.UserID with a fresh signing
+// secret. This is the only time the secret is returned.
+func (s *WebhookService) CreateWebhook(webhook *model.Webhook) (*model.Webhook, error) {
+	if !validWebhookURL(webhook.URL) {
+		return nil, ErrInvalidWebhookURL
+	}
+
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	webhook.Secret = hex.EncodeToString(secret)
+
+	created, err := s.repo.CreateWebhook(webhook)
+	return created, domainError(err)
+}
+
+// UpdateWebhook replaces the url, event types and active flag of a webhook
+// owned by webhook.UserID
+func (s *WebhookService) UpdateWebhook(webhook *model.Webhook) (*model.Webhook, error) {
+	if !validWebhookURL(webhook.URL) {
+		return nil, ErrInvalidWebhookURL
+	}
+
+	updated, err := s.repo.UpdateWebhook(webhook)
+	if err != nil {
+		return nil, domainError(err)
+	}
+
+	updated.Secret = ""
+	return updated, nil
+}
+
+// DeleteWebhook removes a webhook owned by the user
+func (s *WebhookService) DeleteWebhook(id string, userID uuid.UUID) error {
+	return domainError(s.repo.DeleteWebhook(id, userID))
+}
+
+// GetDeliveries retrieves a page of the delivery log of a webhook owned by
+// the user, newest first
+func (s *WebhookService) GetDeliveries(webhookID string, userID uuid.UUID, limit, offset int) ([]model.WebhookDelivery, int, error) {
+	if _, err := s.repo.GetWebhookByID(webhookID, userID); err != nil {
+		return nil, 0, domainError(err)
+	}
+
+	deliveries, total, err := s.repo.GetDeliveries(webhookID, limit, offset)
+	return deliveries, total, domainError(err)
+}
+
+func validWebhookURL(str string) bool {
+	parsed, err := url.Parse(str)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Hostname() != "" && !internalHost(parsed.Hostname())
+}
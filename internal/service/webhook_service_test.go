@@ -0,0 +1,183 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loopsFreitag/DeviceRegistry/internal/model"
+	"github.com/loopsFreitag/DeviceRegistry/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockWebhookRepository is a mock implementation of the webhook repository
+type MockWebhookRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebhookRepository) GetWebhooks(userID uuid.UUID) ([]model.Webhook, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookRepository) GetWebhookByID(id string, userID uuid.UUID) (*model.Webhook, error) {
+	args := m.Called(id, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookRepository) CreateWebhook(webhook *model.Webhook) (*model.Webhook, error) {
+	args := m.Called(webhook)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookRepository) UpdateWebhook(webhook *model.Webhook) (*model.Webhook, error) {
+	args := m.Called(webhook)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookRepository) DeleteWebhook(id string, userID uuid.UUID) error {
+	args := m.Called(id, userID)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) GetDeliveries(webhookID string, limit, offset int) ([]model.WebhookDelivery, int, error) {
+	args := m.Called(webhookID, limit, offset)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]model.WebhookDelivery), args.Int(1), args.Error(2)
+}
+
+func (m *MockWebhookRepository) FanOutEvents(limit int, now time.Time) (int64, error) {
+	args := m.Called(limit, now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWebhookRepository) ClaimDueDeliveries(now, leaseUntil time.Time, limit int) ([]model.PendingWebhookDelivery, error) {
+	args := m.Called(now, leaseUntil, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.PendingWebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookRepository) MarkDelivered(id uuid.UUID, statusCode int, at time.Time) error {
+	args := m.Called(id, statusCode, at)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) MarkFailed(id uuid.UUID, statusCode *int, reason string, retryAt *time.Time) error {
+	args := m.Called(id, statusCode, reason, retryAt)
+	return args.Error(0)
+}
+
+func TestCreateWebhook_GeneratesSecret(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+	service := NewWebhookService(mockRepo)
+
+	webhook := &model.Webhook{UserID: uuid.New(), URL: "https://example.com/hooks", Active: true}
+
+	mockRepo.On("CreateWebhook", mock.MatchedBy(func(w *model.Webhook) bool {
+		return len(w.Secret) == 2*webhookSecretBytes
+	})).Return(webhook, nil)
+
+	result, err := service.CreateWebhook(webhook)
+
+	assert.NoError(t, err)
+	assert.Len(t, result.Secret, 2*webhookSecretBytes)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateWebhook_InvalidURL(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+	service := NewWebhookService(mockRepo)
+
+	for _, url := range []string{
+		"example.com/hooks", "ftp://example.com/hooks", "https://", "://bad",
+		"http://localhost:8080/hooks", "http://127.0.0.1/hooks", "http://[::1]/hooks",
+		"http://10.0.0.5/hooks", "http://169.254.169.254/latest/meta-data",
+	} {
+		result, err := service.CreateWebhook(&model.Webhook{UserID: uuid.New(), URL: url})
+
+		assert.ErrorIs(t, err, ErrInvalidWebhookURL, url)
+		assert.Nil(t, result)
+	}
+	mockRepo.AssertNotCalled(t, "CreateWebhook", mock.Anything)
+}
+
+func TestGetWebhooks_HidesSecrets(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+	service := NewWebhookService(mockRepo)
+
+	userID := uuid.New()
+	mockRepo.On("GetWebhooks", userID).Return([]model.Webhook{
+		{ID: uuid.New(), UserID: userID, Secret: "a"},
+		{ID: uuid.New(), UserID: userID, Secret: "b"},
+	}, nil)
+
+	webhooks, err := service.GetWebhooks(userID)
+
+	assert.NoError(t, err)
+	for _, webhook := range webhooks {
+		assert.Empty(t, webhook.Secret)
+	}
+}
+
+func TestUpdateWebhook_NotFound(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+	service := NewWebhookService(mockRepo)
+
+	webhook := &model.Webhook{ID: uuid.New(), UserID: uuid.New(), URL: "https://example.com/hooks"}
+	mockRepo.On("UpdateWebhook", webhook).Return(nil, repository.ErrWebhookNotFound)
+
+	result, err := service.UpdateWebhook(webhook)
+
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+	assert.Nil(t, result)
+}
+
+func TestGetDeliveries_ChecksOwnership(t *testing.T) {
+	webhookID := uuid.New().String()
+	userID := uuid.New()
+
+	t.Run("owned webhook", func(t *testing.T) {
+		mockRepo := new(MockWebhookRepository)
+		service := NewWebhookService(mockRepo)
+
+		deliveries := []model.WebhookDelivery{{ID: uuid.New()}}
+		mockRepo.On("GetWebhookByID", webhookID, userID).Return(&model.Webhook{}, nil)
+		mockRepo.On("GetDeliveries", webhookID, 20, 0).Return(deliveries, 1, nil)
+
+		result, total, err := service.GetDeliveries(webhookID, userID, 20, 0)
+
+		assert.NoError(t, err)
+		assert.Equal(t, deliveries, result)
+		assert.Equal(t, 1, total)
+	})
+
+	t.Run("someone else's webhook", func(t *testing.T) {
+		mockRepo := new(MockWebhookRepository)
+		service := NewWebhookService(mockRepo)
+
+		mockRepo.On("GetWebhookByID", webhookID, userID).Return(nil, repository.ErrWebhookNotFound)
+
+		result, _, err := service.GetDeliveries(webhookID, userID, 20, 0)
+
+		assert.ErrorIs(t, err, ErrWebhookNotFound)
+		assert.Nil(t, result)
+		mockRepo.AssertNotCalled(t, "GetDeliveries", mock.Anything, mock.Anything, mock.Anything)
+	})
+}